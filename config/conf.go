@@ -40,12 +40,20 @@ type Config struct {
 	GoogleAndroidClientID     string `mapstructure:"GOOGLE_ANDROID_CLIENT_ID"`
 	GoogleIOSClientID         string `mapstructure:"GOOGLE_IOS_CLIENT_ID"`
 	FacebookAppID             string `mapstructure:"FACEBOOK_APP_ID"`
+	AppleClientIDs            string `mapstructure:"APPLE_CLIENT_IDS"` // comma separated e.g the bundle id of the ios app and the services id of the web app
 	SocialsPassword           string `mapstructure:"SOCIALS_PASSWORD"`
 	EmailVerificationPath     string `mapstructure:"EMAIL_VERIFICATION_PATH"`
 	PasswordResetPath         string `mapstructure:"PASSWORD_RESET_PATH"`
 	StripePublicKey           string `mapstructure:"STRIPE_PUBLIC_KEY"`
 	StripeSecretKey           string `mapstructure:"STRIPE_SECRET_KEY"`
 	StripeWebhookSecret       string `mapstructure:"STRIPE_WEBHOOK_SECRET"`
+	PaypalClientID            string `mapstructure:"PAYPAL_CLIENT_ID"`
+	PaypalClientSecret        string `mapstructure:"PAYPAL_CLIENT_SECRET"`
+	PaypalWebhookID           string `mapstructure:"PAYPAL_WEBHOOK_ID"`
+	PaypalApiUrl              string `mapstructure:"PAYPAL_API_URL"`
+	GooglePlayPackageName     string `mapstructure:"GOOGLE_PLAY_PACKAGE_NAME"`
+	GooglePlayCredentialsFile string `mapstructure:"GOOGLE_PLAY_CREDENTIALS_FILE"`
+	GooglePlayPushAudience    string `mapstructure:"GOOGLE_PLAY_PUSH_AUDIENCE"`
 	SocketSecret              string `mapstructure:"SOCKET_SECRET"`
 	S3AccessKey               string `mapstructure:"S3_ACCESS_KEY"`
 	S3SecretKey               string `mapstructure:"S3_SECRET_KEY"`
@@ -65,6 +73,15 @@ type Config struct {
 	RedisUrl                  string `mapstructure:"REDIS_URL"`
 	ReminderCronHours         uint   `mapstructure:"REMINDER_CRON_HOURS"`
 	AppScheme                 string `mapstructure:"APP_SCHEME"`
+
+	ICPUSDRate                float64 `mapstructure:"ICP_USD_RATE"` // fetched from ICP_RATE_URL when not set
+	ICPRateUrl                string  `mapstructure:"ICP_RATE_URL"`
+	CoinUSDValue              float64 `mapstructure:"COIN_USD_VALUE"`
+	LanternUSDValue           float64 `mapstructure:"LANTERN_USD_VALUE"`
+	AuthorRevenueSharePercent float64 `mapstructure:"AUTHOR_REVENUE_SHARE_PERCENT"`
+	SubscriptionGraceDays     int     `mapstructure:"SUBSCRIPTION_GRACE_DAYS"`
+	ApiUrl                    string  `mapstructure:"API_URL"`               // where this api is served, for links in emails
+	AccountDeletionDays       int     `mapstructure:"ACCOUNT_DELETION_DAYS"` // how long users have to change their mind about deleting their account
 }
 
 func GetConfig() (config Config) {
@@ -78,6 +95,13 @@ func GetConfig() (config Config) {
 	viper.SetConfigType("env")
 
 	viper.AutomaticEnv()
+	viper.SetDefault("COIN_USD_VALUE", 0.01)
+	viper.SetDefault("LANTERN_USD_VALUE", 0.01)
+	viper.SetDefault("AUTHOR_REVENUE_SHARE_PERCENT", 50)
+	viper.SetDefault("SUBSCRIPTION_GRACE_DAYS", 3)
+	viper.SetDefault("ACCOUNT_DELETION_DAYS", 14)
+	viper.SetDefault("PAYPAL_API_URL", "https://api-m.sandbox.paypal.com")
+	viper.SetDefault("ICP_RATE_URL", "https://api.coingecko.com/api/v3/simple/price?ids=internet-computer&vs_currencies=usd")
 	var err error
 	if err = viper.ReadInConfig(); err != nil {
 		panic(err)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -19,8 +20,17 @@ func Models() []interface{} {
 		&models.Subscriber{},
 
 		// accounts
+		&models.Permission{},
+		&models.Role{},
 		&models.User{},
 		&models.AuthToken{},
+		&models.RecoveryCode{},
+		&models.SocialAccount{},
+		&models.DataExport{},
+		&models.AuthThrottle{},
+		&models.AuthorApplication{},
+		&models.UserBlock{},
+		&models.EmailChange{},
 
 		// book
 		&models.Tag{},
@@ -33,17 +43,32 @@ func Models() []interface{} {
 		&models.BookReport{},
 		&models.Bookmark{},
 		&models.Chapter{},
+		&models.Paragraph{},
+		&models.BoughtChapter{},
+		&models.BoughtBook{},
 		&models.Gift{},
 		&models.SentGift{},
 		&models.Comment{},
 		&models.Like{},
 		&models.Vote{},
 		&models.FeaturedContent{},
+		&models.BookSimilarity{},
+		&models.BookRecommendation{},
+		&models.ReadingProgress{},
+		&models.ChapterRevision{},
 
 		// wallet
 		&models.Coin{},
 		&models.SubscriptionPlan{},
+		&models.PromoCode{},
 		&models.Transaction{},
+		&models.Payout{},
+		&models.AuthorEarning{},
+		&models.WalletEntry{},
+		&models.PaymentEvent{},
+		&models.Subscription{},
+		&models.ICPWallet{},
+		&models.ICPTransfer{},
 
 		// waitlist
 		&models.Waitlist{},
@@ -61,6 +86,15 @@ func MakeMigrations(db *gorm.DB) {
 	for _, model := range modelsList {
 		db.AutoMigrate(model)
 	}
+	// Fill the search vectors of books written before search existed
+	models.UpdateBookSearchVectors(db, "search_vector IS NULL")
+	// Chapters written before scheduling existed came out when they were written
+	db.Model(&models.Chapter{}).Where("status = ? AND publish_at IS NULL", choices.CS_PUBLISHED).UpdateColumn("publish_at", gorm.Expr("created_at"))
+	// Books bought in full before they were recorded as such, from the debits for them
+	db.Exec(`INSERT INTO bought_books (id, created_at, updated_at, buyer_id, book_id, price)
+		SELECT gen_random_uuid(), MIN(created_at), MIN(created_at), user_id, book_id, -SUM(amount) FROM wallet_entries
+		WHERE reason = ? AND user_id IS NOT NULL AND book_id IS NOT NULL AND amount < 0 GROUP BY user_id, book_id
+		ON CONFLICT DO NOTHING`, choices.WR_BOOK_PURCHASE)
 }
 
 func CreateTables(db *gorm.DB) {
@@ -115,6 +149,13 @@ func ConnectDb(cfg config.Config, loggedOpts ...bool) *gorm.DB {
 		os.Exit(2)
 	}
 
+	// Add Trigram extension for typo tolerant search
+	result = db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm;")
+	if result.Error != nil {
+		log.Fatal("failed to create extension: " + result.Error.Error())
+		os.Exit(2)
+	}
+
 	// Add Migrations
 	if os.Getenv("ENVIRONMENT") != "test" {
 		log.Println("Running Migrations")
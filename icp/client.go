@@ -0,0 +1,248 @@
+package icp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shopspring/decimal"
+)
+
+var httpClient = http.Client{Timeout: 30 * time.Second}
+
+// Talks to the LitPad wallet server, which holds the ICP wallets of users and makes transfers from them
+type Client struct {
+	ApiUrl  string
+	Secret  string
+	RateUrl string
+	UsdRate decimal.Decimal // a fixed price of one ICP in USD. The rate is fetched from RateUrl when it is zero.
+}
+
+func NewClient(cfg config.Config) Client {
+	return Client{
+		ApiUrl: cfg.ICPWalletIp, Secret: cfg.WalletSecret,
+		RateUrl: cfg.ICPRateUrl, UsdRate: decimal.NewFromFloat(cfg.ICPUSDRate),
+	}
+}
+
+// A client set here is used instead of the configured one (see Use)
+var override *Client
+
+// Returns the client for the configured wallet server
+func Get() Client {
+	if override != nil {
+		return *override
+	}
+	return NewClient(config.GetConfig())
+}
+
+// Makes Get return a client, e.g one for a local stand-in of the wallet server in tests
+func Use(client Client) func() {
+	override = &client
+	return func() { override = nil }
+}
+
+// An error response from the wallet server
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wallet server responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Reports whether the wallet server refused a request, so that retrying it won't help
+func IsRejected(err error) bool {
+	var serverErr *Error
+	return errors.As(err, &serverErr) && serverErr.StatusCode >= 400 && serverErr.StatusCode < 500
+}
+
+func IsNotFound(err error) bool {
+	var serverErr *Error
+	return errors.As(err, &serverErr) && serverErr.StatusCode == http.StatusNotFound
+}
+
+type Wallet struct {
+	PublicKey string          `json:"public_key"`
+	AccountID string          `json:"account_id"`
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+type TransferParams struct {
+	Reference string // our ID for the transfer, used to look it up later
+	Username  string // owner of the wallet the transfer is made from
+	Address   string // account ID of the receiving wallet
+	Amount    decimal.Decimal
+}
+
+type Transfer struct {
+	ID        string          `json:"_id"`
+	Reference string          `json:"reference"`
+	Amount    decimal.Decimal `json:"amount"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Time      string          `json:"time"`
+	Status    string          `json:"status"`
+}
+
+// Returns the state of a transfer. Only a status the ledger reports as final confirms or fails it,
+// anything else (e.g a missing or unknown status) leaves it pending so that it is checked again.
+func (t Transfer) State() choices.ICPTransferStatusChoice {
+	switch t.Status {
+	case "confirmed", "completed", "success":
+		return choices.ITS_CONFIRMED
+	case "failed", "rejected":
+		return choices.ITS_FAILED
+	}
+	return choices.ITS_PENDING
+}
+
+func (c Client) token() (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{"iat": now.Unix(), "exp": now.Add(80 * time.Second).Unix()}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(c.Secret))
+}
+
+func (c Client) request(method string, path string, body interface{}, result interface{}) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.ApiUrl+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Access", "Litpad "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		data, _ := io.ReadAll(res.Body)
+		return &Error{StatusCode: res.StatusCode, Message: string(data)}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (c Client) CreateWallet(username string) (*Wallet, error) {
+	wallet := Wallet{}
+	if err := c.request(http.MethodPost, "/wallet", map[string]string{"username": username}, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (c Client) GetWallet(username string) (*Wallet, error) {
+	wallet := Wallet{}
+	if err := c.request(http.MethodGet, "/wallet/"+url.PathEscape(username), nil, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (c Client) GetBalance(username string) (decimal.Decimal, error) {
+	wallet := Wallet{}
+	if err := c.request(http.MethodGet, "/wallet/"+url.PathEscape(username)+"/balance", nil, &wallet); err != nil {
+		return decimal.Zero, err
+	}
+	return wallet.Balance, nil
+}
+
+func (c Client) Transfer(params TransferParams) (*Transfer, error) {
+	body := map[string]string{
+		"reference": params.Reference, "username": params.Username,
+		"address": params.Address, "amount": params.Amount.StringFixed(8),
+	}
+	transfer := Transfer{}
+	if err := c.request(http.MethodPost, "/wallet/transfer", body, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// Looks up a transfer by the reference it was made with
+func (c Client) GetTransfer(reference string) (*Transfer, error) {
+	transfer := Transfer{}
+	if err := c.request(http.MethodGet, "/wallet/transfer/"+url.PathEscape(reference), nil, &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// Fetched rates are reused for a while to keep clear of the rate source's limits
+const rateCacheDuration = 10 * time.Minute
+
+var rateCache = struct {
+	sync.Mutex
+	rate      decimal.Decimal
+	fetchedAt time.Time
+}{}
+
+// Returns the price of one ICP in USD
+func (c Client) GetUsdRate() (decimal.Decimal, error) {
+	if c.UsdRate.IsPositive() {
+		return c.UsdRate, nil
+	}
+	rateCache.Lock()
+	defer rateCache.Unlock()
+	if rateCache.rate.IsPositive() && time.Since(rateCache.fetchedAt) < rateCacheDuration {
+		return rateCache.rate, nil
+	}
+
+	res, err := httpClient.Get(c.RateUrl)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("rate request failed with status %d", res.StatusCode)
+	}
+	// e.g {"internet-computer": {"usd": 9.2}}
+	prices := map[string]map[string]decimal.Decimal{}
+	if err := json.NewDecoder(res.Body).Decode(&prices); err != nil {
+		return decimal.Zero, err
+	}
+	rate := prices["internet-computer"]["usd"]
+	if !rate.IsPositive() {
+		return decimal.Zero, errors.New("rate response has no ICP price")
+	}
+	rateCache.rate = rate
+	rateCache.fetchedAt = time.Now()
+	return rate, nil
+}
+
+// Converts an amount in USD to ICP at the current rate
+func (c Client) ToICP(usd decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	rate, err := c.GetUsdRate()
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	// ICP has 8 decimal places
+	return usd.Div(rate).Round(8), rate, nil
+}
@@ -0,0 +1,82 @@
+package identity
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	appleIssuer  = "https://appleid.apple.com"
+	appleJwksUrl = "https://appleid.apple.com/auth/keys"
+)
+
+type AppleProvider struct {
+	ClientIDs []string // the bundle id of the ios app and the services id of the web app
+	JwksUrl   string
+}
+
+func NewAppleProvider(cfg config.Config) AppleProvider {
+	clientIDs := []string{}
+	for _, clientID := range strings.Split(cfg.AppleClientIDs, ",") {
+		if clientID = strings.TrimSpace(clientID); clientID != "" {
+			clientIDs = append(clientIDs, clientID)
+		}
+	}
+	return AppleProvider{ClientIDs: clientIDs, JwksUrl: appleJwksUrl}
+}
+
+type appleClaims struct {
+	Email string `json:"email"`
+	// Apple has sent this as both a string and a boolean
+	EmailVerified interface{} `json:"email_verified"`
+	jwt.RegisteredClaims
+}
+
+// Verifies an ID token from Sign in with Apple. Apple doesn't put the user's name in it,
+// it only gives it to the client on the first sign in.
+func (a AppleProvider) Verify(token string, deviceType choices.DeviceType) (*Identity, error) {
+	claims := appleClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keySetFor(a.JwksUrl).key(kid)
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(appleIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		// Apple's keys couldn't be fetched, which says nothing about the token
+		var jwksErr *JwksError
+		if errors.As(err, &jwksErr) {
+			return nil, jwksErr
+		}
+		return nil, tokenErr("Invalid Token")
+	}
+	if !a.hasAudience(claims.Audience) {
+		return nil, tokenErr("Invalid Audience")
+	}
+
+	verified := false
+	switch value := claims.EmailVerified.(type) {
+	case bool:
+		verified = value
+	case string:
+		verified = value == "true"
+	}
+	identity := Identity{
+		Provider: choices.SP_APPLE, Subject: claims.Subject,
+		Email: claims.Email, EmailVerified: verified,
+	}
+	return &identity, nil
+}
+
+func (a AppleProvider) hasAudience(audience jwt.ClaimStrings) bool {
+	for _, aud := range audience {
+		for _, clientID := range a.ClientIDs {
+			if aud == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
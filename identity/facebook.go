@@ -0,0 +1,45 @@
+package identity
+
+import (
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	fb "github.com/huandu/facebook/v2"
+)
+
+type FacebookProvider struct {
+	AppID string
+}
+
+func NewFacebookProvider(cfg config.Config) FacebookProvider {
+	return FacebookProvider{AppID: cfg.FacebookAppID}
+}
+
+// Facebook gives clients access tokens, not ID tokens, so the token is checked by using it
+func (f FacebookProvider) Verify(token string, deviceType choices.DeviceType) (*Identity, error) {
+	res, err := fb.Get("/app", fb.Params{"access_token": token})
+	if err != nil {
+		return nil, tokenErr("Token is invalid or expired")
+	}
+	if res["id"] != f.AppID {
+		return nil, tokenErr("Invalid Facebook App ID")
+	}
+	res, err = fb.Get("/me", fb.Params{
+		"fields":       "id,name,email,picture.type(large)",
+		"access_token": token,
+	})
+	if err != nil {
+		return nil, tokenErr("Token is invalid or expired")
+	}
+
+	identity := Identity{Provider: choices.SP_FACEBOOK}
+	res.DecodeField("id", &identity.Subject)
+	res.DecodeField("name", &identity.Name)
+	res.DecodeField("email", &identity.Email)
+	// Facebook only shares emails that have been confirmed
+	identity.EmailVerified = identity.Email != ""
+	var picture string
+	if res.DecodeField("picture.data.url", &picture) == nil && picture != "" {
+		identity.Avatar = &picture
+	}
+	return &identity, nil
+}
@@ -0,0 +1,52 @@
+package identity
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/api/idtoken"
+)
+
+type GoogleProvider struct {
+	AndroidClientID string
+	IOSClientID     string
+}
+
+func NewGoogleProvider(cfg config.Config) GoogleProvider {
+	return GoogleProvider{AndroidClientID: cfg.GoogleAndroidClientID, IOSClientID: cfg.GoogleIOSClientID}
+}
+
+type googleClaims struct {
+	Name          string `mapstructure:"name"`
+	Picture       string `mapstructure:"picture"`
+	Email         string `mapstructure:"email"`
+	EmailVerified bool   `mapstructure:"email_verified"`
+}
+
+func (g GoogleProvider) Verify(token string, deviceType choices.DeviceType) (*Identity, error) {
+	clientID := g.AndroidClientID
+	if deviceType == choices.DT_IOS {
+		clientID = g.IOSClientID
+	}
+	payload, err := idtoken.Validate(context.Background(), token, clientID)
+	if err != nil {
+		if strings.Contains(err.Error(), "audience provided") {
+			return nil, tokenErr("Invalid Audience")
+		}
+		return nil, tokenErr("Invalid Token")
+	}
+
+	claims := googleClaims{}
+	mapstructure.Decode(payload.Claims, &claims)
+	identity := Identity{
+		Provider: choices.SP_GOOGLE, Subject: payload.Subject,
+		Email: claims.Email, EmailVerified: claims.EmailVerified, Name: claims.Name,
+	}
+	if claims.Picture != "" {
+		identity.Avatar = &claims.Picture
+	}
+	return &identity, nil
+}
@@ -0,0 +1,133 @@
+package identity
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	// How long fetched keys are used before they are fetched again
+	jwksMaxAge = 24 * time.Hour
+	// Keys are fetched again for a token signed with an unknown key, but not more often than this
+	jwksMinRefetchInterval = time.Minute
+)
+
+// The keys couldn't be fetched from the provider
+type JwksError struct {
+	Url string
+	Err error
+}
+
+func (e *JwksError) Error() string {
+	return fmt.Sprintf("fetching keys from %s: %s", e.Url, e.Err)
+}
+
+func (e *JwksError) Unwrap() error {
+	return e.Err
+}
+
+// The public keys a provider signs tokens with, cached between requests
+type keySet struct {
+	url       string
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	keySetsMu sync.Mutex
+	keySets   = map[string]*keySet{}
+)
+
+func keySetFor(url string) *keySet {
+	keySetsMu.Lock()
+	defer keySetsMu.Unlock()
+	set, ok := keySets[url]
+	if !ok {
+		set = &keySet{url: url}
+		keySets[url] = set
+	}
+	return set
+}
+
+// Returns the key with an id. Keys are fetched when the cached ones are old, or when
+// the key isn't among them since providers rotate their keys.
+func (s *keySet) key(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	key, ok := s.keys[kid]
+	if ok && age < jwksMaxAge {
+		return key, nil
+	}
+	if !ok && s.keys != nil && age < jwksMinRefetchInterval {
+		return nil, fmt.Errorf("unknown key %s", kid)
+	}
+	keys, err := fetchKeys(s.url)
+	if err != nil {
+		if ok {
+			// The old key is better than failing every sign in while the provider is down
+			return key, nil
+		}
+		return nil, &JwksError{Url: s.url, Err: err}
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	if key, ok = s.keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown key %s", kid)
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func fetchKeys(url string) (map[string]*rsa.PublicKey, error) {
+	res, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", res.StatusCode)
+	}
+	body := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range body.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// Returns a key in the form fetchKeys reads, e.g for serving locally generated keys in tests
+func RSAJwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kid": kid, "kty": "RSA", "alg": "RS256", "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+)
+
+var httpClient = http.Client{Timeout: 15 * time.Second}
+
+// A user's account with an identity provider, from a token the provider gave the client
+type Identity struct {
+	Provider      choices.SocialProviderChoice
+	Subject       string // the provider's id of the account, which unlike the email never changes
+	Email         string
+	EmailVerified bool
+	Name          string
+	Avatar        *string
+}
+
+type IdentityProvider interface {
+	// Checks that a token was given to one of the app's clients and returns whose it is
+	Verify(token string, deviceType choices.DeviceType) (*Identity, error)
+}
+
+// A token that isn't valid for the app. Other errors mean the provider couldn't be reached.
+type TokenError struct {
+	Message string
+}
+
+func (e *TokenError) Error() string {
+	return e.Message
+}
+
+func tokenErr(message string) error {
+	return &TokenError{Message: message}
+}
+
+// Providers set here are used instead of the real ones (see Use)
+var overrides = map[choices.SocialProviderChoice]IdentityProvider{}
+
+func Get(provider choices.SocialProviderChoice) (IdentityProvider, error) {
+	if identityProvider, ok := overrides[provider]; ok {
+		return identityProvider, nil
+	}
+	cfg := config.GetConfig()
+	switch provider {
+	case choices.SP_GOOGLE:
+		return NewGoogleProvider(cfg), nil
+	case choices.SP_FACEBOOK:
+		return NewFacebookProvider(cfg), nil
+	case choices.SP_APPLE:
+		return NewAppleProvider(cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported identity provider %s", provider)
+}
+
+// Makes Get return a provider, e.g one that trusts locally generated keys in tests
+func Use(provider choices.SocialProviderChoice, identityProvider IdentityProvider) func() {
+	overrides[provider] = identityProvider
+	return func() { delete(overrides, provider) }
+}
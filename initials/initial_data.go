@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/google/uuid"
@@ -13,6 +14,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// Creates the permissions and roles, and brings the permissions of existing roles in line with ROLES
+func CreateRoles(db *gorm.DB) {
+	permissions := map[choices.PermissionChoice]models.Permission{}
+	allPermissions := []models.Permission{}
+	for codename, name := range PERMISSIONS {
+		permission := models.Permission{Codename: codename}
+		db.Where(permission).Assign(models.Permission{Name: name}).FirstOrCreate(&permission)
+		permissions[codename] = permission
+		allPermissions = append(allPermissions, permission)
+	}
+
+	for _, item := range ROLES {
+		role := models.Role{Codename: item.Codename}
+		db.Where(role).Assign(models.Role{Name: item.Name, IsStaff: item.Codename.IsStaff()}).FirstOrCreate(&role)
+		rolePermissions := []models.Permission{}
+		if item.Codename == choices.ROLE_SUPERADMIN {
+			rolePermissions = allPermissions
+		} else {
+			for _, codename := range item.Permissions {
+				rolePermissions = append(rolePermissions, permissions[codename])
+			}
+		}
+		db.Model(&role).Association("Permissions").Replace(rolePermissions)
+	}
+}
+
 func createSuperUser(db *gorm.DB, cfg config.Config) models.User {
 	name := "Dark Xenia"
 	user := models.User{
@@ -26,6 +53,9 @@ func createSuperUser(db *gorm.DB, cfg config.Config) models.User {
 		AccountType: choices.ACCTYPE_AUTHOR,
 	}
 	db.FirstOrCreate(&user, models.User{Email: user.Email})
+	if user.RoleID == nil {
+		managers.RoleManager{}.Assign(db, &user, managers.RoleManager{}.GetByCodename(db, choices.ROLE_SUPERADMIN))
+	}
 	return user
 }
 
@@ -42,7 +72,9 @@ func createAuthor(db *gorm.DB, cfg config.Config) models.User {
 		IsStaff:         true,
 	}
 	db.FirstOrCreate(&user, models.User{Email: user.Email})
-
+	if user.RoleID == nil {
+		managers.RoleManager{}.Assign(db, &user, managers.RoleManager{}.GetByCodename(db, choices.ROLE_SUPERADMIN))
+	}
 	return user
 }
 
@@ -227,6 +259,9 @@ func createReview(db *gorm.DB, book models.Book, user models.User) models.Commen
 
 func CreateInitialData(db *gorm.DB, cfg config.Config) {
 	log.Println("Creating Initial Data....")
+	CreateRoles(db)
+	// Give staff made before roles existed a role
+	managers.RoleManager{}.FillStaffRoles(db)
 	createSuperUser(db, cfg)
 	genres := createGenres(db)
 	if cfg.Environment != "production" {
@@ -245,5 +280,13 @@ func CreateInitialData(db *gorm.DB, cfg config.Config) {
 		createReply(db, comment, author)
 		createReview(db, book, author)
 	}
+	// Backfill the wallet ledger for balances that predate it
+	managers.WalletManager{}.CreateOpeningBalances(db)
+	// Backfill subscriptions paid for before they renewed automatically
+	managers.SubscriptionManager{}.CreateFromUsers(db)
+	// Backfill the amounts of transactions recorded before they were stored
+	managers.TransactionManager{}.FillAmounts(db)
+	// Make the tokens issued before sessions were recorded sessions of their own
+	managers.UserManager{}.FillTokenFamilies(db)
 	log.Println("Initial Data Created....")
 }
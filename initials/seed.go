@@ -30,6 +30,49 @@ var (
 		"Series You Can't Put Down", "Binge-Worthy Romances", "Complete Sagas", "Fantasy Epics", "Ultimate Page-Turners",
 	}
 
+	PERMISSIONS = map[choices.PermissionChoice]string{
+		choices.PERM_VIEW_DASHBOARD:   "View the admin dashboard",
+		choices.PERM_VIEW_USERS:       "View users",
+		choices.PERM_MANAGE_USERS:     "Change account types and deactivate users",
+		choices.PERM_MANAGE_ADMINS:    "Invite admins and give them roles",
+		choices.PERM_WRITE_BOOKS:      "Write books",
+		choices.PERM_MANAGE_BOOKS:     "Edit and delete any book",
+		choices.PERM_VIEW_BOOKS:       "View books, their stats and contracts",
+		choices.PERM_MANAGE_CATALOG:   "Manage genres, tags, sections and featured contents",
+		choices.PERM_VIEW_PAYMENTS:    "View transactions, earnings, payouts and reports",
+		choices.PERM_MANAGE_PAYMENTS:  "Refund transactions, settle payouts and manage plans and promo codes",
+		choices.PERM_VIEW_SUBSCRIBERS: "View subscribers",
+		choices.PERM_VIEW_WAITLIST:    "View the waitlist",
+	}
+
+	// Superadmins get every permission
+	ROLES = []struct {
+		Codename    choices.RoleChoice
+		Name        string
+		Permissions []choices.PermissionChoice
+	}{
+		{Codename: choices.ROLE_SUPERADMIN, Name: "Superadmin"},
+		{Codename: choices.ROLE_CONTENT_MODERATOR, Name: "Content Moderator", Permissions: []choices.PermissionChoice{
+			choices.PERM_VIEW_DASHBOARD, choices.PERM_VIEW_USERS, choices.PERM_WRITE_BOOKS, choices.PERM_MANAGE_BOOKS,
+			choices.PERM_VIEW_BOOKS, choices.PERM_MANAGE_CATALOG, choices.PERM_VIEW_WAITLIST,
+		}},
+		{Codename: choices.ROLE_FINANCE, Name: "Finance", Permissions: []choices.PermissionChoice{
+			choices.PERM_VIEW_DASHBOARD, choices.PERM_VIEW_USERS, choices.PERM_VIEW_PAYMENTS,
+			choices.PERM_MANAGE_PAYMENTS, choices.PERM_VIEW_SUBSCRIBERS,
+		}},
+		{Codename: choices.ROLE_SUPPORT, Name: "Support", Permissions: []choices.PermissionChoice{
+			choices.PERM_VIEW_DASHBOARD, choices.PERM_VIEW_USERS, choices.PERM_MANAGE_USERS, choices.PERM_VIEW_BOOKS,
+			choices.PERM_VIEW_PAYMENTS, choices.PERM_VIEW_SUBSCRIBERS, choices.PERM_VIEW_WAITLIST,
+		}},
+		{Codename: choices.ROLE_STAFF, Name: "Staff", Permissions: []choices.PermissionChoice{
+			choices.PERM_VIEW_DASHBOARD, choices.PERM_VIEW_USERS, choices.PERM_MANAGE_USERS, choices.PERM_WRITE_BOOKS,
+			choices.PERM_MANAGE_BOOKS, choices.PERM_VIEW_BOOKS, choices.PERM_MANAGE_CATALOG, choices.PERM_VIEW_PAYMENTS,
+			choices.PERM_MANAGE_PAYMENTS, choices.PERM_VIEW_SUBSCRIBERS, choices.PERM_VIEW_WAITLIST,
+		}},
+		{Codename: choices.ROLE_AUTHOR, Name: "Author", Permissions: []choices.PermissionChoice{choices.PERM_WRITE_BOOKS}},
+		{Codename: choices.ROLE_READER, Name: "Reader"},
+	}
+
 	GIFTNAMES []string = []string{"Red rose", "Black dahlia", "Scroll", "Magic wand", "Wolf", "Baby Dragon"}
 
 	BOOK = models.Book{
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/senders"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+const TypeExportUserData = "export_user_data"
+
+type DataExportTaskPayload struct {
+	ExportID uuid.UUID
+}
+
+// Builds the archive of an export and emails its download link to the user
+func ExportUserData(db *gorm.DB, exportID uuid.UUID) error {
+	dataExportManager := managers.DataExportManager{}
+	export := dataExportManager.GetByID(db, exportID)
+	if export == nil {
+		return fmt.Errorf("no data export with id %s", exportID)
+	}
+	archive, err := dataExportManager.BuildArchive(db, export.User)
+	if err != nil {
+		dataExportManager.MarkFailed(db, export)
+		return err
+	}
+	token := dataExportManager.MarkReady(db, export, archive)
+
+	cfg := config.GetConfig()
+	url := fmt.Sprintf("%s/api/v1/exports/%s", cfg.ApiUrl, token)
+	senders.SendEmail(&export.User, senders.ET_DATA_EXPORT_READY, nil, nil, map[string]interface{}{"url": url})
+	return nil
+}
+
+func DataExportTaskHandler(db *gorm.DB) asynq.HandlerFunc {
+	return func(ctx context.Context, task *asynq.Task) error {
+		var payload DataExportTaskPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			log.Printf("Error unmarshaling task payload: %v\n", err)
+			return err
+		}
+		if err := ExportUserData(db, payload.ExportID); err != nil {
+			log.Printf("Error exporting user data: %v\n", err)
+			return err
+		}
+		return nil
+	}
+}
+
+// Queues the building of an export. Without a queue (e.g in tests) it is built right away.
+func QueueDataExport(db *gorm.DB, exportID uuid.UUID) {
+	if client == nil {
+		ExportUserData(db, exportID)
+		return
+	}
+	data, _ := json.Marshal(DataExportTaskPayload{ExportID: exportID})
+	task := asynq.NewTask(TypeExportUserData, data)
+	if _, err := client.Enqueue(task, asynq.Queue("low"), asynq.MaxRetry(3)); err != nil {
+		log.Printf("Failed to enqueue data export task: %v\n", err)
+	}
+}
+
+// Stops a subscription from renewing with its provider, so that an account that is deleted isn't charged again
+func cancelRenewal(db *gorm.DB, subscription *models.Subscription) error {
+	provider, err := payments.Get(subscription.Provider)
+	if err != nil {
+		return err
+	}
+	subProvider, ok := provider.(payments.SubscriptionProvider)
+	if !ok {
+		return fmt.Errorf("%s subscriptions can't be canceled", subscription.Provider)
+	}
+	if err := subProvider.CancelSubscription(*subscription.Reference); err != nil {
+		return err
+	}
+	return managers.SubscriptionManager{}.Cancel(db, subscription)
+}
+
+// Deletes the accounts whose cool-off period has ended and removes expired exports
+func AccountDeletionJob(db *gorm.DB) {
+	userManager := managers.UserManager{}
+	subscriptionManager := managers.SubscriptionManager{}
+	for _, user := range userManager.GetDueForDeletion(db) {
+		// Deletion is only requested without a renewing subscription, but one can be started during the cool-off period
+		if sub := subscriptionManager.GetByUser(db, user); sub != nil && sub.Renews() {
+			if err := cancelRenewal(db, sub); err != nil {
+				log.Printf("Failed to cancel the subscription of account %s: %v\n", user.ID, err)
+				continue
+			}
+		}
+		// A copy keeps the address the email goes to, which the deletion removes
+		deletedUser := user
+		if err := userManager.DeleteAccount(db, &user); err != nil {
+			log.Printf("Failed to delete account %s: %v\n", user.ID, err)
+			continue
+		}
+		senders.SendEmail(&deletedUser, senders.ET_ACCOUNT_DELETED, nil, nil, nil)
+	}
+	managers.DataExportManager{}.DeleteExpired(db)
+}
+
+func RunAccountDeletionCron(db *gorm.DB) {
+	c := cron.New()
+
+	c.AddFunc("@hourly", func() {
+		go AccountDeletionJob(db)
+	})
+	c.Start()
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/LitPad/backend/managers"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Publishes the chapters authors scheduled, which notifies their followers and those who bookmarked their books
+func ChapterPublishingJob(db *gorm.DB) {
+	published := managers.ChapterManager{}.PublishDue(db)
+	if len(published) > 0 {
+		log.Printf("%d scheduled chapters published\n", len(published))
+	}
+}
+
+func RunChapterPublishingCron(db *gorm.DB) {
+	c := cron.New()
+
+	// Chapters come out within a minute of their time
+	c.AddFunc("@every 1m", func() {
+		go ChapterPublishingJob(db)
+	})
+	c.Start()
+}
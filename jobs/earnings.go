@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/managers"
+	"github.com/robfig/cron/v3"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Shares last month's subscription revenue among authors.
+// Running it more than once for the same month doesn't credit authors twice.
+func SubscriptionShareJob(db *gorm.DB, cfg config.Config) {
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -1, 0)
+	sharePercent := decimal.NewFromFloat(cfg.AuthorRevenueSharePercent)
+
+	earnings := managers.AuthorEarningManager{}.DistributeSubscriptionRevenue(db, start, end, sharePercent)
+	log.Printf("Subscription revenue share for %s computed for %d authors\n", start.Format("2006-01"), len(earnings))
+}
+
+func RunEarningsCron(cfg config.Config, db *gorm.DB) {
+	c := cron.New()
+
+	// Run at midnight on the first day of every month
+	c.AddFunc("@monthly", func() {
+		go SubscriptionShareJob(db, cfg)
+	})
+	c.Start()
+}
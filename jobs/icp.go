@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/LitPad/backend/icp"
+	"github.com/LitPad/backend/managers"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Sends the gifts of ICP transfers that were confirmed after their requests ended
+func ICPReconciliationJob(db *gorm.DB) {
+	confirmed, failed := managers.ICPTransferManager{}.Reconcile(db, icp.Get())
+	if confirmed > 0 || failed > 0 {
+		log.Printf("ICP transfers reconciled: %d confirmed, %d failed\n", confirmed, failed)
+	}
+}
+
+func RunICPReconciliationCron(db *gorm.DB) {
+	c := cron.New()
+
+	// Pending transfers are checked every five minutes
+	c.AddFunc("@every 5m", func() {
+		go ICPReconciliationJob(db)
+	})
+	c.Start()
+}
@@ -11,15 +11,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// The queue tasks are sent to, set once the jobs run
+var client *asynq.Client
+
 func RunJobs(cfg config.Config, db *gorm.DB) {
 	// RunJobs runs the jobs
 	// Initialize the Asynq client and GORM DB (replace with your actual setup)
     redisClient := asynq.NewClient(asynq.RedisClientOpt{Addr: cfg.RedisUrl})
+	client = redisClient
 
 	SetupWorker(db, cfg.RedisUrl)
 
 	// Initial run
 	go ReminderJob(db, redisClient)
+	go SubscriptionShareJob(db, cfg)
+	go RecommendationJob(db)
+	RunEarningsCron(cfg, db)
+	RunICPReconciliationCron(db)
+	RunAccountDeletionCron(db)
+	RunRecommendationsCron(db)
+	RunChapterPublishingCron(db)
 
 	// RunWithCron(cfg, db, redisClient)
 	RunWithTicker(cfg, db, redisClient)
@@ -43,6 +54,7 @@ func SetupWorker(db *gorm.DB, redisUrl string) {
 	mux := asynq.NewServeMux()
 	taskHandler := EmailTaskHandler(db)
 	mux.HandleFunc(TypeSendEmail, taskHandler)
+	mux.HandleFunc(TypeExportUserData, DataExportTaskHandler(db))
 
 	// Start the Asynq worker in a separate goroutine to process tasks
 	go func() {
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"log"
+
+	"github.com/LitPad/backend/managers"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Recomputes how alike books are by their readers, then every reader's recommendations from it
+func RecommendationJob(db *gorm.DB) {
+	recommendationManager := managers.BookRecommendationManager{}
+	if err := recommendationManager.ComputeSimilarities(db); err != nil {
+		log.Printf("Failed to compute book similarities: %v\n", err)
+		return
+	}
+	count := recommendationManager.ComputeAll(db)
+	log.Printf("Book recommendations computed for %d readers\n", count)
+}
+
+func RunRecommendationsCron(db *gorm.DB) {
+	c := cron.New()
+
+	c.AddFunc("@every 6h", func() {
+		go RecommendationJob(db)
+	})
+	c.Start()
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/senders"
 	"github.com/google/uuid"
@@ -67,46 +68,36 @@ func QueueEmailTask(redisClient *asynq.Client, user *models.User, emailType send
 }
 
 func ReminderJob(db *gorm.DB, redisClient *asynq.Client) {
-	currentTime := time.Now()
-	oneWeekLater := currentTime.Add(7 * 24 * time.Hour)
+	subscriptionManager := managers.SubscriptionManager{}
+	oneWeekLater := time.Now().Add(7 * 24 * time.Hour)
 
-	// Find users with subscription expiring soon
-	var expiringUsers []models.User
-	db.Where("subscription_expiry BETWEEN ? AND ? AND reminder_sent = ?", currentTime, oneWeekLater, false).Find(&expiringUsers)
+	// Find subscriptions that won't renew and are expiring soon
+	expiringSubscriptions := subscriptionManager.GetExpiring(db, oneWeekLater)
 
 	// Send reminders for expiring subscriptions via Asynq tasks
-	for _, user := range expiringUsers {
+	for _, subscription := range expiringSubscriptions {
 		// Queue a task for sending subscription-expiring email
-		extraData := map[string]interface{}{"subscriptionType": user.CurrentPlan}
-		QueueEmailTask(redisClient, &user, senders.ET_SUBSCRIPTION_EXPIRING, nil, nil, extraData)
+		extraData := map[string]interface{}{"subscriptionType": subscription.Plan.SubType}
+		QueueEmailTask(redisClient, &subscription.User, senders.ET_SUBSCRIPTION_EXPIRING, nil, nil, extraData)
 	}
 
-	// Bulk update reminder_sent for users with expiring subscriptions
-	if len(expiringUsers) > 0 {
-		var userIds []uuid.UUID
-		for _, user := range expiringUsers {
-			userIds = append(userIds, user.ID)
+	// Bulk update reminder_sent for expiring subscriptions
+	if len(expiringSubscriptions) > 0 {
+		var subscriptionIds []uuid.UUID
+		for _, subscription := range expiringSubscriptions {
+			subscriptionIds = append(subscriptionIds, subscription.ID)
 		}
-		db.Model(&models.User{}).Where("id IN ?", userIds).Update("reminder_sent", true)
+		db.Model(&models.Subscription{}).Where("id IN ?", subscriptionIds).Update("reminder_sent", true)
 	}
 
-	// Find users with expired subscriptions
-	var expiredUsers []models.User
-	db.Where("subscription_expiry < ? AND reminder_sent = ?", currentTime, false).Find(&expiredUsers)
-
-	// Send reminders for expired subscriptions via Asynq tasks
-	for _, user := range expiredUsers {
-		// Queue a task for sending subscription-expired email
-		extraData := map[string]interface{}{"subscriptionType": user.CurrentPlan}
-		QueueEmailTask(redisClient, &user, senders.ET_SUBSCRIPTION_EXPIRED, nil, nil, extraData)
-	}
-
-	// Bulk update reminder_sent for users with expired subscriptions
-	if len(expiredUsers) > 0 {
-		var userIds []uuid.UUID
-		for _, user := range expiredUsers {
-			userIds = append(userIds, user.ID)
+	// Expire subscriptions whose access (including the grace period) has ended
+	for _, subscription := range subscriptionManager.GetLapsed(db) {
+		if err := subscriptionManager.Expire(db, &subscription); err != nil {
+			log.Printf("Failed to expire subscription %s: %v\n", subscription.ID, err)
+			continue
 		}
-		db.Model(&models.User{}).Where("id IN ?", userIds).Update("reminder_sent", true)
+		// Queue a task for sending subscription-expired email
+		extraData := map[string]interface{}{"subscriptionType": subscription.Plan.SubType}
+		QueueEmailTask(redisClient, &subscription.User, senders.ET_SUBSCRIPTION_EXPIRED, nil, nil, extraData)
 	}
-}
\ No newline at end of file
+}
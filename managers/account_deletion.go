@@ -0,0 +1,93 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Sets when a user's account is deleted. Asking again keeps the date that was set first.
+func (u UserManager) ScheduleDeletion(db *gorm.DB, user *models.User, coolOffDays int) time.Time {
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt
+	}
+	deletionAt := time.Now().AddDate(0, 0, coolOffDays)
+	user.DeletionScheduledAt = &deletionAt
+	db.Model(user).Update("deletion_scheduled_at", deletionAt)
+	return deletionAt
+}
+
+func (u UserManager) CancelDeletion(db *gorm.DB, user *models.User) {
+	user.DeletionScheduledAt = nil
+	db.Model(user).Update("deletion_scheduled_at", nil)
+}
+
+// Returns the users whose cool-off period has ended
+func (u UserManager) GetDueForDeletion(db *gorm.DB) []models.User {
+	users := []models.User{}
+	db.Where("deletion_scheduled_at <= ? AND account_deleted_at IS NULL", time.Now()).Find(&users)
+	return users
+}
+
+// Deletes a user's account. The user row itself is kept without any personal data, because transactions,
+// wallet entries, earnings and payouts cascade on it and have to stay for accounting. Comments stay
+// under the anonymised user. Books are deleted here rather than left to the SET NULL of their author
+// relation, which would keep them in the catalog without an author and with the author's contract details.
+func (u UserManager) DeleteAccount(db *gorm.DB, user *models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		bookIDs := []uuid.UUID{}
+		tx.Model(&models.Book{}).Where("author_id = ?", user.ID).Pluck("id", &bookIDs)
+		for _, bookID := range bookIDs {
+			if err := (BookManager{}).DeleteBookWithSQL(tx, bookID); err != nil {
+				return err
+			}
+		}
+
+		// Data that only matters to the user
+		for _, model := range []interface{}{
+			&models.AuthToken{}, &models.RecoveryCode{}, &models.SocialAccount{}, &models.DataExport{},
+			&models.Bookmark{}, &models.BookRead{}, &models.AuthorApplication{}, &models.EmailChange{},
+			&models.BookRecommendation{}, &models.ReadingProgress{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("sender_id = ? OR receiver_id = ?", user.ID, user.ID).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? OR blocked_user_id = ?", user.ID, user.ID).Delete(&models.UserBlock{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(user).Association("Followings").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Association("Followers").Clear(); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"name": nil, "bio": nil, "avatar": "",
+			"username":          fmt.Sprintf("deleted-%s", user.ID),
+			"email":             fmt.Sprintf("deleted-%s@users.litpad.invalid", user.ID),
+			"password":          utils.HashPassword(utils.GetRandomString(32)),
+			"is_email_verified": false, "is_active": false, "social_login": false,
+			"is_staff": false, "is_superuser": false, "role_id": nil, "account_type": choices.ACCTYPE_READER, "author_verified_at": nil,
+			"otp": nil, "otp_expiry": nil, "token_string": nil, "token_expiry": nil,
+			"totp_secret": nil, "totp_enabled": false, "totp_last_step": 0,
+			"like_notification": false, "reply_notification": false,
+			"deletion_scheduled_at": nil, "account_deleted_at": now,
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		user.AccountDeletedAt = &now
+		return nil
+	})
+}
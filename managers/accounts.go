@@ -5,6 +5,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/models/scopes"
@@ -12,6 +13,7 @@ import (
 	"github.com/LitPad/backend/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserManager struct {
@@ -37,12 +39,6 @@ func (u UserManager) GetCount(db *gorm.DB) int64 {
 	return count
 }
 
-func (u UserManager) GetActiveSubscribersCount(db *gorm.DB) int64 {
-	var count int64
-	db.Model(u.Model).Where("subscription_expiry IS NOT NULL AND subscription_expiry > ?", time.Now()).Count(&count)
-	return count
-}
-
 func (u UserManager) GetByUsername(db *gorm.DB, username string) *models.User {
 	user := models.User{Username: username}
 	db.Scopes(scopes.FollowerFollowingPreloaderScope).Take(&user, user)
@@ -87,10 +83,13 @@ func (u UserManager) GetSubscribers(db *gorm.DB, subscriptionType *choices.Subsc
 	}
 	if subscriptionStatus != nil {
 		currentTime := time.Now()
-		if *subscriptionStatus == choices.SS_ACTIVE {
+		switch *subscriptionStatus {
+		case choices.SS_ACTIVE:
 			query = query.Where("subscription_expiry > ?", currentTime)
-		} else {
+		case choices.SS_EXPIRED:
 			query = query.Where("subscription_expiry < ?", currentTime)
+		default:
+			query = query.Where("EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.user_id = users.id AND subscriptions.status = ?)", subscriptionStatus)
 		}
 	}
 	query.Find(&subscribers)
@@ -170,14 +169,103 @@ func (u UserManager) GetUserGrowthData(db *gorm.DB, choice choices.UserGrowthCho
 	return results
 }
 
-func (u UserManager) GenerateAuthTokens(db *gorm.DB, user models.User, access string, refresh string) models.AuthToken {
-	tokens := models.AuthToken{UserID: user.ID, Access: access, Refresh: refresh}
+// The device a session was started or last refreshed from
+type SessionDevice struct {
+	UserAgent  string
+	IP         string
+	DeviceType *choices.DeviceType
+}
+
+// Starts a session with its first pair of tokens
+func (u UserManager) GenerateAuthTokens(db *gorm.DB, user models.User, access string, refresh string, device ...SessionDevice) models.AuthToken {
+	now := time.Now()
+	tokens := models.AuthToken{UserID: user.ID, Access: access, Refresh: refresh, LastUsedAt: &now}
+	if len(device) > 0 {
+		tokens.UserAgent = device[0].UserAgent
+		tokens.IP = device[0].IP
+		tokens.DeviceType = device[0].DeviceType
+	}
 	db.Create(&tokens)
 	return tokens
 }
 
+func (u UserManager) GetAuthTokenByRefresh(db *gorm.DB, refresh string) *models.AuthToken {
+	token := models.AuthToken{}
+	db.Take(&token, "refresh = ?", refresh)
+	if token.ID == uuid.Nil {
+		return nil
+	}
+	return &token
+}
+
+// Replaces a session's tokens with a new pair. Tokens that have been replaced already may have been stolen,
+// so using them again revokes the whole session and reused is returned as true.
+func (u UserManager) RotateAuthTokens(db *gorm.DB, token models.AuthToken, access string, refresh string, device SessionDevice) (newToken *models.AuthToken, reused bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the tokens so that concurrent refreshes with the same token can't both succeed
+		current := models.AuthToken{}
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&current, "id = ?", token.ID)
+		if current.ID == uuid.Nil {
+			reused = true
+			return nil
+		}
+		if current.RotatedAt != nil {
+			reused = true
+			return tx.Where("user_id = ? AND family_id = ?", current.UserID, current.FamilyID).Delete(&models.AuthToken{}).Error
+		}
+
+		now := time.Now()
+		if err := tx.Model(&current).Update("rotated_at", now).Error; err != nil {
+			return err
+		}
+		deviceType := current.DeviceType
+		if device.DeviceType != nil {
+			deviceType = device.DeviceType
+		}
+		newToken = &models.AuthToken{
+			UserID: current.UserID, Access: access, Refresh: refresh,
+			FamilyID: current.FamilyID, SignedInAt: current.SignedInAt,
+			UserAgent: device.UserAgent, IP: device.IP, DeviceType: deviceType, LastUsedAt: &now,
+		}
+		if err := tx.Create(newToken).Error; err != nil {
+			return err
+		}
+		// Replaced tokens are only kept for as long as they could be reused
+		refreshLifetime := time.Duration(config.GetConfig().RefreshTokenExpireMinutes) * time.Minute
+		return tx.Where("family_id = ? AND rotated_at < ?", current.FamilyID, now.Add(-refreshLifetime)).Delete(&models.AuthToken{}).Error
+	})
+	if err != nil || reused {
+		return nil, reused, err
+	}
+	return newToken, false, nil
+}
+
+// Returns the current tokens of a user's sessions, most recently used first
+func (u UserManager) GetSessions(db *gorm.DB, user models.User) []models.AuthToken {
+	tokens := []models.AuthToken{}
+	db.Where("user_id = ? AND rotated_at IS NULL", user.ID).Order("last_used_at DESC NULLS LAST").Find(&tokens)
+	return tokens
+}
+
+// Ends a session. False is returned when the user has no session with that ID.
+func (u UserManager) RevokeSession(db *gorm.DB, user models.User, familyID uuid.UUID) bool {
+	result := db.Where("user_id = ? AND family_id = ?", user.ID, familyID).Delete(&models.AuthToken{})
+	return result.RowsAffected > 0
+}
+
+// Sets the families of tokens issued before sessions were recorded, so that each is a session of its own
+func (u UserManager) FillTokenFamilies(db *gorm.DB) {
+	db.Exec("UPDATE auth_tokens SET family_id = id, signed_in_at = created_at WHERE family_id IS NULL")
+}
+
+// Ends the session of an access token
 func (u UserManager) DeleteToken(db *gorm.DB, token string) {
-	db.Where("access = ?", token).Delete(&models.AuthToken{})
+	authToken := models.AuthToken{}
+	db.Take(&authToken, "access = ?", token)
+	if authToken.ID == uuid.Nil {
+		return
+	}
+	db.Where("user_id = ? AND family_id = ?", authToken.UserID, authToken.FamilyID).Delete(&models.AuthToken{})
 }
 
 func (u UserManager) DeleteAllToken(db *gorm.DB, user models.User) {
@@ -235,7 +323,12 @@ func (n NotificationManager) ReadOne(db *gorm.DB, user *models.User, id uuid.UUI
 	return nil
 }
 
+// Notifies a user of what another did. Nothing is created when the receiver has blocked the sender,
+// and the notification returned then has no ID.
 func (n NotificationManager) Create(db *gorm.DB, sender *models.User, receiver models.User, ntype choices.NotificationTypeChoice, text string, book *models.Book, commentID *uuid.UUID, sentGiftID *uuid.UUID) models.Notification {
+	if (UserBlockManager{}).IsBlocked(db, receiver.ID, sender.ID) {
+		return models.Notification{}
+	}
 	notification := models.Notification{
 		SenderID: sender.ID, Sender: *sender, ReceiverID: receiver.ID,
 		Ntype: ntype, CommentID: commentID, SentGiftID: sentGiftID, Text: text,
@@ -0,0 +1,153 @@
+package managers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Failures are forgotten after this long without another one
+	authThrottleWindow = time.Hour
+	maxAuthBackoff     = 15 * time.Minute
+	authLockDuration   = 30 * time.Minute
+)
+
+type authThrottleLimits struct {
+	FreeAttempts int // failures allowed before every attempt has to wait longer than the last
+	LockAfter    int // failures that lock the account or address out
+}
+
+// Limits for an account and for an IP address, which is shared by everyone behind it so it gets more room
+var authThrottleLimitsByScope = map[choices.AuthThrottleScopeChoice][2]authThrottleLimits{
+	choices.ATS_LOGIN:          {{FreeAttempts: 5, LockAfter: 10}, {FreeAttempts: 20, LockAfter: 100}},
+	choices.ATS_VERIFY_EMAIL:   {{FreeAttempts: 5, LockAfter: 10}, {FreeAttempts: 20, LockAfter: 100}},
+	choices.ATS_PASSWORD_RESET: {{FreeAttempts: 3, LockAfter: 10}, {FreeAttempts: 10, LockAfter: 50}},
+}
+
+type AuthThrottleManager struct {
+	Model     models.AuthThrottle
+	ModelList []models.AuthThrottle
+}
+
+func (a AuthThrottleManager) get(db *gorm.DB, scope choices.AuthThrottleScopeChoice, key string) models.AuthThrottle {
+	throttle := models.AuthThrottle{Scope: scope, Key: key}
+	db.Take(&throttle, throttle)
+	return throttle
+}
+
+// Returns how long an account and an IP address have to wait before trying an action again, zero if they can try now
+func (a AuthThrottleManager) Wait(db *gorm.DB, scope choices.AuthThrottleScopeChoice, email string, ip string) (time.Duration, bool) {
+	account := a.get(db, scope, strings.ToLower(email))
+	address := a.get(db, scope, ip)
+	wait := max(account.Wait(), address.Wait())
+	return wait, account.IsLocked() || address.IsLocked()
+}
+
+// Counts a failed attempt against an account and an IP address. Each failure past the free ones doubles the
+// wait before the next attempt, and too many lock out the account or address. The token for the
+// unlock link of the account is returned when this failure locked it.
+func (a AuthThrottleManager) RecordFailure(db *gorm.DB, scope choices.AuthThrottleScopeChoice, email string, ip string) *string {
+	limits := authThrottleLimitsByScope[scope]
+	unlockToken := a.recordFailure(db, scope, strings.ToLower(email), false, limits[0])
+	a.recordFailure(db, scope, ip, true, limits[1])
+	return unlockToken
+}
+
+func (a AuthThrottleManager) recordFailure(db *gorm.DB, scope choices.AuthThrottleScopeChoice, key string, isIP bool, limits authThrottleLimits) *string {
+	var unlockToken *string
+	throttle := models.AuthThrottle{Scope: scope, Key: key, IsIP: isIP}
+	db.Transaction(func(tx *gorm.DB) error {
+		tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&throttle)
+		// Locking the row keeps concurrent failures from being counted as one
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("scope = ? AND key = ?", scope, key).Take(&throttle)
+
+		now := time.Now()
+		if throttle.LastFailureAt != nil && now.Sub(*throttle.LastFailureAt) > authThrottleWindow && !throttle.IsLocked() {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = &now
+		if throttle.Failures > limits.FreeAttempts {
+			backoff := time.Duration(math.Pow(2, float64(throttle.Failures-limits.FreeAttempts-1))) * time.Second
+			retryAt := now.Add(min(backoff, maxAuthBackoff))
+			throttle.RetryAt = &retryAt
+		}
+		if throttle.Failures >= limits.LockAfter {
+			lockedUntil := now.Add(authLockDuration)
+			throttle.Failures = 0
+			throttle.RetryAt = nil
+			throttle.LockedUntil = &lockedUntil
+			if !isIP {
+				token := generateUnlockToken()
+				hashed := hashUnlockToken(token)
+				throttle.UnlockToken = &hashed
+				unlockToken = &token
+			}
+		}
+		return tx.Save(&throttle).Error
+	})
+	return unlockToken
+}
+
+// Forgets the failures of an account after a successful attempt. Those of the IP address stay,
+// otherwise signing in to one account would let an address keep guessing at others.
+func (a AuthThrottleManager) Clear(db *gorm.DB, scope choices.AuthThrottleScopeChoice, email string) {
+	db.Where("scope = ? AND key = ? AND (locked_until IS NULL OR locked_until < ?)", scope, strings.ToLower(email), time.Now()).Delete(&a.Model)
+}
+
+// Removes the lockout that an unlock link was sent for
+func (a AuthThrottleManager) Unlock(db *gorm.DB, token string) bool {
+	result := db.Where("unlock_token = ? AND locked_until > ?", hashUnlockToken(token), time.Now()).Delete(&a.Model)
+	return result.RowsAffected > 0
+}
+
+// Removes every throttle of an account, e.g after its password is reset
+func (a AuthThrottleManager) ClearAccount(db *gorm.DB, email string) {
+	db.Where("key = ? AND is_ip = ?", strings.ToLower(email), false).Delete(&a.Model)
+}
+
+// Returns the accounts and addresses that are locked out or have to wait before another attempt
+func (a AuthThrottleManager) GetActive(db *gorm.DB, scope *choices.AuthThrottleScopeChoice) []models.AuthThrottle {
+	throttles := a.ModelList
+	now := time.Now()
+	query := db.Where("(locked_until > ? OR retry_at > ?)", now, now)
+	if scope != nil {
+		query = query.Where("scope = ?", *scope)
+	}
+	query.Order("updated_at DESC").Find(&throttles)
+	return throttles
+}
+
+func (a AuthThrottleManager) GetByID(db *gorm.DB, id uuid.UUID) *models.AuthThrottle {
+	throttle := a.Model
+	db.Take(&throttle, id)
+	if throttle.ID == uuid.Nil {
+		return nil
+	}
+	return &throttle
+}
+
+func (a AuthThrottleManager) Delete(db *gorm.DB, throttle models.AuthThrottle) {
+	db.Delete(&throttle)
+}
+
+func generateUnlockToken() string {
+	randomBytes := make([]byte, 32)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+func hashUnlockToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
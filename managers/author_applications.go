@@ -0,0 +1,112 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuthorApplicationManager struct {
+	Model     models.AuthorApplication
+	ModelList []models.AuthorApplication
+}
+
+func (a AuthorApplicationManager) GetAll(db *gorm.DB, status *choices.AuthorApplicationStatusChoice) []models.AuthorApplication {
+	applications := a.ModelList
+	query := db.Joins("User")
+	if status != nil {
+		query = query.Where("author_applications.status = ?", *status)
+	}
+	query.Order("author_applications.created_at DESC").Find(&applications)
+	return applications
+}
+
+func (a AuthorApplicationManager) GetByID(db *gorm.DB, id uuid.UUID) *models.AuthorApplication {
+	application := a.Model
+	db.Joins("User").Take(&application, "author_applications.id = ?", id)
+	if application.ID == uuid.Nil {
+		return nil
+	}
+	return &application
+}
+
+// Returns the application a user submitted last
+func (a AuthorApplicationManager) GetLatestByUser(db *gorm.DB, user models.User) *models.AuthorApplication {
+	application := a.Model
+	db.Joins("User").Where("author_applications.user_id = ?", user.ID).Order("author_applications.created_at DESC").Take(&application)
+	if application.ID == uuid.Nil {
+		return nil
+	}
+	return &application
+}
+
+// Submits an application for a reader. Those with one under review have to wait for its decision first.
+func (a AuthorApplicationManager) Create(db *gorm.DB, user models.User, data schemas.AuthorApplicationCreateSchema) (*models.AuthorApplication, *utils.ErrorResponse) {
+	if user.AccountType == choices.ACCTYPE_AUTHOR {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "You're an author already")
+		return nil, &errData
+	}
+	latest := a.GetLatestByUser(db, user)
+	if latest != nil && latest.Status == choices.AAS_PENDING {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Your last application is still being reviewed")
+		return nil, &errData
+	}
+	application := models.AuthorApplication{
+		UserID: user.ID, User: user, PenName: data.PenName, Bio: data.Bio,
+		SampleWork: data.SampleWork, Links: data.Links, Status: choices.AAS_PENDING,
+	}
+	db.Create(&application)
+	return &application, nil
+}
+
+// Records staff's decision on an application. Approving it makes the applicant a verified author.
+func (a AuthorApplicationManager) Review(db *gorm.DB, application *models.AuthorApplication, reviewer models.User, status choices.AuthorApplicationStatusChoice, reason *string) *utils.ErrorResponse {
+	if application.Status != choices.AAS_PENDING {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This application has been reviewed already")
+		return &errData
+	}
+	if status == choices.AAS_DECLINED && reason == nil {
+		errData := utils.ValidationErr("reason", "Give a reason for declining the application")
+		return &errData
+	}
+	now := time.Now()
+	reviewed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only a pending application is updated, so two staff reviewing it at the same time can't both decide on it
+		result := tx.Model(&models.AuthorApplication{}).Where("id = ? AND status = ?", application.ID, choices.AAS_PENDING).
+			Updates(map[string]interface{}{"status": status, "reason": reason, "reviewer_id": reviewer.ID, "reviewed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		reviewed = true
+		if status != choices.AAS_APPROVED {
+			return nil
+		}
+		return tx.Model(&application.User).Updates(map[string]interface{}{"account_type": choices.ACCTYPE_AUTHOR, "author_verified_at": now}).Error
+	})
+	if err != nil {
+		errData := utils.ServerErr("Something went wrong while reviewing the application")
+		return &errData
+	}
+	if !reviewed {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This application has been reviewed already")
+		return &errData
+	}
+	application.Status = status
+	application.Reason = reason
+	application.ReviewerID = &reviewer.ID
+	application.ReviewedAt = &now
+	if status == choices.AAS_APPROVED {
+		application.User.AccountType = choices.ACCTYPE_AUTHOR
+		application.User.AuthorVerifiedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package managers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/models/scopes"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Searches stop at this many books, which is more than anyone pages through
+const searchResultsLimit = 1000
+
+const searchHighlightOptions = "StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2"
+
+type BookSearchFilters struct {
+	Query         string
+	GenreSlug     string
+	TagSlug       string
+	AgeDiscretion *choices.AgeType
+	Completed     *bool
+	InChapters    bool // also match the text of chapters
+}
+
+// Turns search text into a tsquery matching every word of it by prefix, e.g "dark fore" becomes "dark:* & fore:*"
+func searchTsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Returns the books matching a search, best first. Books match on their search vectors (see models.UpdateBookSearchVectors)
+// and titles or author usernames close enough to the search text, so typos are tolerated.
+func (b BookManager) Search(db *gorm.DB, filters BookSearchFilters) ([]schemas.BookSearchHit, *utils.ErrorResponse) {
+	hits := []schemas.BookSearchHit{}
+	text := strings.TrimSpace(filters.Query)
+	tsQuery := searchTsQuery(text)
+	if tsQuery == "" {
+		errData := utils.InvalidParamErr("Enter a word to search for")
+		return hits, &errData
+	}
+
+	query := db.Model(&b.Model).Joins("JOIN users ON users.id = books.author_id")
+
+	// Facet filters
+	if filters.GenreSlug != "" {
+		genre := models.Genre{Slug: filters.GenreSlug}
+		db.Take(&genre, genre)
+		if genre.ID == uuid.Nil {
+			errData := utils.NotFoundErr("Invalid book genre")
+			return hits, &errData
+		}
+		query = query.Where("books.genre_id = ?", genre.ID)
+	}
+	if filters.TagSlug != "" {
+		tag := models.Tag{Slug: filters.TagSlug}
+		db.Take(&tag, tag)
+		if tag.ID == uuid.Nil {
+			return hits, nil
+		}
+		query = query.Where("books.id IN (?)", db.Table("book_tags").Select("book_id").Where("tag_id = ?", tag.ID))
+	}
+	if filters.AgeDiscretion != nil {
+		query = query.Where("books.age_discretion = ?", *filters.AgeDiscretion)
+	}
+	if filters.Completed != nil {
+		query = query.Where("books.completed = ?", *filters.Completed)
+	}
+
+	rankSQL := "ts_rank(books.search_vector, to_tsquery('english', ?)) + word_similarity(?, books.title)"
+	matchSQL := "books.search_vector @@ to_tsquery('english', ?) OR ? <% books.title OR ? <% users.username"
+	paragraphIDSQL := "NULL::uuid"
+	if filters.InChapters {
+		query = query.Joins(`LEFT JOIN LATERAL (
+			SELECT paragraphs.id, ts_rank(paragraphs.search_vector, to_tsquery('english', ?)) AS rank
+			FROM paragraphs JOIN chapters ON chapters.id = paragraphs.chapter_id
+			WHERE chapters.book_id = books.id AND chapters.status = 'PUBLISHED' AND paragraphs.search_vector @@ to_tsquery('english', ?)
+			ORDER BY rank DESC LIMIT 1
+		) AS paragraph_hits ON true`, tsQuery, tsQuery)
+		// Chapter text counts for less than what the book says about itself
+		rankSQL += " + coalesce(paragraph_hits.rank, 0) / 2"
+		matchSQL += " OR paragraph_hits.id IS NOT NULL"
+		paragraphIDSQL = "paragraph_hits.id"
+	}
+
+	query.
+		Select(fmt.Sprintf("books.id AS book_id, %s AS rank, %s AS paragraph_id", rankSQL, paragraphIDSQL), tsQuery, text).
+		Where("("+matchSQL+")", tsQuery, text, text).
+		Order("rank DESC, books.created_at DESC").
+		Limit(searchResultsLimit).
+		Scan(&hits)
+	return hits, nil
+}
+
+func searchHitBookIDs(hits []schemas.BookSearchHit) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.BookID)
+	}
+	return ids
+}
+
+// Returns the books of search hits. They aren't ordered like the hits.
+func (b BookManager) GetSearchHitBooks(db *gorm.DB, hits []schemas.BookSearchHit) []models.Book {
+	books := b.ModelList
+	if len(hits) == 0 {
+		return books
+	}
+	db.Scopes(scopes.AuthorGenreTagBookPreloadScope).Where("books.id IN ?", searchHitBookIDs(hits)).Find(&books)
+	return books
+}
+
+// Returns the snippets of search hits with the words that matched marked, keyed by book ID
+func (b BookManager) GetSearchHighlights(db *gorm.DB, hits []schemas.BookSearchHit, text string) map[uuid.UUID]schemas.BookSearchHighlightSchema {
+	highlights := make(map[uuid.UUID]schemas.BookSearchHighlightSchema)
+	tsQuery := searchTsQuery(text)
+	if len(hits) == 0 || tsQuery == "" {
+		return highlights
+	}
+
+	bookHighlights := []struct {
+		BookID uuid.UUID
+		Title  string
+		Blurb  string
+	}{}
+	db.Model(&b.Model).
+		Select(`books.id AS book_id,
+			ts_headline('english', books.title, to_tsquery('english', ?), 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS title,
+			ts_headline('english', books.blurb, to_tsquery('english', ?), ?) AS blurb`, tsQuery, tsQuery, searchHighlightOptions).
+		Where("books.id IN ?", searchHitBookIDs(hits)).
+		Scan(&bookHighlights)
+	for _, highlight := range bookHighlights {
+		highlights[highlight.BookID] = schemas.BookSearchHighlightSchema{Title: highlight.Title, Blurb: highlight.Blurb}
+	}
+
+	paragraphIDs := []uuid.UUID{}
+	for _, hit := range hits {
+		if hit.ParagraphID != nil {
+			paragraphIDs = append(paragraphIDs, *hit.ParagraphID)
+		}
+	}
+	if len(paragraphIDs) == 0 {
+		return highlights
+	}
+	paragraphHighlights := []struct {
+		BookID      uuid.UUID
+		ChapterSlug string
+		Content     string
+	}{}
+	db.Model(&models.Paragraph{}).
+		Select("chapters.book_id, chapters.slug AS chapter_slug, ts_headline('english', paragraphs.text, to_tsquery('english', ?), ?) AS content", tsQuery, searchHighlightOptions).
+		Joins("JOIN chapters ON chapters.id = paragraphs.chapter_id").
+		Where("paragraphs.id IN ?", paragraphIDs).
+		Scan(&paragraphHighlights)
+	for _, paragraphHighlight := range paragraphHighlights {
+		highlight := highlights[paragraphHighlight.BookID]
+		highlight.ChapterSlug = &paragraphHighlight.ChapterSlug
+		highlight.Content = &paragraphHighlight.Content
+		highlights[paragraphHighlight.BookID] = highlight
+	}
+	return highlights
+}
+
+// Counts search hits by genre, tag, age discretion and completion, for narrowing a search down further
+func (b BookManager) GetSearchFacets(db *gorm.DB, hits []schemas.BookSearchHit) schemas.BookSearchFacetsSchema {
+	facets := schemas.BookSearchFacetsSchema{
+		Genres: []schemas.SearchFacetSchema{}, Tags: []schemas.SearchFacetSchema{},
+		AgeDiscretions: []schemas.SearchFacetSchema{}, Completed: []schemas.SearchFacetSchema{},
+	}
+	if len(hits) == 0 {
+		return facets
+	}
+	ids := searchHitBookIDs(hits)
+	db.Model(&b.Model).
+		Select("genres.slug AS value, genres.name AS name, COUNT(*) AS count").
+		Joins("JOIN genres ON genres.id = books.genre_id").
+		Where("books.id IN ?", ids).
+		Group("genres.slug, genres.name").Order("count DESC, name").
+		Scan(&facets.Genres)
+	db.Table("book_tags").
+		Select("tags.slug AS value, tags.name AS name, COUNT(*) AS count").
+		Joins("JOIN tags ON tags.id = book_tags.tag_id").
+		Where("book_tags.book_id IN ?", ids).
+		Group("tags.slug, tags.name").Order("count DESC, name").
+		Scan(&facets.Tags)
+	db.Model(&b.Model).
+		Select("age_discretion::text AS value, age_discretion::text || '+' AS name, COUNT(*) AS count").
+		Where("books.id IN ?", ids).
+		Group("age_discretion").Order("age_discretion").
+		Scan(&facets.AgeDiscretions)
+	db.Model(&b.Model).
+		Select("completed::text AS value, CASE WHEN completed THEN 'Completed' ELSE 'Ongoing' END AS name, COUNT(*) AS count").
+		Where("books.id IN ?", ids).
+		Group("completed").Order("completed DESC").
+		Scan(&facets.Completed)
+	return facets
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/utils"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -608,10 +609,13 @@ func (b BookManager) DeleteBookWithSQL(db *gorm.DB, bookID uuid.UUID) error {
             return fmt.Errorf("failed to delete votes: %w", err)
         }
 
-        // Step 4: Delete book reads
+        // Step 4: Delete book reads and reading progress
         if err := tx.Exec("DELETE FROM book_reads WHERE book_id = $1", bookID).Error; err != nil {
             return fmt.Errorf("failed to delete book reads: %w", err)
         }
+        if err := tx.Exec("DELETE FROM reading_progresses WHERE book_id = $1", bookID).Error; err != nil {
+            return fmt.Errorf("failed to delete reading progress: %w", err)
+        }
 
         // Step 5: Delete book bookmarks
         if err := tx.Exec("DELETE FROM bookmarks WHERE book_id = $1", bookID).Error; err != nil {
@@ -633,12 +637,28 @@ func (b BookManager) DeleteBookWithSQL(db *gorm.DB, bookID uuid.UUID) error {
             return fmt.Errorf("failed to delete paragraphs: %w", err)
         }
 
-        // Step 8: Delete chapters
+        // Step 8: Delete chapter revisions and chapters
+        if err := tx.Exec(`
+            DELETE FROM chapter_revisions 
+            WHERE chapter_id IN (
+                SELECT id FROM chapters WHERE book_id = $1
+            )
+        `, bookID).Error; err != nil {
+            return fmt.Errorf("failed to delete chapter revisions: %w", err)
+        }
         if err := tx.Exec("DELETE FROM chapters WHERE book_id = $1", bookID).Error; err != nil {
             return fmt.Errorf("failed to delete chapters: %w", err)
         }
 
-        // Step 9: Finally, delete the book itself
+        // Step 9: Delete recommendations of and similarities to this book
+        if err := tx.Exec("DELETE FROM book_recommendations WHERE book_id = $1 OR because_of_book_id = $1", bookID).Error; err != nil {
+            return fmt.Errorf("failed to delete book recommendations: %w", err)
+        }
+        if err := tx.Exec("DELETE FROM book_similarities WHERE book_id = $1 OR similar_book_id = $1", bookID).Error; err != nil {
+            return fmt.Errorf("failed to delete book similarities: %w", err)
+        }
+
+        // Step 10: Finally, delete the book itself
         if err := tx.Exec("DELETE FROM books WHERE id = $1", bookID).Error; err != nil {
             return fmt.Errorf("failed to delete book: %w", err)
         }
@@ -699,7 +719,7 @@ func (c ChapterManager) GetBySlug(db *gorm.DB, slug string) (*models.Chapter, *u
 
 func (c ChapterManager) GetBySlugWithComments(db *gorm.DB, slug string, index uint) (*models.Chapter, []models.Comment, *utils.ErrorResponse) {
 	chapter := models.Chapter{Slug: slug}
-	db.Take(&chapter, chapter)
+	db.Joins("Book").Take(&chapter, chapter)
 	if chapter.ID == uuid.Nil {
 		errD := utils.NotFoundErr("No chapter with that slug")
 		return nil, nil, &errD
@@ -715,18 +735,46 @@ func (c ChapterManager) GetBySlugWithComments(db *gorm.DB, slug string, index ui
 	return &chapter, comments, nil
 }
 
+// Returns every chapter of a book whatever its status, for those who write it
+func (c ChapterManager) GetAllByBook(db *gorm.DB, book models.Book) []models.Chapter {
+	chapters := c.ModelList
+	db.Where("book_id = ?", book.ID).Order("created_at ASC").Find(&chapters)
+	return chapters
+}
+
 func (c ChapterManager) IsFirstChapter(db *gorm.DB, chapter models.Chapter) bool {
 	firstChapter := c.Model
-	db.Order("created_at ASC").First(&firstChapter)
+	db.Scopes(scopes.PublishedChapterScope).Where("book_id = ?", chapter.BookID).Order("created_at ASC").First(&firstChapter)
 	return firstChapter.ID == chapter.ID
 }
 
-func (c ChapterManager) Create(db *gorm.DB, book models.Book, data schemas.ChapterCreateSchema) models.Chapter {
+// Sets the status a chapter is saved with. Chapters are published right away unless they are drafted or scheduled.
+func setChapterStatus(chapter *models.Chapter, data schemas.ChapterCreateSchema) {
+	switch data.Status {
+	case "":
+		if chapter.Status == "" {
+			chapter.Status = choices.CS_PUBLISHED
+		}
+	case choices.CS_SCHEDULED:
+		chapter.Status = data.Status
+		chapter.PublishAt = data.PublishAt
+	default:
+		chapter.Status = data.Status
+	}
+	if chapter.Status == choices.CS_PUBLISHED && chapter.PublishAt == nil {
+		now := time.Now()
+		chapter.PublishAt = &now
+	}
+}
+
+// Creates a chapter with its first revision
+func (c ChapterManager) Create(db *gorm.DB, book models.Book, data schemas.ChapterCreateSchema, editor *models.User) models.Chapter {
 	chapter := models.Chapter{
 		BookID: book.ID,
 		Title:  data.Title,
 		IsLast: data.IsLast,
 	}
+	setChapterStatus(&chapter, data)
 	db.Create(&chapter)
 	// Generate paragraphs
 	paragraphsToCreate := []models.Paragraph{}
@@ -735,82 +783,152 @@ func (c ChapterManager) Create(db *gorm.DB, book models.Book, data schemas.Chapt
 	}
 	db.Create(&paragraphsToCreate)
 	chapter.Paragraphs = paragraphsToCreate
+	ChapterRevisionManager{}.Snapshot(db, chapter, editor)
+	if chapter.IsPublished() {
+		c.published(db, chapter, true)
+	}
 	return chapter
 }
 
-func (c ChapterManager) Update(db *gorm.DB, chapter models.Chapter, data schemas.ChapterCreateSchema) models.Chapter {
-	// Update title only if changed
-	if chapter.Title != data.Title {
+// Updates a chapter and saves it as a new revision. Chapters written before revisions existed get their previous version saved first.
+func (c ChapterManager) Update(db *gorm.DB, chapter models.Chapter, data schemas.ChapterCreateSchema, editor *models.User) (models.Chapter, error) {
+	previousStatus := chapter.Status
+	err := db.Transaction(func(tx *gorm.DB) error {
+		revisionManager := ChapterRevisionManager{}
+		var revisionsCount int64
+		tx.Model(&revisionManager.Model).Where("chapter_id = ?", chapter.ID).Count(&revisionsCount)
+		if revisionsCount == 0 {
+			if _, err := revisionManager.Snapshot(tx, chapter, nil); err != nil {
+				return err
+			}
+		}
+
 		chapter.Title = data.Title
+		chapter.IsLast = data.IsLast
+		setChapterStatus(&chapter, data)
+		if err := tx.Omit(clause.Associations).Save(&chapter).Error; err != nil {
+			return err
+		}
+		paragraphs, err := c.reflowParagraphs(tx, chapter, data.Paragraphs)
+		if err != nil {
+			return err
+		}
+		chapter.Paragraphs = paragraphs
+		_, err = revisionManager.Snapshot(tx, chapter, editor)
+		return err
+	})
+	if err != nil {
+		return chapter, err
 	}
-	chapter.IsLast = data.IsLast
-	db.Save(&chapter)
-
-	existingParagraphs := chapter.Paragraphs
+	if chapter.IsPublished() && previousStatus != choices.CS_PUBLISHED {
+		// Readers were told about unpublished chapters when they first came out
+		c.published(db, chapter, previousStatus != choices.CS_UNPUBLISHED)
+	}
+	return chapter, nil
+}
 
-	existingMap := make(map[uint]models.Paragraph) // Existing paragraphs indexed by index
-	for _, p := range existingParagraphs {
-		existingMap[p.Index] = p
+// Rewrites the paragraphs of a chapter to texts. Paragraphs are matched to the texts by content rather than position
+// (see matchParagraphs), so the comments on them stay with their text when it is moved or edited.
+// Only paragraphs that are gone altogether are deleted, with their comments.
+func (c ChapterManager) reflowParagraphs(tx *gorm.DB, chapter models.Chapter, texts []string) ([]models.Paragraph, error) {
+	existing := sortedParagraphs(chapter.Paragraphs)
+	oldTexts := make([]string, 0, len(existing))
+	for _, paragraph := range existing {
+		oldTexts = append(oldTexts, paragraph.Text)
 	}
+	matches := matchParagraphs(oldTexts, texts)
 
+	paragraphs := make([]models.Paragraph, len(texts))
+	kept := make(map[uuid.UUID]bool, len(matches))
 	toInsert := []models.Paragraph{}
-	toUpdate := []models.Paragraph{}
-	toDelete := []uuid.UUID{} // Store IDs for deletion
-
-	existingIndexes := make(map[uint]bool)
-	for i, text := range data.Paragraphs {
-		index := uint(i)
-
-		if existingPara, exists := existingMap[index]; exists {
-			// Update only if text has changed
-			if existingPara.Text != text {
-				toUpdate = append(toUpdate, models.Paragraph{BaseModel: models.BaseModel{ID: existingPara.ID}, Text: text})
-			}
-			existingIndexes[index] = true
-		} else {
-			// Insert new paragraph
+	for i, text := range texts {
+		index := uint(i + 1)
+		old, ok := matches[i]
+		if !ok {
 			toInsert = append(toInsert, models.Paragraph{ChapterID: chapter.ID, Index: index, Text: text})
+			continue
+		}
+		paragraph := existing[old]
+		kept[paragraph.ID] = true
+		if paragraph.Text != text || paragraph.Index != index {
+			updates := map[string]interface{}{"text": text, "index": index, "updated_at": time.Now()}
+			if err := tx.Model(&models.Paragraph{}).Where("id = ?", paragraph.ID).UpdateColumns(updates).Error; err != nil {
+				return nil, err
+			}
 		}
+		paragraph.Text = text
+		paragraph.Index = index
+		paragraphs[i] = paragraph
 	}
 
-	// Find paragraphs to delete (those not in `existingIndexes`)
-	for _, p := range existingParagraphs {
-		if !existingIndexes[p.Index] {
-			toDelete = append(toDelete, p.ID)
+	toDelete := []uuid.UUID{}
+	for _, paragraph := range existing {
+		if !kept[paragraph.ID] {
+			toDelete = append(toDelete, paragraph.ID)
 		}
 	}
-
-	// Step 5: Execute Bulk Queries Efficiently
-	tx := db.Begin()
-
-	// Bulk Update (Uses GORM's Batch Update Feature)
-	if len(toUpdate) > 0 {
-		for _, p := range toUpdate {
-			if err := tx.Model(&models.Paragraph{}).Where("id = ?", p.ID).Update("text", p.Text).Error; err != nil {
-				tx.Rollback()
-				fmt.Errorf("failed to update paragraphs: %w", err)
-			}
+	if len(toDelete) > 0 {
+		if err := tx.Where("id IN ?", toDelete).Delete(&models.Paragraph{}).Error; err != nil {
+			return nil, err
 		}
 	}
-
-	// Bulk Insert
 	if len(toInsert) > 0 {
 		if err := tx.Create(&toInsert).Error; err != nil {
-			tx.Rollback()
-			fmt.Errorf("failed to insert paragraphs: %w", err)
+			return nil, err
+		}
+		for _, paragraph := range toInsert {
+			paragraphs[paragraph.Index-1] = paragraph
 		}
 	}
+	return paragraphs, nil
+}
 
-	// Bulk Delete
-	if len(toDelete) > 0 {
-		if err := tx.Where("id IN ?", toDelete).Delete(&models.Paragraph{}).Error; err != nil {
-			tx.Rollback()
-			fmt.Errorf("failed to delete paragraphs: %w", err)
-		}
+// Completes the book of a chapter that just came out if it is the last one, and tells the author's followers
+// and those who bookmarked the book about it if it is new
+func (c ChapterManager) published(db *gorm.DB, chapter models.Chapter, isNew bool) {
+	if chapter.IsLast {
+		db.Model(&models.Book{}).Where("id = ?", chapter.BookID).UpdateColumn("completed", true)
+	}
+	if !isNew {
+		return
 	}
+	book := models.Book{}
+	db.Joins("Author").Take(&book, "books.id = ?", chapter.BookID)
+	followers := []models.User{}
+	db.Model(&book.Author).Association("Followers").Find(&followers)
+	bookmarkers := []models.User{}
+	db.Where("id IN (?)", db.Model(&models.Bookmark{}).Select("user_id").Where("book_id = ?", book.ID)).Find(&bookmarkers)
 
-	tx.Commit()
-	return chapter
+	text := fmt.Sprintf("%s published a new chapter of %s: %s", book.Author.Username, book.Title, chapter.Title)
+	notified := map[uuid.UUID]bool{book.AuthorID: true}
+	for _, receiver := range append(followers, bookmarkers...) {
+		if notified[receiver.ID] {
+			continue
+		}
+		notified[receiver.ID] = true
+		NotificationManager{}.Create(db, &book.Author, receiver, choices.NT_NEW_CHAPTER, text, &book, nil, nil)
+	}
+}
+
+// Publishes the scheduled chapters whose time has come and returns them
+func (c ChapterManager) PublishDue(db *gorm.DB) []models.Chapter {
+	due := c.ModelList
+	db.Where("status = ? AND publish_at <= ?", choices.CS_SCHEDULED, time.Now()).Order("publish_at ASC").Find(&due)
+	published := c.ModelList
+	for _, chapter := range due {
+		// Another run may have published it already
+		result := db.Model(&chapter).Where("status = ?", choices.CS_SCHEDULED).UpdateColumns(map[string]interface{}{
+			"status": choices.CS_PUBLISHED, "updated_at": time.Now(),
+		})
+		if result.RowsAffected == 0 {
+			continue
+		}
+		chapter.Status = choices.CS_PUBLISHED
+		models.UpdateBookSearchVectors(db, "id = ?", chapter.BookID)
+		c.published(db, chapter, true)
+		published = append(published, chapter)
+	}
+	return published
 }
 
 func (c ChapterManager) DeleteChapterWithAllRelations(db *gorm.DB, chapterID uuid.UUID) error {
@@ -905,16 +1023,45 @@ func (c ChapterManager) DeleteChapterWithSQL(db *gorm.DB, chapterID uuid.UUID) e
             return fmt.Errorf("failed to delete comments: %w", err)
         }
 
-        // Step 3: Delete paragraphs
+        // Step 3: Delete paragraphs and revisions
         if err := tx.Exec("DELETE FROM paragraphs WHERE chapter_id = $1", chapterID).Error; err != nil {
             return fmt.Errorf("failed to delete paragraphs: %w", err)
         }
+        if err := tx.Exec("DELETE FROM chapter_revisions WHERE chapter_id = $1", chapterID).Error; err != nil {
+            return fmt.Errorf("failed to delete chapter revisions: %w", err)
+        }
 
-        // Step 4: Delete chapter
-        if err := tx.Exec("DELETE FROM chapters WHERE id = $1", chapterID).Error; err != nil {
+        // Step 4: Move readers on this chapter to the start of the next one, or the one before if it was the last.
+        // Their furthest chapter becomes the one before, so funnels don't count them as reaching further than they did.
+        neighbourSQL := `(
+            SELECT other.id FROM chapters other JOIN chapters deleted ON deleted.id = $1
+            WHERE other.book_id = deleted.book_id AND other.id != deleted.id AND other.created_at %s deleted.created_at
+            ORDER BY other.created_at %s LIMIT 1
+        )`
+        nextSQL := fmt.Sprintf(neighbourSQL, ">", "ASC")
+        previousSQL := fmt.Sprintf(neighbourSQL, "<", "DESC")
+        if err := tx.Exec("UPDATE reading_progresses SET chapter_id = coalesce("+nextSQL+", "+previousSQL+"), paragraph_index = 0 WHERE chapter_id = $1", chapterID).Error; err != nil {
+            return fmt.Errorf("failed to move reading progress: %w", err)
+        }
+        if err := tx.Exec("UPDATE reading_progresses SET furthest_chapter_id = coalesce("+previousSQL+", "+nextSQL+") WHERE furthest_chapter_id = $1", chapterID).Error; err != nil {
+            return fmt.Errorf("failed to move reading progress: %w", err)
+        }
+        // It was the book's only chapter
+        if err := tx.Exec("DELETE FROM reading_progresses WHERE chapter_id IS NULL OR furthest_chapter_id IS NULL").Error; err != nil {
+            return fmt.Errorf("failed to delete reading progress: %w", err)
+        }
+
+        // Step 5: Delete chapter
+        var bookID uuid.UUID
+        if err := tx.Raw("DELETE FROM chapters WHERE id = $1 RETURNING book_id", chapterID).Scan(&bookID).Error; err != nil {
             return fmt.Errorf("failed to delete chapter: %w", err)
         }
 
+        // Step 6: Drop the chapter's title from its book's search vector
+        if err := models.UpdateBookSearchVectors(tx, "id = ?", bookID); err != nil {
+            return fmt.Errorf("failed to update book search vector: %w", err)
+        }
+
         return nil
     })
 }
@@ -928,6 +1075,121 @@ func (c ChapterManager) GetParagraph(db *gorm.DB, chapter models.Chapter, index
 	return &paragraph
 }
 
+type BoughtChapterManager struct {
+	Model     models.BoughtChapter
+	ModelList []models.BoughtChapter
+}
+
+func (b BoughtChapterManager) GetByBuyerAndChapter(db *gorm.DB, buyer *models.User, chapter models.Chapter) *models.BoughtChapter {
+	boughtChapter := models.BoughtChapter{BuyerID: buyer.ID, ChapterID: chapter.ID}
+	db.Take(&boughtChapter, boughtChapter)
+	if boughtChapter.ID == uuid.Nil {
+		return nil
+	}
+	return &boughtChapter
+}
+
+// Whether a user has bought a book in full
+func (b BoughtChapterManager) HasBoughtBook(db *gorm.DB, buyer *models.User, bookID uuid.UUID) bool {
+	var count int64
+	db.Model(&models.BoughtBook{}).Where("buyer_id = ? AND book_id = ?", buyer.ID, bookID).Count(&count)
+	return count > 0
+}
+
+func (b BoughtChapterManager) GetBoughtChapterIDs(db *gorm.DB, buyer *models.User, book models.Book) []uuid.UUID {
+	chapterIDs := []uuid.UUID{}
+	db.Model(&b.Model).
+		Joins("JOIN chapters ON chapters.id = bought_chapters.chapter_id").
+		Where("bought_chapters.buyer_id = ? AND chapters.book_id = ?", buyer.ID, book.ID).
+		Pluck("bought_chapters.chapter_id", &chapterIDs)
+	return chapterIDs
+}
+
+// Debits the buyer's coins, records the purchased chapters (and the book when it is bought in full) and credits the author's earnings in a single transaction.
+func (b BoughtChapterManager) purchase(db *gorm.DB, buyer *models.User, book models.Book, chapters []models.Chapter, price int, reason choices.WalletReasonChoice, source choices.EarningSourceChoice, coinValue decimal.Decimal) ([]models.BoughtChapter, *utils.ErrorResponse) {
+	boughtChapters := []models.BoughtChapter{}
+	coins := buyer.Coins
+	err := db.Transaction(func(tx *gorm.DB) error {
+		movement := WalletMovement{From: buyer, Currency: choices.CUR_COIN, Amount: price}
+		if errD := (WalletManager{}).Transfer(tx, reason, WalletReference{BookID: &book.ID}, movement); errD != nil {
+			return errD
+		}
+
+		// Spread the price across the chapters so the stored prices add up to what was paid
+		share := price / len(chapters)
+		remainder := price % len(chapters)
+		for i, chapter := range chapters {
+			chapterPrice := share
+			if i == 0 {
+				chapterPrice += remainder
+			}
+			boughtChapters = append(boughtChapters, models.BoughtChapter{BuyerID: buyer.ID, ChapterID: chapter.ID, Price: chapterPrice})
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&boughtChapters)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < int64(len(boughtChapters)) {
+			errD := utils.RequestErr(utils.ERR_ALREADY_BOUGHT, "You have bought this already")
+			return &errD
+		}
+		if reason == choices.WR_BOOK_PURCHASE {
+			boughtBook := models.BoughtBook{BuyerID: buyer.ID, BookID: book.ID, Price: price}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&boughtBook)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				errD := utils.RequestErr(utils.ERR_ALREADY_BOUGHT, "You have bought this book already")
+				return &errD
+			}
+		}
+		return AuthorEarningManager{}.CreditPurchase(tx, book, *buyer, source, price, coinValue)
+	})
+	if err != nil {
+		buyer.Coins = coins // the debit was rolled back
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return nil, errD
+		}
+		errD := utils.ServerErr("Something went wrong while processing your purchase")
+		return nil, &errD
+	}
+	return boughtChapters, nil
+}
+
+func (b BoughtChapterManager) BuyChapter(db *gorm.DB, buyer *models.User, chapter models.Chapter, coinValue decimal.Decimal) (*models.BoughtChapter, *utils.ErrorResponse) {
+	book := chapter.Book
+	boughtChapters, errD := b.purchase(db, buyer, book, []models.Chapter{chapter}, book.ChapterPrice, choices.WR_CHAPTER_PURCHASE, choices.ES_CHAPTER_PURCHASE, coinValue)
+	if errD != nil {
+		return nil, errD
+	}
+	return &boughtChapters[0], nil
+}
+
+// Buys every chapter of the book the buyer doesn't own yet at the book's full price, along with those that come out later.
+func (b BoughtChapterManager) BuyBook(db *gorm.DB, buyer *models.User, book models.Book, coinValue decimal.Decimal) ([]models.BoughtChapter, *utils.ErrorResponse) {
+	if b.HasBoughtBook(db, buyer, book.ID) {
+		errD := utils.RequestErr(utils.ERR_ALREADY_BOUGHT, "You have bought this book already")
+		return nil, &errD
+	}
+	boughtChapterIDs := b.GetBoughtChapterIDs(db, buyer, book)
+	owned := make(map[uuid.UUID]bool, len(boughtChapterIDs))
+	for _, id := range boughtChapterIDs {
+		owned[id] = true
+	}
+	chaptersToBuy := []models.Chapter{}
+	for _, chapter := range book.Chapters {
+		if !owned[chapter.ID] {
+			chaptersToBuy = append(chaptersToBuy, chapter)
+		}
+	}
+	if len(chaptersToBuy) == 0 {
+		errD := utils.RequestErr(utils.ERR_ALREADY_BOUGHT, "You have bought all the chapters of this book already")
+		return nil, &errD
+	}
+	return b.purchase(db, buyer, book, chaptersToBuy, *book.FullPrice, choices.WR_BOOK_PURCHASE, choices.ES_BOOK_PURCHASE, coinValue)
+}
+
 type TagManager struct {
 	Model     models.Tag
 	ModelList []models.Tag
@@ -1171,10 +1433,27 @@ func (v VoteManager) GetByUserAndBook(db *gorm.DB, user *models.User, book *mode
 	return &vote
 }
 
-func (v VoteManager) Create(db *gorm.DB, user *models.User, book *models.Book) models.Vote {
+// Records a vote and spends a lantern from the voter's wallet for it
+func (v VoteManager) Create(db *gorm.DB, user *models.User, book *models.Book) (*models.Vote, *utils.ErrorResponse) {
 	vote := models.Vote{UserID: user.ID, User: *user, Book: *book, BookID: book.ID}
-	db.Create(&vote)
-	return vote
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&vote).Error; err != nil {
+			return err
+		}
+		movement := WalletMovement{From: user, Currency: choices.CUR_LANTERN, Amount: 1}
+		if errD := (WalletManager{}).Transfer(tx, choices.WR_VOTE, WalletReference{VoteID: &vote.ID, BookID: &book.ID}, movement); errD != nil {
+			return errD
+		}
+		return nil
+	})
+	if err != nil {
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return nil, errD
+		}
+		errD := utils.ServerErr("Something went wrong while voting")
+		return nil, &errD
+	}
+	return &vote, nil
 }
 
 type BookmarkManager struct {
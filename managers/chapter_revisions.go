@@ -0,0 +1,198 @@
+package managers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// How alike two paragraphs must be (see paragraphSimilarity) to count as one paragraph that was edited
+const paragraphMatchThreshold = 0.5
+
+type ChapterRevisionManager struct {
+	Model     models.ChapterRevision
+	ModelList []models.ChapterRevision
+}
+
+// Saves a chapter as it is now as its next revision
+func (c ChapterRevisionManager) Snapshot(db *gorm.DB, chapter models.Chapter, editor *models.User) (models.ChapterRevision, error) {
+	var latest uint
+	db.Model(&c.Model).Where("chapter_id = ?", chapter.ID).Select("coalesce(max(number), 0)").Scan(&latest)
+	paragraphs := make([]string, 0, len(chapter.Paragraphs))
+	for _, paragraph := range sortedParagraphs(chapter.Paragraphs) {
+		paragraphs = append(paragraphs, paragraph.Text)
+	}
+	revision := models.ChapterRevision{
+		ChapterID: chapter.ID, Number: latest + 1,
+		Title: chapter.Title, Paragraphs: paragraphs, IsLast: chapter.IsLast,
+	}
+	if editor != nil {
+		revision.EditorID = &editor.ID
+	}
+	err := db.Create(&revision).Error
+	return revision, err
+}
+
+// Returns the revisions of a chapter, the latest first
+func (c ChapterRevisionManager) GetAllByChapter(db *gorm.DB, chapter models.Chapter) []models.ChapterRevision {
+	revisions := c.ModelList
+	db.Preload("Editor").Where("chapter_id = ?", chapter.ID).Order("number DESC").Find(&revisions)
+	return revisions
+}
+
+// Returns a revision of a chapter by its number, or its latest one when the number is 0
+func (c ChapterRevisionManager) GetByNumber(db *gorm.DB, chapter models.Chapter, number uint) (*models.ChapterRevision, *utils.ErrorResponse) {
+	revision := c.Model
+	query := db.Preload("Editor").Where("chapter_id = ?", chapter.ID)
+	if number > 0 {
+		query = query.Where("number = ?", number)
+	}
+	query.Order("number DESC").Take(&revision)
+	if revision.ID == uuid.Nil {
+		errD := utils.NotFoundErr("Chapter has no revision with that number")
+		return nil, &errD
+	}
+	return &revision, nil
+}
+
+// Compares the paragraphs of two revisions in the order of the newer one, with removed paragraphs
+// where they used to be. Paragraphs are matched by content so moved ones show as such rather than as removed and added.
+func (c ChapterRevisionManager) Diff(from models.ChapterRevision, to models.ChapterRevision) []schemas.ParagraphDiffSchema {
+	diff := []schemas.ParagraphDiffSchema{}
+	matches := matchParagraphs(from.Paragraphs, to.Paragraphs)
+	matched := make(map[int]bool, len(matches))
+	for _, old := range matches {
+		matched[old] = true
+	}
+
+	nextRemoved := 0
+	addRemovedBefore := func(end int) {
+		for ; nextRemoved < end; nextRemoved++ {
+			if matched[nextRemoved] {
+				continue
+			}
+			oldIndex := uint(nextRemoved + 1)
+			oldText := from.Paragraphs[nextRemoved]
+			diff = append(diff, schemas.ParagraphDiffSchema{Change: choices.PC_REMOVED, OldIndex: &oldIndex, OldText: &oldText})
+		}
+	}
+	for i := range to.Paragraphs {
+		newIndex := uint(i + 1)
+		newText := to.Paragraphs[i]
+		old, ok := matches[i]
+		if !ok {
+			diff = append(diff, schemas.ParagraphDiffSchema{Change: choices.PC_ADDED, NewIndex: &newIndex, NewText: &newText})
+			continue
+		}
+		addRemovedBefore(old)
+		oldIndex := uint(old + 1)
+		oldText := from.Paragraphs[old]
+		change := choices.PC_UNCHANGED
+		if oldText != newText {
+			change = choices.PC_EDITED
+		}
+		diff = append(diff, schemas.ParagraphDiffSchema{Change: change, OldIndex: &oldIndex, NewIndex: &newIndex, OldText: &oldText, NewText: &newText})
+	}
+	addRemovedBefore(len(from.Paragraphs))
+	return diff
+}
+
+// Returns a copy of paragraphs in the order of their indexes
+func sortedParagraphs(paragraphs []models.Paragraph) []models.Paragraph {
+	sorted := append([]models.Paragraph{}, paragraphs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+	return sorted
+}
+
+// How alike two paragraphs are from 0 to 1, as the share of words they have in common
+func paragraphSimilarity(a []string, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	counts := make(map[string]int, len(a))
+	for _, word := range a {
+		counts[word]++
+	}
+	common := 0
+	for _, word := range b {
+		if counts[word] > 0 {
+			counts[word]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// Pairs old paragraphs with the new ones they became by content rather than position, so a paragraph is still
+// itself after being moved or edited. Identical texts pair first, then the most alike ones that are alike enough.
+// Returns the old position of each new paragraph that has one.
+func matchParagraphs(oldTexts []string, newTexts []string) map[int]int {
+	matches := make(map[int]int)
+	oldMatched := make([]bool, len(oldTexts))
+
+	unmatchedByText := make(map[string][]int)
+	for i, text := range oldTexts {
+		unmatchedByText[text] = append(unmatchedByText[text], i)
+	}
+	for j, text := range newTexts {
+		if olds := unmatchedByText[text]; len(olds) > 0 {
+			matches[j] = olds[0]
+			oldMatched[olds[0]] = true
+			unmatchedByText[text] = olds[1:]
+		}
+	}
+
+	words := func(text string) []string { return strings.Fields(strings.ToLower(text)) }
+	oldWords := make([][]string, len(oldTexts))
+	for i, text := range oldTexts {
+		if !oldMatched[i] {
+			oldWords[i] = words(text)
+		}
+	}
+	type pair struct {
+		old, new int
+		score    float64
+	}
+	pairs := []pair{}
+	for j, text := range newTexts {
+		if _, ok := matches[j]; ok {
+			continue
+		}
+		newWords := words(text)
+		for i := range oldTexts {
+			if oldMatched[i] {
+				continue
+			}
+			if score := paragraphSimilarity(oldWords[i], newWords); score >= paragraphMatchThreshold {
+				pairs = append(pairs, pair{old: i, new: j, score: score})
+			}
+		}
+	}
+	// The most alike first, and of equally alike ones those that moved the least
+	distance := func(p pair) int {
+		if p.old > p.new {
+			return p.old - p.new
+		}
+		return p.new - p.old
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		if pairs[a].score != pairs[b].score {
+			return pairs[a].score > pairs[b].score
+		}
+		return distance(pairs[a]) < distance(pairs[b])
+	})
+	for _, p := range pairs {
+		if _, ok := matches[p.new]; ok || oldMatched[p.old] {
+			continue
+		}
+		matches[p.new] = p.old
+		oldMatched[p.old] = true
+	}
+	return matches
+}
@@ -0,0 +1,320 @@
+package managers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	// How long the download link of an export works
+	dataExportLifetime = 7 * 24 * time.Hour
+	// Users can ask for one export in this time
+	dataExportInterval = 24 * time.Hour
+)
+
+type DataExportManager struct {
+	Model     models.DataExport
+	ModelList []models.DataExport
+}
+
+// Returns the export a user asked for last if it was recent enough that they can't ask for another yet
+func (d DataExportManager) GetRecent(db *gorm.DB, user models.User) *models.DataExport {
+	export := d.Model
+	db.Omit("archive").Where("user_id = ? AND created_at > ? AND status != ?", user.ID, time.Now().Add(-dataExportInterval), choices.DES_FAILED).
+		Order("created_at DESC").Take(&export)
+	if export.ID == uuid.Nil {
+		return nil
+	}
+	return &export
+}
+
+func (d DataExportManager) Create(db *gorm.DB, user models.User) models.DataExport {
+	export := models.DataExport{UserID: user.ID, Status: choices.DES_PENDING}
+	db.Create(&export)
+	return export
+}
+
+func (d DataExportManager) GetByID(db *gorm.DB, id uuid.UUID) *models.DataExport {
+	export := d.Model
+	db.Joins("User").Omit("archive").Take(&export, id)
+	if export.ID == uuid.Nil {
+		return nil
+	}
+	return &export
+}
+
+// Returns a ready export by the token of its download link
+func (d DataExportManager) GetByToken(db *gorm.DB, token string) *models.DataExport {
+	export := d.Model
+	db.Where("token = ? AND status = ?", hashExportToken(token), choices.DES_READY).Take(&export)
+	if export.ID == uuid.Nil || export.IsExpired() {
+		return nil
+	}
+	return &export
+}
+
+// Stores the archive of an export and returns the token for downloading it
+func (d DataExportManager) MarkReady(db *gorm.DB, export *models.DataExport, archive []byte) string {
+	randomBytes := make([]byte, 32)
+	rand.Read(randomBytes)
+	token := hex.EncodeToString(randomBytes)
+	hashed := hashExportToken(token)
+	expiresAt := time.Now().Add(dataExportLifetime)
+	export.Status = choices.DES_READY
+	export.Token = &hashed
+	export.Archive = archive
+	export.ExpiresAt = &expiresAt
+	db.Model(export).Updates(map[string]interface{}{"status": export.Status, "token": hashed, "archive": archive, "expires_at": expiresAt})
+	return token
+}
+
+func (d DataExportManager) MarkFailed(db *gorm.DB, export *models.DataExport) {
+	export.Status = choices.DES_FAILED
+	db.Model(export).Update("status", export.Status)
+}
+
+// Removes the archives of exports whose links have expired
+func (d DataExportManager) DeleteExpired(db *gorm.DB) int64 {
+	return db.Where("expires_at < ?", time.Now()).Delete(&d.Model).RowsAffected
+}
+
+func hashExportToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// What goes into an export. Each field is a json file of the archive.
+type exportProfile struct {
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	Name           *string    `json:"name"`
+	Bio            *string    `json:"bio"`
+	Avatar         string     `json:"avatar"`
+	AccountType    string     `json:"account_type"`
+	SocialLogin    bool       `json:"social_login"`
+	Coins          int        `json:"coins"`
+	Lanterns       int        `json:"lanterns"`
+	CurrentPlan    *string    `json:"current_plan"`
+	PlanExpiresAt  *time.Time `json:"plan_expires_at"`
+	Followers      []string   `json:"followers"`
+	Followings     []string   `json:"followings"`
+	LinkedAccounts []string   `json:"linked_accounts"`
+	JoinedAt       time.Time  `json:"joined_at"`
+}
+
+type exportChapter struct {
+	Title      string    `json:"title"`
+	Slug       string    `json:"slug"`
+	Paragraphs []string  `json:"paragraphs"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type exportBook struct {
+	Title      string                 `json:"title"`
+	Slug       string                 `json:"slug"`
+	Blurb      string                 `json:"blurb"`
+	CoverImage string                 `json:"cover_image"`
+	Completed  bool                   `json:"completed"`
+	Contract   map[string]interface{} `json:"contract"`
+	Chapters   []exportChapter        `json:"chapters"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type exportComment struct {
+	Text      string               `json:"text"`
+	Rating    choices.RatingChoice `json:"rating,omitempty"`
+	Book      *string              `json:"book,omitempty"`      // for reviews
+	Paragraph *string              `json:"paragraph,omitempty"` // the chapter of a paragraph comment
+	IsReply   bool                 `json:"is_reply"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+type exportBookAction struct {
+	Book      string    `json:"book"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportTransaction struct {
+	Reference  string          `json:"reference"`
+	Purpose    string          `json:"purpose"`
+	Type       string          `json:"payment_type"`
+	Status     string          `json:"status"`
+	Amount     decimal.Decimal `json:"amount"`
+	Quantity   int             `json:"quantity"`
+	BonusCoins int             `json:"bonus_coins"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type exportNotification struct {
+	Type      string    `json:"type"`
+	Text      string    `json:"text"`
+	IsRead    bool      `json:"is_read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportSentGift struct {
+	Gift      string    `json:"gift"`
+	Receiver  string    `json:"receiver"`
+	Claimed   bool      `json:"claimed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Collects a user's data into a zip of json files
+func (d DataExportManager) BuildArchive(db *gorm.DB, user models.User) ([]byte, error) {
+	db.Preload("Followers").Preload("Followings").Take(&user, user.ID)
+	profile := exportProfile{
+		Username: user.Username, Email: user.Email, Name: user.Name, Bio: user.Bio, Avatar: user.Avatar,
+		AccountType: string(user.AccountType), SocialLogin: user.SocialLogin, Coins: user.Coins, Lanterns: user.Lanterns,
+		PlanExpiresAt: user.SubscriptionExpiry, Followers: []string{}, Followings: []string{}, LinkedAccounts: []string{},
+		JoinedAt: user.CreatedAt,
+	}
+	if user.CurrentPlan != nil {
+		plan := string(*user.CurrentPlan)
+		profile.CurrentPlan = &plan
+	}
+	for _, follower := range user.Followers {
+		profile.Followers = append(profile.Followers, follower.Username)
+	}
+	for _, following := range user.Followings {
+		profile.Followings = append(profile.Followings, following.Username)
+	}
+	for _, account := range (SocialAccountManager{}).GetByUser(db, user) {
+		profile.LinkedAccounts = append(profile.LinkedAccounts, string(account.Provider))
+	}
+
+	books := []models.Book{}
+	db.Where("author_id = ?", user.ID).Preload("Chapters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Preload("Chapters.Paragraphs", func(db *gorm.DB) *gorm.DB {
+		return db.Order(`"index" ASC`)
+	}).Order("created_at ASC").Find(&books)
+	exportBooks := []exportBook{}
+	for _, book := range books {
+		exportBooks = append(exportBooks, exportBookData(book))
+	}
+
+	comments := []models.Comment{}
+	db.Where("user_id = ?", user.ID).Joins("Book").Preload("Paragraph.Chapter").Order("comments.created_at ASC").Find(&comments)
+	exportComments := []exportComment{}
+	for _, comment := range comments {
+		exportComments = append(exportComments, exportCommentData(comment))
+	}
+
+	votes := []models.Vote{}
+	db.Where("user_id = ?", user.ID).Joins("Book").Order("votes.created_at ASC").Find(&votes)
+	exportVotes := []exportBookAction{}
+	for _, vote := range votes {
+		exportVotes = append(exportVotes, exportBookAction{Book: vote.Book.Slug, CreatedAt: vote.CreatedAt})
+	}
+
+	bookmarks := []models.Bookmark{}
+	db.Where("user_id = ?", user.ID).Joins("Book").Order("bookmarks.created_at ASC").Find(&bookmarks)
+	exportBookmarks := []exportBookAction{}
+	for _, bookmark := range bookmarks {
+		exportBookmarks = append(exportBookmarks, exportBookAction{Book: bookmark.Book.Slug, CreatedAt: bookmark.CreatedAt})
+	}
+
+	transactions := []models.Transaction{}
+	db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&transactions)
+	exportTransactions := []exportTransaction{}
+	for _, transaction := range transactions {
+		exportTransactions = append(exportTransactions, exportTransaction{
+			Reference: transaction.Reference, Purpose: string(transaction.PaymentPurpose), Type: string(transaction.PaymentType),
+			Status: string(transaction.PaymentStatus), Amount: transaction.Amount, Quantity: transaction.Quantity,
+			BonusCoins: transaction.BonusCoins, CreatedAt: transaction.CreatedAt,
+		})
+	}
+
+	notifications := []models.Notification{}
+	db.Where("receiver_id = ?", user.ID).Order("created_at ASC").Find(&notifications)
+	exportNotifications := []exportNotification{}
+	for _, notification := range notifications {
+		exportNotifications = append(exportNotifications, exportNotification{
+			Type: string(notification.Ntype), Text: notification.Text, IsRead: notification.IsRead, CreatedAt: notification.CreatedAt,
+		})
+	}
+
+	sentGifts := []models.SentGift{}
+	db.Where("sender_id = ?", user.ID).Joins("Gift").Joins("Receiver").Order("sent_gifts.created_at ASC").Find(&sentGifts)
+	exportSentGifts := []exportSentGift{}
+	for _, sentGift := range sentGifts {
+		exportSentGifts = append(exportSentGifts, exportSentGift{
+			Gift: sentGift.Gift.Name, Receiver: sentGift.Receiver.Username, Claimed: sentGift.Claimed, CreatedAt: sentGift.CreatedAt,
+		})
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"books.json", exportBooks},
+		{"comments.json", exportComments},
+		{"votes.json", exportVotes},
+		{"bookmarks.json", exportBookmarks},
+		{"transactions.json", exportTransactions},
+		{"notifications.json", exportNotifications},
+		{"sent-gifts.json", exportSentGifts},
+	}
+	buffer := new(bytes.Buffer)
+	archive := zip.NewWriter(buffer)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func exportBookData(book models.Book) exportBook {
+	data := exportBook{
+		Title: book.Title, Slug: book.Slug, Blurb: book.Blurb, CoverImage: book.CoverImage,
+		Completed: book.Completed, Chapters: []exportChapter{}, CreatedAt: book.CreatedAt,
+		Contract: map[string]interface{}{
+			"full_name": book.FullName, "email": book.Email, "pen_name": book.PenName, "age": book.Age,
+			"country": book.Country, "address": book.Address, "city": book.City, "state": book.State,
+			"postal_code": book.PostalCode, "telephone_number": book.TelephoneNumber, "id_type": book.IDType,
+			"id_front_image": book.IDFrontImage, "id_back_image": book.IDBackImage,
+			"intended_contract": book.IntendedContract, "status": book.ContractStatus,
+		},
+	}
+	for _, chapter := range book.Chapters {
+		paragraphs := []string{}
+		for _, paragraph := range chapter.Paragraphs {
+			paragraphs = append(paragraphs, paragraph.Text)
+		}
+		data.Chapters = append(data.Chapters, exportChapter{Title: chapter.Title, Slug: chapter.Slug, Paragraphs: paragraphs, CreatedAt: chapter.CreatedAt})
+	}
+	return data
+}
+
+func exportCommentData(comment models.Comment) exportComment {
+	data := exportComment{Text: comment.Text, Rating: comment.Rating, IsReply: comment.ParentID != nil, CreatedAt: comment.CreatedAt}
+	if comment.Book != nil {
+		data.Book = &comment.Book.Slug
+	}
+	if comment.Paragraph != nil {
+		data.Paragraph = &comment.Paragraph.Chapter.Slug
+	}
+	return data
+}
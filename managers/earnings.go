@@ -0,0 +1,219 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuthorEarningManager struct {
+	Model     models.AuthorEarning
+	ModelList []models.AuthorEarning
+}
+
+func (a AuthorEarningManager) GetAll(db *gorm.DB, authorID *uuid.UUID, source *choices.EarningSourceChoice) []models.AuthorEarning {
+	earnings := a.ModelList
+	query := db.Joins("Author").Joins("Book")
+	if authorID != nil {
+		query = query.Where("author_earnings.author_id = ?", *authorID)
+	}
+	if source != nil {
+		query = query.Where("author_earnings.source = ?", *source)
+	}
+	query.Order("author_earnings.created_at DESC").Find(&earnings)
+	return earnings
+}
+
+func (a AuthorEarningManager) GetBalance(db *gorm.DB, authorID uuid.UUID) decimal.Decimal {
+	var balance decimal.Decimal
+	db.Model(&a.Model).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("author_id = ?", authorID).
+		Scan(&balance)
+	return balance
+}
+
+// Credits the author with the lantern value of a gift. A gift is only ever credited once.
+func (a AuthorEarningManager) CreditGift(db *gorm.DB, sentGift models.SentGift, lanternValue decimal.Decimal) {
+	earning := models.AuthorEarning{
+		AuthorID:    sentGift.ReceiverID,
+		Source:      choices.ES_GIFT,
+		Amount:      lanternValue.Mul(decimal.NewFromInt(int64(sentGift.Gift.Lanterns))),
+		SentGiftID:  &sentGift.ID,
+		Description: fmt.Sprintf("%s gift from %s", sentGift.Gift.Name, sentGift.Sender.Username),
+	}
+	db.Clauses(clause.OnConflict{DoNothing: true}).Create(&earning)
+}
+
+// Credits the author with the value of the coins spent on his/her book
+func (a AuthorEarningManager) CreditPurchase(db *gorm.DB, book models.Book, buyer models.User, source choices.EarningSourceChoice, coins int, coinValue decimal.Decimal) error {
+	earning := models.AuthorEarning{
+		AuthorID:    book.AuthorID,
+		Source:      source,
+		Amount:      coinValue.Mul(decimal.NewFromInt(int64(coins))),
+		BookID:      &book.ID,
+		Description: fmt.Sprintf("%s spent %d coins on %s", buyer.Username, coins, book.Title),
+	}
+	return db.Create(&earning).Error
+}
+
+type AuthorReadsCount struct {
+	AuthorID uuid.UUID
+	Reads    int64
+}
+
+// Shares a percentage of the subscription revenue made within a period among authors,
+// weighted by how many readers (excluding the authors themselves) were active on their books.
+// It is safe to run more than once for the same period.
+func (a AuthorEarningManager) DistributeSubscriptionRevenue(db *gorm.DB, start time.Time, end time.Time, sharePercent decimal.Decimal) []models.AuthorEarning {
+	earnings := []models.AuthorEarning{}
+	var revenue decimal.Decimal
+	db.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(subscription_plans.amount * transactions.quantity), 0)").
+		Joins("JOIN subscription_plans ON subscription_plans.id = transactions.subscription_plan_id").
+		Where("transactions.payment_status = ?", choices.PSSUCCEEDED).
+		Where("transactions.created_at >= ? AND transactions.created_at < ?", start, end).
+		Scan(&revenue)
+	pool := revenue.Mul(sharePercent).Div(decimal.NewFromInt(100))
+	if !pool.IsPositive() {
+		return earnings
+	}
+
+	readsCounts := []AuthorReadsCount{}
+	db.Model(&models.BookRead{}).
+		Select("books.author_id, COUNT(book_reads.id) AS reads").
+		Joins("JOIN books ON books.id = book_reads.book_id").
+		Where("book_reads.user_id <> books.author_id").
+		Where("(book_reads.created_at >= ? AND book_reads.created_at < ?) OR (book_reads.updated_at >= ? AND book_reads.updated_at < ?)", start, end, start, end).
+		Group("books.author_id").
+		Scan(&readsCounts)
+
+	var totalReads int64
+	for _, count := range readsCounts {
+		totalReads += count.Reads
+	}
+	if totalReads == 0 {
+		return earnings
+	}
+
+	period := start.Format("2006-01")
+	for _, count := range readsCounts {
+		amount := pool.Mul(decimal.NewFromInt(count.Reads)).Div(decimal.NewFromInt(totalReads)).RoundDown(2)
+		if !amount.IsPositive() {
+			continue
+		}
+		earnings = append(earnings, models.AuthorEarning{
+			AuthorID:    count.AuthorID,
+			Source:      choices.ES_SUBSCRIPTION_SHARE,
+			Amount:      amount,
+			Period:      &period,
+			Description: fmt.Sprintf("Subscription revenue share for %s (%d reads)", period, count.Reads),
+		})
+	}
+	if len(earnings) > 0 {
+		db.Clauses(clause.OnConflict{DoNothing: true}).Create(&earnings)
+	}
+	return earnings
+}
+
+type PayoutManager struct {
+	Model     models.Payout
+	ModelList []models.Payout
+}
+
+func (p PayoutManager) GetAll(db *gorm.DB, authorID *uuid.UUID, status *choices.PayoutStatusChoice) []models.Payout {
+	payouts := p.ModelList
+	query := db.Joins("Author")
+	if authorID != nil {
+		query = query.Where("payouts.author_id = ?", *authorID)
+	}
+	if status != nil {
+		query = query.Where("payouts.status = ?", *status)
+	}
+	query.Order("payouts.created_at DESC").Find(&payouts)
+	return payouts
+}
+
+func (p PayoutManager) GetByID(db *gorm.DB, id uuid.UUID) *models.Payout {
+	payout := models.Payout{}
+	db.Joins("Author").Take(&payout, "payouts.id = ?", id)
+	if payout.ID == uuid.Nil {
+		return nil
+	}
+	return &payout
+}
+
+// Requests a payout and reserves the amount from the author's earnings balance
+func (p PayoutManager) Request(db *gorm.DB, author models.User, amount decimal.Decimal) (*models.Payout, *utils.ErrorResponse) {
+	payout := models.Payout{AuthorID: author.ID, Author: author, Amount: amount, Status: choices.POS_REQUESTED}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the author so that concurrent requests can't reserve the same balance
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&models.User{}, "id = ?", author.ID)
+		balance := AuthorEarningManager{}.GetBalance(tx, author.ID)
+		if balance.LessThan(amount) {
+			errD := utils.RequestErr(utils.ERR_INSUFFICIENT_EARNINGS, "Your earnings balance is insufficient for that payout")
+			return &errD
+		}
+		if err := tx.Create(&payout).Error; err != nil {
+			return err
+		}
+		earning := models.AuthorEarning{
+			AuthorID: author.ID, Source: choices.ES_PAYOUT, Amount: amount.Neg(),
+			PayoutID: &payout.ID, Description: "Payout requested",
+		}
+		return tx.Create(&earning).Error
+	})
+	if err != nil {
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return nil, errD
+		}
+		errD := utils.ServerErr("Something went wrong while requesting your payout")
+		return nil, &errD
+	}
+	return &payout, nil
+}
+
+// Moves a payout through its workflow. Declined payouts are returned to the author's earnings balance.
+func (p PayoutManager) UpdateStatus(db *gorm.DB, payout *models.Payout, status choices.PayoutStatusChoice, note *string, reference *string) *utils.ErrorResponse {
+	if !payout.Status.CanMoveTo(status) {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("Payout can't move from %s to %s", payout.Status, status))
+		return &errD
+	}
+	now := time.Now()
+	payout.Status = status
+	if note != nil {
+		payout.Note = note
+	}
+	switch status {
+	case choices.POS_APPROVED:
+		payout.ApprovedAt = &now
+	case choices.POS_PAID:
+		payout.PaidAt = &now
+		payout.Reference = reference
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(payout).Error; err != nil {
+			return err
+		}
+		if status == choices.POS_DECLINED {
+			earning := models.AuthorEarning{
+				AuthorID: payout.AuthorID, Source: choices.ES_PAYOUT_REVERSAL, Amount: payout.Amount,
+				PayoutID: &payout.ID, Description: "Payout declined",
+			}
+			return tx.Create(&earning).Error
+		}
+		return nil
+	})
+	if err != nil {
+		errD := utils.ServerErr("Something went wrong while updating the payout")
+		return &errD
+	}
+	return nil
+}
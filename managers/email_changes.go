@@ -0,0 +1,122 @@
+package managers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// How long the old address can revert a change for
+const emailRevertWindow = 7 * 24 * time.Hour
+
+type EmailChangeManager struct {
+	Model     models.EmailChange
+	ModelList []models.EmailChange
+}
+
+// Returns the change a user requested last and hasn't confirmed yet
+func (e EmailChangeManager) GetPending(db *gorm.DB, user models.User) *models.EmailChange {
+	change := e.Model
+	db.Where("user_id = ? AND confirmed_at IS NULL", user.ID).Order("created_at DESC").Take(&change)
+	if change.ID == uuid.Nil {
+		return nil
+	}
+	return &change
+}
+
+// Starts a change of a user's email, replacing one they haven't confirmed
+func (e EmailChangeManager) Create(db *gorm.DB, user models.User, newEmail string) models.EmailChange {
+	db.Where("user_id = ? AND confirmed_at IS NULL", user.ID).Delete(&e.Model)
+	otpExpiry := time.Now().Add(time.Duration(config.GetConfig().EmailOtpExpireSeconds) * time.Second)
+	change := models.EmailChange{
+		UserID: user.ID, OldEmail: user.Email, NewEmail: newEmail,
+		Otp: utils.GetRandomInt(6), OtpExpiry: otpExpiry,
+	}
+	db.Create(&change)
+	return change
+}
+
+// Gives the user the new address of a change and signs them out everywhere. The token for the revert link
+// to the old address is returned.
+func (e EmailChangeManager) Confirm(db *gorm.DB, change *models.EmailChange, user *models.User) (*string, *utils.ErrorResponse) {
+	if existingUser := (UserManager{}).GetByEmail(db, change.NewEmail); existingUser != nil {
+		errData := utils.ValidationErr("email", "Email already taken!")
+		return nil, &errData
+	}
+	now := time.Now()
+	revertToken := generateRevertToken()
+	hashed := hashRevertToken(revertToken)
+	revertExpiry := now.Add(emailRevertWindow)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{"email": change.NewEmail, "is_email_verified": true}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.AuthToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(change).Updates(map[string]interface{}{"confirmed_at": now, "revert_token": hashed, "revert_expiry": revertExpiry}).Error
+	})
+	if err != nil {
+		errData := utils.ServerErr("Something went wrong while changing your email")
+		return nil, &errData
+	}
+	user.Email = change.NewEmail
+	user.IsEmailVerified = true
+	change.ConfirmedAt = &now
+	change.RevertToken = &hashed
+	change.RevertExpiry = &revertExpiry
+	return &revertToken, nil
+}
+
+// Gives a user back the old address of a change with the token from its revert link, and signs them out
+// everywhere. Every other change of the user is dropped so that whoever made this one can't revert it back.
+func (e EmailChangeManager) Revert(db *gorm.DB, token string) (*models.EmailChange, *utils.ErrorResponse) {
+	change := e.Model
+	db.Joins("User").Take(&change, "email_changes.revert_token = ? AND email_changes.reverted_at IS NULL AND email_changes.revert_expiry > ?", hashRevertToken(token), time.Now())
+	if change.ID == uuid.Nil {
+		errData := utils.NotFoundErr("Invalid or expired revert token")
+		return nil, &errData
+	}
+	if existingUser := (UserManager{}).GetByEmail(db, change.OldEmail); existingUser != nil && existingUser.ID != change.UserID {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Your old email is used by another account now")
+		return nil, &errData
+	}
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&change.User).Updates(map[string]interface{}{"email": change.OldEmail, "is_email_verified": true}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", change.UserID).Delete(&models.AuthToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND id != ?", change.UserID, change.ID).Delete(&e.Model).Error; err != nil {
+			return err
+		}
+		return tx.Model(&change).Update("reverted_at", now).Error
+	})
+	if err != nil {
+		errData := utils.ServerErr("Something went wrong while reverting your email")
+		return nil, &errData
+	}
+	change.User.Email = change.OldEmail
+	change.RevertedAt = &now
+	return &change, nil
+}
+
+func generateRevertToken() string {
+	randomBytes := make([]byte, 32)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+func hashRevertToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,9 @@ package managers
 
 import (
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/models/scopes"
+	"github.com/LitPad/backend/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -51,17 +53,35 @@ func (g SentGiftManager) GetByWriterAndID(db *gorm.DB, writer models.User, id uu
 	return &sentGift
 }
 
-func (s SentGiftManager) Create(db *gorm.DB, gift models.Gift, sender models.User, receiver models.User) models.SentGift {
+// Records a sent gift and moves its price out of the sender's coins (the sender is rewarded with the gift's lanterns)
+func (s SentGiftManager) Create(db *gorm.DB, gift models.Gift, sender *models.User, receiver models.User) (*models.SentGift, *utils.ErrorResponse) {
 	sentGift := models.SentGift{
-		SenderID: sender.ID, Sender: sender,
+		SenderID: sender.ID, Sender: *sender,
 		ReceiverID: receiver.ID, Receiver: receiver,
 		GiftID: gift.ID, Gift: gift,
 	}
-	db.Create(&sentGift)
-	sender.Coins -= gift.Price
-	sender.Lanterns += gift.Lanterns
-	db.Save(&sender)
-	return sentGift
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sentGift).Error; err != nil {
+			return err
+		}
+		errD := WalletManager{}.Transfer(tx, choices.WR_GIFT, WalletReference{SentGiftID: &sentGift.ID},
+			WalletMovement{From: sender, Currency: choices.CUR_COIN, Amount: gift.Price},
+			WalletMovement{To: sender, Currency: choices.CUR_LANTERN, Amount: gift.Lanterns},
+		)
+		if errD != nil {
+			return errD
+		}
+		return nil
+	})
+	if err != nil {
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return nil, errD
+		}
+		errD := utils.ServerErr("Something went wrong while sending the gift")
+		return nil, &errD
+	}
+	sentGift.Sender = *sender
+	return &sentGift, nil
 }
 
 func (s SentGiftManager) Process(db *gorm.DB, gift models.Gift, sender models.User, receiver models.User) models.SentGift{
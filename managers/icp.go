@@ -0,0 +1,179 @@
+package managers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LitPad/backend/icp"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICPWalletManager struct {
+	Model models.ICPWallet
+}
+
+func (i ICPWalletManager) GetByUser(db *gorm.DB, user models.User) *models.ICPWallet {
+	wallet := models.ICPWallet{}
+	db.Take(&wallet, "user_id = ?", user.ID)
+	if wallet.ID == uuid.Nil {
+		return nil
+	}
+	return &wallet
+}
+
+// Returns a user's wallet, storing it first if it was created on the wallet server before wallets were recorded.
+// Nil is returned when the user has no wallet.
+func (i ICPWalletManager) GetOrSync(db *gorm.DB, client icp.Client, user models.User) (*models.ICPWallet, error) {
+	if wallet := i.GetByUser(db, user); wallet != nil {
+		return wallet, nil
+	}
+	serverWallet, err := client.GetWallet(user.Username)
+	if err != nil {
+		if icp.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	wallet := i.Save(db, user, *serverWallet)
+	return &wallet, nil
+}
+
+func (i ICPWalletManager) Save(db *gorm.DB, user models.User, serverWallet icp.Wallet) models.ICPWallet {
+	wallet := models.ICPWallet{UserID: user.ID, AccountID: serverWallet.AccountID, PublicKey: serverWallet.PublicKey}
+	db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"account_id", "public_key", "updated_at"}),
+	}).Create(&wallet)
+	wallet.User = user
+	return wallet
+}
+
+type ICPTransferManager struct {
+	Model     models.ICPTransfer
+	ModelList []models.ICPTransfer
+}
+
+func (i ICPTransferManager) Create(db *gorm.DB, sender models.User, receiver models.User, receiverWallet models.ICPWallet, gift models.Gift, amount decimal.Decimal, usdRate decimal.Decimal) models.ICPTransfer {
+	transfer := models.ICPTransfer{
+		SenderID: sender.ID, ReceiverID: receiver.ID, GiftID: gift.ID,
+		Address: receiverWallet.AccountID, Amount: amount, UsdRate: usdRate,
+	}
+	db.Create(&transfer)
+	transfer.Sender = sender
+	transfer.Receiver = receiver
+	transfer.Gift = gift
+	return transfer
+}
+
+// Returns pending transfers created before a time, oldest first
+func (i ICPTransferManager) GetPending(db *gorm.DB, before time.Time) []models.ICPTransfer {
+	transfers := i.ModelList
+	db.Joins("Sender").Joins("Receiver").Joins("Gift").
+		Where("icp_transfers.status = ? AND icp_transfers.created_at < ?", choices.ITS_PENDING, before).
+		Order("icp_transfers.created_at ASC").Find(&transfers)
+	return transfers
+}
+
+// Sends the gift a transfer paid for. Transfers that aren't pending are left untouched, so it is safe to repeat.
+// The notification for the writer is returned when the gift is sent.
+func (i ICPTransferManager) Confirm(db *gorm.DB, transfer *models.ICPTransfer, reference *string) (*models.Notification, error) {
+	var notification *models.Notification
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the transfer so that the request and the reconciliation job don't both send the gift
+		current := models.ICPTransfer{}
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&current, "id = ?", transfer.ID)
+		if current.Status != choices.ITS_PENDING {
+			transfer.Status = current.Status
+			transfer.SentGiftID = current.SentGiftID
+			return nil
+		}
+
+		// The writer has the ICP already, so there's nothing left to claim
+		sentGift := models.SentGift{SenderID: transfer.SenderID, ReceiverID: transfer.ReceiverID, GiftID: transfer.GiftID, Claimed: true}
+		if err := tx.Create(&sentGift).Error; err != nil {
+			return err
+		}
+		sentGift.Sender = transfer.Sender
+		sentGift.Receiver = transfer.Receiver
+		sentGift.Gift = transfer.Gift
+
+		now := time.Now()
+		updates := map[string]interface{}{"status": choices.ITS_CONFIRMED, "sent_gift_id": sentGift.ID, "confirmed_at": now}
+		if reference != nil {
+			updates["reference"] = *reference
+		}
+		if err := tx.Model(transfer).Updates(updates).Error; err != nil {
+			return err
+		}
+		transfer.Status = choices.ITS_CONFIRMED
+		transfer.SentGiftID = &sentGift.ID
+		transfer.SentGift = &sentGift
+		transfer.ConfirmedAt = &now
+		if reference != nil {
+			transfer.Reference = reference
+		}
+
+		createdNotification := NotificationManager{}.Create(
+			tx, &transfer.Sender, transfer.Receiver, choices.NT_GIFT,
+			fmt.Sprintf("%s sent you a gift.", transfer.Sender.Username),
+			nil, nil, &sentGift.ID,
+		)
+		notification = &createdNotification
+		return nil
+	})
+	return notification, err
+}
+
+func (i ICPTransferManager) Fail(db *gorm.DB, transfer *models.ICPTransfer, reason string) {
+	transfer.Status = choices.ITS_FAILED
+	transfer.Error = &reason
+	db.Model(transfer).Where("status = ?", choices.ITS_PENDING).Updates(map[string]interface{}{"status": choices.ITS_FAILED, "error": reason})
+}
+
+// Stores the wallet server's ID for a transfer that is still being confirmed
+func (i ICPTransferManager) SetReference(db *gorm.DB, transfer *models.ICPTransfer, reference string) {
+	transfer.Reference = &reference
+	db.Model(transfer).Update("reference", reference)
+}
+
+// How long the wallet server has to record a transfer before it is treated as not made
+const icpTransferTimeout = 30 * time.Minute
+
+// Asks the wallet server about transfers that are still pending and sends the gifts of the confirmed ones.
+// Only transfers older than a minute are checked so that the ones being made now are left to their requests.
+func (i ICPTransferManager) Reconcile(db *gorm.DB, client icp.Client) (confirmed int, failed int) {
+	for _, transfer := range i.GetPending(db, time.Now().Add(-time.Minute)) {
+		serverTransfer, err := client.GetTransfer(transfer.ID.String())
+		if err != nil {
+			if icp.IsNotFound(err) && time.Since(transfer.CreatedAt) > icpTransferTimeout {
+				i.Fail(db, &transfer, "The wallet server has no record of the transfer")
+				failed++
+			} else {
+				log.Printf("Failed to check ICP transfer %s: %s\n", transfer.ID, err)
+				db.Model(&transfer).Update("attempts", gorm.Expr("attempts + 1"))
+			}
+			continue
+		}
+
+		switch serverTransfer.State() {
+		case choices.ITS_CONFIRMED:
+			if _, err := i.Confirm(db, &transfer, &serverTransfer.ID); err != nil {
+				log.Printf("Failed to confirm ICP transfer %s: %s\n", transfer.ID, err)
+				continue
+			}
+			confirmed++
+		case choices.ITS_FAILED:
+			i.Fail(db, &transfer, "The transfer was rejected by the ledger")
+			failed++
+		default:
+			db.Model(&transfer).Update("attempts", gorm.Expr("attempts + 1"))
+		}
+	}
+	return confirmed, failed
+}
@@ -0,0 +1,188 @@
+package managers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How long a payment that is still being made holds on to a redemption of its promo code
+const promoCodeHold = time.Hour
+
+type PromoCodeManager struct {
+	Model     models.PromoCode
+	ModelList []models.PromoCode
+}
+
+func (p PromoCodeManager) GetAll(db *gorm.DB) []models.PromoCode {
+	promoCodes := p.ModelList
+	db.Joins("SubscriptionPlan").Joins("Coin").Order("promo_codes.created_at DESC").Find(&promoCodes)
+	return promoCodes
+}
+
+func (p PromoCodeManager) GetByID(db *gorm.DB, id uuid.UUID) *models.PromoCode {
+	promoCode := models.PromoCode{}
+	db.Joins("SubscriptionPlan").Joins("Coin").Take(&promoCode, "promo_codes.id = ?", id)
+	if promoCode.ID == uuid.Nil {
+		return nil
+	}
+	return &promoCode
+}
+
+func (p PromoCodeManager) GetByCode(db *gorm.DB, code string) *models.PromoCode {
+	promoCode := models.PromoCode{}
+	db.Take(&promoCode, "code = ?", strings.ToUpper(code))
+	if promoCode.ID == uuid.Nil {
+		return nil
+	}
+	return &promoCode
+}
+
+// Returns how many times a code has been used for payments that were taken, by everyone or by a user
+func (p PromoCodeManager) GetRedemptionCount(db *gorm.DB, promoCode models.PromoCode, userID *uuid.UUID) int64 {
+	var count int64
+	query := db.Model(&models.Transaction{}).Where("promo_code_id = ? AND payment_status IN ?", promoCode.ID, takenPaymentStatuses)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	query.Count(&count)
+	return count
+}
+
+// Returns how many times a code has been used for payments that were taken or are still being made, by everyone or by a user.
+// Payments still being made hold on to their redemption for promoCodeHold, so several can't be started to get around the limits.
+func (p PromoCodeManager) GetClaimedCount(db *gorm.DB, promoCode models.PromoCode, userID *uuid.UUID) int64 {
+	var count int64
+	query := db.Model(&models.Transaction{}).Where("promo_code_id = ?", promoCode.ID).
+		Where("payment_status IN ? OR (payment_status = ? AND created_at > ?)", takenPaymentStatuses, choices.PSPENDING, time.Now().Add(-promoCodeHold))
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	query.Count(&count)
+	return count
+}
+
+func (p PromoCodeManager) checkLimits(db *gorm.DB, promoCode models.PromoCode, user models.User) *utils.ErrorResponse {
+	if promoCode.MaxRedemptions != nil && p.GetClaimedCount(db, promoCode, nil) >= int64(*promoCode.MaxRedemptions) {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code has been fully redeemed")
+		return &errD
+	}
+	if p.GetClaimedCount(db, promoCode, &user.ID) >= int64(promoCode.MaxRedemptionsPerUser) {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "You have used this promo code already")
+		return &errD
+	}
+	return nil
+}
+
+// Checks a code's limits again with the code locked, so that payments started at the same time can't claim the same redemption.
+// Call it in the transaction that saves the payment's pending transaction.
+func (p PromoCodeManager) Claim(tx *gorm.DB, promoCode models.PromoCode, user models.User) *utils.ErrorResponse {
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&promoCode, "id = ?", promoCode.ID)
+	return p.checkLimits(tx, promoCode, user)
+}
+
+// Whether a payment made with a code is still within the code's limits when it is taken.
+// A payment that was started long before can be taken after the code has run out (see GetClaimedCount).
+func (p PromoCodeManager) WithinLimits(tx *gorm.DB, transaction models.Transaction) bool {
+	promoCode := p.Model
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&promoCode, "id = ?", transaction.PromoCodeID)
+	if promoCode.ID == uuid.Nil {
+		return false
+	}
+	if promoCode.MaxRedemptions != nil && p.GetRedemptionCount(tx, promoCode, nil) >= int64(*promoCode.MaxRedemptions) {
+		return false
+	}
+	return p.GetRedemptionCount(tx, promoCode, &transaction.UserID) < int64(promoCode.MaxRedemptionsPerUser)
+}
+
+// Returns the code a user entered for buying a plan or a coin pack if he/she can use it for that purchase
+func (p PromoCodeManager) GetRedeemable(db *gorm.DB, code string, user models.User, plan *models.SubscriptionPlan, coin *models.Coin) (*models.PromoCode, *utils.ErrorResponse) {
+	promoCode := p.GetByCode(db, code)
+	if promoCode == nil || !promoCode.IsActive {
+		errD := utils.RequestErr(utils.ERR_INVALID_VALUE, "Invalid promo code")
+		return nil, &errD
+	}
+	now := time.Now()
+	if promoCode.StartsAt != nil && now.Before(*promoCode.StartsAt) {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code isn't active yet")
+		return nil, &errD
+	}
+	if promoCode.EndsAt != nil && now.After(*promoCode.EndsAt) {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code has expired")
+		return nil, &errD
+	}
+	planMismatch := promoCode.SubscriptionPlanID != nil && (plan == nil || *promoCode.SubscriptionPlanID != plan.ID)
+	coinMismatch := promoCode.CoinID != nil && (coin == nil || *promoCode.CoinID != coin.ID)
+	if planMismatch || coinMismatch {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code isn't valid for this purchase")
+		return nil, &errD
+	}
+	if errD := p.checkLimits(db, *promoCode, user); errD != nil {
+		return nil, errD
+	}
+	return promoCode, nil
+}
+
+// Returns the redemption counts of codes, keyed by their IDs
+func (p PromoCodeManager) GetRedemptionCounts(db *gorm.DB, promoCodes []models.PromoCode) map[uuid.UUID]int64 {
+	ids := []uuid.UUID{}
+	for _, promoCode := range promoCodes {
+		ids = append(ids, promoCode.ID)
+	}
+	rows := []struct {
+		PromoCodeID uuid.UUID
+		Count       int64
+	}{}
+	db.Model(&models.Transaction{}).Select("promo_code_id, COUNT(*) AS count").
+		Where("promo_code_id IN ? AND payment_status IN ?", ids, takenPaymentStatuses).
+		Group("promo_code_id").Scan(&rows)
+	counts := map[uuid.UUID]int64{}
+	for _, row := range rows {
+		counts[row.PromoCodeID] = row.Count
+	}
+	return counts
+}
+
+func (p PromoCodeManager) Create(db *gorm.DB, data schemas.PromoCodeCreateSchema, plan *models.SubscriptionPlan, coin *models.Coin) models.PromoCode {
+	promoCode := models.PromoCode{}
+	promoCode = p.setData(promoCode, data, plan, coin)
+	db.Omit("SubscriptionPlan", "Coin").Create(&promoCode)
+	return promoCode
+}
+
+func (p PromoCodeManager) Update(db *gorm.DB, promoCode models.PromoCode, data schemas.PromoCodeCreateSchema, plan *models.SubscriptionPlan, coin *models.Coin) models.PromoCode {
+	promoCode = p.setData(promoCode, data, plan, coin)
+	// Save so that cleared optional fields are stored as NULL
+	db.Omit("SubscriptionPlan", "Coin").Save(&promoCode)
+	return promoCode
+}
+
+func (p PromoCodeManager) setData(promoCode models.PromoCode, data schemas.PromoCodeCreateSchema, plan *models.SubscriptionPlan, coin *models.Coin) models.PromoCode {
+	promoCode.Code = strings.ToUpper(data.Code)
+	promoCode.DiscountType = data.DiscountType
+	promoCode.DiscountValue = data.DiscountValue
+	promoCode.BonusCoins = data.BonusCoins
+	promoCode.StartsAt = data.StartsAt
+	promoCode.EndsAt = data.EndsAt
+	promoCode.MaxRedemptions = data.MaxRedemptions
+	promoCode.MaxRedemptionsPerUser = data.MaxRedemptionsPerUser
+	promoCode.IsActive = data.IsActive
+	promoCode.SubscriptionPlanID = nil
+	promoCode.SubscriptionPlan = plan
+	if plan != nil {
+		promoCode.SubscriptionPlanID = &plan.ID
+	}
+	promoCode.CoinID = nil
+	promoCode.Coin = coin
+	if coin != nil {
+		promoCode.CoinID = &coin.ID
+	}
+	return promoCode
+}
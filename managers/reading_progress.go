@@ -0,0 +1,119 @@
+package managers
+
+import (
+	"math"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/models/scopes"
+	"github.com/LitPad/backend/schemas"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReadingProgressManager struct {
+	Model     models.ReadingProgress
+	ModelList []models.ReadingProgress
+}
+
+func readingProgressPreloadScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Book.Author").Preload("Book.Genre").Preload("Book.SubSections").Preload("Book.SubSections.Section").
+		Preload("Book.Tags").Preload("Book.Chapters", scopes.PublishedChapterScope).Preload("Book.Votes").Preload("Book.Reads").Preload("Chapter")
+}
+
+func (r ReadingProgressManager) GetByUserAndBook(db *gorm.DB, user models.User, book models.Book) *models.ReadingProgress {
+	progress := r.Model
+	db.Joins("Chapter").Where("reading_progresses.user_id = ? AND reading_progresses.book_id = ?", user.ID, book.ID).Take(&progress)
+	if progress.ID == uuid.Nil {
+		return nil
+	}
+	return &progress
+}
+
+// Saves where a user is in a chapter's book, working out how far through the book that is.
+// Places a device was at before the saved one are ignored so a device syncing after being offline doesn't move the reader back,
+// in which case the saved progress is returned with false.
+func (r ReadingProgressManager) Save(db *gorm.DB, user models.User, chapter models.Chapter, paragraphIndex uint, readAt time.Time) (models.ReadingProgress, bool) {
+	progress := r.Model
+	db.Joins("Chapter").Where("reading_progresses.user_id = ? AND reading_progresses.book_id = ?", user.ID, chapter.BookID).Take(&progress)
+	if progress.ID != uuid.Nil && readAt.Before(progress.ReadAt) {
+		return progress, false
+	}
+
+	chapterIDs := []uuid.UUID{}
+	// Authors can read their chapters before they come out
+	db.Model(&models.Chapter{}).Where("book_id = ? AND (status = ? OR id = ?)", chapter.BookID, choices.CS_PUBLISHED, chapter.ID).
+		Order("created_at ASC").Pluck("id", &chapterIDs)
+	positions := make(map[uuid.UUID]int, len(chapterIDs))
+	for i, id := range chapterIDs {
+		positions[id] = i
+	}
+	position := positions[chapter.ID]
+
+	var paragraphsCount int64
+	db.Model(&models.Paragraph{}).Where("chapter_id = ?", chapter.ID).Count(&paragraphsCount)
+	chapterRead := 1.0
+	if paragraphsCount > 0 {
+		chapterRead = math.Min(float64(paragraphIndex)/float64(paragraphsCount), 1)
+	}
+	percent := (float64(position) + chapterRead) / float64(len(chapterIDs)) * 100
+
+	furthestPosition, ok := positions[progress.FurthestChapterID]
+	if !ok || position >= furthestPosition {
+		progress.FurthestChapterID = chapter.ID
+	}
+	progress.UserID = user.ID
+	progress.BookID = chapter.BookID
+	progress.ChapterID = chapter.ID
+	progress.Chapter = chapter
+	progress.ParagraphIndex = paragraphIndex
+	progress.Percent = math.Round(percent*100) / 100
+	progress.ReadAt = readAt
+	db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"chapter_id", "paragraph_index", "percent", "furthest_chapter_id", "read_at", "updated_at"}),
+	}).Create(&progress)
+	return progress, true
+}
+
+// Returns the books a user has started but not finished, the one they read last first
+func (r ReadingProgressManager) GetContinueReading(db *gorm.DB, user models.User) []models.ReadingProgress {
+	progresses := r.ModelList
+	db.Scopes(readingProgressPreloadScope).
+		Where("user_id = ? AND percent < 100", user.ID).
+		Where("book_id NOT IN (?)", db.Model(&models.BookRead{}).Select("book_id").Where("user_id = ? AND completed = ?", user.ID, true)).
+		Order("read_at DESC").
+		Find(&progresses)
+	return progresses
+}
+
+// Returns how many readers reached each published chapter of a book and how many of them stopped there
+func (r ReadingProgressManager) GetChapterDropOff(db *gorm.DB, bookID uuid.UUID) []schemas.ChapterDropOffSchema {
+	chapters := []schemas.ChapterDropOffSchema{}
+	db.Raw(`
+		WITH ordered AS (
+			SELECT id, title, slug, ROW_NUMBER() OVER (ORDER BY created_at) AS position FROM chapters
+			WHERE book_id = $1 AND status = 'PUBLISHED'
+		),
+		furthest AS (
+			SELECT ordered.position FROM reading_progresses JOIN ordered ON ordered.id = reading_progresses.furthest_chapter_id
+			WHERE reading_progresses.book_id = $1
+		)
+		SELECT ordered.title, ordered.slug, ordered.position,
+			(SELECT COUNT(*) FROM furthest WHERE furthest.position >= ordered.position) AS readers
+		FROM ordered ORDER BY ordered.position
+	`, bookID).Scan(&chapters)
+
+	for i := range chapters {
+		if chapters[0].Readers > 0 {
+			chapters[i].Retention = float64(chapters[i].Readers) / float64(chapters[0].Readers) * 100
+		}
+		// Readers of the last chapter have nowhere further to go
+		if i < len(chapters)-1 && chapters[i].Readers > 0 {
+			chapters[i].DropOff = float64(chapters[i].Readers-chapters[i+1].Readers) / float64(chapters[i].Readers) * 100
+		}
+	}
+	return chapters
+}
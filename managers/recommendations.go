@@ -0,0 +1,303 @@
+package managers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/models/scopes"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	recommendationsPerUser = 50
+	similarBooksPerBook    = 50
+	// How much the genres and tags of a user's books count next to what their co-readers read
+	affinityWeight = 0.5
+	// Books picked by genre and tag affinity are the most read of this many
+	affinityCandidates = 200
+	trendingWindow     = 30 * 24 * time.Hour
+)
+
+type BookRecommendationManager struct {
+	Model     models.BookRecommendation
+	ModelList []models.BookRecommendation
+}
+
+func recommendationPreloadScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Book.Author").Preload("Book.Genre").Preload("Book.SubSections").Preload("Book.SubSections.Section").
+		Preload("Book.Tags").Preload("Book.Chapters", scopes.PublishedChapterScope).Preload("Book.Votes").Preload("Book.Reads").Preload("BecauseOfBook")
+}
+
+// Recomputes how alike the books read by the same people are, as the cosine similarity of their readers.
+// Only the closest books to each book are kept.
+func (b BookRecommendationManager) ComputeSimilarities(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM book_similarities").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			WITH readers AS (
+				SELECT book_id, COUNT(DISTINCT user_id) AS count FROM book_reads GROUP BY book_id
+			),
+			pairs AS (
+				SELECT mine.book_id, other.book_id AS similar_book_id, COUNT(DISTINCT mine.user_id) AS co_readers
+				FROM book_reads mine
+				JOIN book_reads other ON other.user_id = mine.user_id AND other.book_id != mine.book_id
+				GROUP BY mine.book_id, other.book_id
+			),
+			scored AS (
+				SELECT pairs.book_id, pairs.similar_book_id,
+					pairs.co_readers / sqrt(book_readers.count * similar_book_readers.count) AS score
+				FROM pairs
+				JOIN readers book_readers ON book_readers.book_id = pairs.book_id
+				JOIN readers similar_book_readers ON similar_book_readers.book_id = pairs.similar_book_id
+			)
+			INSERT INTO book_similarities (created_at, updated_at, book_id, similar_book_id, score)
+			SELECT now(), now(), book_id, similar_book_id, score FROM (
+				SELECT scored.*, ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY score DESC) AS position FROM scored
+			) ranked
+			WHERE position <= ?
+		`, similarBooksPerBook).Error
+	})
+}
+
+// Weighs the books a user read, bookmarked, voted for or rated by how much they say about the user's taste.
+// Low ratings count against a book.
+func (b BookRecommendationManager) getSeeds(db *gorm.DB, userID uuid.UUID) map[uuid.UUID]float64 {
+	rows := []struct {
+		BookID uuid.UUID
+		Weight float64
+	}{}
+	db.Raw(`
+		SELECT book_id, SUM(weight) AS weight FROM (
+			SELECT book_id, CASE WHEN completed THEN 2.0 ELSE 1.0 END AS weight FROM book_reads WHERE user_id = @user
+			UNION ALL SELECT DISTINCT book_id, 1.0 FROM bookmarks WHERE user_id = @user
+			UNION ALL SELECT DISTINCT book_id, 1.0 FROM votes WHERE user_id = @user
+			UNION ALL SELECT book_id, (rating - 3) * 0.5 FROM comments
+				WHERE user_id = @user AND book_id IS NOT NULL AND parent_id IS NULL AND rating > 0
+		) signals
+		GROUP BY book_id HAVING SUM(weight) > 0
+	`, map[string]interface{}{"user": userID}).Scan(&rows)
+
+	seeds := make(map[uuid.UUID]float64)
+	for _, row := range rows {
+		seeds[row.BookID] = row.Weight
+	}
+	return seeds
+}
+
+// Returns the books never recommended to a user: those they completed or reported and their own
+func (b BookRecommendationManager) getExcludedBookIDs(db *gorm.DB, userID uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{}
+	if userID == uuid.Nil {
+		return ids
+	}
+	db.Raw(`
+		SELECT book_id FROM book_reads WHERE user_id = @user AND completed
+		UNION SELECT book_id FROM book_reports WHERE user_id = @user
+		UNION SELECT id FROM books WHERE author_id = @user
+	`, map[string]interface{}{"user": userID}).Scan(&ids)
+	return ids
+}
+
+// Recomputes a user's recommendations from books like theirs and the genres and tags of their books.
+// Books the user already knows of aren't recommended.
+func (b BookRecommendationManager) ComputeForUser(db *gorm.DB, userID uuid.UUID) ([]models.BookRecommendation, error) {
+	seeds := b.getSeeds(db, userID)
+	skip := make(map[uuid.UUID]bool)
+	for _, id := range b.getExcludedBookIDs(db, userID) {
+		skip[id] = true
+	}
+	seedIDs := []uuid.UUID{}
+	for id := range seeds {
+		seedIDs = append(seedIDs, id)
+		skip[id] = true
+	}
+
+	candidates := make(map[uuid.UUID]*models.BookRecommendation)
+	coReadScores := make(map[uuid.UUID]float64)
+	bestSeedScores := make(map[uuid.UUID]float64)
+	if len(seedIDs) > 0 {
+		// Books read by the people who read the user's books
+		similarities := []models.BookSimilarity{}
+		db.Where("book_id IN ?", seedIDs).Find(&similarities)
+		for _, similarity := range similarities {
+			if skip[similarity.SimilarBookID] {
+				continue
+			}
+			score := similarity.Score * seeds[similarity.BookID]
+			candidate, ok := candidates[similarity.SimilarBookID]
+			if !ok {
+				candidate = &models.BookRecommendation{UserID: userID, BookID: similarity.SimilarBookID, Reason: choices.RR_BECAUSE_YOU_READ}
+				candidates[similarity.SimilarBookID] = candidate
+			}
+			candidate.Score += score
+			coReadScores[similarity.SimilarBookID] += score
+			if score > bestSeedScores[similarity.SimilarBookID] {
+				bestSeedScores[similarity.SimilarBookID] = score
+				seedID := similarity.BookID
+				candidate.BecauseOfBookID = &seedID
+			}
+		}
+
+		// Books in the genres and with the tags of the user's books, weighted by the share of the user's taste they make up
+		seedBooks := []models.Book{}
+		db.Preload("Tags").Select("id", "genre_id").Where("id IN ?", seedIDs).Find(&seedBooks)
+		totalWeight := 0.0
+		genreWeights := make(map[uuid.UUID]float64)
+		tagWeights := make(map[uuid.UUID]float64)
+		for _, book := range seedBooks {
+			weight := seeds[book.ID]
+			totalWeight += weight
+			genreWeights[book.GenreID] += weight
+			for _, tag := range book.Tags {
+				tagWeights[tag.ID] += weight
+			}
+		}
+		genreIDs := []uuid.UUID{}
+		for id := range genreWeights {
+			genreIDs = append(genreIDs, id)
+		}
+		tagIDs := []uuid.UUID{}
+		for id := range tagWeights {
+			tagIDs = append(tagIDs, id)
+		}
+		skipIDs := []uuid.UUID{}
+		for id := range skip {
+			skipIDs = append(skipIDs, id)
+		}
+
+		affinityBooks := []models.Book{}
+		query := db.Preload("Tags").Select("id", "genre_id").Where("id NOT IN ?", skipIDs)
+		if len(tagIDs) > 0 {
+			query = query.Where("(genre_id IN ? OR id IN (?))", genreIDs, db.Table("book_tags").Select("book_id").Where("tag_id IN ?", tagIDs))
+		} else {
+			query = query.Where("genre_id IN ?", genreIDs)
+		}
+		query.Order("(SELECT COUNT(*) FROM book_reads WHERE book_reads.book_id = books.id) DESC").
+			Limit(affinityCandidates).Find(&affinityBooks)
+		for _, book := range affinityBooks {
+			tagAffinity := 0.0
+			for _, tag := range book.Tags {
+				tagAffinity += tagWeights[tag.ID]
+			}
+			if len(book.Tags) > 0 {
+				tagAffinity /= float64(len(book.Tags))
+			}
+			score := affinityWeight * (genreWeights[book.GenreID] + tagAffinity) / (2 * totalWeight)
+			candidate, ok := candidates[book.ID]
+			if !ok {
+				candidate = &models.BookRecommendation{UserID: userID, BookID: book.ID}
+				candidates[book.ID] = candidate
+			}
+			candidate.Score += score
+			// Credit whichever counted for more
+			if score > coReadScores[book.ID] {
+				candidate.Reason = choices.RR_YOUR_GENRES
+				candidate.BecauseOfBookID = nil
+			}
+		}
+	}
+
+	recommendations := b.ModelList
+	for _, candidate := range candidates {
+		recommendations = append(recommendations, *candidate)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	if len(recommendations) > recommendationsPerUser {
+		recommendations = recommendations[:recommendationsPerUser]
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&b.Model).Error; err != nil {
+			return err
+		}
+		if len(recommendations) == 0 {
+			return nil
+		}
+		return tx.Create(&recommendations).Error
+	})
+	return recommendations, err
+}
+
+// Recomputes the recommendations of everyone who has read, bookmarked, voted for or reviewed a book.
+// It returns how many users got them.
+func (b BookRecommendationManager) ComputeAll(db *gorm.DB) int {
+	userIDs := []uuid.UUID{}
+	db.Raw(`
+		SELECT user_id FROM book_reads
+		UNION SELECT user_id FROM bookmarks
+		UNION SELECT user_id FROM votes
+		UNION SELECT user_id FROM comments WHERE book_id IS NOT NULL
+	`).Scan(&userIDs)
+	count := 0
+	for _, userID := range userIDs {
+		if _, err := b.ComputeForUser(db, userID); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the books most read lately, optionally of a genre, as recommendations
+func (b BookRecommendationManager) getTrending(db *gorm.DB, genreID *uuid.UUID, skipIDs []uuid.UUID, limit int, reason choices.RecommendationReasonChoice) []models.BookRecommendation {
+	recommendations := b.ModelList
+	if limit < 1 {
+		return recommendations
+	}
+	books := []models.Book{}
+	query := db.Preload("Author").Preload("Genre").Preload("SubSections").Preload("SubSections.Section").
+		Preload("Tags").Preload("Chapters", scopes.PublishedChapterScope).Preload("Votes").Preload("Reads")
+	if genreID != nil {
+		query = query.Where("books.genre_id = ?", *genreID)
+	}
+	if len(skipIDs) > 0 {
+		query = query.Where("books.id NOT IN ?", skipIDs)
+	}
+	query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                "(SELECT COUNT(*) FROM book_reads WHERE book_reads.book_id = books.id AND book_reads.created_at > ?) DESC, books.created_at DESC",
+		Vars:               []interface{}{time.Now().Add(-trendingWindow)},
+		WithoutParentheses: true,
+	}}).Limit(limit).Find(&books)
+	for _, book := range books {
+		recommendations = append(recommendations, models.BookRecommendation{BookID: book.ID, Book: book, Reason: reason})
+	}
+	return recommendations
+}
+
+// Returns a user's recommendations, best first. Until the job has picked enough for them (or for guests)
+// they are topped up with the books trending in the genre they chose on the waitlist, then anywhere.
+func (b BookRecommendationManager) GetForUser(db *gorm.DB, user models.User) []models.BookRecommendation {
+	recommendations := b.ModelList
+	skipIDs := b.getExcludedBookIDs(db, user.ID)
+	if user.ID != uuid.Nil {
+		query := db.Scopes(recommendationPreloadScope).Where("user_id = ?", user.ID)
+		// Books completed or reported since the job ran
+		if len(skipIDs) > 0 {
+			query = query.Where("book_id NOT IN ?", skipIDs)
+		}
+		query.Order("score DESC").Find(&recommendations)
+	}
+	for _, recommendation := range recommendations {
+		skipIDs = append(skipIDs, recommendation.BookID)
+	}
+
+	if user.Email != "" {
+		waitlist := models.Waitlist{}
+		db.Where("email = ?", user.Email).Order("created_at DESC").Take(&waitlist)
+		if waitlist.ID != uuid.Nil {
+			fromGenre := b.getTrending(db, &waitlist.GenreID, skipIDs, recommendationsPerUser-len(recommendations), choices.RR_WAITLIST_GENRE)
+			for _, recommendation := range fromGenre {
+				skipIDs = append(skipIDs, recommendation.BookID)
+			}
+			recommendations = append(recommendations, fromGenre...)
+		}
+	}
+	return append(recommendations, b.getTrending(db, nil, skipIDs, recommendationsPerUser-len(recommendations), choices.RR_TRENDING)...)
+}
@@ -0,0 +1,76 @@
+package managers
+
+import (
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RoleManager struct {
+	Model     models.Role
+	ModelList []models.Role
+}
+
+func (r RoleManager) GetAll(db *gorm.DB) []models.Role {
+	roles := r.ModelList
+	db.Preload("Permissions").Order("name ASC").Find(&roles)
+	return roles
+}
+
+func (r RoleManager) GetByCodename(db *gorm.DB, codename choices.RoleChoice) *models.Role {
+	role := models.Role{Codename: codename}
+	db.Preload("Permissions").Take(&role, role)
+	if role.ID == uuid.Nil {
+		return nil
+	}
+	return &role
+}
+
+// Returns what a user is allowed to do, from their staff role and the role of their account type
+func (r RoleManager) GetUserPermissions(db *gorm.DB, user models.User) map[choices.PermissionChoice]bool {
+	codenames := []choices.PermissionChoice{}
+	query := db.Model(&models.Permission{}).
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id")
+	if user.RoleID != nil {
+		query = query.Where("roles.id = ? OR roles.codename = ?", *user.RoleID, user.AccountType.Role())
+	} else {
+		query = query.Where("roles.codename = ?", user.AccountType.Role())
+	}
+	query.Distinct().Pluck("permissions.codename", &codenames)
+
+	permissions := map[choices.PermissionChoice]bool{}
+	for _, codename := range codenames {
+		permissions[codename] = true
+	}
+	return permissions
+}
+
+// Gives a user a staff role, or takes it away when role is nil. The staff flags of the user are kept in line with it.
+func (r RoleManager) Assign(db *gorm.DB, user *models.User, role *models.Role) {
+	user.Role = role
+	user.RoleID = nil
+	user.IsStaff = false
+	user.IsSuperuser = false
+	if role != nil {
+		user.RoleID = &role.ID
+		user.IsStaff = role.IsStaff
+		user.IsSuperuser = role.Codename == choices.ROLE_SUPERADMIN
+	}
+	db.Model(user).Updates(map[string]interface{}{"role_id": user.RoleID, "is_staff": user.IsStaff, "is_superuser": user.IsSuperuser})
+}
+
+// Gives staff who were made staff before roles existed a role. Superusers become superadmins and other staff
+// get the staff role, which can do everything but manage admins, until a superadmin narrows it down.
+func (r RoleManager) FillStaffRoles(db *gorm.DB) {
+	superadmin := r.GetByCodename(db, choices.ROLE_SUPERADMIN)
+	staff := r.GetByCodename(db, choices.ROLE_STAFF)
+	if superadmin == nil || staff == nil {
+		return
+	}
+	db.Model(&models.User{}).Where("is_staff = ? AND is_superuser = ? AND role_id IS NULL", true, true).
+		Update("role_id", superadmin.ID)
+	db.Model(&models.User{}).Where("is_staff = ? AND role_id IS NULL", true).
+		Update("role_id", staff.ID)
+}
@@ -0,0 +1,80 @@
+package managers
+
+import (
+	"fmt"
+
+	"github.com/LitPad/backend/identity"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SocialAccountManager struct {
+	Model     models.SocialAccount
+	ModelList []models.SocialAccount
+}
+
+func (s SocialAccountManager) GetByUser(db *gorm.DB, user models.User) []models.SocialAccount {
+	accounts := s.ModelList
+	db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&accounts)
+	return accounts
+}
+
+func (s SocialAccountManager) GetByIdentity(db *gorm.DB, identity identity.Identity) *models.SocialAccount {
+	account := models.SocialAccount{Provider: identity.Provider, Subject: identity.Subject}
+	db.Take(&account, account)
+	if account.ID == uuid.Nil {
+		return nil
+	}
+	return &account
+}
+
+// Links an identity provider account to a user. A user can have one account per provider.
+func (s SocialAccountManager) Link(db *gorm.DB, user models.User, identity identity.Identity) (*models.SocialAccount, *utils.ErrorResponse) {
+	existing := s.GetByIdentity(db, identity)
+	if existing != nil {
+		if existing.UserID != user.ID {
+			errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("That %s account is linked to another user", identity.Provider))
+			return nil, &errData
+		}
+		return existing, nil
+	}
+	account := models.SocialAccount{UserID: user.ID, Provider: identity.Provider}
+	db.Take(&account, account)
+	if account.ID != uuid.Nil {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("You have linked a %s account already. Unlink it first", identity.Provider))
+		return nil, &errData
+	}
+	account.Subject = identity.Subject
+	account.Email = identity.Email
+	if err := db.Create(&account).Error; err != nil {
+		// Linked by a request that came in at the same time
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("That %s account is linked already", identity.Provider))
+		return nil, &errData
+	}
+	return &account, nil
+}
+
+// Removes a user's account with a provider. Users who signed up with a provider
+// have no password of their own, so they have to keep at least one account.
+func (s SocialAccountManager) Unlink(db *gorm.DB, user models.User, provider choices.SocialProviderChoice) *utils.ErrorResponse {
+	accounts := s.GetByUser(db, user)
+	var account *models.SocialAccount
+	for i := range accounts {
+		if accounts[i].Provider == provider {
+			account = &accounts[i]
+		}
+	}
+	if account == nil {
+		errData := utils.NotFoundErr(fmt.Sprintf("You haven't linked a %s account", provider))
+		return &errData
+	}
+	if user.SocialLogin && len(accounts) == 1 {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Link another account before unlinking this one, you won't be able to sign in otherwise")
+		return &errData
+	}
+	db.Delete(account)
+	return nil
+}
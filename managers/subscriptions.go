@@ -0,0 +1,252 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type SubscriptionManager struct {
+	Model     models.Subscription
+	ModelList []models.Subscription
+}
+
+func (s SubscriptionManager) GetByUser(db *gorm.DB, user models.User) *models.Subscription {
+	subscription := models.Subscription{}
+	db.Joins("Plan").Take(&subscription, "subscriptions.user_id = ?", user.ID)
+	if subscription.ID == uuid.Nil {
+		return nil
+	}
+	return &subscription
+}
+
+func (s SubscriptionManager) GetByReference(db *gorm.DB, reference string) *models.Subscription {
+	subscription := models.Subscription{}
+	db.Joins("Plan").Joins("User").Take(&subscription, "subscriptions.reference = ?", reference)
+	if subscription.ID == uuid.Nil {
+		return nil
+	}
+	return &subscription
+}
+
+func (s SubscriptionManager) GetActiveCount(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&s.Model).
+		Where("status IN ?", []choices.SubscriptionStatusChoice{choices.SS_ACTIVE, choices.SS_PAST_DUE, choices.SS_CANCELED}).
+		Where("expires_at > ?", time.Now()).
+		Count(&count)
+	return count
+}
+
+// Returns subscriptions that won't renew and whose access ends within the given period, and haven't been reminded yet
+func (s SubscriptionManager) GetExpiring(db *gorm.DB, until time.Time) []models.Subscription {
+	subscriptions := s.ModelList
+	db.Joins("Plan").Joins("User").
+		Where("subscriptions.status IN ?", []choices.SubscriptionStatusChoice{choices.SS_ACTIVE, choices.SS_PAST_DUE, choices.SS_CANCELED}).
+		Where("subscriptions.cancel_at_period_end = ? OR subscriptions.status = ? OR subscriptions.reference IS NULL", true, choices.SS_PAST_DUE).
+		Where("subscriptions.expires_at BETWEEN ? AND ?", time.Now(), until).
+		Where("subscriptions.reminder_sent = ?", false).
+		Find(&subscriptions)
+	return subscriptions
+}
+
+// Returns subscriptions whose access has ended but haven't been marked as expired yet
+func (s SubscriptionManager) GetLapsed(db *gorm.DB) []models.Subscription {
+	subscriptions := s.ModelList
+	db.Joins("Plan").Joins("User").
+		Where("subscriptions.status IN ?", []choices.SubscriptionStatusChoice{choices.SS_ACTIVE, choices.SS_PAST_DUE, choices.SS_CANCELED}).
+		Where("subscriptions.expires_at < ?", time.Now()).
+		Find(&subscriptions)
+	return subscriptions
+}
+
+// Keeps the subscription columns on the user (used for quick access checks) in sync
+func (s SubscriptionManager) syncUser(db *gorm.DB, subscription models.Subscription, plan models.SubscriptionPlan) error {
+	return db.Model(&models.User{}).Where("id = ?", subscription.UserID).
+		Updates(map[string]interface{}{"subscription_expiry": subscription.ExpiresAt, "current_plan": plan.SubType}).Error
+}
+
+// Creates or reuses the user's subscription row for a new provider subscription that is waiting for its first payment
+func (s SubscriptionManager) Start(db *gorm.DB, user models.User, plan models.SubscriptionPlan, provider choices.PaymentType, reference string, customerReference string) (*models.Subscription, error) {
+	subscription := models.Subscription{}
+	db.Take(&subscription, "user_id = ?", user.ID)
+	subscription.UserID = user.ID
+	subscription.Provider = provider
+	subscription.PlanID = plan.ID
+	subscription.Plan = plan
+	subscription.Status = choices.SS_INCOMPLETE
+	subscription.Reference = &reference
+	subscription.CustomerReference = &customerReference
+	subscription.CurrentPeriodStart = time.Now()
+	subscription.CurrentPeriodEnd = plan.PeriodEnd(subscription.CurrentPeriodStart)
+	subscription.ExpiresAt = subscription.CurrentPeriodStart
+	subscription.CancelAtPeriodEnd = false
+	subscription.CanceledAt = nil
+	subscription.ReminderSent = false
+	if err := db.Save(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Starts a paid period. Subscriptions that renew keep access for the grace period after it,
+// so that a late or failed renewal doesn't lock the reader out straight away.
+func (s SubscriptionManager) Activate(db *gorm.DB, subscription *models.Subscription, plan models.SubscriptionPlan, start time.Time, end time.Time, graceDays int) error {
+	subscription.PlanID = plan.ID
+	subscription.Plan = plan
+	subscription.Status = choices.SS_ACTIVE
+	subscription.CurrentPeriodStart = start
+	subscription.CurrentPeriodEnd = end
+	subscription.ExpiresAt = end
+	if subscription.CanceledAt != nil {
+		subscription.Status = choices.SS_CANCELED
+	} else if subscription.Renews() {
+		subscription.ExpiresAt = end.AddDate(0, 0, graceDays)
+	}
+	subscription.ReminderSent = false
+	if err := db.Save(subscription).Error; err != nil {
+		return err
+	}
+	return s.syncUser(db, *subscription, plan)
+}
+
+// Adds a one-off paid period to the user's subscription, after any time he/she has left.
+// Unless the subscription renews with the provider that was paid, it becomes a non renewing one of that provider.
+func (s SubscriptionManager) Extend(db *gorm.DB, user models.User, plan models.SubscriptionPlan, provider choices.PaymentType) (*models.Subscription, error) {
+	subscription := models.Subscription{}
+	db.Take(&subscription, "user_id = ?", user.ID)
+	subscription.UserID = user.ID
+	start := time.Now()
+	periodStart := start
+	if subscription.IsActive() {
+		periodStart = subscription.CurrentPeriodStart
+		if subscription.CurrentPeriodEnd.After(start) {
+			start = subscription.CurrentPeriodEnd
+		}
+	}
+	if subscription.Provider != provider || !subscription.Renews() {
+		// Leftover references of a lapsed provider subscription would make it look like it renews
+		subscription.Provider = provider
+		subscription.Reference = nil
+		subscription.CustomerReference = nil
+		subscription.CancelAtPeriodEnd = true
+	}
+	if err := s.Activate(db, &subscription, plan, periodStart, plan.PeriodEnd(start), 0); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Marks a subscription whose renewal failed. Access is kept till the end of the grace period.
+func (s SubscriptionManager) MarkPastDue(db *gorm.DB, subscription *models.Subscription) error {
+	if subscription.Status != choices.SS_ACTIVE {
+		return nil
+	}
+	subscription.Status = choices.SS_PAST_DUE
+	return db.Save(subscription).Error
+}
+
+// Stops a subscription from renewing. Access is kept till the end of the current period.
+func (s SubscriptionManager) Cancel(db *gorm.DB, subscription *models.Subscription) error {
+	now := time.Now()
+	subscription.Status = choices.SS_CANCELED
+	subscription.CancelAtPeriodEnd = true
+	subscription.CanceledAt = &now
+	subscription.ExpiresAt = subscription.CurrentPeriodEnd
+	if err := db.Save(subscription).Error; err != nil {
+		return err
+	}
+	return s.syncUser(db, *subscription, subscription.Plan)
+}
+
+// Undoes a cancellation made on the provider's side before the period ended
+func (s SubscriptionManager) Resume(db *gorm.DB, subscription *models.Subscription, graceDays int) error {
+	if subscription.Status != choices.SS_CANCELED {
+		return nil
+	}
+	subscription.CancelAtPeriodEnd = false
+	subscription.CanceledAt = nil
+	return s.Activate(db, subscription, subscription.Plan, subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd, graceDays)
+}
+
+func (s SubscriptionManager) Expire(db *gorm.DB, subscription *models.Subscription) error {
+	now := time.Now()
+	subscription.Status = choices.SS_EXPIRED
+	if subscription.ExpiresAt.After(now) {
+		subscription.ExpiresAt = now
+	}
+	if err := db.Save(subscription).Error; err != nil {
+		return err
+	}
+	return s.syncUser(db, *subscription, subscription.Plan)
+}
+
+// Takes a paid period of the plan off the user's subscription, e.g when its payment was refunded or charged back.
+// The subscription expires straight away if no paid time is left.
+func (s SubscriptionManager) Revoke(db *gorm.DB, user models.User, plan models.SubscriptionPlan) error {
+	subscription := models.Subscription{}
+	db.Joins("Plan").Take(&subscription, "subscriptions.user_id = ?", user.ID)
+	if subscription.ID == uuid.Nil || subscription.Status == choices.SS_EXPIRED {
+		return nil
+	}
+	grace := subscription.ExpiresAt.Sub(subscription.CurrentPeriodEnd)
+	end := plan.PeriodStart(subscription.CurrentPeriodEnd)
+	if !end.After(time.Now()) {
+		return s.Expire(db, &subscription)
+	}
+	subscription.CurrentPeriodEnd = end
+	if subscription.CurrentPeriodStart.After(end) {
+		subscription.CurrentPeriodStart = subscription.Plan.PeriodStart(end)
+	}
+	subscription.ExpiresAt = end.Add(grace)
+	if err := db.Save(&subscription).Error; err != nil {
+		return err
+	}
+	return s.syncUser(db, subscription, subscription.Plan)
+}
+
+// Returns what is due when moving to a more expensive plan: the new plan's amount
+// less the unused part of the current period.
+func (s SubscriptionManager) ProrationAmount(subscription models.Subscription, plan models.SubscriptionPlan, at time.Time) decimal.Decimal {
+	period := subscription.CurrentPeriodEnd.Sub(subscription.CurrentPeriodStart)
+	remaining := subscription.CurrentPeriodEnd.Sub(at)
+	unused := decimal.Zero
+	if period > 0 && remaining > 0 {
+		unused = subscription.Plan.Amount.Mul(decimal.NewFromInt(int64(remaining))).Div(decimal.NewFromInt(int64(period)))
+	}
+	amount := plan.Amount.Sub(unused)
+	if amount.IsNegative() {
+		return decimal.Zero
+	}
+	return amount.Round(2)
+}
+
+// Creates subscriptions for users that paid for one before subscriptions renewed automatically.
+// Only users without a subscription are considered, so it is safe to run more than once.
+func (s SubscriptionManager) CreateFromUsers(db *gorm.DB) {
+	users := []models.User{}
+	db.Where("subscription_expiry > ? AND current_plan IS NOT NULL", time.Now()).
+		Where("NOT EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.user_id = users.id)").
+		Find(&users)
+	for _, user := range users {
+		plan := models.SubscriptionPlan{}
+		db.Take(&plan, "sub_type = ?", *user.CurrentPlan)
+		if plan.ID == uuid.Nil {
+			continue
+		}
+		end := *user.SubscriptionExpiry
+		subscription := models.Subscription{
+			UserID: user.ID, PlanID: plan.ID, Status: choices.SS_ACTIVE,
+			CurrentPeriodStart: end.AddDate(0, -1, 0), CurrentPeriodEnd: end, ExpiresAt: end,
+			CancelAtPeriodEnd: true, ReminderSent: user.ReminderSent,
+		}
+		if plan.SubType == choices.ST_ANNUAL {
+			subscription.CurrentPeriodStart = end.AddDate(-1, 0, 0)
+		}
+		db.Create(&subscription)
+	}
+}
@@ -1,9 +1,15 @@
 package managers
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionManager struct {
@@ -22,3 +28,128 @@ func (t TransactionManager) GetSubscriptionRevenue (db *gorm.DB) float64 {
 	return totalRevenue
 }
 
+// Statuses of payments that were taken, including the ones that were reversed later
+var takenPaymentStatuses = []choices.PaymentStatus{choices.PSSUCCEEDED, choices.PSREFUNDED, choices.PSDISPUTED}
+var reversedPaymentStatuses = []choices.PaymentStatus{choices.PSREFUNDED, choices.PSDISPUTED}
+
+// Returns revenue per period (start inclusive, end exclusive) split by payment purpose, subscription plan and coin pack
+func (t TransactionManager) GetRevenueReport(db *gorm.DB, interval choices.ReportIntervalChoice, start time.Time, end time.Time) []schemas.RevenueReportRow {
+	rows := []schemas.RevenueReportRow{}
+	// The interval is one of the valid choices, so it is safe to put in the query
+	period := fmt.Sprintf("DATE_TRUNC('%s', transactions.created_at)", interval)
+	db.Model(&t.Model).
+		Select(period+` AS period, transactions.payment_purpose, subscription_plans.sub_type, coins.amount AS coin_pack,
+			COUNT(*) AS payments,
+			COALESCE(SUM(CAST(transactions.amount AS NUMERIC)), 0) AS gross_revenue,
+			COALESCE(SUM(CAST(transactions.amount AS NUMERIC)) FILTER (WHERE transactions.payment_status IN ?), 0) AS refunded_amount,
+			COALESCE(SUM(CAST(transactions.amount AS NUMERIC)) FILTER (WHERE transactions.payment_status = ?), 0) AS net_revenue`,
+			reversedPaymentStatuses, choices.PSSUCCEEDED).
+		Joins("LEFT JOIN subscription_plans ON subscription_plans.id = transactions.subscription_plan_id").
+		Joins("LEFT JOIN coins ON coins.id = transactions.coin_id").
+		Where("transactions.payment_status IN ?", takenPaymentStatuses).
+		Where("transactions.created_at >= ? AND transactions.created_at < ?", start, end).
+		Group("1, 2, 3, 4").
+		Order("period ASC, transactions.payment_purpose ASC, subscription_plans.sub_type ASC, coin_pack ASC").
+		Scan(&rows)
+	return rows
+}
+
+// Returns the totals, refund and failure rates and average revenue per user for payments made in a range (start inclusive, end exclusive)
+func (t TransactionManager) GetRevenueSummary(db *gorm.DB, start time.Time, end time.Time) schemas.RevenueSummarySchema {
+	summary := schemas.RevenueSummarySchema{}
+	db.Raw(`WITH totals AS (
+			SELECT
+				COUNT(*) FILTER (WHERE payment_status IN @taken) AS payments,
+				COUNT(*) FILTER (WHERE payment_status = @failed) AS failed_payments,
+				COUNT(*) FILTER (WHERE payment_status = @refunded) AS refunds,
+				COUNT(*) FILTER (WHERE payment_status = @disputed) AS chargebacks,
+				COALESCE(SUM(CAST(amount AS NUMERIC)) FILTER (WHERE payment_status IN @taken), 0) AS gross_revenue,
+				COALESCE(SUM(CAST(amount AS NUMERIC)) FILTER (WHERE payment_status IN @reversed), 0) AS refunded_amount,
+				COUNT(DISTINCT user_id) FILTER (WHERE payment_status = @succeeded) AS paying_users
+			FROM transactions
+			WHERE created_at >= @start AND created_at < @end
+		), registered AS (
+			SELECT COUNT(*) AS users FROM users WHERE created_at < @end
+		)
+		SELECT totals.*, registered.users,
+			totals.gross_revenue - totals.refunded_amount AS net_revenue,
+			COALESCE((totals.refunds + totals.chargebacks)::float / NULLIF(totals.payments, 0), 0) AS refund_rate,
+			COALESCE(totals.failed_payments::float / NULLIF(totals.payments + totals.failed_payments, 0), 0) AS failure_rate,
+			COALESCE(ROUND((totals.gross_revenue - totals.refunded_amount) / NULLIF(registered.users, 0), 2), 0) AS arpu,
+			COALESCE(ROUND((totals.gross_revenue - totals.refunded_amount) / NULLIF(totals.paying_users, 0), 2), 0) AS arppu
+		FROM totals, registered`,
+		map[string]interface{}{
+			"taken": takenPaymentStatuses, "reversed": reversedPaymentStatuses, "failed": choices.PSFAILED,
+			"refunded": choices.PSREFUNDED, "disputed": choices.PSDISPUTED, "succeeded": choices.PSSUCCEEDED,
+			"start": start, "end": end,
+		}).
+		Scan(&summary)
+	summary.Start = start
+	summary.End = end
+	return summary
+}
+
+// Sets the amounts of transactions recorded before they were stored, from the current prices.
+// Only transactions without an amount are considered, so it is safe to run more than once.
+func (t TransactionManager) FillAmounts(db *gorm.DB) {
+	db.Exec(`UPDATE transactions SET amount = coins.price * GREATEST(transactions.quantity, 1) FROM coins
+		WHERE coins.id = transactions.coin_id AND CAST(transactions.amount AS NUMERIC) = 0`)
+	db.Exec(`UPDATE transactions SET amount = subscription_plans.amount FROM subscription_plans
+		WHERE subscription_plans.id = transactions.subscription_plan_id AND CAST(transactions.amount AS NUMERIC) = 0`)
+}
+
+type PaymentEventManager struct {
+	Model     models.PaymentEvent
+	ModelList []models.PaymentEvent
+}
+
+func (p PaymentEventManager) GetAll(db *gorm.DB, status *choices.PaymentEventStatusChoice) []models.PaymentEvent {
+	events := p.ModelList
+	query := db.Joins("Transaction")
+	if status != nil {
+		query = query.Where("payment_events.status = ?", *status)
+	}
+	query.Order("payment_events.created_at DESC").Find(&events)
+	return events
+}
+
+func (p PaymentEventManager) GetByID(db *gorm.DB, id uuid.UUID) *models.PaymentEvent {
+	event := models.PaymentEvent{}
+	db.Joins("Transaction").Take(&event, "payment_events.id = ?", id)
+	if event.ID == uuid.Nil {
+		return nil
+	}
+	return &event
+}
+
+// Stores an event the first time it is received and returns the stored copy on redeliveries
+func (p PaymentEventManager) Record(db *gorm.DB, provider choices.PaymentType, eventID string, eventType string, payload []byte) (*models.PaymentEvent, error) {
+	event := models.PaymentEvent{Provider: provider, EventID: eventID, Type: eventType, Payload: payload, Status: choices.PES_RECEIVED}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event).Error; err != nil {
+		return nil, err
+	}
+	storedEvent := models.PaymentEvent{}
+	if err := db.Take(&storedEvent, "provider = ? AND event_id = ?", provider, eventID).Error; err != nil {
+		return nil, err
+	}
+	return &storedEvent, nil
+}
+
+// Records the outcome of an attempt at applying an event
+func (p PaymentEventManager) Finish(db *gorm.DB, event *models.PaymentEvent, status choices.PaymentEventStatusChoice, transactionID *uuid.UUID, err error) {
+	event.Status = status
+	event.Attempts += 1
+	event.Error = nil
+	event.ProcessedAt = nil
+	if err != nil {
+		errMsg := err.Error()
+		event.Error = &errMsg
+	} else {
+		now := time.Now()
+		event.ProcessedAt = &now
+	}
+	if transactionID != nil {
+		event.TransactionID = transactionID
+	}
+	db.Save(event)
+}
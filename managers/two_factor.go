@@ -0,0 +1,118 @@
+package managers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodesCount = 10
+	// Incorrect second factor codes allowed in a row before verification is locked for a while
+	maxTwoFactorFailures  = 5
+	twoFactorLockDuration = 15 * time.Minute
+)
+
+// Stores a new authenticator secret for a user. It is only used once the user confirms it with a code.
+func (u UserManager) SetTotpSecret(db *gorm.DB, user *models.User) string {
+	secret := utils.GenerateTotpSecret()
+	user.TotpSecret = &secret
+	user.TotpLastStep = 0
+	db.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0})
+	return secret
+}
+
+// Turns on authenticator codes for a user and returns their recovery codes
+func (u UserManager) EnableTotp(db *gorm.DB, user *models.User) []string {
+	user.TotpEnabled = true
+	db.Model(user).Update("totp_enabled", true)
+	return u.GenerateRecoveryCodes(db, *user)
+}
+
+func (u UserManager) DisableTotp(db *gorm.DB, user *models.User) {
+	user.TotpEnabled = false
+	user.TotpSecret = nil
+	db.Model(user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": nil, "totp_last_step": 0})
+	db.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{})
+}
+
+// Replaces a user's recovery codes. Only hashes are stored, so the codes are returned to be shown once.
+func (u UserManager) GenerateRecoveryCodes(db *gorm.DB, user models.User) []string {
+	codes := make([]string, recoveryCodesCount)
+	recoveryCodes := make([]models.RecoveryCode, recoveryCodesCount)
+	for i := range codes {
+		codes[i] = utils.GenerateRecoveryCode()
+		recoveryCodes[i] = models.RecoveryCode{UserID: user.ID, Code: utils.HashRecoveryCode(codes[i])}
+	}
+	db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&recoveryCodes).Error
+	})
+	return codes
+}
+
+// Checks a second factor code. Incorrect codes are counted and the user is locked out of
+// verification after too many of them, so locked users fail even with a correct code.
+func (u UserManager) VerifyTwoFactorCode(db *gorm.DB, user *models.User, method choices.TwoFactorMethodChoice, code string) bool {
+	if user.IsTwoFactorLocked() {
+		return false
+	}
+	valid := false
+	updates := map[string]interface{}{}
+	switch method {
+	case choices.TFM_TOTP:
+		if user.TotpEnabled && user.TotpSecret != nil {
+			var step int64
+			step, valid = utils.ValidateTotp(*user.TotpSecret, code, time.Now(), user.TotpLastStep)
+			if valid {
+				// Recording the step only if it is newer keeps concurrent requests from using a code twice
+				result := db.Model(user).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+				valid = result.RowsAffected > 0
+				user.TotpLastStep = step
+			}
+		}
+	case choices.TFM_EMAIL:
+		otp, err := strconv.ParseUint(strings.TrimSpace(code), 10, 32)
+		if err == nil && user.Otp != nil && *user.Otp == uint(otp) && !user.IsOtpExpired() {
+			valid = true
+			user.Otp = nil
+			user.OtpExpiry = nil
+			updates["otp"] = nil
+			updates["otp_expiry"] = nil
+		}
+	case choices.TFM_RECOVERY:
+		if user.TotpEnabled {
+			// Marking the code as used in the same query keeps it from being used twice
+			result := db.Model(&models.RecoveryCode{}).
+				Where("user_id = ? AND code = ? AND used_at IS NULL", user.ID, utils.HashRecoveryCode(code)).
+				Update("used_at", time.Now())
+			valid = result.RowsAffected > 0
+		}
+	}
+
+	if valid {
+		user.TwoFactorFailures = 0
+		user.TwoFactorLockedUntil = nil
+		updates["two_factor_failures"] = 0
+		updates["two_factor_locked_until"] = nil
+	} else {
+		user.TwoFactorFailures++
+		updates["two_factor_failures"] = gorm.Expr("two_factor_failures + 1")
+		if user.TwoFactorFailures >= maxTwoFactorFailures {
+			lockedUntil := time.Now().Add(twoFactorLockDuration)
+			user.TwoFactorFailures = 0
+			user.TwoFactorLockedUntil = &lockedUntil
+			updates["two_factor_failures"] = 0
+			updates["two_factor_locked_until"] = lockedUntil
+		}
+	}
+	db.Model(user).Updates(updates)
+	return valid
+}
@@ -0,0 +1,79 @@
+package managers
+
+import (
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserBlockManager struct {
+	Model     models.UserBlock
+	ModelList []models.UserBlock
+}
+
+func (u UserBlockManager) GetAllByUser(db *gorm.DB, user models.User, kind *choices.BlockKindChoice) []models.UserBlock {
+	blocks := u.ModelList
+	query := db.Joins("BlockedUser").Where("user_blocks.user_id = ?", user.ID)
+	if kind != nil {
+		query = query.Where("user_blocks.kind = ?", *kind)
+	}
+	query.Order("user_blocks.created_at DESC").Find(&blocks)
+	return blocks
+}
+
+func (u UserBlockManager) Get(db *gorm.DB, user models.User, blockedUser models.User) *models.UserBlock {
+	block := u.Model
+	db.Joins("BlockedUser").Take(&block, "user_blocks.user_id = ? AND user_blocks.blocked_user_id = ?", user.ID, blockedUser.ID)
+	if block.ID == uuid.Nil {
+		return nil
+	}
+	return &block
+}
+
+// Blocks or mutes a user, replacing what the user did to them before. Blocking also
+// ends the following between both of them.
+func (u UserBlockManager) Set(db *gorm.DB, user models.User, blockedUser models.User, kind choices.BlockKindChoice) (*models.UserBlock, error) {
+	block := models.UserBlock{UserID: user.ID, BlockedUserID: blockedUser.ID, Kind: kind}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "blocked_user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"kind", "updated_at"}),
+		}).Create(&block).Error
+		if err != nil || kind != choices.BK_BLOCK {
+			return err
+		}
+		if err := tx.Model(&user).Association("Followings").Delete(&blockedUser); err != nil {
+			return err
+		}
+		return tx.Model(&user).Association("Followers").Delete(&blockedUser)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u.Get(db, user, blockedUser), nil
+}
+
+// Unblocks or unmutes a user. It returns false when the user hadn't done either.
+func (u UserBlockManager) Remove(db *gorm.DB, user models.User, blockedUser models.User) bool {
+	result := db.Where("user_id = ? AND blocked_user_id = ?", user.ID, blockedUser.ID).Delete(&u.Model)
+	return result.RowsAffected > 0
+}
+
+// Checks if a user has blocked another
+func (u UserBlockManager) IsBlocked(db *gorm.DB, userID uuid.UUID, blockedUserID uuid.UUID) bool {
+	var count int64
+	db.Model(&u.Model).Where("user_id = ? AND blocked_user_id = ? AND kind = ?", userID, blockedUserID, choices.BK_BLOCK).Count(&count)
+	return count > 0
+}
+
+// Returns the IDs of the users whose comments are hidden from a user, those the user blocked or muted
+func (u UserBlockManager) GetHiddenUserIDs(db *gorm.DB, user models.User) []uuid.UUID {
+	ids := []uuid.UUID{}
+	if user.ID == uuid.Nil {
+		return ids
+	}
+	db.Model(&u.Model).Where("user_id = ?", user.ID).Pluck("blocked_user_id", &ids)
+	return ids
+}
@@ -0,0 +1,212 @@
+package managers
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Moves an amount of a currency from one wallet to another. A nil user stands for the platform
+// e.g coins bought with money come from the platform and coins spent on books go back to it.
+type WalletMovement struct {
+	From     *models.User
+	To       *models.User
+	Currency choices.CurrencyChoice
+	Amount   int
+	// Lets the sender's balance go below zero. The negative balance is what he/she owes
+	// e.g coins that were spent before the payment for them was refunded.
+	AllowDebt bool
+}
+
+// What a set of movements was made for
+type WalletReference struct {
+	TransactionID *uuid.UUID
+	SentGiftID    *uuid.UUID
+	VoteID        *uuid.UUID
+	BookID        *uuid.UUID
+}
+
+type WalletManager struct {
+	Model     models.WalletEntry
+	ModelList []models.WalletEntry
+}
+
+func (w WalletManager) GetByUser(db *gorm.DB, user models.User, currency *choices.CurrencyChoice, reason *choices.WalletReasonChoice) []models.WalletEntry {
+	entries := w.ModelList
+	query := db.Joins("Book").Where("wallet_entries.user_id = ?", user.ID)
+	if currency != nil {
+		query = query.Where("wallet_entries.currency = ?", *currency)
+	}
+	if reason != nil {
+		query = query.Where("wallet_entries.reason = ?", *reason)
+	}
+	query.Order("wallet_entries.created_at DESC").Find(&entries)
+	return entries
+}
+
+// Returns a user's balance as derived from the ledger
+func (w WalletManager) GetBalance(db *gorm.DB, userID uuid.UUID, currency choices.CurrencyChoice) int {
+	var balance int
+	db.Model(&w.Model).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND currency = ?", userID, currency).
+		Scan(&balance)
+	return balance
+}
+
+// Records each movement as a debit/credit pair and updates the cached balances on the users, all in one transaction.
+// The users involved are locked first so that concurrent movements can't spend the same balance twice.
+func (w WalletManager) Transfer(db *gorm.DB, reason choices.WalletReasonChoice, reference WalletReference, movements ...WalletMovement) *utils.ErrorResponse {
+	users := map[uuid.UUID]*models.User{}
+	for _, movement := range movements {
+		for _, user := range []*models.User{movement.From, movement.To} {
+			if user != nil {
+				users[user.ID] = user
+			}
+		}
+	}
+	userIDs := []uuid.UUID{}
+	for id := range users {
+		userIDs = append(userIDs, id)
+	}
+	// Always lock in the same order to avoid deadlocks between opposite movements
+	sort.Slice(userIDs, func(i, j int) bool { return bytes.Compare(userIDs[i][:], userIDs[j][:]) < 0 })
+
+	balances := map[uuid.UUID]map[choices.CurrencyChoice]int{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range userIDs {
+			lockedUser := models.User{}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&lockedUser, "id = ?", id).Error; err != nil {
+				return err
+			}
+			balances[id] = map[choices.CurrencyChoice]int{choices.CUR_COIN: lockedUser.Coins, choices.CUR_LANTERN: lockedUser.Lanterns}
+		}
+
+		groupID := uuid.New()
+		entries := []models.WalletEntry{}
+		entry := func(user *models.User, currency choices.CurrencyChoice, amount int, allowDebt bool) *utils.ErrorResponse {
+			walletEntry := models.WalletEntry{
+				GroupID: groupID, Currency: currency, Amount: amount, Reason: reason,
+				TransactionID: reference.TransactionID, SentGiftID: reference.SentGiftID,
+				VoteID: reference.VoteID, BookID: reference.BookID,
+			}
+			if user != nil {
+				balance := balances[user.ID][currency] + amount
+				if balance < 0 && !allowDebt {
+					errD := utils.RequestErr(utils.ERR_INSUFFICIENT_COINS, "You have insufficient coins")
+					if currency == choices.CUR_LANTERN {
+						errD = utils.RequestErr(utils.ERR_INSUFFICIENT_LANTERNS, "You have insufficient lanterns")
+					}
+					return &errD
+				}
+				balances[user.ID][currency] = balance
+				walletEntry.UserID = &user.ID
+				walletEntry.BalanceAfter = &balance
+			}
+			entries = append(entries, walletEntry)
+			return nil
+		}
+		for _, movement := range movements {
+			if movement.Amount == 0 {
+				continue
+			}
+			if errD := entry(movement.From, movement.Currency, -movement.Amount, movement.AllowDebt); errD != nil {
+				return errD
+			}
+			if errD := entry(movement.To, movement.Currency, movement.Amount, false); errD != nil {
+				return errD
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return err
+		}
+		for _, id := range userIDs {
+			balance := balances[id]
+			err := tx.Model(&models.User{}).Where("id = ?", id).
+				Updates(map[string]interface{}{"coins": balance[choices.CUR_COIN], "lanterns": balance[choices.CUR_LANTERN]}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return errD
+		}
+		errD := utils.ServerErr("Something went wrong while updating your wallet")
+		return &errD
+	}
+
+	// Keep the callers' copies of the users in sync
+	for id, user := range users {
+		user.Coins = balances[id][choices.CUR_COIN]
+		user.Lanterns = balances[id][choices.CUR_LANTERN]
+	}
+	return nil
+}
+
+// Takes back coins credited for a payment that was refunded or charged back.
+// Coins the user has converted to lanterns are taken from the lanterns (at the same 1:1 rate)
+// and whatever has been spent already is left on the coin balance as debt.
+func (w WalletManager) ClawBack(db *gorm.DB, reason choices.WalletReasonChoice, reference WalletReference, user *models.User, coins int) *utils.ErrorResponse {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		lockedUser := models.User{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&lockedUser, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		fromLanterns := 0
+		if shortfall := coins - max(lockedUser.Coins, 0); shortfall > 0 {
+			fromLanterns = min(shortfall, max(lockedUser.Lanterns, 0))
+		}
+		errD := w.Transfer(tx, reason, reference,
+			WalletMovement{From: user, Currency: choices.CUR_COIN, Amount: coins - fromLanterns, AllowDebt: true},
+			WalletMovement{From: user, Currency: choices.CUR_LANTERN, Amount: fromLanterns},
+		)
+		if errD != nil {
+			return errD
+		}
+		return nil
+	})
+	if err != nil {
+		if errD, ok := err.(*utils.ErrorResponse); ok {
+			return errD
+		}
+		errD := utils.ServerErr("Something went wrong while updating the wallet")
+		return &errD
+	}
+	return nil
+}
+
+// Records the balances users had before the ledger existed so that their entries add up to their cached balances.
+// Only users without any wallet entry are considered, so it is safe to run more than once.
+func (w WalletManager) CreateOpeningBalances(db *gorm.DB) {
+	users := []models.User{}
+	db.Where("coins > 0 OR lanterns > 0").
+		Where("NOT EXISTS (SELECT 1 FROM wallet_entries WHERE wallet_entries.user_id = users.id)").
+		Find(&users)
+	for _, user := range users {
+		groupID := uuid.New()
+		entries := []models.WalletEntry{}
+		for currency, amount := range map[choices.CurrencyChoice]int{choices.CUR_COIN: user.Coins, choices.CUR_LANTERN: user.Lanterns} {
+			if amount == 0 {
+				continue
+			}
+			balance := amount
+			entries = append(entries,
+				models.WalletEntry{GroupID: groupID, Currency: currency, Amount: -amount, Reason: choices.WR_OPENING_BALANCE},
+				models.WalletEntry{GroupID: groupID, UserID: &user.ID, Currency: currency, Amount: amount, BalanceAfter: &balance, Reason: choices.WR_OPENING_BALANCE},
+			)
+		}
+		db.Create(&entries)
+	}
+}
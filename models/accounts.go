@@ -16,27 +16,38 @@ import (
 type User struct {
 	BaseModel
 	Name            *string `gorm:"type: varchar(255);null"`
-	Username        string  `gorm:"type: varchar(1000);not null;unique;"`
+	Username        string  `gorm:"type: varchar(1000);not null;unique;index:idx_users_username_trgm,type:gin,expression:username gin_trgm_ops"`
 	Email           string  `gorm:"not null;unique;"`
 	Password        string  `gorm:"not null"`
 	IsEmailVerified bool    `gorm:"default:false"`
-	IsSuperuser     bool    `gorm:"default:false"`
-	IsStaff         bool    `gorm:"default:false"`
+	IsSuperuser     bool    `gorm:"default:false"` // mirrors the role, see RoleManager.Assign
+	IsStaff         bool    `gorm:"default:false"` // mirrors the role, see RoleManager.Assign
 	IsActive        bool    `gorm:"default:true"`
 
+	// The staff role of the user. What else a user can do comes with their account type.
+	RoleID *uuid.UUID `gorm:"null"`
+	Role   *Role      `gorm:"foreignKey:RoleID;constraint:OnDelete:SET NULL;<-:false"`
+
 	Otp         *uint      `gorm:"null"`
 	OtpExpiry   *time.Time `gorm:"null"`
 	TokenString *string    `gorm:"null"`
 	TokenExpiry *time.Time `gorm:"null"`
 
+	TotpSecret           *string    `gorm:"type:varchar(100);null"`
+	TotpEnabled          bool       `gorm:"default:false"`
+	TotpLastStep         int64      `gorm:"default:0"` // time step of the last accepted code, so codes can't be replayed
+	TwoFactorFailures    int        `gorm:"default:0"`
+	TwoFactorLockedUntil *time.Time `gorm:"null"`
+
 	Avatar            string          `gorm:"type:varchar(1000);null;"`
 	SocialLogin       bool            `gorm:"default:false"`
 	Bio               *string         `gorm:"type:varchar(1000);null;"`
 	AccountType       choices.AccType `gorm:"type:varchar(100); default:READER"`
+	AuthorVerifiedAt  *time.Time      `gorm:"null"` // set when staff approve the user's author application
 	Followings        []User          `gorm:"many2many:user_followers;foreignKey:ID;joinForeignKey:Follower;References:ID;joinReferences:Following"`
 	Followers         []User          `gorm:"many2many:user_followers;foreignKey:ID;joinForeignKey:Following;References:ID;joinReferences:Follower"`
-	Coins             int             `gorm:"default:0"`
-	Lanterns          int             `gorm:"default:0"`
+	Coins             int             `gorm:"default:0;<-:create"` // cached balance, only changed through the wallet ledger
+	Lanterns          int             `gorm:"default:0;<-:create"` // cached balance, only changed through the wallet ledger
 	LikeNotification  bool            `gorm:"default:false"`
 	ReplyNotification bool            `gorm:"default:false"`
 
@@ -44,10 +55,18 @@ type User struct {
 	SubscriptionExpiry *time.Time                      `gorm:"index,null"`
 	ReminderSent       bool                            `gorm:"default:false"`
 
+	DeletionScheduledAt *time.Time `gorm:"index;null"` // the account is deleted then, unless the user cancels before it
+	AccountDeletedAt    *time.Time `gorm:"null"`       // the row is kept without personal data, see UserManager.DeleteAccount
+
 	// Back referenced
 	Books []Book `gorm:"foreignKey:AuthorID"`
 }
 
+// Authors get a verified badge once staff approve their application
+func (u User) IsVerifiedAuthor() bool {
+	return u.AccountType == choices.ACCTYPE_AUTHOR && u.AuthorVerifiedAt != nil
+}
+
 func (u *User) GenerateOTP(db *gorm.DB) {
 	cfg := config.GetConfig()
 	// Create new otp
@@ -80,6 +99,15 @@ func (u User) IsOtpExpired() bool {
 	return time.Now().UTC().After((*u.OtpExpiry).UTC())
 }
 
+// Staff have to pass a second factor to sign in, whether or not they have set up an authenticator app
+func (u User) RequiresTwoFactor() bool {
+	return u.TotpEnabled || u.IsStaff || u.IsSuperuser
+}
+
+func (u User) IsTwoFactorLocked() bool {
+	return u.TwoFactorLockedUntil != nil && time.Now().Before(*u.TwoFactorLockedUntil)
+}
+
 func (user User) SubscriptionExpired() bool {
 	if user.SubscriptionExpiry == nil {
 		return true
@@ -129,12 +157,166 @@ func (user *User) GenerateUsername(db *gorm.DB, email string, username *string)
 	return uniqueUsername
 }
 
+// A pair of tokens for a signed in device. Every refresh replaces the pair with a new one in the same family,
+// so a family is a session and its tokens that have been replaced are kept to detect refresh token reuse.
 type AuthToken struct {
 	BaseModel
 	UserID  uuid.UUID `gorm:"index"`
 	User    User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
 	Access  string    `gorm:"unique"`
 	Refresh string    `gorm:"unique"`
+
+	FamilyID   uuid.UUID           `gorm:"type:uuid;index"`
+	SignedInAt time.Time           // when the family's first tokens were issued
+	RotatedAt  *time.Time          // when these tokens were replaced. They can't be used after that.
+	UserAgent  string              `gorm:"type: varchar(500)"`
+	IP         string              `gorm:"type: varchar(100)"`
+	DeviceType *choices.DeviceType `gorm:"type: varchar(100)"`
+	LastUsedAt *time.Time
+}
+
+func (a *AuthToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.FamilyID == uuid.Nil {
+		// The first tokens of a session
+		a.FamilyID = uuid.New()
+		a.SignedInAt = time.Now()
+	}
+	return
+}
+
+type Permission struct {
+	BaseModel
+	Codename choices.PermissionChoice `gorm:"type:varchar(100);unique"`
+	Name     string                   `gorm:"type:varchar(255)"`
+}
+
+// A set of permissions. Roles and their permissions are seeded in initials.
+type Role struct {
+	BaseModel
+	Codename    choices.RoleChoice `gorm:"type:varchar(100);unique"`
+	Name        string             `gorm:"type:varchar(255)"`
+	IsStaff     bool               `gorm:"default:false"`
+	Permissions []Permission       `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+}
+
+func (r Role) HasPermission(permission choices.PermissionChoice) bool {
+	for _, p := range r.Permissions {
+		if p.Codename == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// One-time codes for signing in when the authenticator app isn't available
+type RecoveryCode struct {
+	BaseModel
+	UserID uuid.UUID `gorm:"index"`
+	User   User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	Code   string    `gorm:"type:varchar(64);unique"` // hashed
+	UsedAt *time.Time
+}
+
+// An account with an identity provider that a user signs in with. A user can link several providers.
+type SocialAccount struct {
+	BaseModel
+	UserID   uuid.UUID                    `gorm:"index"`
+	User     User                         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	Provider choices.SocialProviderChoice `gorm:"type:varchar(20);uniqueIndex:idx_social_account_subject"`
+	Subject  string                       `gorm:"type:varchar(255);uniqueIndex:idx_social_account_subject"` // the provider's id of the account
+	Email    string                       `gorm:"type:varchar(255)"`
+}
+
+// An archive of a user's data for them to download
+type DataExport struct {
+	BaseModel
+	UserID    uuid.UUID                      `gorm:"index"`
+	User      User                           `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	Status    choices.DataExportStatusChoice `gorm:"type:varchar(20);default:PENDING"`
+	Token     *string                        `gorm:"type:varchar(64);unique"` // hashed, for the download link
+	Archive   []byte                         `gorm:"type:bytea"`              // a zip of json files
+	ExpiresAt *time.Time                     `gorm:"null"`
+}
+
+func (d DataExport) IsExpired() bool {
+	return d.ExpiresAt != nil && time.Now().After(*d.ExpiresAt)
+}
+
+// Failed attempts at an auth action, kept for an account and for an IP address so that
+// guessing slows down with every attempt and ends in a lockout
+type AuthThrottle struct {
+	BaseModel
+	Scope         choices.AuthThrottleScopeChoice `gorm:"type:varchar(20);uniqueIndex:idx_auth_throttle_key"`
+	Key           string                          `gorm:"type:varchar(255);uniqueIndex:idx_auth_throttle_key"` // an email address, or an IP address
+	IsIP          bool                            `gorm:"default:false"`
+	Failures      int                             `gorm:"default:0"`
+	LastFailureAt *time.Time                      `gorm:"null"`
+	RetryAt       *time.Time                      `gorm:"null"` // no attempts are allowed before then
+	LockedUntil   *time.Time                      `gorm:"null;index"`
+	UnlockToken   *string                         `gorm:"type:varchar(64);unique"` // hashed, for the link in the lockout email
+}
+
+func (a AuthThrottle) IsLocked() bool {
+	return a.LockedUntil != nil && time.Now().Before(*a.LockedUntil)
+}
+
+// How long until another attempt is allowed, zero if one is allowed now
+func (a AuthThrottle) Wait() time.Duration {
+	waitUntil := a.RetryAt
+	if a.IsLocked() {
+		waitUntil = a.LockedUntil
+	}
+	if waitUntil == nil || time.Now().After(*waitUntil) {
+		return 0
+	}
+	return time.Until(*waitUntil)
+}
+
+// A reader's request to become an author, which staff approve or decline
+type AuthorApplication struct {
+	BaseModel
+	UserID     uuid.UUID                             `gorm:"index"`
+	User       User                                  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	PenName    string                                `gorm:"type:varchar(100)"`
+	Bio        string                                `gorm:"type:varchar(1000)"`
+	SampleWork string                                `gorm:"type:text"`
+	Links      []string                              `gorm:"serializer:json;type:text"`
+	Status     choices.AuthorApplicationStatusChoice `gorm:"type:varchar(20);default:PENDING;index"`
+	Reason     *string                               `gorm:"type:varchar(1000);null"` // given by staff with their decision
+	ReviewerID *uuid.UUID                            `gorm:"null"`
+	Reviewer   *User                                 `gorm:"foreignKey:ReviewerID;constraint:OnDelete:SET NULL;<-:false"`
+	ReviewedAt *time.Time                            `gorm:"null"`
+}
+
+// A user's change of email address. The new address is confirmed with an OTP sent to it, and the old one
+// gets a link that reverts the change in case someone else made it.
+type EmailChange struct {
+	BaseModel
+	UserID       uuid.UUID  `gorm:"index"`
+	User         User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	OldEmail     string
+	NewEmail     string
+	Otp          uint
+	OtpExpiry    time.Time
+	ConfirmedAt  *time.Time `gorm:"null"`
+	RevertToken  *string    `gorm:"null;unique"` // hashed, the raw token is only in the email to the old address
+	RevertExpiry *time.Time `gorm:"null"`
+	RevertedAt   *time.Time `gorm:"null"`
+}
+
+func (e EmailChange) IsOtpExpired() bool {
+	return time.Now().After(e.OtpExpiry)
+}
+
+// A user blocking or muting another. Blocked users can't comment on, reply to, like, follow or gift the
+// user and don't notify them. Muted users still can, but their comments are hidden from the user.
+type UserBlock struct {
+	BaseModel
+	UserID        uuid.UUID               `gorm:"uniqueIndex:idx_user_block"`
+	User          User                    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	BlockedUserID uuid.UUID               `gorm:"uniqueIndex:idx_user_block;index"`
+	BlockedUser   User                    `gorm:"foreignKey:BlockedUserID;constraint:OnDelete:CASCADE;<-:false"`
+	Kind          choices.BlockKindChoice `gorm:"type:varchar(10)"`
 }
 
 type Notification struct {
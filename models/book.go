@@ -37,6 +37,10 @@ func (tag *Tag) BeforeSave(tx *gorm.DB) (err error) {
 	return
 }
 
+func (tag *Tag) AfterUpdate(tx *gorm.DB) (err error) {
+	return UpdateBookSearchVectors(tx, "id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)", tag.ID)
+}
+
 func (t Tag) BooksCount() int {
 	return len(t.Books)
 }
@@ -71,10 +75,11 @@ type Book struct {
 	BaseModel
 	AuthorID      uuid.UUID
 	Author        User   `gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL;<-:false"`
-	Title         string `gorm:"type: varchar(1000)"`
+	Title         string `gorm:"type: varchar(1000);index:idx_books_title_trgm,type:gin,expression:title gin_trgm_ops"`
 	Slug          string `gorm:"unique"`
 	Blurb         string `gorm:"type: varchar(10000)"`
 	AgeDiscretion choices.AgeType
+	SearchVector  string `gorm:"type:tsvector;index:idx_books_search_vector,type:gin;->:false"` // see UpdateBookSearchVectors
 
 	GenreID uuid.UUID
 	Genre   Genre `gorm:"foreignKey:GenreID;constraint:OnDelete:SET NULL;<-:false"`
@@ -123,6 +128,9 @@ type Book struct {
 func (b Book) GetWordCount() int {
 	totalWords := 0
 	for _, chapter := range b.Chapters {
+		if !chapter.IsPublished() {
+			continue
+		}
 		for _, paragraph := range chapter.Paragraphs {
 			totalWords += len(strings.Fields(paragraph.Text))
 		}
@@ -177,6 +185,31 @@ func (b *Book) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (b *Book) AfterSave(tx *gorm.DB) (err error) {
+	return UpdateBookSearchVectors(tx, "id = ?", b.ID)
+}
+
+// Weighs a book's title highest, then its pen name and tags, its blurb and lastly its published chapter titles
+const bookSearchVectorSQL = `UPDATE books SET search_vector =
+	setweight(to_tsvector('english', coalesce(books.title, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(books.pen_name, '')), 'B') ||
+	setweight(to_tsvector('english', coalesce((
+		SELECT string_agg(tags.name, ' ') FROM tags JOIN book_tags ON book_tags.tag_id = tags.id
+		WHERE book_tags.book_id = books.id
+	), '')), 'B') ||
+	setweight(to_tsvector('english', coalesce(books.blurb, '')), 'C') ||
+	setweight(to_tsvector('english', coalesce((
+		SELECT string_agg(chapters.title, ' ') FROM chapters WHERE chapters.book_id = books.id AND chapters.status = 'PUBLISHED'
+	), '')), 'D')
+	WHERE `
+
+// Rebuilds the search vectors of the books matching a condition. Tags and chapters live in other
+// tables so the vectors can't be generated columns, and are rebuilt whenever a book, one of its
+// chapters or tags is written instead.
+func UpdateBookSearchVectors(tx *gorm.DB, query string, args ...interface{}) error {
+	return tx.Exec(bookSearchVectorSQL+query, args...).Error
+}
+
 type BookSubSection struct {
 	BookID         uuid.UUID `gorm:"primaryKey"`
 	SubSectionID   uuid.UUID `gorm:"primaryKey"`
@@ -213,6 +246,27 @@ type Chapter struct {
 	Slug       string      `gorm:"unique"`
 	Paragraphs []Paragraph `gorm:"foreignKey:ChapterID;constraint:OnDelete:CASCADE;"`
 	IsLast     bool        `gorm:"default:false"`
+
+	// Only published chapters are seen by readers. Scheduled ones are published by a job once PublishAt passes.
+	Status    choices.ChapterStatusChoice `gorm:"type:varchar(20);default:PUBLISHED;index"`
+	PublishAt *time.Time                  // when it was or will be published
+}
+
+func (c Chapter) IsPublished() bool {
+	return c.Status == choices.CS_PUBLISHED
+}
+
+// A version of a chapter, saved whenever it is written or updated so earlier ones can be compared and restored
+type ChapterRevision struct {
+	BaseModel
+	ChapterID  uuid.UUID  `gorm:"uniqueIndex:idx_chapter_revision_number"`
+	Chapter    Chapter    `gorm:"foreignKey:ChapterID;constraint:OnDelete:CASCADE;<-:false"`
+	Number     uint       `gorm:"uniqueIndex:idx_chapter_revision_number"` // from 1, the latest being the chapter as it is
+	EditorID   *uuid.UUID // who saved it, unknown for chapters written before revisions existed
+	Editor     *User      `gorm:"foreignKey:EditorID;constraint:OnDelete:SET NULL;<-:false"`
+	Title      string     `gorm:"type: varchar(255)"`
+	Paragraphs []string   `gorm:"serializer:json;type:text"`
+	IsLast     bool
 }
 
 func (c *Chapter) GenerateUniqueSlug(tx *gorm.DB) string {
@@ -239,6 +293,10 @@ func (c *Chapter) BeforeSave(tx *gorm.DB) (err error) {
 	return
 }
 
+func (c *Chapter) AfterSave(tx *gorm.DB) (err error) {
+	return UpdateBookSearchVectors(tx, "id = ?", c.BookID)
+}
+
 type Paragraph struct {
 	BaseModel
 	ChapterID uuid.UUID
@@ -246,12 +304,34 @@ type Paragraph struct {
 	Index     uint
 	Text      string    `gorm:"type:text"`
 	Comments  []Comment `gorm:"foreignKey:ParagraphID;constraint:OnDelete:CASCADE"`
+
+	// Kept up to date by postgres itself
+	SearchVector string `gorm:"type:tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(text, ''))) STORED;index:idx_paragraphs_search_vector,type:gin;->:false"`
 }
 
 func (p Paragraph) CommentsCount() int {
 	return len(p.Comments)
 }
 
+type BoughtChapter struct {
+	BaseModel
+	BuyerID   uuid.UUID `gorm:"uniqueIndex:idx_buyer_chapter"`
+	Buyer     User      `gorm:"foreignKey:BuyerID;constraint:OnDelete:CASCADE;<-:false"`
+	ChapterID uuid.UUID `gorm:"uniqueIndex:idx_buyer_chapter"`
+	Chapter   Chapter   `gorm:"foreignKey:ChapterID;constraint:OnDelete:CASCADE;<-:false"`
+	Price     int       // coins paid for this chapter (a share of the full price for whole book purchases)
+}
+
+// A book bought in full. Its buyer can read every chapter of it, including those that come out later.
+type BoughtBook struct {
+	BaseModel
+	BuyerID uuid.UUID `gorm:"uniqueIndex:idx_buyer_book"`
+	Buyer   User      `gorm:"foreignKey:BuyerID;constraint:OnDelete:CASCADE;<-:false"`
+	BookID  uuid.UUID `gorm:"uniqueIndex:idx_buyer_book"`
+	Book    Book      `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;<-:false"`
+	Price   int       // coins paid for the book
+}
+
 type Comment struct {
 	BaseModel
 	UserID uuid.UUID
@@ -315,3 +395,42 @@ type FeaturedContent struct {
 	SeenBy   []*User `gorm:"many2many:featured_content_seen_by;"`
 	IsActive bool    `gorm:"default:true;"`
 }
+
+// How alike two books are by the readers they share. Recomputed by the recommendations job.
+type BookSimilarity struct {
+	BaseModel
+	BookID        uuid.UUID `gorm:"uniqueIndex:idx_book_similarity"`
+	Book          Book      `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;<-:false"`
+	SimilarBookID uuid.UUID `gorm:"uniqueIndex:idx_book_similarity"`
+	SimilarBook   Book      `gorm:"foreignKey:SimilarBookID;constraint:OnDelete:CASCADE;<-:false"`
+	Score         float64
+}
+
+// A book picked for a user by the recommendations job and why it was picked
+type BookRecommendation struct {
+	BaseModel
+	UserID          uuid.UUID `gorm:"uniqueIndex:idx_user_recommendation"`
+	User            User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	BookID          uuid.UUID `gorm:"uniqueIndex:idx_user_recommendation"`
+	Book            Book      `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;<-:false"`
+	Score           float64
+	Reason          choices.RecommendationReasonChoice `gorm:"type:varchar(20)"`
+	BecauseOfBookID *uuid.UUID                         // the user's book it is most like, for BECAUSE_YOU_READ
+	BecauseOfBook   *Book                              `gorm:"foreignKey:BecauseOfBookID;constraint:OnDelete:CASCADE;<-:false"`
+}
+
+// Where a user is in a book, saved by whichever device they read it on last
+type ReadingProgress struct {
+	BaseModel
+	UserID            uuid.UUID `gorm:"uniqueIndex:idx_user_reading_progress"`
+	User              User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	BookID            uuid.UUID `gorm:"uniqueIndex:idx_user_reading_progress;index"`
+	Book              Book      `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE;<-:false"`
+	ChapterID         uuid.UUID
+	Chapter           Chapter `gorm:"foreignKey:ChapterID;constraint:OnDelete:CASCADE;<-:false"`
+	ParagraphIndex    uint
+	Percent           float64   // of the book, from 0 to 100
+	FurthestChapterID uuid.UUID // the latest chapter they have reached, even if they went back since
+	FurthestChapter   Chapter   `gorm:"foreignKey:FurthestChapterID;constraint:OnDelete:CASCADE;<-:false"`
+	ReadAt            time.Time // when the device was at this place, which can be before it synced
+}
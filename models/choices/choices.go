@@ -15,6 +15,14 @@ func (a AccType) IsValid() bool {
 	return false
 }
 
+// Returns the role that comes with an account type
+func (a AccType) Role() RoleChoice {
+	if a == ACCTYPE_AUTHOR {
+		return ROLE_AUTHOR
+	}
+	return ROLE_READER
+}
+
 type PaymentType string
 
 const (
@@ -53,11 +61,13 @@ const (
 	PSSUCCEEDED PaymentStatus = "SUCCEEDED"
 	PSFAILED    PaymentStatus = "FAILED"
 	PSCANCELED  PaymentStatus = "CANCELED"
+	PSREFUNDED  PaymentStatus = "REFUNDED"
+	PSDISPUTED  PaymentStatus = "DISPUTED" // charged back by the buyer's bank
 )
 
 func (p PaymentStatus) IsValid() bool {
 	switch p {
-	case PSPENDING, PSSUCCEEDED, PSFAILED, PSCANCELED:
+	case PSPENDING, PSSUCCEEDED, PSFAILED, PSCANCELED, PSREFUNDED, PSDISPUTED:
 		return true
 	}
 	return false
@@ -115,18 +125,20 @@ func (r RatingChoice) IsValid() bool {
 type NotificationTypeChoice string
 
 const (
-	NT_LIKE          NotificationTypeChoice = "LIKE"
-	NT_REPLY         NotificationTypeChoice = "REPLY"
-	NT_FOLLOWING     NotificationTypeChoice = "FOLLOWING"
-	NT_BOOK_PURCHASE NotificationTypeChoice = "BOOK_PURCHASE"
-	NT_GIFT          NotificationTypeChoice = "GIFT"
-	NT_REVIEW        NotificationTypeChoice = "REVIEW"
-	NT_VOTE          NotificationTypeChoice = "VOTE"
+	NT_LIKE               NotificationTypeChoice = "LIKE"
+	NT_REPLY              NotificationTypeChoice = "REPLY"
+	NT_FOLLOWING          NotificationTypeChoice = "FOLLOWING"
+	NT_BOOK_PURCHASE      NotificationTypeChoice = "BOOK_PURCHASE"
+	NT_GIFT               NotificationTypeChoice = "GIFT"
+	NT_REVIEW             NotificationTypeChoice = "REVIEW"
+	NT_VOTE               NotificationTypeChoice = "VOTE"
+	NT_AUTHOR_APPLICATION NotificationTypeChoice = "AUTHOR_APPLICATION"
+	NT_NEW_CHAPTER        NotificationTypeChoice = "NEW_CHAPTER"
 )
 
 func (n NotificationTypeChoice) IsValid() bool {
 	switch n {
-	case NT_LIKE, NT_REPLY, NT_FOLLOWING, NT_BOOK_PURCHASE, NT_GIFT, NT_REVIEW, NT_VOTE:
+	case NT_LIKE, NT_REPLY, NT_FOLLOWING, NT_BOOK_PURCHASE, NT_GIFT, NT_REVIEW, NT_VOTE, NT_AUTHOR_APPLICATION, NT_NEW_CHAPTER:
 		return true
 	}
 	return false
@@ -219,13 +231,16 @@ func (s SubscriptionTypeChoice) IsValid() bool {
 type SubscriptionStatusChoice string
 
 const (
-	SS_ACTIVE  SubscriptionStatusChoice = "ACTIVE"
-	SS_EXPIRED SubscriptionStatusChoice = "EXPIRED"
+	SS_INCOMPLETE SubscriptionStatusChoice = "INCOMPLETE" // waiting for the first payment
+	SS_ACTIVE     SubscriptionStatusChoice = "ACTIVE"
+	SS_PAST_DUE   SubscriptionStatusChoice = "PAST_DUE" // a renewal failed, access is kept for a grace period
+	SS_CANCELED   SubscriptionStatusChoice = "CANCELED" // won't renew, access is kept till the end of the period
+	SS_EXPIRED    SubscriptionStatusChoice = "EXPIRED"
 )
 
 func (s SubscriptionStatusChoice) IsValid() bool {
 	switch s {
-	case SS_ACTIVE, SS_EXPIRED:
+	case SS_INCOMPLETE, SS_ACTIVE, SS_PAST_DUE, SS_CANCELED, SS_EXPIRED:
 		return true
 	}
 	return false
@@ -254,6 +269,37 @@ func (u UserGrowthChoice) IsValid() bool {
 	return false
 }
 
+type PromoDiscountTypeChoice string
+
+const (
+	PDT_PERCENTAGE PromoDiscountTypeChoice = "PERCENTAGE"
+	PDT_FIXED      PromoDiscountTypeChoice = "FIXED"
+)
+
+func (p PromoDiscountTypeChoice) IsValid() bool {
+	switch p {
+	case PDT_PERCENTAGE, PDT_FIXED:
+		return true
+	}
+	return false
+}
+
+type ReportIntervalChoice string
+
+const (
+	RI_DAY   ReportIntervalChoice = "day"
+	RI_WEEK  ReportIntervalChoice = "week"
+	RI_MONTH ReportIntervalChoice = "month"
+)
+
+func (r ReportIntervalChoice) IsValid() bool {
+	switch r {
+	case RI_DAY, RI_WEEK, RI_MONTH:
+		return true
+	}
+	return false
+}
+
 type ReplyType string
 
 const (
@@ -299,3 +345,307 @@ func (s FeaturedContentLocationChoice) IsValid() bool {
 	}
 	return false
 }
+
+type EarningSourceChoice string
+
+const (
+	ES_GIFT               EarningSourceChoice = "GIFT"
+	ES_CHAPTER_PURCHASE   EarningSourceChoice = "CHAPTER_PURCHASE"
+	ES_BOOK_PURCHASE      EarningSourceChoice = "BOOK_PURCHASE"
+	ES_SUBSCRIPTION_SHARE EarningSourceChoice = "SUBSCRIPTION_SHARE"
+	ES_PAYOUT             EarningSourceChoice = "PAYOUT"
+	ES_PAYOUT_REVERSAL    EarningSourceChoice = "PAYOUT_REVERSAL"
+)
+
+func (e EarningSourceChoice) IsValid() bool {
+	switch e {
+	case ES_GIFT, ES_CHAPTER_PURCHASE, ES_BOOK_PURCHASE, ES_SUBSCRIPTION_SHARE, ES_PAYOUT, ES_PAYOUT_REVERSAL:
+		return true
+	}
+	return false
+}
+
+type PayoutStatusChoice string
+
+const (
+	POS_REQUESTED PayoutStatusChoice = "REQUESTED"
+	POS_APPROVED  PayoutStatusChoice = "APPROVED"
+	POS_PAID      PayoutStatusChoice = "PAID"
+	POS_DECLINED  PayoutStatusChoice = "DECLINED"
+)
+
+func (p PayoutStatusChoice) IsValid() bool {
+	switch p {
+	case POS_REQUESTED, POS_APPROVED, POS_PAID, POS_DECLINED:
+		return true
+	}
+	return false
+}
+
+// Checks if a payout can move from the current status to the next one (REQUESTED -> APPROVED -> PAID)
+func (p PayoutStatusChoice) CanMoveTo(next PayoutStatusChoice) bool {
+	switch p {
+	case POS_REQUESTED:
+		return next == POS_APPROVED || next == POS_DECLINED
+	case POS_APPROVED:
+		return next == POS_PAID || next == POS_DECLINED
+	}
+	return false
+}
+
+type CurrencyChoice string
+
+const (
+	CUR_COIN    CurrencyChoice = "COIN"
+	CUR_LANTERN CurrencyChoice = "LANTERN"
+)
+
+func (c CurrencyChoice) IsValid() bool {
+	switch c {
+	case CUR_COIN, CUR_LANTERN:
+		return true
+	}
+	return false
+}
+
+type WalletReasonChoice string
+
+const (
+	WR_OPENING_BALANCE     WalletReasonChoice = "OPENING_BALANCE"
+	WR_COIN_PURCHASE       WalletReasonChoice = "COIN_PURCHASE"
+	WR_LANTERN_CONVERSION  WalletReasonChoice = "LANTERN_CONVERSION"
+	WR_VOTE                WalletReasonChoice = "VOTE"
+	WR_GIFT                WalletReasonChoice = "GIFT"
+	WR_GIFT_CLAIM          WalletReasonChoice = "GIFT_CLAIM"
+	WR_CHAPTER_PURCHASE    WalletReasonChoice = "CHAPTER_PURCHASE"
+	WR_BOOK_PURCHASE       WalletReasonChoice = "BOOK_PURCHASE"
+	WR_REFUND              WalletReasonChoice = "REFUND"
+	WR_CHARGEBACK          WalletReasonChoice = "CHARGEBACK"
+	WR_CHARGEBACK_REVERSAL WalletReasonChoice = "CHARGEBACK_REVERSAL"
+	WR_PROMO_BONUS         WalletReasonChoice = "PROMO_BONUS"
+)
+
+func (w WalletReasonChoice) IsValid() bool {
+	switch w {
+	case WR_OPENING_BALANCE, WR_COIN_PURCHASE, WR_LANTERN_CONVERSION, WR_VOTE, WR_GIFT, WR_GIFT_CLAIM, WR_CHAPTER_PURCHASE, WR_BOOK_PURCHASE,
+		WR_REFUND, WR_CHARGEBACK, WR_CHARGEBACK_REVERSAL, WR_PROMO_BONUS:
+		return true
+	}
+	return false
+}
+
+type PaymentEventStatusChoice string
+
+const (
+	PES_RECEIVED  PaymentEventStatusChoice = "RECEIVED"
+	PES_PROCESSED PaymentEventStatusChoice = "PROCESSED"
+	PES_FAILED    PaymentEventStatusChoice = "FAILED"
+	PES_IGNORED   PaymentEventStatusChoice = "IGNORED"
+)
+
+func (p PaymentEventStatusChoice) IsValid() bool {
+	switch p {
+	case PES_RECEIVED, PES_PROCESSED, PES_FAILED, PES_IGNORED:
+		return true
+	}
+	return false
+}
+
+type ICPTransferStatusChoice string
+
+const (
+	ITS_PENDING   ICPTransferStatusChoice = "PENDING"
+	ITS_CONFIRMED ICPTransferStatusChoice = "CONFIRMED"
+	ITS_FAILED    ICPTransferStatusChoice = "FAILED"
+)
+
+func (i ICPTransferStatusChoice) IsValid() bool {
+	switch i {
+	case ITS_PENDING, ITS_CONFIRMED, ITS_FAILED:
+		return true
+	}
+	return false
+}
+
+type TwoFactorMethodChoice string
+
+const (
+	TFM_TOTP     TwoFactorMethodChoice = "totp"
+	TFM_EMAIL    TwoFactorMethodChoice = "email"
+	TFM_RECOVERY TwoFactorMethodChoice = "recovery"
+)
+
+func (t TwoFactorMethodChoice) IsValid() bool {
+	switch t {
+	case TFM_TOTP, TFM_EMAIL, TFM_RECOVERY:
+		return true
+	}
+	return false
+}
+
+type RoleChoice string
+
+const (
+	ROLE_SUPERADMIN        RoleChoice = "superadmin"
+	ROLE_CONTENT_MODERATOR RoleChoice = "content-moderator"
+	ROLE_FINANCE           RoleChoice = "finance"
+	ROLE_SUPPORT           RoleChoice = "support"
+	ROLE_STAFF             RoleChoice = "staff" // staff made before roles existed
+	ROLE_AUTHOR            RoleChoice = "author"
+	ROLE_READER            RoleChoice = "reader"
+)
+
+func (r RoleChoice) IsValid() bool {
+	switch r {
+	case ROLE_SUPERADMIN, ROLE_CONTENT_MODERATOR, ROLE_FINANCE, ROLE_SUPPORT, ROLE_STAFF, ROLE_AUTHOR, ROLE_READER:
+		return true
+	}
+	return false
+}
+
+// Roles that can be given to staff. Authors and readers get theirs from their account type.
+func (r RoleChoice) IsStaff() bool {
+	switch r {
+	case ROLE_SUPERADMIN, ROLE_CONTENT_MODERATOR, ROLE_FINANCE, ROLE_SUPPORT, ROLE_STAFF:
+		return true
+	}
+	return false
+}
+
+type PermissionChoice string
+
+const (
+	PERM_VIEW_DASHBOARD   PermissionChoice = "dashboard.view"
+	PERM_VIEW_USERS       PermissionChoice = "users.view"
+	PERM_MANAGE_USERS     PermissionChoice = "users.manage"
+	PERM_MANAGE_ADMINS    PermissionChoice = "admins.manage"
+	PERM_WRITE_BOOKS      PermissionChoice = "books.write"  // create books and edit your own
+	PERM_MANAGE_BOOKS     PermissionChoice = "books.manage" // edit and delete anyone's books
+	PERM_VIEW_BOOKS       PermissionChoice = "books.view"
+	PERM_MANAGE_CATALOG   PermissionChoice = "catalog.manage" // genres, tags, sections and featured contents
+	PERM_VIEW_PAYMENTS    PermissionChoice = "payments.view"
+	PERM_MANAGE_PAYMENTS  PermissionChoice = "payments.manage"
+	PERM_VIEW_SUBSCRIBERS PermissionChoice = "subscribers.view"
+	PERM_VIEW_WAITLIST    PermissionChoice = "waitlist.view"
+)
+
+func (p PermissionChoice) IsValid() bool {
+	switch p {
+	case PERM_VIEW_DASHBOARD, PERM_VIEW_USERS, PERM_MANAGE_USERS, PERM_MANAGE_ADMINS,
+		PERM_WRITE_BOOKS, PERM_MANAGE_BOOKS, PERM_VIEW_BOOKS, PERM_MANAGE_CATALOG,
+		PERM_VIEW_PAYMENTS, PERM_MANAGE_PAYMENTS, PERM_VIEW_SUBSCRIBERS, PERM_VIEW_WAITLIST:
+		return true
+	}
+	return false
+}
+
+type SocialProviderChoice string
+
+const (
+	SP_GOOGLE   SocialProviderChoice = "google"
+	SP_FACEBOOK SocialProviderChoice = "facebook"
+	SP_APPLE    SocialProviderChoice = "apple"
+)
+
+func (s SocialProviderChoice) IsValid() bool {
+	switch s {
+	case SP_GOOGLE, SP_FACEBOOK, SP_APPLE:
+		return true
+	}
+	return false
+}
+
+type DataExportStatusChoice string
+
+const (
+	DES_PENDING DataExportStatusChoice = "PENDING"
+	DES_READY   DataExportStatusChoice = "READY"
+	DES_FAILED  DataExportStatusChoice = "FAILED"
+)
+
+type AuthThrottleScopeChoice string
+
+const (
+	ATS_LOGIN          AuthThrottleScopeChoice = "LOGIN"
+	ATS_VERIFY_EMAIL   AuthThrottleScopeChoice = "VERIFY_EMAIL"
+	ATS_PASSWORD_RESET AuthThrottleScopeChoice = "PASSWORD_RESET"
+)
+
+func (a AuthThrottleScopeChoice) IsValid() bool {
+	switch a {
+	case ATS_LOGIN, ATS_VERIFY_EMAIL, ATS_PASSWORD_RESET:
+		return true
+	}
+	return false
+}
+
+type AuthorApplicationStatusChoice string
+
+const (
+	AAS_PENDING  AuthorApplicationStatusChoice = "PENDING"
+	AAS_APPROVED AuthorApplicationStatusChoice = "APPROVED"
+	AAS_DECLINED AuthorApplicationStatusChoice = "DECLINED"
+)
+
+func (a AuthorApplicationStatusChoice) IsValid() bool {
+	switch a {
+	case AAS_PENDING, AAS_APPROVED, AAS_DECLINED:
+		return true
+	}
+	return false
+}
+
+// Checks if staff can give the status to an application when reviewing it
+func (a AuthorApplicationStatusChoice) IsDecision() bool {
+	return a == AAS_APPROVED || a == AAS_DECLINED
+}
+
+type BlockKindChoice string
+
+const (
+	BK_BLOCK BlockKindChoice = "BLOCK"
+	BK_MUTE  BlockKindChoice = "MUTE"
+)
+
+func (b BlockKindChoice) IsValid() bool {
+	switch b {
+	case BK_BLOCK, BK_MUTE:
+		return true
+	}
+	return false
+}
+
+type RecommendationReasonChoice string
+
+const (
+	RR_BECAUSE_YOU_READ RecommendationReasonChoice = "BECAUSE_YOU_READ"
+	RR_YOUR_GENRES      RecommendationReasonChoice = "YOUR_GENRES"
+	RR_WAITLIST_GENRE   RecommendationReasonChoice = "WAITLIST_GENRE"
+	RR_TRENDING         RecommendationReasonChoice = "TRENDING"
+)
+
+type ChapterStatusChoice string
+
+const (
+	CS_DRAFT       ChapterStatusChoice = "DRAFT"
+	CS_SCHEDULED   ChapterStatusChoice = "SCHEDULED"
+	CS_PUBLISHED   ChapterStatusChoice = "PUBLISHED"
+	CS_UNPUBLISHED ChapterStatusChoice = "UNPUBLISHED"
+)
+
+func (c ChapterStatusChoice) IsValid() bool {
+	switch c {
+	case CS_DRAFT, CS_SCHEDULED, CS_PUBLISHED, CS_UNPUBLISHED:
+		return true
+	}
+	return false
+}
+
+type ParagraphChangeChoice string
+
+const (
+	PC_UNCHANGED ParagraphChangeChoice = "UNCHANGED"
+	PC_EDITED    ParagraphChangeChoice = "EDITED"
+	PC_ADDED     ParagraphChangeChoice = "ADDED"
+	PC_REMOVED   ParagraphChangeChoice = "REMOVED"
+)
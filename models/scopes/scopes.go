@@ -2,6 +2,7 @@ package scopes
 
 import (
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"gorm.io/gorm"
 )
 
@@ -21,16 +22,21 @@ func FollowerFollowingBooksPreloaderScope(db *gorm.DB) *gorm.DB {
 	return db.Scopes(FollowerFollowingPreloaderScope).Preload("Books").Preload("Followers.Followers").Preload("Followings").Preload("Followings.Followers").Preload("Followings.Books")
 }
 
+// Leaves out the chapters readers can't see yet, e.g for Preload("Chapters", PublishedChapterScope)
+func PublishedChapterScope(db *gorm.DB) *gorm.DB {
+	return db.Where("chapters.status = ?", choices.CS_PUBLISHED)
+}
+
 func AuthorGenreTagBookScope(db *gorm.DB) *gorm.DB {
-	return db.Joins("Author").Joins("Genre").Preload("SubSections").Preload("SubSections.Section").Preload("Tags").Preload("Chapters").Preload("Votes").Preload("Reads")
+	return db.Joins("Author").Joins("Genre").Preload("SubSections").Preload("SubSections.Section").Preload("Tags").Preload("Chapters", PublishedChapterScope).Preload("Votes").Preload("Reads")
 }
 
 func AuthorGenreTagBookPreloadScope(db *gorm.DB) *gorm.DB {
-	return db.Preload("Author").Preload("Genre").Preload("SubSections").Preload("SubSections.Section").Preload("Tags").Preload("Chapters").Preload("Votes").Preload("Reads")
+	return db.Preload("Author").Preload("Genre").Preload("SubSections").Preload("SubSections.Section").Preload("Tags").Preload("Chapters", PublishedChapterScope).Preload("Votes").Preload("Reads")
 }
 
 func TagsChaptersVotesBookScope(db *gorm.DB) *gorm.DB {
-	return db.Preload("Tags").Preload("Chapters").Preload("Votes").Preload("Reads")
+	return db.Preload("Tags").Preload("Chapters", PublishedChapterScope).Preload("Votes").Preload("Reads")
 }
 
 func AuthorGenreTagReviewsBookScope(db *gorm.DB) *gorm.DB {
@@ -1,15 +1,20 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/LitPad/backend/models/choices"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type Coin struct {
 	BaseModel
-	Amount int             `gorm:"default:0" json:"amount"`
-	Price  decimal.Decimal `gorm:"default:0" json:"price"`
+	Amount           int             `gorm:"default:0" json:"amount"`
+	Price            decimal.Decimal `gorm:"default:0" json:"price"`
+	ProductReference *string         `gorm:"type: varchar(255);unique" json:"product_reference"` // the app store product the coins are sold as
 }
 
 type Transaction struct {
@@ -33,13 +38,17 @@ type Transaction struct {
 	PaymentType    choices.PaymentType    `json:"payment_type"`
 	PaymentPurpose choices.PaymentPurpose `json:"payment_purpose"`
 	PaymentStatus  choices.PaymentStatus  `json:"payment_status" gorm:"default:PENDING"`
+	Amount         decimal.Decimal        `json:"amount" gorm:"default:0"` // what was charged in USD, for all the packs bought
+	PromoCodeID    *uuid.UUID             `json:"promo_code_id"`
+	PromoCode      *PromoCode             `gorm:"foreignKey:PromoCodeID;constraint:OnDelete:SET NULL"`
+	BonusCoins     int                    `gorm:"default:0"` // added by the promo code
 	ClientSecret  string 
 }
 
 func (t Transaction) CoinsTotal() *int {
 	if t.Coin != nil {
 		amount := t.Coin.Amount
-		coinsTotal := t.Quantity * amount
+		coinsTotal := t.Quantity*amount + t.BonusCoins
 		return &coinsTotal
 	}
 	return nil
@@ -47,6 +56,205 @@ func (t Transaction) CoinsTotal() *int {
 
 type SubscriptionPlan struct {
 	BaseModel
-	Amount  decimal.Decimal                `gorm:"default:0"`
-	SubType choices.SubscriptionTypeChoice `gorm:"default:MONTHLY;unique"`
+	Amount           decimal.Decimal                `gorm:"default:0"`
+	SubType          choices.SubscriptionTypeChoice `gorm:"default:MONTHLY;unique"`
+	PriceReference   *string                        `gorm:"type: varchar(255)"`        // the payment provider's recurring price for the current amount
+	ProductReference *string                        `gorm:"type: varchar(255);unique"` // the app store product a period of the plan is sold as
+}
+
+// Returns when a period of the plan starting at the given time ends
+func (p SubscriptionPlan) PeriodEnd(start time.Time) time.Time {
+	if p.SubType == choices.ST_ANNUAL {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// The opposite of PeriodEnd
+func (p SubscriptionPlan) PeriodStart(end time.Time) time.Time {
+	if p.SubType == choices.ST_ANNUAL {
+		return end.AddDate(-1, 0, 0)
+	}
+	return end.AddDate(0, -1, 0)
+}
+
+// A code that discounts a purchase and/or adds bonus coins to it, optionally only for a plan or a coin pack
+type PromoCode struct {
+	BaseModel
+	Code          string                          `gorm:"type: varchar(50);unique;not null"` // stored in uppercase
+	DiscountType  choices.PromoDiscountTypeChoice `gorm:"type: varchar(100);default:PERCENTAGE"`
+	DiscountValue decimal.Decimal                 `gorm:"default:0"` // a percentage or an amount in USD
+	BonusCoins    int                             `gorm:"default:0"`
+
+	StartsAt              *time.Time
+	EndsAt                *time.Time
+	MaxRedemptions        *int // by all users. Unlimited when nil.
+	MaxRedemptionsPerUser int  `gorm:"default:1"`
+
+	SubscriptionPlanID *uuid.UUID
+	SubscriptionPlan   *SubscriptionPlan `gorm:"foreignKey:SubscriptionPlanID;constraint:OnDelete:CASCADE"`
+	CoinID             *uuid.UUID
+	Coin               *Coin `gorm:"foreignKey:CoinID;constraint:OnDelete:CASCADE"`
+	IsActive           bool
+}
+
+// Returns an amount less the code's discount
+func (p PromoCode) Discounted(amount decimal.Decimal) decimal.Decimal {
+	discount := p.DiscountValue
+	if p.DiscountType == choices.PDT_PERCENTAGE {
+		discount = amount.Mul(p.DiscountValue).Div(decimal.NewFromInt(100))
+	}
+	discounted := amount.Sub(discount).Round(2)
+	if discounted.IsNegative() {
+		return decimal.Zero
+	}
+	return discounted
+}
+
+// An append-only record of what an author has earned (or been paid out) in USD
+type AuthorEarning struct {
+	BaseModel
+	AuthorID uuid.UUID `gorm:"uniqueIndex:idx_author_earning_period"`
+	Author   User      `gorm:"foreignKey:AuthorID;constraint:OnDelete:CASCADE;<-:false"`
+
+	Source choices.EarningSourceChoice `gorm:"type: varchar(100)"`
+	Amount decimal.Decimal             `gorm:"default:0"` // negative for payouts
+
+	BookID     *uuid.UUID
+	Book       *Book      `gorm:"foreignKey:BookID;constraint:OnDelete:SET NULL;<-:false"`
+	SentGiftID *uuid.UUID `gorm:"unique"`
+	SentGift   *SentGift  `gorm:"foreignKey:SentGiftID;constraint:OnDelete:SET NULL;<-:false"`
+	PayoutID   *uuid.UUID
+	Payout     *Payout `gorm:"foreignKey:PayoutID;constraint:OnDelete:SET NULL;<-:false"`
+
+	Period      *string `gorm:"uniqueIndex:idx_author_earning_period"` // e.g 2024-06 (for subscription revenue shares)
+	Description string  `gorm:"type: varchar(1000)"`
+}
+
+func (e *AuthorEarning) BeforeUpdate(tx *gorm.DB) (err error) {
+	return errors.New("author earnings are append-only")
+}
+
+type Payout struct {
+	BaseModel
+	AuthorID   uuid.UUID
+	Author     User                       `gorm:"foreignKey:AuthorID;constraint:OnDelete:CASCADE;<-:false"`
+	Amount     decimal.Decimal            `gorm:"default:0"`
+	Status     choices.PayoutStatusChoice `gorm:"default:REQUESTED"`
+	Reference  *string                    `gorm:"type: varchar(1000)"` // reference of the transfer made to the author
+	Note       *string                    `gorm:"type: varchar(1000)"` // admin note e.g reason for declining
+	ApprovedAt *time.Time
+	PaidAt     *time.Time
+}
+
+// One side of a coin/lantern movement. Every movement is recorded as a debit and a credit
+// sharing the same GroupID, and a user's balance is the sum of his/her entries for a currency.
+type WalletEntry struct {
+	BaseModel
+	GroupID uuid.UUID  `gorm:"index"`
+	UserID  *uuid.UUID `gorm:"index"` // nil for the platform's side of a movement
+	User    *User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+
+	Currency     choices.CurrencyChoice     `gorm:"type: varchar(100)"`
+	Amount       int                        // negative for debits
+	BalanceAfter *int                       // the user's balance after the entry (nil for the platform)
+	Reason       choices.WalletReasonChoice `gorm:"type: varchar(100)"`
+
+	TransactionID *uuid.UUID
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:SET NULL;<-:false"`
+	SentGiftID    *uuid.UUID
+	SentGift      *SentGift `gorm:"foreignKey:SentGiftID;constraint:OnDelete:SET NULL;<-:false"`
+	VoteID        *uuid.UUID
+	Vote          *Vote `gorm:"foreignKey:VoteID;constraint:OnDelete:SET NULL;<-:false"`
+	BookID        *uuid.UUID
+	Book          *Book `gorm:"foreignKey:BookID;constraint:OnDelete:SET NULL;<-:false"`
+}
+
+func (e *WalletEntry) BeforeUpdate(tx *gorm.DB) (err error) {
+	return errors.New("wallet entries are append-only")
+}
+
+// A payment provider's webhook event. Events are stored before they are applied
+// so that redeliveries are detected and failed events can be replayed.
+type PaymentEvent struct {
+	BaseModel
+	Provider choices.PaymentType              `gorm:"type: varchar(100);default:STRIPE;uniqueIndex:idx_provider_event"`
+	EventID  string                           `gorm:"type: varchar(255);uniqueIndex:idx_provider_event;not null"`
+	Type     string                           `gorm:"type: varchar(255)"`
+	Payload  []byte                           // the raw event as received
+	Status   choices.PaymentEventStatusChoice `gorm:"type: varchar(100);default:RECEIVED"`
+	Error    *string                          `gorm:"type: text"` // why the last attempt failed
+
+	TransactionID *uuid.UUID
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:SET NULL;<-:false"`
+
+	Attempts    int `gorm:"default:0"`
+	ProcessedAt *time.Time
+}
+
+// A user's subscription. It renews automatically through the payment provider until it is canceled.
+type Subscription struct {
+	BaseModel
+	UserID uuid.UUID `gorm:"unique"`
+	User   User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	PlanID uuid.UUID
+	Plan   SubscriptionPlan `gorm:"foreignKey:PlanID;constraint:OnDelete:RESTRICT;<-:false"`
+
+	Provider          choices.PaymentType              `gorm:"type: varchar(100);default:STRIPE"`
+	Status            choices.SubscriptionStatusChoice `gorm:"type: varchar(100);default:INCOMPLETE"`
+	Reference         *string                          `gorm:"type: varchar(255);unique"` // the provider's subscription id (nil for one-off payments)
+	CustomerReference *string                          `gorm:"type: varchar(255)"`
+
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	ExpiresAt          time.Time `gorm:"index"` // when access ends, including any grace period
+	CancelAtPeriodEnd  bool      `gorm:"default:false"`
+	CanceledAt         *time.Time
+	ReminderSent       bool `gorm:"default:false"`
+}
+
+func (s Subscription) IsActive() bool {
+	switch s.Status {
+	case choices.SS_ACTIVE, choices.SS_PAST_DUE, choices.SS_CANCELED:
+		return time.Now().Before(s.ExpiresAt)
+	}
+	return false
+}
+
+// Checks if the subscription will renew at the end of its period
+func (s Subscription) Renews() bool {
+	return s.Reference != nil && !s.CancelAtPeriodEnd && (s.Status == choices.SS_ACTIVE || s.Status == choices.SS_PAST_DUE)
+}
+
+// A user's wallet on the ICP wallet server
+type ICPWallet struct {
+	BaseModel
+	UserID    uuid.UUID `gorm:"unique"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;<-:false"`
+	AccountID string    `gorm:"type: varchar(255);not null"` // the address transfers are sent to
+	PublicKey string    `gorm:"type: varchar(500)"`
+}
+
+// A gift paid for with ICP. The gift is only sent once the wallet server confirms the transfer.
+type ICPTransfer struct {
+	BaseModel
+	SenderID   uuid.UUID
+	Sender     User `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE;<-:false"`
+	ReceiverID uuid.UUID
+	Receiver   User `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE;<-:false"`
+	GiftID     uuid.UUID
+	Gift       Gift `gorm:"foreignKey:GiftID;constraint:OnDelete:CASCADE;<-:false"`
+
+	Address   string          `gorm:"type: varchar(255)"` // account ID of the receiver's wallet
+	Amount    decimal.Decimal `gorm:"default:0"`          // in ICP
+	UsdRate   decimal.Decimal `gorm:"default:0"`          // price of one ICP in USD when the transfer was made
+	Reference *string         `gorm:"type: varchar(255)"` // the wallet server's ID for the transfer
+
+	Status   choices.ICPTransferStatusChoice `gorm:"type: varchar(100);default:PENDING"`
+	Error    *string                         `gorm:"type: text"` // why the transfer failed
+	Attempts int                             `gorm:"default:0"`  // reconciliation attempts
+
+	SentGiftID  *uuid.UUID
+	SentGift    *SentGift `gorm:"foreignKey:SentGiftID;constraint:OnDelete:SET NULL;<-:false"`
+	ConfirmedAt *time.Time
 }
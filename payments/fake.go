@@ -0,0 +1,117 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// A provider that works without the network, for tests.
+// Its webhook events are Event values as json and are signed with the "Fake-Signature: valid" header.
+// Like Google Play, it can only make one-off payments. The fakes of the other providers add what those can do.
+type FakeProvider struct {
+	PaymentType choices.PaymentType
+	Status      choices.PaymentStatus // the status of new intents
+	Refunds     *[]string             // references of the payments refunded
+	Canceled    *[]string             // references of the subscriptions canceled
+}
+
+func NewFakeProvider(paymentType choices.PaymentType) FakeProvider {
+	return FakeProvider{PaymentType: paymentType, Status: choices.PSPENDING, Refunds: &[]string{}, Canceled: &[]string{}}
+}
+
+// A fake of a provider whose approved payments are captured by the app, like PayPal
+type FakeCapturer struct {
+	FakeProvider
+}
+
+// A fake of a provider that renews subscriptions, like Stripe
+type FakeSubscriptionProvider struct {
+	FakeProvider
+}
+
+// Makes Get return a fake for the payment type until the returned function is called.
+// The fake can do what the real provider of the payment type can.
+func UseFake(paymentType choices.PaymentType) (FakeProvider, func()) {
+	provider := NewFakeProvider(paymentType)
+	switch paymentType {
+	case choices.PTYPE_STRIPE:
+		overrides[paymentType] = FakeSubscriptionProvider{provider}
+	case choices.PTYPE_PAYPAL:
+		overrides[paymentType] = FakeCapturer{provider}
+	default:
+		// Google Play purchases are paid for on the device
+		provider.Status = choices.PSSUCCEEDED
+		overrides[paymentType] = provider
+	}
+	return provider, func() { delete(overrides, paymentType) }
+}
+
+func (f FakeProvider) reference(prefix string) string {
+	return fmt.Sprintf("fake_%s_%s", prefix, uuid.NewString())
+}
+
+func (f FakeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	reference := f.reference("payment")
+	if params.Token != nil {
+		reference = *params.Token
+	}
+	return &Intent{Reference: reference, ClientSecret: reference + "_secret", Status: f.Status}, nil
+}
+
+func (f FakeProvider) VerifyWebhook(payload []byte, headers http.Header) (*Event, error) {
+	if headers.Get("Fake-Signature") != "valid" {
+		return nil, fmt.Errorf("invalid fake signature")
+	}
+	return f.ParseEvent(payload)
+}
+
+func (f FakeProvider) ParseEvent(payload []byte) (*Event, error) {
+	event := Event{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (f FakeCapturer) Capture(reference string) error {
+	return nil
+}
+
+func (f FakeProvider) Refund(reference string, amount decimal.Decimal) error {
+	*f.Refunds = append(*f.Refunds, reference)
+	return nil
+}
+
+func (f FakeProvider) FetchStatus(reference string) (choices.PaymentStatus, error) {
+	return f.Status, nil
+}
+
+func (f FakeSubscriptionProvider) CreatePrice(name string, amount decimal.Decimal, interval string) (string, error) {
+	return f.reference("price"), nil
+}
+
+func (f FakeSubscriptionProvider) CreateSubscription(params SubscriptionParams) (*Subscription, error) {
+	subscription := Subscription{
+		Reference: f.reference("subscription"), CustomerReference: f.reference("customer"),
+		InvoiceReference: f.reference("invoice"),
+	}
+	if params.CustomerReference != nil {
+		subscription.CustomerReference = *params.CustomerReference
+	}
+	subscription.ClientSecret = subscription.InvoiceReference + "_secret"
+	return &subscription, nil
+}
+
+func (f FakeSubscriptionProvider) ChangeSubscriptionPrice(reference string, priceReference string) error {
+	return nil
+}
+
+func (f FakeSubscriptionProvider) CancelSubscription(reference string) error {
+	*f.Canceled = append(*f.Canceled, reference)
+	return nil
+}
@@ -0,0 +1,154 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/shopspring/decimal"
+	"google.golang.org/api/androidpublisher/v3"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+// Verifies in-app purchases made with Google Play Billing. Purchases are paid for on the device,
+// so there is nothing for the client to confirm and they are settled as soon as they are verified.
+// Transactions are referenced by "<product id>:<purchase token>".
+type GooglePlayProvider struct {
+	PackageName     string
+	CredentialsFile string
+	PushAudience    string
+}
+
+func NewGooglePlayProvider(cfg config.Config) GooglePlayProvider {
+	return GooglePlayProvider{
+		PackageName: cfg.GooglePlayPackageName, CredentialsFile: cfg.GooglePlayCredentialsFile,
+		PushAudience: cfg.GooglePlayPushAudience,
+	}
+}
+
+func GooglePlayReference(productID string, purchaseToken string) string {
+	return productID + ":" + purchaseToken
+}
+
+func (g GooglePlayProvider) service() (*androidpublisher.Service, error) {
+	return androidpublisher.NewService(context.Background(), option.WithCredentialsFile(g.CredentialsFile))
+}
+
+func (g GooglePlayProvider) purchase(reference string) (*androidpublisher.ProductPurchase, error) {
+	productID, token, ok := strings.Cut(reference, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid google play reference %s", reference)
+	}
+	service, err := g.service()
+	if err != nil {
+		return nil, err
+	}
+	return service.Purchases.Products.Get(g.PackageName, productID, token).Do()
+}
+
+func purchaseStatus(purchase *androidpublisher.ProductPurchase) choices.PaymentStatus {
+	switch purchase.PurchaseState {
+	case 0:
+		return choices.PSSUCCEEDED
+	case 1:
+		return choices.PSCANCELED
+	}
+	return choices.PSPENDING
+}
+
+// Verifies the purchase token sent by the app and consumes the purchase so the product can be bought again
+func (g GooglePlayProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	if params.Token == nil || params.ProductReference == nil {
+		return nil, fmt.Errorf("a google play purchase needs a product and a purchase token")
+	}
+	reference := GooglePlayReference(*params.ProductReference, *params.Token)
+	purchase, err := g.purchase(reference)
+	if err != nil {
+		return nil, err
+	}
+	status := purchaseStatus(purchase)
+	if status == choices.PSSUCCEEDED && purchase.ConsumptionState == 0 {
+		service, err := g.service()
+		if err != nil {
+			return nil, err
+		}
+		if err := service.Purchases.Products.Consume(g.PackageName, *params.ProductReference, *params.Token).Do(); err != nil {
+			return nil, err
+		}
+	}
+	return &Intent{Reference: reference, Status: status}, nil
+}
+
+// Real-time developer notifications are pushed through Cloud Pub/Sub with a google signed token
+func (g GooglePlayProvider) VerifyWebhook(payload []byte, headers http.Header) (*Event, error) {
+	token := strings.TrimPrefix(headers.Get("Authorization"), "Bearer ")
+	if _, err := idtoken.Validate(context.Background(), token, g.PushAudience); err != nil {
+		return nil, err
+	}
+	return g.ParseEvent(payload)
+}
+
+func (g GooglePlayProvider) ParseEvent(payload []byte) (*Event, error) {
+	push := struct {
+		Message struct {
+			Data      []byte `json:"data"` // base64 encoded json
+			MessageID string `json:"messageId"`
+		} `json:"message"`
+	}{}
+	if err := json.Unmarshal(payload, &push); err != nil {
+		return nil, err
+	}
+	notification := struct {
+		PackageName                string `json:"packageName"`
+		OneTimeProductNotification *struct {
+			NotificationType int    `json:"notificationType"`
+			PurchaseToken    string `json:"purchaseToken"`
+			Sku              string `json:"sku"`
+		} `json:"oneTimeProductNotification"`
+	}{}
+	if err := json.Unmarshal(push.Message.Data, &notification); err != nil {
+		return nil, err
+	}
+	event := Event{ID: push.Message.MessageID, Kind: EK_IGNORED}
+	productNotification := notification.OneTimeProductNotification
+	if productNotification == nil {
+		return &event, nil
+	}
+	event.Type = fmt.Sprintf("oneTimeProductNotification.%d", productNotification.NotificationType)
+	event.Reference = GooglePlayReference(productNotification.Sku, productNotification.PurchaseToken)
+	switch productNotification.NotificationType {
+	case 1: // ONE_TIME_PRODUCT_PURCHASED
+		event.Kind = EK_PAYMENT
+		event.Status = choices.PSSUCCEEDED
+	case 2: // ONE_TIME_PRODUCT_CANCELED
+		event.Kind = EK_PAYMENT
+		event.Status = choices.PSCANCELED
+	}
+	return &event, nil
+}
+
+// Google Play only refunds whole orders
+func (g GooglePlayProvider) Refund(reference string, amount decimal.Decimal) error {
+	purchase, err := g.purchase(reference)
+	if err != nil {
+		return err
+	}
+	service, err := g.service()
+	if err != nil {
+		return err
+	}
+	return service.Orders.Refund(g.PackageName, purchase.OrderId).Revoke(true).Do()
+}
+
+func (g GooglePlayProvider) FetchStatus(reference string) (choices.PaymentStatus, error) {
+	purchase, err := g.purchase(reference)
+	if err != nil {
+		return "", err
+	}
+	return purchaseStatus(purchase), nil
+}
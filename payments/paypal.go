@@ -0,0 +1,309 @@
+package payments
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/shopspring/decimal"
+)
+
+var paypalClient = http.Client{Timeout: 30 * time.Second}
+
+// Takes payments through PayPal Orders. Buyers approve orders on PayPal and the app captures them.
+type PaypalProvider struct {
+	ClientID     string
+	ClientSecret string
+	WebhookID    string
+	ApiUrl       string
+}
+
+func NewPaypalProvider(cfg config.Config) PaypalProvider {
+	return PaypalProvider{
+		ClientID: cfg.PaypalClientID, ClientSecret: cfg.PaypalClientSecret,
+		WebhookID: cfg.PaypalWebhookID, ApiUrl: strings.TrimSuffix(cfg.PaypalApiUrl, "/"),
+	}
+}
+
+type paypalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+type paypalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type paypalCapture struct {
+	ID     string       `json:"id"`
+	Status string       `json:"status"`
+	Amount paypalAmount `json:"amount"`
+}
+
+// A capture with the order it belongs to
+type paypalOrderCapture struct {
+	paypalCapture
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+}
+
+type paypalOrder struct {
+	ID            string       `json:"id"`
+	Status        string       `json:"status"`
+	Links         []paypalLink `json:"links"`
+	PurchaseUnits []struct {
+		Payments struct {
+			Captures []paypalCapture `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+}
+
+type paypalRefund struct {
+	ID     string       `json:"id"`
+	Status string       `json:"status"`
+	Amount paypalAmount `json:"amount"`
+	Links  []paypalLink `json:"links"`
+}
+
+type paypalEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+func (p PaypalProvider) accessToken() (string, error) {
+	req, err := http.NewRequest(http.MethodPost, p.ApiUrl+"/v1/oauth2/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.ClientID, p.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := paypalClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("paypal authentication failed with status %d", res.StatusCode)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// Calls the paypal api and decodes the response into result (if given)
+func (p PaypalProvider) request(method string, path string, body interface{}, result interface{}) error {
+	token, err := p.accessToken()
+	if err != nil {
+		return err
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, p.ApiUrl+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := paypalClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		data, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("paypal request to %s failed with status %d: %s", path, res.StatusCode, data)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (p PaypalProvider) getOrder(reference string) (*paypalOrder, error) {
+	order := paypalOrder{}
+	if err := p.request(http.MethodGet, "/v2/checkout/orders/"+reference, nil, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (p PaypalProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	body := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{
+			{
+				"description": params.Description,
+				"amount":      paypalAmount{CurrencyCode: "USD", Value: params.Amount.StringFixed(2)},
+			},
+		},
+	}
+	order := paypalOrder{}
+	if err := p.request(http.MethodPost, "/v2/checkout/orders", body, &order); err != nil {
+		return nil, err
+	}
+	intent := Intent{Reference: order.ID, Status: choices.PSPENDING}
+	for _, link := range order.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			// The buyer approves the order with this link
+			intent.ClientSecret = link.Href
+		}
+	}
+	return &intent, nil
+}
+
+func (p PaypalProvider) VerifyWebhook(payload []byte, headers http.Header) (*Event, error) {
+	body := map[string]interface{}{
+		"auth_algo":         headers.Get("Paypal-Auth-Algo"),
+		"cert_url":          headers.Get("Paypal-Cert-Url"),
+		"transmission_id":   headers.Get("Paypal-Transmission-Id"),
+		"transmission_sig":  headers.Get("Paypal-Transmission-Sig"),
+		"transmission_time": headers.Get("Paypal-Transmission-Time"),
+		"webhook_id":        p.WebhookID,
+		"webhook_event":     json.RawMessage(payload),
+	}
+	result := struct {
+		VerificationStatus string `json:"verification_status"`
+	}{}
+	if err := p.request(http.MethodPost, "/v1/notifications/verify-webhook-signature", body, &result); err != nil {
+		return nil, err
+	}
+	if result.VerificationStatus != "SUCCESS" {
+		return nil, fmt.Errorf("invalid paypal webhook signature")
+	}
+	return p.ParseEvent(payload)
+}
+
+func (p PaypalProvider) ParseEvent(payload []byte) (*Event, error) {
+	paypalEvent := paypalEvent{}
+	if err := json.Unmarshal(payload, &paypalEvent); err != nil {
+		return nil, err
+	}
+	event := Event{ID: paypalEvent.ID, Type: paypalEvent.EventType, Kind: EK_IGNORED}
+
+	switch paypalEvent.EventType {
+	case "CHECKOUT.ORDER.APPROVED":
+		order := paypalOrder{}
+		if err := json.Unmarshal(paypalEvent.Resource, &order); err != nil {
+			return nil, err
+		}
+		event.Kind = EK_PAYMENT_APPROVED
+		event.Reference = order.ID
+		event.Status = choices.PSPENDING
+
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.DENIED", "PAYMENT.CAPTURE.DECLINED":
+		capture := paypalOrderCapture{}
+		if err := json.Unmarshal(paypalEvent.Resource, &capture); err != nil {
+			return nil, err
+		}
+		// Transactions are referenced by their order
+		event.Kind = EK_PAYMENT
+		event.Reference = capture.SupplementaryData.RelatedIDs.OrderID
+		event.Status = choices.PSFAILED
+		if paypalEvent.EventType == "PAYMENT.CAPTURE.COMPLETED" {
+			event.Status = choices.PSSUCCEEDED
+			amount, err := decimal.NewFromString(capture.Amount.Value)
+			if err != nil {
+				return nil, err
+			}
+			event.Amount = &amount
+		}
+
+	case "PAYMENT.CAPTURE.REFUNDED", "PAYMENT.CAPTURE.REVERSED":
+		// Reversals are made by paypal when the buyer's bank charges a payment back
+		paypalRefund := paypalRefund{}
+		if err := json.Unmarshal(paypalEvent.Resource, &paypalRefund); err != nil {
+			return nil, err
+		}
+		var captureUrl string
+		for _, link := range paypalRefund.Links {
+			if link.Rel == "up" {
+				captureUrl = link.Href
+			}
+		}
+		if captureUrl == "" {
+			return nil, fmt.Errorf("refund %s has no capture", paypalRefund.ID)
+		}
+		capture := paypalOrderCapture{}
+		if err := p.request(http.MethodGet, strings.TrimPrefix(captureUrl, p.ApiUrl), nil, &capture); err != nil {
+			return nil, err
+		}
+		if paypalEvent.EventType == "PAYMENT.CAPTURE.REFUNDED" && capture.Status != "REFUNDED" {
+			// Partial refunds are left to support
+			return &event, nil
+		}
+		event.Reference = capture.SupplementaryData.RelatedIDs.OrderID
+		event.Kind = EK_REFUND
+		event.Status = choices.PSREFUNDED
+		if paypalEvent.EventType == "PAYMENT.CAPTURE.REVERSED" {
+			event.Kind = EK_DISPUTE_OPENED
+			event.Status = choices.PSDISPUTED
+		}
+		amount, err := decimal.NewFromString(paypalRefund.Amount.Value)
+		if err != nil {
+			return nil, err
+		}
+		event.Amount = &amount
+	}
+	return &event, nil
+}
+
+func (p PaypalProvider) Capture(reference string) error {
+	order, err := p.getOrder(reference)
+	if err != nil {
+		return err
+	}
+	if order.Status == "COMPLETED" {
+		return nil
+	}
+	return p.request(http.MethodPost, "/v2/checkout/orders/"+reference+"/capture", map[string]interface{}{}, nil)
+}
+
+// Refunds the capture of an order
+func (p PaypalProvider) Refund(reference string, amount decimal.Decimal) error {
+	order, err := p.getOrder(reference)
+	if err != nil {
+		return err
+	}
+	if len(order.PurchaseUnits) == 0 || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return fmt.Errorf("order %s has not been captured", reference)
+	}
+	captureID := order.PurchaseUnits[0].Payments.Captures[0].ID
+	body := map[string]interface{}{"amount": paypalAmount{CurrencyCode: "USD", Value: amount.StringFixed(2)}}
+	return p.request(http.MethodPost, "/v2/payments/captures/"+captureID+"/refund", body, nil)
+}
+
+func (p PaypalProvider) FetchStatus(reference string) (choices.PaymentStatus, error) {
+	order, err := p.getOrder(reference)
+	if err != nil {
+		return "", err
+	}
+	switch order.Status {
+	case "COMPLETED":
+		return choices.PSSUCCEEDED, nil
+	case "VOIDED":
+		return choices.PSCANCELED, nil
+	}
+	return choices.PSPENDING, nil
+}
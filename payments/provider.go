@@ -0,0 +1,141 @@
+package payments
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/shopspring/decimal"
+)
+
+type IntentParams struct {
+	Amount           decimal.Decimal // in USD
+	Description      string
+	Token            *string // a tokenized card (stripe) or a purchase token (google play)
+	ProductReference *string // the store product that was bought (google play)
+}
+
+type Intent struct {
+	Reference    string
+	ClientSecret string                // what the client needs to complete the payment e.g stripe's client secret or paypal's approval link
+	Status       choices.PaymentStatus // PENDING unless the provider settles the payment straight away
+}
+
+type EventKind string
+
+const (
+	EK_IGNORED               EventKind = "IGNORED"
+	EK_PAYMENT               EventKind = "PAYMENT"          // a one-off payment changed status
+	EK_PAYMENT_APPROVED      EventKind = "PAYMENT_APPROVED" // the buyer approved a payment that still has to be captured
+	EK_INVOICE_PAID          EventKind = "INVOICE_PAID"
+	EK_INVOICE_FAILED        EventKind = "INVOICE_FAILED"
+	EK_SUBSCRIPTION_CANCELED EventKind = "SUBSCRIPTION_CANCELED"
+	EK_SUBSCRIPTION_RESUMED  EventKind = "SUBSCRIPTION_RESUMED"
+	EK_SUBSCRIPTION_PAST_DUE EventKind = "SUBSCRIPTION_PAST_DUE"
+	EK_SUBSCRIPTION_ENDED    EventKind = "SUBSCRIPTION_ENDED"
+	EK_REFUND                EventKind = "REFUND"         // a payment was refunded in full
+	EK_DISPUTE_OPENED        EventKind = "DISPUTE_OPENED" // the buyer's bank is charging a payment back
+	EK_DISPUTE_WON           EventKind = "DISPUTE_WON"    // a charged back payment was returned to the app
+)
+
+// A provider's webhook event reduced to what the app acts on
+type Event struct {
+	ID        string
+	Type      string // the provider's event type
+	Kind      EventKind
+	Reference string                // the payment or invoice the event is about
+	Status    choices.PaymentStatus // the payment's new status
+	Amount    *decimal.Decimal      // the amount paid in USD, nil when the provider doesn't report it
+
+	// For subscription events
+	SubscriptionReference string
+	PriceReference        *string
+	PeriodStart           time.Time
+	PeriodEnd             time.Time
+}
+
+type PaymentProvider interface {
+	CreateIntent(params IntentParams) (*Intent, error)
+	// Checks that a webhook request came from the provider and parses its event
+	VerifyWebhook(payload []byte, headers http.Header) (*Event, error)
+	// Parses an event that has been verified already e.g when it is replayed
+	ParseEvent(payload []byte) (*Event, error)
+	// Refunds the given amount of a payment
+	Refund(reference string, amount decimal.Decimal) error
+	FetchStatus(reference string) (choices.PaymentStatus, error)
+}
+
+// Implemented by providers whose approved payments have to be captured by the app
+type Capturer interface {
+	Capture(reference string) error
+}
+
+type SubscriptionParams struct {
+	Email             string
+	Name              string
+	PriceReference    string
+	CustomerReference *string
+	Token             string
+	AmountOff         decimal.Decimal // taken off the first invoice only
+}
+
+type Subscription struct {
+	Reference         string
+	CustomerReference string
+	InvoiceReference  string // the first invoice
+	ClientSecret      string // for confirming the first payment on the client
+}
+
+// Implemented by providers that can renew subscriptions automatically
+type SubscriptionProvider interface {
+	// Creates a recurring price. Interval is either month or year.
+	CreatePrice(name string, amount decimal.Decimal, interval string) (string, error)
+	CreateSubscription(params SubscriptionParams) (*Subscription, error)
+	// Moves a subscription to another price, charging the prorated difference immediately
+	ChangeSubscriptionPrice(reference string, priceReference string) error
+	// Stops a subscription from renewing at the end of its period
+	CancelSubscription(reference string) error
+}
+
+// Providers set here are used instead of the real ones (see UseFake)
+var overrides = map[choices.PaymentType]PaymentProvider{}
+
+func Get(paymentType choices.PaymentType) (PaymentProvider, error) {
+	if provider, ok := overrides[paymentType]; ok {
+		return provider, nil
+	}
+	cfg := config.GetConfig()
+	switch paymentType {
+	case choices.PTYPE_STRIPE:
+		return NewStripeProvider(cfg), nil
+	case choices.PTYPE_PAYPAL:
+		return NewPaypalProvider(cfg), nil
+	case choices.PTYPE_GPAY:
+		return NewGooglePlayProvider(cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported payment type %s", paymentType)
+}
+
+// Returns the payment type for the slug used in webhook urls
+func TypeFromSlug(slug string) (choices.PaymentType, bool) {
+	switch slug {
+	case "stripe":
+		return choices.PTYPE_STRIPE, true
+	case "paypal":
+		return choices.PTYPE_PAYPAL, true
+	case "google-play":
+		return choices.PTYPE_GPAY, true
+	}
+	return "", false
+}
+
+func toCents(amount decimal.Decimal) int64 {
+	return amount.Mul(decimal.NewFromInt(100)).IntPart()
+}
+
+func fromCents(cents int64) *decimal.Decimal {
+	amount := decimal.NewFromInt(cents).Div(decimal.NewFromInt(100))
+	return &amount
+}
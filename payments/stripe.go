@@ -0,0 +1,329 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/charge"
+	"github.com/stripe/stripe-go/v78/coupon"
+	"github.com/stripe/stripe-go/v78/customer"
+	"github.com/stripe/stripe-go/v78/invoice"
+	"github.com/stripe/stripe-go/v78/paymentintent"
+	"github.com/stripe/stripe-go/v78/paymentmethod"
+	"github.com/stripe/stripe-go/v78/price"
+	"github.com/stripe/stripe-go/v78/refund"
+	"github.com/stripe/stripe-go/v78/subscription"
+	"github.com/stripe/stripe-go/v78/webhook"
+)
+
+type StripeProvider struct {
+	WebhookSecret string
+}
+
+func NewStripeProvider(cfg config.Config) StripeProvider {
+	stripe.Key = cfg.StripeSecretKey
+	return StripeProvider{WebhookSecret: cfg.StripeWebhookSecret}
+}
+
+// Creates a payment method from a tokenized card (e.g a Google Pay token)
+func (s StripeProvider) paymentMethod(token string) (*stripe.PaymentMethod, error) {
+	return paymentmethod.New(&stripe.PaymentMethodParams{
+		Type: stripe.String("card"),
+		Card: &stripe.PaymentMethodCardParams{Token: stripe.String(token)},
+	})
+}
+
+func (s StripeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	intentParams := &stripe.PaymentIntentParams{
+		Amount:      stripe.Int64(toCents(params.Amount)),
+		Currency:    stripe.String(string(stripe.CurrencyUSD)),
+		Description: stripe.String(params.Description),
+	}
+
+	// Determine payment method type based on token presence
+	if params.Token != nil {
+		// Tokenized card e.g Google Pay
+		paymentMethod, err := s.paymentMethod(*params.Token)
+		if err != nil {
+			return nil, err
+		}
+		intentParams.PaymentMethod = stripe.String(paymentMethod.ID)
+		intentParams.ConfirmationMethod = stripe.String(string(stripe.PaymentIntentConfirmationMethodManual))
+		intentParams.Confirm = stripe.Bool(true)
+	} else {
+		// Card, Cashapp, etc (No token provided)
+		intentParams.PaymentMethodTypes = stripe.StringSlice([]string{"card", "cashapp"})
+	}
+	intent, err := paymentintent.New(intentParams)
+	if err != nil {
+		return nil, err
+	}
+	// Payments are settled through the webhook events
+	return &Intent{Reference: intent.ID, ClientSecret: intent.ClientSecret, Status: choices.PSPENDING}, nil
+}
+
+func (s StripeProvider) VerifyWebhook(payload []byte, headers http.Header) (*Event, error) {
+	if _, err := webhook.ConstructEvent(payload, headers.Get("Stripe-Signature"), s.WebhookSecret); err != nil {
+		return nil, err
+	}
+	return s.ParseEvent(payload)
+}
+
+func (s StripeProvider) ParseEvent(payload []byte) (*Event, error) {
+	stripeEvent := stripe.Event{}
+	if err := json.Unmarshal(payload, &stripeEvent); err != nil {
+		return nil, err
+	}
+	event := Event{ID: stripeEvent.ID, Type: string(stripeEvent.Type), Kind: EK_IGNORED}
+
+	switch stripeEvent.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed", "payment_intent.canceled":
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &intent); err != nil {
+			return nil, err
+		}
+		if intent.Invoice != nil {
+			// Recurring subscription payments are settled through the invoice events
+			return &event, nil
+		}
+		event.Kind = EK_PAYMENT
+		event.Reference = intent.ID
+		event.Amount = fromCents(intent.AmountReceived)
+		event.Status = choices.PSSUCCEEDED
+		switch stripeEvent.Type {
+		case "payment_intent.payment_failed":
+			event.Status = choices.PSFAILED
+		case "payment_intent.canceled":
+			event.Status = choices.PSCANCELED
+		}
+
+	case "invoice.paid", "invoice.payment_failed":
+		var stripeInvoice stripe.Invoice
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &stripeInvoice); err != nil {
+			return nil, err
+		}
+		if stripeInvoice.Subscription == nil {
+			return &event, nil
+		}
+		event.Reference = stripeInvoice.ID
+		event.SubscriptionReference = stripeInvoice.Subscription.ID
+		if stripeEvent.Type == "invoice.payment_failed" {
+			event.Kind = EK_INVOICE_FAILED
+			event.Status = choices.PSFAILED
+			event.Amount = fromCents(stripeInvoice.AmountDue)
+			return &event, nil
+		}
+		event.Kind = EK_INVOICE_PAID
+		event.Status = choices.PSSUCCEEDED
+		event.Amount = fromCents(stripeInvoice.AmountPaid)
+		// The line with the new price holds the period that was paid for.
+		// Plan changes also have a negative line crediting the unused part of the old plan.
+		if stripeInvoice.Lines != nil {
+			for _, line := range stripeInvoice.Lines.Data {
+				if line.Amount < 0 || line.Period == nil {
+					continue
+				}
+				event.PeriodStart, event.PeriodEnd = time.Unix(line.Period.Start, 0), time.Unix(line.Period.End, 0)
+				if line.Price != nil {
+					event.PriceReference = &line.Price.ID
+				}
+			}
+		}
+
+	case "charge.refunded":
+		var stripeCharge stripe.Charge
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &stripeCharge); err != nil {
+			return nil, err
+		}
+		if !stripeCharge.Refunded {
+			// Partial refunds are left to support
+			return &event, nil
+		}
+		event.Kind = EK_REFUND
+		event.Reference = chargeReference(&stripeCharge)
+		event.Status = choices.PSREFUNDED
+		event.Amount = fromCents(stripeCharge.AmountRefunded)
+
+	case "charge.dispute.created", "charge.dispute.closed":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &dispute); err != nil {
+			return nil, err
+		}
+		if dispute.Charge == nil {
+			return &event, nil
+		}
+		disputedCharge := dispute.Charge
+		if disputedCharge.Created == 0 {
+			// The charge isn't expanded, so the invoice it paid for (if any) is unknown
+			fetchedCharge, err := charge.Get(disputedCharge.ID, nil)
+			if err != nil {
+				return nil, err
+			}
+			disputedCharge = fetchedCharge
+		}
+		event.Reference = chargeReference(disputedCharge)
+		event.Amount = fromCents(dispute.Amount)
+		if stripeEvent.Type == "charge.dispute.created" {
+			event.Kind = EK_DISPUTE_OPENED
+			event.Status = choices.PSDISPUTED
+		} else if dispute.Status == stripe.DisputeStatusWon {
+			event.Kind = EK_DISPUTE_WON
+			event.Status = choices.PSSUCCEEDED
+		}
+
+	case "customer.subscription.updated", "customer.subscription.deleted":
+		var stripeSubscription stripe.Subscription
+		if err := json.Unmarshal(stripeEvent.Data.Raw, &stripeSubscription); err != nil {
+			return nil, err
+		}
+		event.SubscriptionReference = stripeSubscription.ID
+		switch {
+		case stripeEvent.Type == "customer.subscription.deleted",
+			stripeSubscription.Status == stripe.SubscriptionStatusCanceled,
+			stripeSubscription.Status == stripe.SubscriptionStatusIncompleteExpired:
+			event.Kind = EK_SUBSCRIPTION_ENDED
+		case stripeSubscription.Status == stripe.SubscriptionStatusPastDue,
+			stripeSubscription.Status == stripe.SubscriptionStatusUnpaid:
+			event.Kind = EK_SUBSCRIPTION_PAST_DUE
+		case stripeSubscription.CancelAtPeriodEnd:
+			event.Kind = EK_SUBSCRIPTION_CANCELED
+		default:
+			event.Kind = EK_SUBSCRIPTION_RESUMED
+		}
+	}
+	return &event, nil
+}
+
+// Returns how the transaction of a charge is referenced: by its invoice for subscriptions, by its payment intent otherwise
+func chargeReference(stripeCharge *stripe.Charge) string {
+	if stripeCharge.Invoice != nil {
+		return stripeCharge.Invoice.ID
+	}
+	if stripeCharge.PaymentIntent != nil {
+		return stripeCharge.PaymentIntent.ID
+	}
+	return stripeCharge.ID
+}
+
+// Refunds a payment intent. Invoices are refunded through their payment intent.
+func (s StripeProvider) Refund(reference string, amount decimal.Decimal) error {
+	if strings.HasPrefix(reference, "in_") {
+		stripeInvoice, err := invoice.Get(reference, nil)
+		if err != nil {
+			return err
+		}
+		if stripeInvoice.PaymentIntent == nil {
+			return fmt.Errorf("invoice %s has no payment", reference)
+		}
+		reference = stripeInvoice.PaymentIntent.ID
+	}
+	_, err := refund.New(&stripe.RefundParams{PaymentIntent: stripe.String(reference), Amount: stripe.Int64(toCents(amount))})
+	return err
+}
+
+func (s StripeProvider) FetchStatus(reference string) (choices.PaymentStatus, error) {
+	intent, err := paymentintent.Get(reference, nil)
+	if err != nil {
+		return "", err
+	}
+	switch intent.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return choices.PSSUCCEEDED, nil
+	case stripe.PaymentIntentStatusCanceled:
+		return choices.PSCANCELED, nil
+	}
+	return choices.PSPENDING, nil
+}
+
+func (s StripeProvider) CreatePrice(name string, amount decimal.Decimal, interval string) (string, error) {
+	stripePrice, err := price.New(&stripe.PriceParams{
+		Currency:    stripe.String(string(stripe.CurrencyUSD)),
+		UnitAmount:  stripe.Int64(toCents(amount)),
+		Recurring:   &stripe.PriceRecurringParams{Interval: stripe.String(interval)},
+		ProductData: &stripe.PriceProductDataParams{Name: stripe.String(name)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return stripePrice.ID, nil
+}
+
+// Creates a subscription that renews automatically with the given card.
+// The first invoice is charged straight away and its payment intent is returned with the subscription.
+func (s StripeProvider) CreateSubscription(params SubscriptionParams) (*Subscription, error) {
+	customerReference := params.CustomerReference
+	if customerReference == nil {
+		stripeCustomer, err := customer.New(&stripe.CustomerParams{Email: stripe.String(params.Email), Name: stripe.String(params.Name)})
+		if err != nil {
+			return nil, err
+		}
+		customerReference = &stripeCustomer.ID
+	}
+	paymentMethod, err := s.paymentMethod(params.Token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := paymentmethod.Attach(paymentMethod.ID, &stripe.PaymentMethodAttachParams{Customer: customerReference}); err != nil {
+		return nil, err
+	}
+
+	subscriptionParams := &stripe.SubscriptionParams{
+		Customer:             customerReference,
+		Items:                []*stripe.SubscriptionItemsParams{{Price: stripe.String(params.PriceReference)}},
+		DefaultPaymentMethod: stripe.String(paymentMethod.ID),
+		PaymentBehavior:      stripe.String("allow_incomplete"),
+	}
+	if params.AmountOff.IsPositive() {
+		stripeCoupon, err := coupon.New(&stripe.CouponParams{
+			AmountOff: stripe.Int64(toCents(params.AmountOff)), Currency: stripe.String(string(stripe.CurrencyUSD)),
+			Duration: stripe.String(string(stripe.CouponDurationOnce)), MaxRedemptions: stripe.Int64(1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		subscriptionParams.Discounts = []*stripe.SubscriptionDiscountParams{{Coupon: stripe.String(stripeCoupon.ID)}}
+	}
+	subscriptionParams.AddExpand("latest_invoice.payment_intent")
+	stripeSubscription, err := subscription.New(subscriptionParams)
+	if err != nil {
+		return nil, err
+	}
+	result := Subscription{Reference: stripeSubscription.ID, CustomerReference: *customerReference}
+	if stripeInvoice := stripeSubscription.LatestInvoice; stripeInvoice != nil {
+		result.InvoiceReference = stripeInvoice.ID
+		if stripeInvoice.PaymentIntent != nil {
+			result.ClientSecret = stripeInvoice.PaymentIntent.ClientSecret
+		}
+	}
+	return &result, nil
+}
+
+func (s StripeProvider) ChangeSubscriptionPrice(reference string, priceReference string) error {
+	stripeSubscription, err := subscription.Get(reference, nil)
+	if err != nil {
+		return err
+	}
+	if stripeSubscription.Items == nil || len(stripeSubscription.Items.Data) == 0 {
+		return fmt.Errorf("subscription %s has no items", reference)
+	}
+	_, err = subscription.Update(reference, &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{ID: stripe.String(stripeSubscription.Items.Data[0].ID), Price: stripe.String(priceReference)},
+		},
+		ProrationBehavior: stripe.String("always_invoice"),
+		PaymentBehavior:   stripe.String("error_if_incomplete"),
+	})
+	return err
+}
+
+func (s StripeProvider) CancelSubscription(reference string) error {
+	_, err := subscription.Update(reference, &stripe.SubscriptionParams{CancelAtPeriodEnd: stripe.Bool(true)})
+	return err
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Returns the redis store when redis is set up, otherwise counts are kept in memory
+func NewStore(cfg config.Config) Store {
+	if cfg.RedisUrl == "" {
+		return NewMemoryStore()
+	}
+	return NewRedisStore(cfg.RedisUrl)
+}
+
+// Returns a middleware that limits requests by a policy. Signed in users are counted by their ID,
+// so it should come after AuthMiddleware where there's one, and everyone else by their IP address.
+func New(store Store, name PolicyName) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		policy := Get(name)
+		count, resetIn, err := store.Hit(c.UserContext(), key(c, name), policy.Window)
+		if err != nil {
+			// Requests are let through rather than failing along with the store
+			log.Println("Rate limit store error:", err)
+			return c.Next()
+		}
+		if resetIn < 0 {
+			resetIn = policy.Window
+		}
+		reset := strconv.Itoa(int(math.Ceil(resetIn.Seconds())))
+		c.Set("RateLimit-Limit", strconv.Itoa(policy.Max))
+		c.Set("RateLimit-Remaining", strconv.Itoa(max(policy.Max-count, 0)))
+		c.Set("RateLimit-Reset", reset)
+		c.Set("RateLimit-Policy", policy.String())
+		if count > policy.Max {
+			c.Set(fiber.HeaderRetryAfter, reset)
+			return c.Status(429).JSON(utils.RateLimitError("Rate Limit Reached"))
+		}
+		return c.Next()
+	}
+}
+
+func key(c *fiber.Ctx, name PolicyName) string {
+	user, ok := c.Locals("user").(*models.User)
+	if ok && user != nil && user.ID != uuid.Nil {
+		return fmt.Sprintf("ratelimit:%s:user:%s", name, user.ID)
+	}
+	return fmt.Sprintf("ratelimit:%s:ip:%s", name, c.IP())
+}
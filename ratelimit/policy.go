@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type PolicyName string
+
+const (
+	GLOBAL   PolicyName = "global"   // every request, by IP address
+	AUTH     PolicyName = "auth"     // signing up, in and everything around it
+	COMMENTS PolicyName = "comments" // reviews, replies and paragraph comments
+	VOTES    PolicyName = "votes"
+	GIFTS    PolicyName = "gifts"
+)
+
+// How many requests a user or an IP address can make in a window
+type Policy struct {
+	Max    int
+	Window time.Duration
+}
+
+// As described by the RateLimit-Policy header e.g 100;w=60
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Max, int(p.Window.Seconds()))
+}
+
+var policies = map[PolicyName]Policy{
+	GLOBAL:   {Max: 100, Window: time.Minute},
+	AUTH:     {Max: 20, Window: time.Minute},
+	COMMENTS: {Max: 10, Window: time.Minute},
+	VOTES:    {Max: 30, Window: time.Minute},
+	GIFTS:    {Max: 10, Window: time.Minute},
+}
+
+// Policies set here are used instead of the ones above (see Use)
+var (
+	overridesMu sync.RWMutex
+	overrides   = map[PolicyName]Policy{}
+)
+
+func Get(name PolicyName) Policy {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	if policy, ok := overrides[name]; ok {
+		return policy
+	}
+	return policies[name]
+}
+
+// Makes Get return a policy, e.g a small one to reach the limit quickly in tests
+func Use(name PolicyName, policy Policy) func() {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides[name] = policy
+	return func() {
+		overridesMu.Lock()
+		defer overridesMu.Unlock()
+		delete(overrides, name)
+	}
+}
+
+// Every policy's name, e.g to relax them all in tests
+func Names() []PolicyName {
+	names := []PolicyName{}
+	for name := range policies {
+		names = append(names, name)
+	}
+	return names
+}
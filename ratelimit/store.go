@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Counts the requests of a key in fixed windows
+type Store interface {
+	// Counts a request and returns the number of requests in the current window and how long until it ends
+	Hit(ctx context.Context, key string, window time.Duration) (int, time.Duration, error)
+}
+
+// Keeps counts in redis so they survive restarts and are shared by every instance of the api
+type RedisStore struct {
+	Client *redis.Client
+}
+
+// The window starts with the first request, and counting and setting its expiry at once
+// keeps a key from being left without one
+var hitScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+func NewRedisStore(url string) *RedisStore {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		// Urls are given as host:port for asynq
+		options = &redis.Options{Addr: url}
+	}
+	return &RedisStore{Client: redis.NewClient(options)}
+}
+
+func (r *RedisStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	result, err := hitScript.Run(ctx, r.Client, []string{key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(result[0]), time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// Keeps counts in memory, for running without redis e.g locally
+type MemoryStore struct {
+	mu      sync.Mutex
+	windows map[string]memoryWindow
+	sweptAt time.Time
+}
+
+type memoryWindow struct {
+	count  int
+	endsAt time.Time
+}
+
+// Ended windows are dropped this often so keys of past visitors don't pile up
+const memorySweepInterval = 10 * time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: map[string]memoryWindow{}, sweptAt: time.Now()}
+}
+
+func (m *MemoryStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if now.Sub(m.sweptAt) > memorySweepInterval {
+		for windowKey, current := range m.windows {
+			if now.After(current.endsAt) {
+				delete(m.windows, windowKey)
+			}
+		}
+		m.sweptAt = now
+	}
+	current, ok := m.windows[key]
+	if !ok || now.After(current.endsAt) {
+		current = memoryWindow{endsAt: now.Add(window)}
+	}
+	current.count++
+	m.windows[key] = current
+	return current.count, current.endsAt.Sub(now), nil
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/senders"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List Author Applications
+// @Description Retrieves the applications of readers to become authors, newest first, with optional filtering by status.
+// @Tags Admin | Authors
+// @Accept json
+// @Produce json
+// @Param status query string false "Status to filter by" Enums(PENDING, APPROVED, DECLINED)
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.AuthorApplicationsResponseSchema "Successfully retrieved list of applications"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/authors/applications [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetAuthorApplications(c *fiber.Ctx) error {
+	db := ep.DB
+	var status *choices.AuthorApplicationStatusChoice
+	statusQuery := GetQueryValue(c, "status")
+	if statusQuery != nil {
+		statusVal := choices.AuthorApplicationStatusChoice(*statusQuery)
+		if !statusVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid application status"))
+		}
+		status = &statusVal
+	}
+
+	applications := authorApplicationManager.GetAll(db, status)
+	// Paginate and return applications
+	paginatedData, paginatedApplications, err := PaginateQueryset(applications, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	applications = paginatedApplications.([]models.AuthorApplication)
+	response := schemas.AuthorApplicationsResponseSchema{
+		ResponseSchema: ResponseMessage("Applications fetched successfully"),
+		Data: schemas.AuthorApplicationsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(applications),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Get An Author Application
+// @Description Retrieves a single application to become an author.
+// @Tags Admin | Authors
+// @Param id path string true "Application ID (uuid)"
+// @Success 200 {object} schemas.AuthorApplicationResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /admin/authors/applications/{id} [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetAuthorApplication(c *fiber.Ctx) error {
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	application := authorApplicationManager.GetByID(ep.DB, *parsedID)
+	if application == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No application with that ID"))
+	}
+	response := schemas.AuthorApplicationResponseSchema{
+		ResponseSchema: ResponseMessage("Application fetched successfully"),
+		Data:           schemas.AuthorApplicationSchema{}.Init(*application),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Review An Author Application
+// @Description Approves or declines an application to become an author. A reason is required for declining.
+// @Description Approval makes the applicant a verified author. Either way the applicant is notified and emailed.
+// @Tags Admin | Authors
+// @Param id path string true "Application ID (uuid)"
+// @Param data body schemas.AuthorApplicationReviewSchema true "Review data"
+// @Success 200 {object} schemas.AuthorApplicationResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /admin/authors/applications/{id} [put]
+// @Security BearerAuth
+func (ep Endpoint) AdminReviewAuthorApplication(c *fiber.Ctx) error {
+	db := ep.DB
+	reviewer := RequestUser(c)
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	application := authorApplicationManager.GetByID(db, *parsedID)
+	if application == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No application with that ID"))
+	}
+
+	data := schemas.AuthorApplicationReviewSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if errData := authorApplicationManager.Review(db, application, *reviewer, data.Status, data.Reason); errData != nil {
+		errCode := 400
+		if errData.Code == utils.ERR_INVALID_ENTRY {
+			errCode = 422
+		}
+		return c.Status(errCode).JSON(errData)
+	}
+
+	// Let the applicant know
+	message := "Application declined successfully"
+	emailType := senders.ET_AUTHOR_DECLINED
+	text := "Your author application wasn't approved this time."
+	if data.Status == choices.AAS_APPROVED {
+		message = "Application approved successfully"
+		emailType = senders.ET_AUTHOR_APPROVED
+		text = "Your author application has been approved. Welcome aboard!"
+	}
+	notification := notificationManager.Create(db, reviewer, application.User, choices.NT_AUTHOR_APPLICATION, text, nil, nil, nil)
+	SendNotificationInSocket(c, notification)
+	extraData := map[string]interface{}{}
+	if data.Reason != nil {
+		extraData["reason"] = *data.Reason
+	}
+	go senders.SendEmail(&application.User, emailType, nil, nil, extraData)
+
+	response := schemas.AuthorApplicationResponseSchema{
+		ResponseSchema: ResponseMessage(message),
+		Data:           schemas.AuthorApplicationSchema{}.Init(*application),
+	}
+	return c.Status(200).JSON(response)
+}
@@ -707,6 +707,7 @@ func (ep Endpoint) AdminGetBookRetentionStats(c *fiber.Ctx) error {
 		return c.Status(404).JSON(err)
 	}
 	retentionData := bookManager.GetReaderRetentionPieData(db, book.ID)
+	retentionData.Chapters = readingProgressManager.GetChapterDropOff(db, book.ID)
 	response := schemas.BookRetentionStatsResponseSchema{
 		ResponseSchema: ResponseMessage("Book Retention data fetched successfully"),
 		Data:           retentionData,
@@ -31,7 +31,7 @@ func (ep Endpoint) AdminDashboard(c *fiber.Ctx) error {
 		return c.Status(400).JSON(utils.InvalidParamErr("Invalid user growth filter choice!"))
 	}
 	totalUsers := userManager.GetCount(db)
-	activeSubscribers := userManager.GetActiveSubscribersCount(db)
+	activeSubscribers := subscriptionManager.GetActiveCount(db)
 	subscriptionRevenue := transactionManager.GetSubscriptionRevenue(db)
 	userSubscriptionPlanPercentages := userManager.GetUserPlanPercentages(db)
 	userGrowthData := userManager.GetUserGrowthData(db, userGrowthFilter)
@@ -1,10 +1,19 @@
 package routes
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
 	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 // @Summary Update A Plan Amount
@@ -25,6 +34,10 @@ func (ep Endpoint) UpdateSubscriptionPlan(c *fiber.Ctx) error {
 	}
 	plan := models.SubscriptionPlan{SubType: data.SubType}
 	db.Take(&plan, plan)
+	if !plan.Amount.Equal(data.Amount) {
+		// Stripe prices can't be edited, a new one is created for the next subscriptions
+		plan.PriceReference = nil
+	}
 	plan.Amount = data.Amount
 	db.Save(&plan)
 	response := schemas.SubscriptionPlanResponseSchema{
@@ -76,4 +89,527 @@ func (ep Endpoint) AdminGetTransactions(c *fiber.Ctx) error {
 		}.Init(transactions),
 	}
 	return c.Status(200).JSON(response)
-}
\ No newline at end of file
+}
+
+// @Summary Refund A Transaction
+// @Description `This endpoint allows an admin to refund a successful payment in full through its provider`
+// @Description `The coins it bought are taken back (leaving any that were spent as debt) or the subscription time it paid for is removed`
+// @Tags Admin | Payments
+// @Param id path string true "Transaction ID (uuid)"
+// @Success 200 {object} schemas.PaymentResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /admin/payments/transactions/{id}/refund [post]
+// @Security BearerAuth
+func (ep Endpoint) AdminRefundTransaction(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	transaction := models.Transaction{}
+	db.Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, "transactions.id = ?", parsedID)
+	if transaction.ID == uuid.Nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No transaction with that ID"))
+	}
+	if transaction.PaymentStatus != choices.PSSUCCEEDED {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Only successful payments can be refunded"))
+	}
+	provider, err := payments.Get(transaction.PaymentType)
+	if err != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This payment can't be refunded"))
+	}
+	if err := provider.Refund(transaction.Reference, transactionAmount(transaction)); err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Failed to refund the payment"))
+	}
+	// The provider's refund event finds the transaction reversed already
+	refundedTransaction, err := reverseTransaction(db, transaction.Reference, choices.PSREFUNDED)
+	if err != nil {
+		return c.Status(500).JSON(utils.ServerErr("The payment was refunded but what it paid for couldn't be taken back"))
+	}
+	response := schemas.PaymentResponseSchema{
+		ResponseSchema: ResponseMessage("Transaction refunded successfully"),
+		Data:           schemas.TransactionSchema{}.Init(*refundedTransaction),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Authors Earnings Ledger with Pagination
+// @Description Retrieves the earnings ledger of all authors with support for pagination and optional filtering based on username and source.
+// @Tags Admin | Payments
+// @Accept json
+// @Produce json
+// @Param username query string false "Username to filter by"
+// @Param source query string false "Source to filter by" Enums(GIFT, CHAPTER_PURCHASE, BOOK_PURCHASE, SUBSCRIPTION_SHARE, PAYOUT, PAYOUT_REVERSAL)
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.AuthorEarningsResponseSchema "Successfully retrieved list of earnings"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/earnings [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetEarnings(c *fiber.Ctx) error {
+	db := ep.DB
+	var authorID *uuid.UUID
+	username := GetQueryValue(c, "username")
+	if username != nil {
+		user := models.User{Username: *username}
+		db.Take(&user, user)
+		// A non-existent username leaves a nil ID which empties the query
+		authorID = &user.ID
+	}
+
+	var source *choices.EarningSourceChoice
+	sourceQuery := GetQueryValue(c, "source")
+	if sourceQuery != nil {
+		sourceVal := choices.EarningSourceChoice(*sourceQuery)
+		if !sourceVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid earning source"))
+		}
+		source = &sourceVal
+	}
+
+	earnings := earningManager.GetAll(db, authorID, source)
+	// Paginate and return earnings
+	paginatedData, paginatedEarnings, err := PaginateQueryset(earnings, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	earnings = paginatedEarnings.([]models.AuthorEarning)
+	responseData := schemas.AuthorEarningsResponseDataSchema{
+		PaginatedResponseDataSchema: *paginatedData,
+	}
+	if authorID != nil {
+		balance := earningManager.GetBalance(db, *authorID)
+		responseData.Balance = &balance
+	}
+	response := schemas.AuthorEarningsResponseSchema{
+		ResponseSchema: ResponseMessage("Earnings fetched successfully"),
+		Data:           responseData.Init(earnings),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Authors Payout Requests with Pagination
+// @Description Retrieves payout requests with support for pagination and optional filtering based on username and status.
+// @Tags Admin | Payments
+// @Accept json
+// @Produce json
+// @Param username query string false "Username to filter by"
+// @Param status query string false "Status to filter by" Enums(REQUESTED, APPROVED, PAID, DECLINED)
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.PayoutsResponseSchema "Successfully retrieved list of payouts"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/payouts [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetPayouts(c *fiber.Ctx) error {
+	db := ep.DB
+	var authorID *uuid.UUID
+	username := GetQueryValue(c, "username")
+	if username != nil {
+		user := models.User{Username: *username}
+		db.Take(&user, user)
+		authorID = &user.ID
+	}
+
+	var status *choices.PayoutStatusChoice
+	statusQuery := GetQueryValue(c, "status")
+	if statusQuery != nil {
+		statusVal := choices.PayoutStatusChoice(*statusQuery)
+		if !statusVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid payout status"))
+		}
+		status = &statusVal
+	}
+
+	payouts := payoutManager.GetAll(db, authorID, status)
+	// Paginate and return payouts
+	paginatedData, paginatedPayouts, err := PaginateQueryset(payouts, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	payouts = paginatedPayouts.([]models.Payout)
+	response := schemas.PayoutsResponseSchema{
+		ResponseSchema: ResponseMessage("Payouts fetched successfully"),
+		Data: schemas.PayoutsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(payouts),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Update A Payout Status
+// @Description `This endpoint allows an admin to move a payout through its workflow: REQUESTED -> APPROVED -> PAID`
+// @Description `A requested or approved payout can also be DECLINED, which returns the amount to the author's balance`
+// @Description `A reference is required when marking a payout as PAID`
+// @Tags Admin | Payments
+// @Param id path string true "Payout ID (uuid)"
+// @Param payout body schemas.PayoutStatusUpdateSchema true "Payout status data"
+// @Success 200 {object} schemas.PayoutResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /admin/payments/payouts/{id} [put]
+// @Security BearerAuth
+func (ep Endpoint) AdminUpdatePayoutStatus(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	payout := payoutManager.GetByID(db, *parsedID)
+	if payout == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No payout with that ID"))
+	}
+
+	data := schemas.PayoutStatusUpdateSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if err := payoutManager.UpdateStatus(db, payout, data.Status, data.Note, data.Reference); err != nil {
+		return c.Status(400).JSON(err)
+	}
+	response := schemas.PayoutResponseSchema{
+		ResponseSchema: ResponseMessage("Payout updated successfully"),
+		Data:           schemas.PayoutSchema{}.Init(*payout),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Payment Events with Pagination
+// @Description `Retrieves the payment webhook events that were received, e.g to find the ones that failed to be applied.`
+// @Tags Admin | Payments
+// @Accept json
+// @Produce json
+// @Param status query string false "Status to filter by" Enums(RECEIVED, PROCESSED, FAILED, IGNORED)
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.PaymentEventsResponseSchema "Successfully retrieved list of events"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/events [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetPaymentEvents(c *fiber.Ctx) error {
+	db := ep.DB
+	var status *choices.PaymentEventStatusChoice
+	statusQuery := GetQueryValue(c, "status")
+	if statusQuery != nil {
+		statusVal := choices.PaymentEventStatusChoice(*statusQuery)
+		if !statusVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid event status"))
+		}
+		status = &statusVal
+	}
+
+	events := paymentEventManager.GetAll(db, status)
+	// Paginate and return events
+	paginatedData, paginatedEvents, err := PaginateQueryset(events, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	events = paginatedEvents.([]models.PaymentEvent)
+	response := schemas.PaymentEventsResponseSchema{
+		ResponseSchema: ResponseMessage("Events fetched successfully"),
+		Data: schemas.PaymentEventsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(events),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Replay A Payment Event
+// @Description `This endpoint allows an admin to apply a stored payment event again, e.g after fixing what made it fail`
+// @Description `Events that have been processed already can't be replayed`
+// @Tags Admin | Payments
+// @Param id path string true "Payment Event ID (uuid)"
+// @Success 200 {object} schemas.PaymentEventResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /admin/payments/events/{id}/replay [post]
+// @Security BearerAuth
+func (ep Endpoint) AdminReplayPaymentEvent(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	event := paymentEventManager.GetByID(db, *parsedID)
+	if event == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No payment event with that ID"))
+	}
+	if event.Status == choices.PES_PROCESSED {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This event has been processed already"))
+	}
+	if err := processPaymentEvent(db, ep.Config, event); err != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, err.Error()))
+	}
+	event = paymentEventManager.GetByID(db, event.ID)
+	response := schemas.PaymentEventResponseSchema{
+		ResponseSchema: ResponseMessage("Event replayed successfully"),
+		Data:           schemas.PaymentEventSchema{}.Init(*event),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Revenue Report
+// @Description `Retrieves the revenue of each day, week or month in a date range, split by payment purpose, subscription plan and coin pack`
+// @Description `Add format=csv to download the report as a csv file`
+// @Tags Admin | Payments
+// @Produce json
+// @Produce text/csv
+// @Param interval query string false "Length of each period" Enums(day, week, month) default(day)
+// @Param start query string false "First day of the report (YYYY-MM-DD). Defaults to 30 days before the end"
+// @Param end query string false "Last day of the report (YYYY-MM-DD). Defaults to today"
+// @Param format query string false "Response format" Enums(json, csv)
+// @Success 200 {object} schemas.RevenueReportResponseSchema "Successfully retrieved the report"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/reports/revenue [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetRevenueReport(c *fiber.Ctx) error {
+	db := ep.DB
+	interval := choices.ReportIntervalChoice(c.Query("interval", string(choices.RI_DAY)))
+	if !interval.IsValid() {
+		return c.Status(400).JSON(utils.InvalidParamErr("Invalid report interval"))
+	}
+	start, end, errD := ReportRange(c)
+	if errD != nil {
+		return c.Status(400).JSON(errD)
+	}
+
+	rows := transactionManager.GetRevenueReport(db, interval, *start, *end)
+	if WantsCSV(c) {
+		header := []string{"period", "payment_purpose", "sub_type", "coin_pack", "payments", "gross_revenue", "refunded_amount", "net_revenue"}
+		csvRows := [][]string{}
+		for _, row := range rows {
+			subType, coinPack := "", ""
+			if row.SubType != nil {
+				subType = string(*row.SubType)
+			}
+			if row.CoinPack != nil {
+				coinPack = strconv.Itoa(*row.CoinPack)
+			}
+			csvRows = append(csvRows, []string{
+				row.Period.Format(time.DateOnly), string(row.PaymentPurpose), subType, coinPack, strconv.FormatInt(row.Payments, 10),
+				row.GrossRevenue.StringFixed(2), row.RefundedAmount.StringFixed(2), row.NetRevenue.StringFixed(2),
+			})
+		}
+		filename := fmt.Sprintf("revenue_%s_%s_%s.csv", interval, start.Format(time.DateOnly), end.AddDate(0, 0, -1).Format(time.DateOnly))
+		return SendCSV(c, filename, header, csvRows)
+	}
+	response := schemas.RevenueReportResponseSchema{
+		ResponseSchema: ResponseMessage("Revenue report fetched successfully"),
+		Data:           schemas.RevenueReportSchema{Start: *start, End: end.AddDate(0, 0, -1), Interval: interval, Items: rows},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Revenue Summary
+// @Description `Retrieves the revenue, refund and failure rates and average revenue per user (ARPU) of the payments made in a date range`
+// @Description `Add format=csv to download the summary as a csv file`
+// @Tags Admin | Payments
+// @Produce json
+// @Produce text/csv
+// @Param start query string false "First day of the summary (YYYY-MM-DD). Defaults to 30 days before the end"
+// @Param end query string false "Last day of the summary (YYYY-MM-DD). Defaults to today"
+// @Param format query string false "Response format" Enums(json, csv)
+// @Success 200 {object} schemas.RevenueSummaryResponseSchema "Successfully retrieved the summary"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/reports/summary [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetRevenueSummary(c *fiber.Ctx) error {
+	db := ep.DB
+	start, end, errD := ReportRange(c)
+	if errD != nil {
+		return c.Status(400).JSON(errD)
+	}
+
+	summary := transactionManager.GetRevenueSummary(db, *start, *end)
+	summary.End = end.AddDate(0, 0, -1)
+	if WantsCSV(c) {
+		header := []string{
+			"start", "end", "payments", "failed_payments", "refunds", "chargebacks", "gross_revenue", "refunded_amount",
+			"net_revenue", "refund_rate", "failure_rate", "users", "paying_users", "arpu", "arppu",
+		}
+		row := []string{
+			summary.Start.Format(time.DateOnly), summary.End.Format(time.DateOnly),
+			strconv.FormatInt(summary.Payments, 10), strconv.FormatInt(summary.FailedPayments, 10),
+			strconv.FormatInt(summary.Refunds, 10), strconv.FormatInt(summary.Chargebacks, 10),
+			summary.GrossRevenue.StringFixed(2), summary.RefundedAmount.StringFixed(2), summary.NetRevenue.StringFixed(2),
+			strconv.FormatFloat(summary.RefundRate, 'f', 4, 64), strconv.FormatFloat(summary.FailureRate, 'f', 4, 64),
+			strconv.FormatInt(summary.Users, 10), strconv.FormatInt(summary.PayingUsers, 10),
+			summary.Arpu.StringFixed(2), summary.Arppu.StringFixed(2),
+		}
+		filename := fmt.Sprintf("revenue_summary_%s_%s.csv", summary.Start.Format(time.DateOnly), summary.End.Format(time.DateOnly))
+		return SendCSV(c, filename, header, [][]string{row})
+	}
+	response := schemas.RevenueSummaryResponseSchema{
+		ResponseSchema: ResponseMessage("Revenue summary fetched successfully"),
+		Data:           summary,
+	}
+	return c.Status(200).JSON(response)
+}
+
+// Checks a promo code's data and returns the plan or coin pack it is restricted to
+func validatePromoCodeData(db *gorm.DB, data schemas.PromoCodeCreateSchema, promoCode *models.PromoCode) (*models.SubscriptionPlan, *models.Coin, *utils.ErrorResponse) {
+	existingPromoCode := promoCodeManager.GetByCode(db, data.Code)
+	if existingPromoCode != nil && (promoCode == nil || existingPromoCode.ID != promoCode.ID) {
+		errD := utils.ValidationErr("code", "Promo code already exists")
+		return nil, nil, &errD
+	}
+	if data.DiscountValue.IsNegative() {
+		errD := utils.ValidationErr("discount_value", "Can't be negative")
+		return nil, nil, &errD
+	}
+	if data.DiscountType == choices.PDT_PERCENTAGE && data.DiscountValue.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		errD := utils.ValidationErr("discount_value", "Must be less than 100 percent")
+		return nil, nil, &errD
+	}
+	if !data.DiscountValue.IsPositive() && data.BonusCoins == 0 {
+		errD := utils.ValidationErr("discount_value", "Set a discount or bonus coins")
+		return nil, nil, &errD
+	}
+	if data.StartsAt != nil && data.EndsAt != nil && !data.EndsAt.After(*data.StartsAt) {
+		errD := utils.ValidationErr("ends_at", "Must be after the start")
+		return nil, nil, &errD
+	}
+	if data.SubType != nil && data.CoinID != nil {
+		errD := utils.ValidationErr("coin_id", "A promo code can't be restricted to a plan and a coin pack")
+		return nil, nil, &errD
+	}
+
+	var plan *models.SubscriptionPlan
+	if data.SubType != nil {
+		plan = &models.SubscriptionPlan{}
+		db.Take(plan, "sub_type = ?", *data.SubType)
+		if plan.ID == uuid.Nil {
+			errD := utils.ValidationErr("subtype", "No subscription plan with that type")
+			return nil, nil, &errD
+		}
+	}
+	var coin *models.Coin
+	if data.CoinID != nil {
+		coin = &models.Coin{}
+		db.Take(coin, "id = ?", *data.CoinID)
+		if coin.ID == uuid.Nil {
+			errD := utils.ValidationErr("coin_id", "No set of coins with that ID")
+			return nil, nil, &errD
+		}
+	}
+	return plan, coin, nil
+}
+
+// @Summary Promo Codes with Pagination
+// @Description `Retrieves all promo codes with how many times each has been redeemed`
+// @Tags Admin | Payments
+// @Accept json
+// @Produce json
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.PromoCodesResponseSchema "Successfully retrieved list of promo codes"
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Router /admin/payments/promo-codes [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetPromoCodes(c *fiber.Ctx) error {
+	db := ep.DB
+	promoCodes := promoCodeManager.GetAll(db)
+	// Paginate and return promo codes
+	paginatedData, paginatedPromoCodes, err := PaginateQueryset(promoCodes, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	promoCodes = paginatedPromoCodes.([]models.PromoCode)
+	response := schemas.PromoCodesResponseSchema{
+		ResponseSchema: ResponseMessage("Promo codes fetched successfully"),
+		Data: schemas.PromoCodesResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(promoCodes, promoCodeManager.GetRedemptionCounts(db, promoCodes)),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Add A Promo Code
+// @Description `This endpoint allows an admin to create a promo code that discounts coin packs or subscription plans and/or adds bonus coins`
+// @Description `The discount is a percentage or a fixed amount in USD. A code can be restricted to a single plan or coin pack`
+// @Tags Admin | Payments
+// @Param promo_code body schemas.PromoCodeCreateSchema true "Promo code data"
+// @Success 201 {object} schemas.PromoCodeResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /admin/payments/promo-codes [post]
+// @Security BearerAuth
+func (ep Endpoint) AdminCreatePromoCode(c *fiber.Ctx) error {
+	db := ep.DB
+	data := schemas.PromoCodeCreateSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	plan, coin, errD := validatePromoCodeData(db, data, nil)
+	if errD != nil {
+		return c.Status(422).JSON(errD)
+	}
+	promoCode := promoCodeManager.Create(db, data, plan, coin)
+	response := schemas.PromoCodeResponseSchema{
+		ResponseSchema: ResponseMessage("Promo code created successfully"),
+		Data:           schemas.PromoCodeSchema{}.Init(promoCode, 0),
+	}
+	return c.Status(201).JSON(response)
+}
+
+// @Summary Update A Promo Code
+// @Description `This endpoint allows an admin to update a promo code, e.g to deactivate it or extend its validity`
+// @Tags Admin | Payments
+// @Param id path string true "Promo Code ID (uuid)"
+// @Param promo_code body schemas.PromoCodeCreateSchema true "Promo code data"
+// @Success 200 {object} schemas.PromoCodeResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /admin/payments/promo-codes/{id} [put]
+// @Security BearerAuth
+func (ep Endpoint) AdminUpdatePromoCode(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	promoCode := promoCodeManager.GetByID(db, *parsedID)
+	if promoCode == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No promo code with that ID"))
+	}
+
+	data := schemas.PromoCodeCreateSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	plan, coin, errD := validatePromoCodeData(db, data, promoCode)
+	if errD != nil {
+		return c.Status(422).JSON(errD)
+	}
+	updatedPromoCode := promoCodeManager.Update(db, *promoCode, data, plan, coin)
+	response := schemas.PromoCodeResponseSchema{
+		ResponseSchema: ResponseMessage("Promo code updated successfully"),
+		Data:           schemas.PromoCodeSchema{}.Init(updatedPromoCode, promoCodeManager.GetRedemptionCount(db, updatedPromoCode, nil)),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Delete A Promo Code
+// @Description `This endpoint allows an admin to delete a promo code that hasn't been redeemed. Redeemed codes should be deactivated instead`
+// @Tags Admin | Payments
+// @Param id path string true "Promo Code ID (uuid)"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /admin/payments/promo-codes/{id} [delete]
+// @Security BearerAuth
+func (ep Endpoint) AdminDeletePromoCode(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	promoCode := promoCodeManager.GetByID(db, *parsedID)
+	if promoCode == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No promo code with that ID"))
+	}
+	if promoCodeManager.GetRedemptionCount(db, *promoCode, nil) > 0 {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code has been redeemed. Deactivate it instead"))
+	}
+	db.Delete(promoCode)
+	return c.Status(200).JSON(ResponseMessage("Promo code deleted successfully"))
+}
@@ -99,13 +99,15 @@ func (ep Endpoint) AdminUpdateUser(c *fiber.Ctx) error {
 }
 
 // @Summary Invite Admin
-// @Description Updates the account type of a specified user ot an admin and/or author.
+// @Description Gives a user a staff role and optionally makes them an author.
+// @Description Roles: superadmin, content-moderator, finance, support, staff
 // @Tags Admin | Users
 // @Accept json
 // @Produce json
 // @Param data body schemas.InviteAdminSchema true "Role update data"
 // @Success 200 {object} schemas.UserProfileResponseSchema "Successfully updated user details"
 // @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 403 {object} utils.ErrorResponse "Permission denied"
 // @Failure 500 {object} utils.ErrorResponse "Internal server error"
 // @Router /admin/users/admins/invite [post]
 // @Security BearerAuth
@@ -122,13 +124,15 @@ func (ep Endpoint) InviteAdmin(c *fiber.Ctx) error {
 	if user.ID == uuid.Nil{
 		return c.Status(422).JSON(utils.ValidationErr("email", "No user with that email"))
 	}
-	if data.Admin {
-		user.IsStaff = true
+	role := roleManager.GetByCodename(db, data.Role)
+	if role == nil {
+		return c.Status(500).JSON(utils.ServerErr("Roles haven't been set up"))
 	}
+	roleManager.Assign(db, &user, role)
 	if data.Author {
 		user.AccountType = choices.ACCTYPE_AUTHOR
+		db.Model(&user).Update("account_type", user.AccountType)
 	}
-	db.Save(&user)
 
 	response := schemas.UserProfileResponseSchema{
 		ResponseSchema: ResponseMessage("User upgraded successfully!"),
@@ -137,6 +141,90 @@ func (ep Endpoint) InviteAdmin(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+// @Summary List Roles
+// @Description Returns the roles and the permissions each of them gives.
+// @Tags Admin | Users
+// @Produce json
+// @Success 200 {object} schemas.RolesResponseSchema
+// @Failure 403 {object} utils.ErrorResponse "Permission denied"
+// @Router /admin/users/roles [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetRoles(c *fiber.Ctx) error {
+	roles := roleManager.GetAll(ep.DB)
+	data := []schemas.RoleSchema{}
+	for _, role := range roles {
+		data = append(data, schemas.RoleSchema{}.Init(role))
+	}
+	response := schemas.RolesResponseSchema{
+		ResponseSchema: ResponseMessage("Roles fetched successfully"),
+		Data:           data,
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary List Lockouts
+// @Description Returns the accounts and IP addresses that are locked out of, or slowed down on, an auth action after failed attempts.
+// @Tags Admin | Users
+// @Produce json
+// @Param scope query string false "Auth action to filter by" Enums(LOGIN, VERIFY_EMAIL, PASSWORD_RESET)
+// @Param page query int false "Current page" default(1)
+// @Success 200 {object} schemas.LockoutsResponseSchema
+// @Failure 400 {object} utils.ErrorResponse "Invalid query parameters"
+// @Failure 403 {object} utils.ErrorResponse "Permission denied"
+// @Router /admin/users/lockouts [get]
+// @Security BearerAuth
+func (ep Endpoint) AdminGetLockouts(c *fiber.Ctx) error {
+	db := ep.DB
+	var scope *choices.AuthThrottleScopeChoice
+	scopeQuery := GetQueryValue(c, "scope")
+	if scopeQuery != nil {
+		scopeVal := choices.AuthThrottleScopeChoice(*scopeQuery)
+		if !scopeVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid scope"))
+		}
+		scope = &scopeVal
+	}
+
+	throttles := authThrottleManager.GetActive(db, scope)
+	// Paginate and return lockouts
+	paginatedData, paginatedThrottles, err := PaginateQueryset(throttles, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	throttles = paginatedThrottles.([]models.AuthThrottle)
+	response := schemas.LockoutsResponseSchema{
+		ResponseSchema: ResponseMessage("Lockouts fetched successfully"),
+		Data: schemas.LockoutsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(throttles),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Clear A Lockout
+// @Description Lets an account or IP address try an auth action again straight away.
+// @Tags Admin | Users
+// @Produce json
+// @Param id path string true "Lockout ID (uuid)"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse "Invalid ID"
+// @Failure 404 {object} utils.ErrorResponse "Lockout not found"
+// @Router /admin/users/lockouts/{id} [delete]
+// @Security BearerAuth
+func (ep Endpoint) AdminClearLockout(c *fiber.Ctx) error {
+	db := ep.DB
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	throttle := authThrottleManager.GetByID(db, *parsedID)
+	if throttle == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No lockout with that ID"))
+	}
+	authThrottleManager.Delete(db, *throttle)
+	return c.Status(200).JSON(ResponseMessage("Lockout cleared successfully"))
+}
+
 // @Summary Reactivate/Deactivate User
 // @Description Allows the admin to deactivate/reactivate a user.
 // @Tags Admin | Users
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/models/scopes"
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/senders"
@@ -54,6 +55,7 @@ func (ep Endpoint) Register(c *fiber.Ctx) error {
 // @Param verify_email body schemas.VerifyEmailRequestSchema true "Verify Email object"
 // @Success 200 {object} schemas.ResponseSchema
 // @Failure 422 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
 // @Router /auth/verify-email [post]
 func (ep Endpoint) VerifyEmail(c *fiber.Ctx) error {
 	db := ep.DB
@@ -65,11 +67,18 @@ func (ep Endpoint) VerifyEmail(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
+	// Slow down OTP guessing
+	if errCode, errData := CheckAuthThrottle(c, db, choices.ATS_VERIFY_EMAIL, data.Email); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
 	user := models.User{Email: data.Email, Otp: &data.Otp}
 	db.Take(&user, user)
 	if user.ID == uuid.Nil {
+		RecordAuthFailure(c, db, choices.ATS_VERIFY_EMAIL, data.Email)
 		return c.Status(404).JSON(utils.RequestErr(utils.ERR_INCORRECT_OTP, "Invalid Email or OTP"))
 	}
+	authThrottleManager.Clear(db, choices.ATS_VERIFY_EMAIL, data.Email)
 
 	if user.IsOtpExpired() {
 		return c.Status(400).JSON(utils.RequestErr(utils.ERR_EXPIRED_OTP, "Expired OTP"))
@@ -129,6 +138,7 @@ func (ep Endpoint) ResendVerificationEmail(c *fiber.Ctx) error {
 // @Success 200 {object} schemas.ResponseSchema
 // @Failure 422 {object} utils.ErrorResponse
 // @Failure 404 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
 // @Router /auth/send-password-reset-link [post]
 func (ep Endpoint) SendPasswordResetLink(c *fiber.Ctx) error {
 	db := ep.DB
@@ -140,6 +150,12 @@ func (ep Endpoint) SendPasswordResetLink(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
+	// Every request counts since each one sends an email, so links can't be requested endlessly
+	if errCode, errData := CheckAuthThrottle(c, db, choices.ATS_PASSWORD_RESET, data.Email); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	RecordAuthFailure(c, db, choices.ATS_PASSWORD_RESET, data.Email)
+
 	user := models.User{Email: data.Email}
 	db.Take(&user, user)
 	if user.ID == uuid.Nil {
@@ -211,6 +227,8 @@ func (ep Endpoint) SetNewPassword(c *fiber.Ctx) error {
 	user.TokenString = nil
 	user.TokenExpiry = nil
 	db.Save(&user)
+	// Whoever can reset the password owns the account, so its lockouts end here
+	authThrottleManager.ClearAccount(db, user.Email)
 
 	// Send Email
 	go senders.SendEmail(&user, senders.ET_RESET_SUCC, nil, nil, nil)
@@ -219,11 +237,14 @@ func (ep Endpoint) SetNewPassword(c *fiber.Ctx) error {
 
 // @Summary Login a user
 // @Description This endpoint generates new access and refresh tokens for authentication
+// @Description Users with two-factor authentication, and all staff, get a challenge token instead. It is exchanged for tokens at /auth/2fa/verify.
 // @Tags Auth
 // @Param user body schemas.LoginSchema true "User login"
 // @Success 201 {object} schemas.LoginResponseSchema
+// @Success 200 {object} schemas.TwoFactorChallengeResponseSchema
 // @Failure 422 {object} utils.ErrorResponse
 // @Failure 401 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
 // @Router /auth/login [post]
 func (ep Endpoint) Login(c *fiber.Ctx) error {
 	db := ep.DB
@@ -235,76 +256,87 @@ func (ep Endpoint) Login(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
+	if errCode, errData := CheckAuthThrottle(c, db, choices.ATS_LOGIN, data.Email); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
 	user := models.User{Email: data.Email}
 	db.Scopes(scopes.FollowerFollowingUnVerifiedPreloaderScope).Take(&user, user)
 	if user.ID == uuid.Nil || !utils.CheckPasswordHash(data.Password, user.Password) {
+		RecordAuthFailure(c, db, choices.ATS_LOGIN, data.Email)
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_CREDENTIALS, "Invalid Credentials"))
 	}
+	authThrottleManager.Clear(db, choices.ATS_LOGIN, data.Email)
 
 	if !user.IsEmailVerified {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNVERIFIED_USER, "Verify your email first"))
 	}
 
-	// Create Auth Tokens
-	tokens := userManager.GenerateAuthTokens(db, user, GenerateAccessToken(user), GenerateRefreshToken())
-	featuredContents := userManager.GetFeaturedContents(db, user)
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: ResponseMessage("Login successful"),
-		Data:           schemas.TokensResponseSchema{Access: tokens.Access, Refresh: tokens.Refresh}.Init(user, featuredContents),
-	}
-	return c.Status(201).JSON(response)
+	// Create Auth Tokens, or a challenge for users with two-factor authentication
+	return LoginOrChallenge(c, db, user, data.DeviceType, "Login successful")
 }
 
-// @Summary Login a user via google
-// @Description `This endpoint generates new access and refresh tokens for authentication via google`
-// @Description `Pass in token gotten from gsi client authentication here in payload to retrieve tokens for authorization`
+// @Summary Unlock an account
+// @Description `This endpoint ends the lockout of an account with the token from the link in the lockout email.`
 // @Tags Auth
-// @Param user body schemas.SocialLoginSchema true "User login"
-// @Success 201 {object} schemas.LoginResponseSchema
+// @Param token body schemas.UnlockAccountSchema true "Unlock object"
+// @Success 200 {object} schemas.ResponseSchema
 // @Failure 422 {object} utils.ErrorResponse
-// @Failure 401 {object} utils.ErrorResponse
-// @Router /auth/google [post]
-func (ep Endpoint) GoogleLogin(c *fiber.Ctx) error {
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /auth/unlock-account [post]
+func (ep Endpoint) UnlockAccount(c *fiber.Ctx) error {
 	db := ep.DB
 
-	data := schemas.SocialLoginSchema{}
+	data := schemas.UnlockAccountSchema{}
 
 	// Validate request
 	if errCode, errData := ValidateRequest(c, &data); errData != nil {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	userGoogleData, errData := ConvertGoogleToken(data.Token, data.DeviceType)
-	if errData != nil {
-		return c.Status(401).JSON(errData)
+	if !authThrottleManager.Unlock(db, data.Token) {
+		return c.Status(404).JSON(utils.RequestErr(utils.ERR_INCORRECT_TOKEN, "Invalid or expired unlock token"))
 	}
+	return c.Status(200).JSON(ResponseMessage("Account unlocked successfully"))
+}
 
-	email := userGoogleData.Email
-	name := userGoogleData.Name
-	avatar := userGoogleData.Picture
+// @Summary Revert an email change
+// @Description `This endpoint gives a user back their old email with the token from the link sent to it when the email was changed.`
+// @Description `The user is logged out of every device.`
+// @Tags Auth
+// @Param token body schemas.RevertEmailChangeSchema true "Revert object"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /auth/revert-email [post]
+func (ep Endpoint) RevertEmailChange(c *fiber.Ctx) error {
+	db := ep.DB
 
-	user, token, err := RegisterSocialUser(db, email, name, &avatar, "google")
-	if err != nil {
-		return c.Status(401).JSON(err)
-	}
-	featuredContents := userManager.GetFeaturedContents(db, *user)
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: ResponseMessage("Social Login successful"),
-		Data:           schemas.TokensResponseSchema{Access: token.Access, Refresh: token.Refresh}.Init(*user, featuredContents),
+	data := schemas.RevertEmailChangeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
 	}
-	return c.Status(201).JSON(response)
+
+	change, errData := emailChangeManager.Revert(db, data.Token)
+	if errData != nil {
+		errCode := 500
+		switch errData.Code {
+		case utils.ERR_NON_EXISTENT:
+			errCode = 404
+		case utils.ERR_NOT_ALLOWED:
+			errCode = 400
+		}
+		return c.Status(errCode).JSON(errData)
+	}
+	go senders.UpdateBrevoEmail(change.User.Username, change.NewEmail, change.OldEmail)
+	return c.Status(200).JSON(ResponseMessage("Email reverted successfully. Set a new password if someone else changed it"))
 }
 
-// @Summary Login a user via facebook
-// @Description `This endpoint generates new access and refresh tokens for authentication via facebook`
-// @Description `Pass in token gotten from facebook client authentication here in payload to retrieve tokens for authorization`
-// @Tags Auth
-// @Param user body schemas.SocialLoginSchema true "User login"
-// @Success 201 {object} schemas.LoginResponseSchema
-// @Failure 422 {object} utils.ErrorResponse
-// @Failure 401 {object} utils.ErrorResponse
-// @Router /auth/facebook [post]
-func (ep Endpoint) FacebookLogin(c *fiber.Ctx) error {
+// Signs in with an identity provider's token, creating the user on their first sign in
+func (ep Endpoint) socialLogin(c *fiber.Ctx, provider choices.SocialProviderChoice, message string) error {
 	db := ep.DB
 
 	data := schemas.SocialLoginSchema{}
@@ -314,24 +346,62 @@ func (ep Endpoint) FacebookLogin(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	userFacebookData, errData := ConvertFacebookToken(data.Token)
+	userIdentity, errCode, errData := VerifySocialToken(provider, data.Token, data.DeviceType)
 	if errData != nil {
-		return c.Status(401).JSON(errData)
+		return c.Status(*errCode).JSON(errData)
 	}
 
-	email := userFacebookData.Email
-	name := userFacebookData.Name
-
-	user, token, err := RegisterSocialUser(db, email, name, nil, "facebook")
+	user, err := RegisterSocialUser(db, *userIdentity, data.Name)
 	if err != nil {
 		return c.Status(401).JSON(err)
 	}
-	featuredContents := userManager.GetFeaturedContents(db, *user)
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: ResponseMessage("Login successful"),
-		Data:           schemas.TokensResponseSchema{Access: token.Access, Refresh: token.Refresh}.Init(*user, featuredContents),
-	}
-	return c.Status(201).JSON(response)
+	return LoginOrChallenge(c, db, *user, &data.DeviceType, message)
+}
+
+// @Summary Login a user via google
+// @Description `This endpoint generates new access and refresh tokens for authentication via google`
+// @Description `Pass in token gotten from gsi client authentication here in payload to retrieve tokens for authorization`
+// @Tags Auth
+// @Param user body schemas.SocialLoginSchema true "User login"
+// @Success 201 {object} schemas.LoginResponseSchema
+// @Success 200 {object} schemas.TwoFactorChallengeResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
+// @Router /auth/google [post]
+func (ep Endpoint) GoogleLogin(c *fiber.Ctx) error {
+	return ep.socialLogin(c, choices.SP_GOOGLE, "Social Login successful")
+}
+
+// @Summary Login a user via facebook
+// @Description `This endpoint generates new access and refresh tokens for authentication via facebook`
+// @Description `Pass in token gotten from facebook client authentication here in payload to retrieve tokens for authorization`
+// @Tags Auth
+// @Param user body schemas.SocialLoginSchema true "User login"
+// @Success 201 {object} schemas.LoginResponseSchema
+// @Success 200 {object} schemas.TwoFactorChallengeResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
+// @Router /auth/facebook [post]
+func (ep Endpoint) FacebookLogin(c *fiber.Ctx) error {
+	return ep.socialLogin(c, choices.SP_FACEBOOK, "Login successful")
+}
+
+// @Summary Login a user via apple
+// @Description `This endpoint generates new access and refresh tokens for authentication via Sign in with Apple`
+// @Description `Pass in the identity token gotten from apple client authentication here in payload to retrieve tokens for authorization`
+// @Description `Apple only gives the user's name to the client on the first sign in, so pass it in as name then`
+// @Tags Auth
+// @Param user body schemas.SocialLoginSchema true "User login"
+// @Success 201 {object} schemas.LoginResponseSchema
+// @Success 200 {object} schemas.TwoFactorChallengeResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
+// @Router /auth/apple [post]
+func (ep Endpoint) AppleLogin(c *fiber.Ctx) error {
+	return ep.socialLogin(c, choices.SP_APPLE, "Social Login successful")
 }
 
 // @Summary Refresh tokens
@@ -354,18 +424,22 @@ func (ep Endpoint) Refresh(c *fiber.Ctx) error {
 	}
 
 	refreshToken := data.Refresh
-	token := models.AuthToken{Refresh: refreshToken}
-	db.Take(&token, token)
-	if token.ID == uuid.Nil || !DecodeRefreshToken(refreshToken) {
+	token := userManager.GetAuthTokenByRefresh(db, refreshToken)
+	if token == nil || !DecodeRefreshToken(refreshToken) {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token is invalid or expired"))
 	}
 	user := models.User{BaseModel: models.BaseModel{ID: token.UserID}}
 	db.Find(scopes.FollowerFollowingPreloaderScope).Take(&user, user)
 
-	// Create and Update Auth Tokens
-	token.Access = GenerateAccessToken(user)
-	token.Refresh = GenerateRefreshToken()
-	db.Save(&token)
+	// Replace the session's tokens
+	newToken, reused, err := userManager.RotateAuthTokens(db, *token, GenerateAccessToken(user), GenerateRefreshToken(), RequestDevice(c, nil))
+	if err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong while refreshing your tokens"))
+	}
+	if reused {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token has been used already. Login again"))
+	}
+	token = newToken
 	featuredContents := userManager.GetFeaturedContents(db, user)
 
 	response := schemas.LoginResponseSchema{
@@ -403,3 +477,47 @@ func (ep Endpoint) LogoutAll(c *fiber.Ctx) error {
 	userManager.DeleteAllToken(db, *user)
 	return c.Status(200).JSON(ResponseMessage("Logout from all devices successful"))
 }
+
+// @Summary List sessions
+// @Description `This endpoint returns the devices a user is signed in on`
+// @Tags Auth
+// @Success 200 {object} schemas.SessionsResponseSchema
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /auth/sessions [get]
+// @Security BearerAuth
+func (ep Endpoint) GetSessions(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	currentAccess := c.Get("Authorization")[7:]
+	sessions := []schemas.SessionSchema{}
+	for _, token := range userManager.GetSessions(db, *user) {
+		sessions = append(sessions, schemas.SessionSchema{}.Init(token, currentAccess))
+	}
+	response := schemas.SessionsResponseSchema{
+		ResponseSchema: ResponseMessage("Sessions fetched successfully"),
+		Data:           sessions,
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Revoke a session
+// @Description `This endpoint logs a user out from one device. The tokens of that session can't be used or refreshed afterwards.`
+// @Tags Auth
+// @Param id path string true "Session ID (uuid)"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+// @Security BearerAuth
+func (ep Endpoint) RevokeSession(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	parsedID := ParseUUID(c.Params("id"))
+	if parsedID == nil {
+		return c.Status(400).JSON(utils.InvalidParamErr("You entered an invalid uuid"))
+	}
+	if !userManager.RevokeSession(db, *user, *parsedID) {
+		return c.Status(404).JSON(utils.NotFoundErr("You don't have a session with that ID"))
+	}
+	return c.Status(200).JSON(ResponseMessage("Session revoked successfully"))
+}
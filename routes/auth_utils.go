@@ -1,22 +1,24 @@
 package routes
 
 import (
-	"context"
 	"fmt"
 	"log"
-	"strings"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/identity"
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/models/scopes"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/senders"
 	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	fb "github.com/huandu/facebook/v2"
-	"github.com/mitchellh/mapstructure"
-	"google.golang.org/api/idtoken"
 	"gorm.io/gorm"
 )
 
@@ -27,6 +29,20 @@ type AccessTokenPayload struct {
 	jwt.RegisteredClaims
 }
 
+// Given after a correct password to users who still have to pass a second factor.
+// It can only be exchanged for tokens at /auth/2fa/verify.
+type TwoFactorChallengePayload struct {
+	UserId     uuid.UUID           `json:"user_id"`
+	Purpose    string              `json:"purpose"`
+	DeviceType *choices.DeviceType `json:"device_type"`
+	jwt.RegisteredClaims
+}
+
+const (
+	twoFactorChallengePurpose = "2fa"
+	twoFactorChallengeExpiry  = 5 * time.Minute
+)
+
 type RefreshTokenPayload struct {
 	Data string `json:"data"`
 	jwt.RegisteredClaims
@@ -46,6 +62,8 @@ func GenerateAccessToken(user models.User, admin...bool) string {
 		RegisteredClaims: jwt.RegisteredClaims{
 			// In JWT, the expiry time is expressed as unix milliseconds
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			// Keeps tokens issued to a user in the same second apart
+			ID: uuid.NewString(),
 		},
 	}
 
@@ -99,13 +117,107 @@ func DecodeAccessToken(token string, db *gorm.DB) (*models.User, *string) {
 		return nil, &tokenErr
 	}
 	tokenObj := models.AuthToken{UserID: claims.UserId, Access: token}
-	result := db.Joins("User").Take(&tokenObj, tokenObj)
+	result := db.Joins("User").Where("auth_tokens.rotated_at IS NULL").Take(&tokenObj, tokenObj)
 	if result.Error != nil {
 		return nil, &tokenErr
 	}
+	// Recorded every few minutes at most, to keep requests from writing each time
+	if tokenObj.LastUsedAt == nil || time.Since(*tokenObj.LastUsedAt) > 5*time.Minute {
+		db.Model(&tokenObj).UpdateColumn("last_used_at", time.Now())
+	}
 	return &tokenObj.User, nil
 }
 
+// Returns the device a request was made from
+func RequestDevice(c *fiber.Ctx, deviceType *choices.DeviceType) managers.SessionDevice {
+	return managers.SessionDevice{UserAgent: c.Get(fiber.HeaderUserAgent), IP: c.IP(), DeviceType: deviceType}
+}
+
+func GenerateTwoFactorChallenge(user models.User, deviceType *choices.DeviceType) (string, time.Time) {
+	cfg := config.GetConfig()
+	expirationTime := time.Now().Add(twoFactorChallengeExpiry)
+	payload := TwoFactorChallengePayload{
+		UserId:     user.ID,
+		Purpose:    twoFactorChallengePurpose,
+		DeviceType: deviceType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        uuid.NewString(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	tokenString, err := token.SignedString(cfg.SecretKeyByte)
+	if err != nil {
+		log.Fatal("Error Generating Two Factor Challenge token: ", err)
+	}
+	return tokenString, expirationTime
+}
+
+func DecodeTwoFactorChallenge(token string, db *gorm.DB) (*models.User, *choices.DeviceType, *string) {
+	cfg := config.GetConfig()
+
+	claims := &TwoFactorChallengePayload{}
+	tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return cfg.SecretKeyByte, nil
+	})
+	tokenErr := "Challenge token is invalid or expired. Login again"
+	if err != nil || !tkn.Valid || claims.Purpose != twoFactorChallengePurpose {
+		return nil, nil, &tokenErr
+	}
+	user := models.User{}
+	result := db.Scopes(scopes.FollowerFollowingPreloaderScope).Take(&user, "id = ?", claims.UserId)
+	if result.Error != nil || !user.IsActive {
+		return nil, nil, &tokenErr
+	}
+	return &user, claims.DeviceType, nil
+}
+
+// Returns the second factors a user can sign in with
+func TwoFactorMethods(user models.User) []choices.TwoFactorMethodChoice {
+	if user.TotpEnabled {
+		return []choices.TwoFactorMethodChoice{choices.TFM_TOTP, choices.TFM_EMAIL, choices.TFM_RECOVERY}
+	}
+	return []choices.TwoFactorMethodChoice{choices.TFM_EMAIL}
+}
+
+// Emails a user a code to sign in with
+func SendTwoFactorEmail(db *gorm.DB, user *models.User) {
+	user.GenerateOTP(db)
+	db.Model(user).Updates(map[string]interface{}{"otp": user.Otp, "otp_expiry": user.OtpExpiry})
+	go senders.SendEmail(user, senders.ET_LOGIN_OTP, user.Otp, nil, nil)
+}
+
+// Signs a user in, or returns a challenge first if they have to pass a second factor
+func LoginOrChallenge(c *fiber.Ctx, db *gorm.DB, user models.User, deviceType *choices.DeviceType, message string) error {
+	if user.RequiresTwoFactor() {
+		challengeToken, expiresAt := GenerateTwoFactorChallenge(user, deviceType)
+		challengeMessage := "Enter a code from your authenticator app to continue"
+		if !user.TotpEnabled {
+			// Staff without an authenticator app get their code by email
+			SendTwoFactorEmail(db, &user)
+			challengeMessage = "Enter the code sent to your email to continue"
+		}
+		response := schemas.TwoFactorChallengeResponseSchema{
+			ResponseSchema: ResponseMessage(challengeMessage),
+			Data: schemas.TwoFactorChallengeSchema{
+				ChallengeToken: challengeToken, Methods: TwoFactorMethods(user), ExpiresAt: expiresAt,
+			},
+		}
+		return c.Status(200).JSON(response)
+	}
+	return issueLoginTokens(c, db, user, deviceType, message)
+}
+
+func issueLoginTokens(c *fiber.Ctx, db *gorm.DB, user models.User, deviceType *choices.DeviceType, message string) error {
+	tokens := userManager.GenerateAuthTokens(db, user, GenerateAccessToken(user), GenerateRefreshToken(), RequestDevice(c, deviceType))
+	featuredContents := userManager.GetFeaturedContents(db, user)
+	response := schemas.LoginResponseSchema{
+		ResponseSchema: ResponseMessage(message),
+		Data:           schemas.TokensResponseSchema{Access: tokens.Access, Refresh: tokens.Refresh}.Init(user, featuredContents),
+	}
+	return c.Status(201).JSON(response)
+}
+
 func DecodeRefreshToken(token string) bool {
 	cfg := config.GetConfig()
 
@@ -128,92 +240,93 @@ func DecodeRefreshToken(token string) bool {
 	return true
 }
 
-// Social Auth
-// GOOGLE
-type GooglePayload struct {
-	SUB           string `json:"sub"`
-	Name          string `json:"name"`
-	GivenName     string `json:"given_name"`
-	FamilyName    string `json:"family_name"`
-	Picture       string `json:"picture"`
-	Email         string `json:"email"`
-	EmailVerified bool   `json:"email_verified"`
-	Locale        string `json:"locale"`
-}
-
-func ConvertGoogleToken(accessToken string, deviceType choices.DeviceType) (*GooglePayload, *utils.ErrorResponse) {
-	cfg := config.GetConfig()
-
-	clientID := cfg.GoogleAndroidClientID
-	if deviceType == choices.DT_IOS {
-		clientID = cfg.GoogleIOSClientID
+// Stops an auth action for an account or IP address that failed it too often recently
+func CheckAuthThrottle(c *fiber.Ctx, db *gorm.DB, scope choices.AuthThrottleScopeChoice, email string) (*int, *utils.ErrorResponse) {
+	wait, locked := authThrottleManager.Wait(db, scope, email, c.IP())
+	if wait == 0 {
+		return nil, nil
 	}
-	payload, err := idtoken.Validate(context.Background(), accessToken, clientID)
-	if err != nil {
-		errMsg := "Invalid Token"
-		if strings.Contains(err.Error(), "audience provided") {
-			errMsg = "Invalid Audience"
-		}
-		errData := utils.RequestErr(utils.ERR_INVALID_TOKEN, errMsg)
-		return nil, &errData
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	errCode := 429
+	if locked {
+		errData := utils.RequestErr(utils.ERR_ACCOUNT_LOCKED, fmt.Sprintf("Too many failed attempts. Try again in %d minutes", int(math.Ceil(wait.Minutes()))))
+		return &errCode, &errData
 	}
-
-	// Bind JSON into struct
-	data := GooglePayload{}
-	mapstructure.Decode(payload.Claims, &data)
-	return &data, nil
+	errData := utils.RateLimitError(fmt.Sprintf("Too many failed attempts. Try again in %d seconds", seconds))
+	return &errCode, &errData
 }
 
-// FACEBOOK
-type FacebookPayload struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+// Counts a failed auth attempt and emails an unlock link to the user whose sign in it locked
+func RecordAuthFailure(c *fiber.Ctx, db *gorm.DB, scope choices.AuthThrottleScopeChoice, email string) {
+	unlockToken := authThrottleManager.RecordFailure(db, scope, email, c.IP())
+	if unlockToken == nil || scope != choices.ATS_LOGIN {
+		return
+	}
+	if user := userManager.GetByEmail(db, email); user != nil {
+		go senders.SendEmail(user, senders.ET_ACCOUNT_LOCKED, nil, unlockToken, nil)
+	}
 }
 
-func ConvertFacebookToken(accessToken string) (*FacebookPayload, *utils.ErrorResponse) {
-	cfg := config.GetConfig()
-
-	res, err := fb.Get("/app", fb.Params{
-		"access_token": accessToken,
-	})
+// Social Auth
+// Checks a token from an identity provider's client sdk and returns whose account it is
+func VerifySocialToken(provider choices.SocialProviderChoice, token string, deviceType choices.DeviceType) (*identity.Identity, *int, *utils.ErrorResponse) {
+	errCode := 401
+	identityProvider, err := identity.Get(provider)
 	if err != nil {
-		errData := utils.RequestErr(utils.ERR_INVALID_TOKEN, "Token is invalid or expired")
-		return nil, &errData
+		errCode = 400
+		errData := utils.InvalidParamErr("Invalid provider")
+		return nil, &errCode, &errData
 	}
-	if res["id"] != cfg.FacebookAppID {
-		errData := utils.RequestErr(utils.ERR_INVALID_TOKEN, "Invalid Facebook App ID")
-		return nil, &errData
-	}
-	res, err = fb.Get("/me", fb.Params{
-		"fields":       "id,name,email",
-		"access_token": accessToken,
-	})
-
+	userIdentity, err := identityProvider.Verify(token, deviceType)
 	if err != nil {
-		errData := utils.RequestErr(utils.ERR_INVALID_TOKEN, "Token is invalid or expired")
-		return nil, &errData
+		if tokenErr, ok := err.(*identity.TokenError); ok {
+			errData := utils.RequestErr(utils.ERR_INVALID_TOKEN, tokenErr.Message)
+			return nil, &errCode, &errData
+		}
+		log.Printf("%s sign in error: %s\n", provider, err)
+		errCode = 503
+		errData := utils.RequestErr(utils.ERR_NETWORK_FAILURE, fmt.Sprintf("Couldn't reach %s. Try again later", provider))
+		return nil, &errCode, &errData
 	}
-	// Bind JSON into struct
-	data := FacebookPayload{}
-	mapstructure.Decode(res, &data)
-	return &data, nil
+	return userIdentity, nil, nil
 }
 
-func RegisterSocialUser(db *gorm.DB, email string, name string, avatar *string, authType string) (*models.User, *models.AuthToken, *utils.ErrorResponse) {
+// Returns the user an identity provider account signs in as. Accounts that haven't been used before are linked
+// to the user with the same email, who is created if there's none. Users who signed up with a password have to
+// link providers themselves, since the provider's word about an email isn't enough to sign in to their account.
+func RegisterSocialUser(db *gorm.DB, userIdentity identity.Identity, name *string) (*models.User, *utils.ErrorResponse) {
 	cfg := config.GetConfig()
 
-	user := models.User{Email: email}
+	user := models.User{}
+	if account := socialAccountManager.GetByIdentity(db, userIdentity); account != nil {
+		db.Scopes(scopes.FollowerFollowingPreloaderScope).Take(&user, account.UserID)
+		return &user, nil
+	}
+	if userIdentity.Email == "" || !userIdentity.EmailVerified {
+		errData := utils.RequestErr(utils.ERR_INVALID_AUTH, fmt.Sprintf("Your %s account has no verified email address", userIdentity.Provider))
+		return nil, &errData
+	}
+
+	user.Email = userIdentity.Email
 	db.Scopes(scopes.FollowerFollowingPreloaderScope).Take(&user, user)
 	if user.ID == uuid.Nil {
-		user = models.User{Name: &name, Email: email, IsEmailVerified: true, Password: cfg.SocialsPassword, Avatar: *avatar, SocialLogin: true}
-		db.Create(&user)
-	} else {
-		if !user.SocialLogin {
-			errData := utils.RequestErr(utils.ERR_INVALID_AUTH, fmt.Sprintf("This account wasn't created via %s. Please sign in using your email and password.", authType))
-			return nil, nil, &errData
+		user = models.User{Email: userIdentity.Email, IsEmailVerified: true, Password: cfg.SocialsPassword, SocialLogin: true}
+		if userIdentity.Name != "" {
+			user.Name = &userIdentity.Name
+		} else if name != nil {
+			user.Name = name
 		}
+		if userIdentity.Avatar != nil {
+			user.Avatar = *userIdentity.Avatar
+		}
+		db.Create(&user)
+	} else if !user.SocialLogin {
+		errData := utils.RequestErr(utils.ERR_INVALID_AUTH, fmt.Sprintf("This account wasn't created via %s. Please sign in using your email and password.", userIdentity.Provider))
+		return nil, &errData
+	}
+	if _, errData := socialAccountManager.Link(db, user, userIdentity); errData != nil {
+		return nil, errData
 	}
-	// Generate tokens
-	token := userManager.GenerateAuthTokens(db, user, GenerateAccessToken(user), GenerateRefreshToken())
-	return &user, &token, nil
+	return &user, nil
 }
@@ -2,13 +2,17 @@ package routes
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 // @Summary View Available Book Tags
@@ -129,6 +133,127 @@ func (ep Endpoint) GetLatestBooks(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+// @Summary Search Books
+// @Description This endpoint searches books by their titles, pen names, tags, blurbs and authors, and optionally their chapters' text.
+// @Description Words match by prefix, near misses of titles and usernames still match, and results come ranked with highlighted snippets and facet counts.
+// @Tags Books
+// @Param q query string true "Search text"
+// @Param page query int false "Current Page" default(1)
+// @Param genre_slug query string false "Filter by Genre slug"
+// @Param tag_slug query string false "Filter by Tag slug"
+// @Param age_discretion query int false "Filter by Age Discretion" Enums(4, 12, 16, 18)
+// @Param completed query bool false "Filter by Completed"
+// @Param in_chapters query bool false "Search the text of chapters too"
+// @Success 200 {object} schemas.BookSearchResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/search [get]
+func (ep Endpoint) SearchBooks(c *fiber.Ctx) error {
+	db := ep.DB
+	filters := managers.BookSearchFilters{
+		Query: c.Query("q"), GenreSlug: c.Query("genre_slug"), TagSlug: c.Query("tag_slug"),
+		InChapters: c.QueryBool("in_chapters"),
+	}
+	if ageQuery := GetQueryValue(c, "age_discretion"); ageQuery != nil {
+		age, err := strconv.Atoi(*ageQuery)
+		ageDiscretion := choices.AgeType(age)
+		if err != nil || !ageDiscretion.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid age discretion"))
+		}
+		filters.AgeDiscretion = &ageDiscretion
+	}
+	if completedQuery := GetQueryValue(c, "completed"); completedQuery != nil {
+		completed, err := strconv.ParseBool(*completedQuery)
+		if err != nil {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid completed value"))
+		}
+		filters.Completed = &completed
+	}
+
+	hits, errData := bookManager.Search(db, filters)
+	if errData != nil {
+		status := 400
+		if errData.Code == utils.ERR_NON_EXISTENT {
+			status = 404
+		}
+		return c.Status(status).JSON(errData)
+	}
+	facets := bookManager.GetSearchFacets(db, hits)
+
+	// Paginate the hits and fetch the books of the page alone
+	paginatedData, paginatedHits, errData := PaginateQueryset(hits, c, 50)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+	hits = paginatedHits.([]schemas.BookSearchHit)
+	books := bookManager.GetSearchHitBooks(db, hits)
+	highlights := bookManager.GetSearchHighlights(db, hits, filters.Query)
+	response := schemas.BookSearchResponseSchema{
+		ResponseSchema: ResponseMessage("Books fetched successfully"),
+		Data: schemas.BookSearchResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData, Facets: facets,
+		}.Init(books, hits, highlights),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary View Recommended Books
+// @Description This endpoint views the books recommended to a user from their reading history, best first.
+// @Description Books like the ones they read come first, then books in their genres, and guests or new readers get the books trending in the genre they chose on the waitlist and then anywhere.
+// @Tags Books
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.BookRecommendationsResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /books/recommended [get]
+// @Security BearerAuth
+func (ep Endpoint) GetRecommendedBooks(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	recommendations := recommendationManager.GetForUser(db, *user)
+
+	// Paginate and return recommendations
+	paginatedData, paginatedRecommendations, err := PaginateQueryset(recommendations, c, 20)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	recommendations = paginatedRecommendations.([]models.BookRecommendation)
+	response := schemas.BookRecommendationsResponseSchema{
+		ResponseSchema: ResponseMessage("Recommended books fetched successfully"),
+		Data: schemas.BookRecommendationsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(recommendations),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary View Books To Continue Reading
+// @Description This endpoint views the books a user has started but not finished, with where they stopped in each, the one they read last first
+// @Tags Books
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.ContinueReadingResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /books/continue-reading [get]
+// @Security BearerAuth
+func (ep Endpoint) GetContinueReading(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	progresses := readingProgressManager.GetContinueReading(db, *user)
+
+	// Paginate and return books
+	paginatedData, paginatedProgresses, err := PaginateQueryset(progresses, c, 20)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	progresses = paginatedProgresses.([]models.ReadingProgress)
+	response := schemas.ContinueReadingResponseSchema{
+		ResponseSchema: ResponseMessage("Books fetched successfully"),
+		Data: schemas.ContinueReadingResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(progresses),
+	}
+	return c.Status(200).JSON(response)
+}
+
 // @Summary View Latest Books By A Particular Author
 // @Description This endpoint views a latest books by an author
 // @Tags Books
@@ -179,6 +304,7 @@ func (ep Endpoint) GetLatestAuthorBooks(c *fiber.Ctx) error {
 // @Description `A Guest user will view just the first chapter`
 // @Description `An Authenticated user will view all the chapters if he's subscribed or he gets only the first chapter`
 // @Description `The owner will view all chapters of the book`
+// @Description `Only published chapters are listed, except to the owner and admins who also get drafts, scheduled and unpublished ones`
 // @Tags Books
 // @Param slug path string true "Get Chapter by Book Slug"
 // @Param page query int false "Current Page" default(1)
@@ -193,6 +319,9 @@ func (ep Endpoint) GetBookChapters(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
+	if CanEditBook(c, db, *book) {
+		book.Chapters = chapterManager.GetAllByBook(db, *book)
+	}
 
 	paginatedData, paginatedChapters, err := PaginateQueryset(book.Chapters, c, 50)
 	if err != nil {
@@ -210,7 +339,7 @@ func (ep Endpoint) GetBookChapters(c *fiber.Ctx) error {
 
 // @Summary View Book Chapter
 // @Description `This endpoint views a single chapter of a book`
-// @Description `An inactive subscriber can only view the chapter if its the first one`
+// @Description `An inactive subscriber can only view the chapter if its the first one or if he has bought it`
 // @Tags Books
 // @Param slug path string true "Get Chapter by Slug"
 // @Success 200 {object} schemas.ChapterResponseSchema
@@ -221,13 +350,12 @@ func (ep Endpoint) GetBookChapter(c *fiber.Ctx) error {
 	db := ep.DB
 	user := RequestUser(c)
 	slug := c.Params("slug")
-	chapter, err := chapterManager.GetBySlug(db, slug)
+	chapter, err := GetVisibleChapter(c, db, slug)
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
-	chapterIsFirst := chapterManager.IsFirstChapter(db, *chapter)
-	if chapter.Book.AuthorID != user.ID && user.SubscriptionExpired() && !chapterIsFirst && !user.IsStaff {
-		return c.Status(401).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Renew your subscription to view this chapter"))
+	if !CanReadChapter(db, user, *chapter) {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Renew your subscription or buy this chapter to view it"))
 	}
 	ReadBook(db, chapter.BookID, user, chapter.IsLast)
 	response := schemas.ChapterResponseSchema{
@@ -239,7 +367,7 @@ func (ep Endpoint) GetBookChapter(c *fiber.Ctx) error {
 
 // @Summary View Comments Of A Paragraph of A Chapter
 // @Description `This endpoint view comments of a single paragraph of a chapter`
-// @Description `An inactive subscriber can only view the paragraph comment if its the first one`
+// @Description `An inactive subscriber can only view the paragraph comment if its the first one or if he has bought the chapter`
 // @Tags Books
 // @Param slug path string true "Chapter Slug"
 // @Param index path int true "Paragraph Index"
@@ -257,10 +385,14 @@ func (ep Endpoint) GetParagraphComments(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
-	chapterIsFirst := chapterManager.IsFirstChapter(db, *chapter)
-	if chapter.Book.AuthorID != user.ID && user.SubscriptionExpired() && !chapterIsFirst && !user.IsStaff {
-		return c.Status(401).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Renew your subscription to view this chapter"))
+	if !CanSeeChapter(c, db, *chapter) {
+		return c.Status(404).JSON(utils.NotFoundErr("No chapter with that slug"))
 	}
+	if !CanReadChapter(db, user, *chapter) {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Renew your subscription or buy this chapter to view it"))
+	}
+
+	comments = ExcludeHiddenComments(db, user, comments)
 
 	// Paginate and return comments
 	paginatedData, paginatedComments, err := PaginateQueryset(comments, c, 100)
@@ -278,6 +410,103 @@ func (ep Endpoint) GetParagraphComments(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+// @Summary Buy A Chapter Of A Book
+// @Description `This endpoint allows a user to buy a single chapter of a book with coins`
+// @Description `The chapter price of the book is debited from the user's coins`
+// @Description `Books in full purchase mode can only be bought as a whole`
+// @Tags Books
+// @Param slug path string true "Chapter slug"
+// @Success 201 {object} schemas.ChapterResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/chapters/chapter/{slug}/buy [get]
+// @Security BearerAuth
+func (ep Endpoint) BuyChapter(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	chapter, err := GetVisibleChapter(c, db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	book := chapter.Book
+	if book.AuthorID == user.ID {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't buy a chapter of your own book"))
+	}
+	if book.ContractStatus != choices.CTS_APPROVED {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This book is not available for purchase"))
+	}
+	if book.FullPurchaseMode {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This book can only be bought in full"))
+	}
+	if boughtChapterManager.GetByBuyerAndChapter(db, user, *chapter) != nil || boughtChapterManager.HasBoughtBook(db, user, book.ID) {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_ALREADY_BOUGHT, "You have bought this chapter already"))
+	}
+
+	coinValue := decimal.NewFromFloat(ep.Config.CoinUSDValue)
+	_, err = boughtChapterManager.BuyChapter(db, user, *chapter, coinValue)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	// Create and send notification in socket
+	author := models.User{}
+	db.Take(&author, "id = ?", book.AuthorID)
+	text := fmt.Sprintf("%s bought a chapter of your book", user.Username)
+	notification := notificationManager.Create(db, user, author, choices.NT_BOOK_PURCHASE, text, &book, nil, nil)
+	SendNotificationInSocket(c, notification)
+
+	response := schemas.ChapterResponseSchema{
+		ResponseSchema: ResponseMessage("Chapter bought successfully"),
+		Data:           schemas.ChapterDetailSchema{}.Init(*chapter),
+	}
+	return c.Status(201).JSON(response)
+}
+
+// @Summary Buy A Book
+// @Description `This endpoint allows a user to buy all the chapters of a book at its full price, including those that come out later`
+// @Description `Only books in full purchase mode can be bought this way`
+// @Tags Books
+// @Param slug path string true "Book slug"
+// @Success 201 {object} schemas.BookResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/{slug}/buy [get]
+// @Security BearerAuth
+func (ep Endpoint) BuyBook(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	book, err := bookManager.GetContractedBookBySlug(db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	if book.AuthorID == user.ID {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't buy your own book"))
+	}
+	if !book.FullPurchaseMode || book.FullPrice == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This book can't be bought in full"))
+	}
+	if len(book.Chapters) == 0 {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This book has no chapters yet"))
+	}
+
+	coinValue := decimal.NewFromFloat(ep.Config.CoinUSDValue)
+	_, err = boughtChapterManager.BuyBook(db, user, *book, coinValue)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	// Create and send notification in socket
+	text := fmt.Sprintf("%s bought your book", user.Username)
+	notification := notificationManager.Create(db, user, book.Author, choices.NT_BOOK_PURCHASE, text, book, nil, nil)
+	SendNotificationInSocket(c, notification)
+
+	response := schemas.BookResponseSchema{
+		ResponseSchema: ResponseMessage("Book bought successfully"),
+		Data:           schemas.BookSchema{}.Init(*book),
+	}
+	return c.Status(201).JSON(response)
+}
+
 // @Summary View Single Book
 // @Description This endpoint views a single book
 // @Tags Books
@@ -363,11 +592,12 @@ func (ep Endpoint) CreateBook(c *fiber.Ctx) error {
 // @Param cover_image formData file false "Cover Image to upload"
 // @Success 200 {object} schemas.BookResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
 // @Router /books/book/{slug} [put]
 // @Security BearerAuth
 func (ep Endpoint) UpdateBook(c *fiber.Ctx) error {
 	db := ep.DB
-	book, err := bookManager.GetBySlug(db, c.Params("slug"), true)
+	book, err := GetWritableBook(c, db, c.Params("slug"), true)
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -419,11 +649,12 @@ func (ep Endpoint) UpdateBook(c *fiber.Ctx) error {
 // @Param slug path string true "Book slug"
 // @Success 200 {object} schemas.ResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 403 {object} utils.ErrorResponse
 // @Router /books/book/{slug} [delete]
 // @Security BearerAuth
 func (ep Endpoint) DeleteBook(c *fiber.Ctx) error {
 	db := ep.DB
-	book, err := bookManager.GetBySlug(db, c.Params("slug"), false)
+	book, err := GetWritableBook(c, db, c.Params("slug"), false)
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -433,7 +664,8 @@ func (ep Endpoint) DeleteBook(c *fiber.Ctx) error {
 
 // @Summary Add A Chapter to a Book
 // @Description `This endpoint allows a writer to add a chapter to his/her book`
-// @Description `Chapter status: DRAFT, PUBLISHED, TRASH`
+// @Description `Chapter status: DRAFT, SCHEDULED, PUBLISHED (the default)`
+// @Description `Scheduled chapters need a publish_at in the future, when they are published and the author's followers and those who bookmarked the book are notified`
 // @Tags Books
 // @Param slug path string true "Book slug"
 // @Param chapter body schemas.ChapterCreateSchema true "Chapter object"
@@ -443,7 +675,7 @@ func (ep Endpoint) DeleteBook(c *fiber.Ctx) error {
 // @Security BearerAuth
 func (ep Endpoint) AddChapter(c *fiber.Ctx) error {
 	db := ep.DB
-	book, err := bookManager.GetBySlug(db, c.Params("slug"), false)
+	book, err := GetWritableBook(c, db, c.Params("slug"), false)
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -452,12 +684,14 @@ func (ep Endpoint) AddChapter(c *fiber.Ctx) error {
 	if errCode, errData := ValidateRequest(c, &data); errData != nil {
 		return c.Status(*errCode).JSON(errData)
 	}
-
-	chapter := chapterManager.Create(db, *book, data)
-	if data.IsLast {
-		book.Completed = true
-		db.Save(&book)
+	if data.Status == choices.CS_UNPUBLISHED {
+		return c.Status(422).JSON(utils.ValidationErr("status", "A new chapter can't be unpublished"))
 	}
+	if errData := ValidateChapterSchedule(data); errData != nil {
+		return c.Status(422).JSON(errData)
+	}
+
+	chapter := chapterManager.Create(db, *book, data, RequestUser(c))
 	response := schemas.ChapterResponseSchema{
 		ResponseSchema: ResponseMessage("Chapter added successfully"),
 		Data:           schemas.ChapterDetailSchema{}.Init(chapter),
@@ -467,7 +701,10 @@ func (ep Endpoint) AddChapter(c *fiber.Ctx) error {
 
 // @Summary Update A Chapter of a Book
 // @Description `This endpoint allows a writer to update a chapter in his/her book`
-// @Description `Chapter status: DRAFT, PUBLISHED, TRASH`
+// @Description `Paragraphs are matched to the new text by content rather than position, so comments stay on the paragraph they were made on when it moves or is edited`
+// @Description `Every update is saved as a new revision of the chapter`
+// @Description `Chapter status: DRAFT, SCHEDULED, PUBLISHED, UNPUBLISHED. The status is kept when none is sent. Only a draft can be scheduled`
+// @Description `Scheduled chapters need a publish_at in the future`
 // @Tags Books
 // @Param slug path string true "Chapter slug"
 // @Param chapter body schemas.ChapterCreateSchema true "Chapter object"
@@ -477,7 +714,7 @@ func (ep Endpoint) AddChapter(c *fiber.Ctx) error {
 // @Security BearerAuth
 func (ep Endpoint) UpdateChapter(c *fiber.Ctx) error {
 	db := ep.DB
-	chapter, err := chapterManager.GetBySlug(db, c.Params("slug"))
+	chapter, err := GetWritableChapter(c, db, c.Params("slug"))
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -486,8 +723,14 @@ func (ep Endpoint) UpdateChapter(c *fiber.Ctx) error {
 	if errCode, errData := ValidateRequest(c, &data); errData != nil {
 		return c.Status(*errCode).JSON(errData)
 	}
+	if errData := ValidateChapterSchedule(data, *chapter); errData != nil {
+		return c.Status(422).JSON(errData)
+	}
 
-	updatedChapter := chapterManager.Update(db, *chapter, data)
+	updatedChapter, errU := chapterManager.Update(db, *chapter, data, RequestUser(c))
+	if errU != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong"))
+	}
 	response := schemas.ChapterResponseSchema{
 		ResponseSchema: ResponseMessage("Chapter updated successfully"),
 		Data:           schemas.ChapterDetailSchema{}.Init(updatedChapter),
@@ -505,7 +748,7 @@ func (ep Endpoint) UpdateChapter(c *fiber.Ctx) error {
 // @Security BearerAuth
 func (ep Endpoint) DeleteChapter(c *fiber.Ctx) error {
 	db := ep.DB
-	chapter, err := chapterManager.GetBySlug(db, c.Params("slug"))
+	chapter, err := GetWritableChapter(c, db, c.Params("slug"))
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -513,6 +756,124 @@ func (ep Endpoint) DeleteChapter(c *fiber.Ctx) error {
 	return c.Status(200).JSON(ResponseMessage("Chapter deleted successfully"))
 }
 
+// @Summary View Revisions Of A Chapter
+// @Description This endpoint allows a writer to view the revisions of a chapter in his/her book, the latest first
+// @Tags Books
+// @Param slug path string true "Chapter slug"
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.ChapterRevisionsResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/chapter/{slug}/revisions [get]
+// @Security BearerAuth
+func (ep Endpoint) GetChapterRevisions(c *fiber.Ctx) error {
+	db := ep.DB
+	chapter, err := GetWritableChapter(c, db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	revisions := chapterRevisionManager.GetAllByChapter(db, *chapter)
+
+	// Paginate and return revisions
+	paginatedData, paginatedRevisions, err := PaginateQueryset(revisions, c, 50)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	revisions = paginatedRevisions.([]models.ChapterRevision)
+	response := schemas.ChapterRevisionsResponseSchema{
+		ResponseSchema: ResponseMessage("Chapter revisions fetched successfully"),
+		Data: schemas.ChapterRevisionsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(revisions),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Compare Revisions Of A Chapter
+// @Description `This endpoint allows a writer to compare two revisions of a chapter paragraph by paragraph`
+// @Description `Paragraphs are matched by content, so moved ones show as UNCHANGED or EDITED at their old and new indexes rather than as REMOVED and ADDED`
+// @Description `By default the latest revision is compared with the one before it`
+// @Tags Books
+// @Param slug path string true "Chapter slug"
+// @Param from query int false "Revision number to compare from"
+// @Param to query int false "Revision number to compare to"
+// @Success 200 {object} schemas.ChapterRevisionDiffResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/chapter/{slug}/revisions/diff [get]
+// @Security BearerAuth
+func (ep Endpoint) GetChapterRevisionDiff(c *fiber.Ctx) error {
+	db := ep.DB
+	chapter, err := GetWritableChapter(c, db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	toNumber := c.QueryInt("to", 0)
+	fromNumber := c.QueryInt("from", 0)
+	if toNumber < 0 || fromNumber < 0 {
+		return c.Status(400).JSON(utils.InvalidParamErr("Invalid revision number"))
+	}
+	to, err := chapterRevisionManager.GetByNumber(db, *chapter, uint(toNumber))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	if fromNumber == 0 {
+		fromNumber = int(to.Number) - 1
+		if fromNumber == 0 {
+			return c.Status(400).JSON(utils.InvalidParamErr("The chapter has no earlier revision to compare with"))
+		}
+	}
+	from, err := chapterRevisionManager.GetByNumber(db, *chapter, uint(fromNumber))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	response := schemas.ChapterRevisionDiffResponseSchema{
+		ResponseSchema: ResponseMessage("Chapter revisions compared successfully"),
+		Data: schemas.ChapterRevisionDiffSchema{
+			From:       schemas.ChapterRevisionSchema{}.Init(*from),
+			To:         schemas.ChapterRevisionSchema{}.Init(*to),
+			Paragraphs: chapterRevisionManager.Diff(*from, *to),
+		},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Restore A Revision Of A Chapter
+// @Description `This endpoint allows a writer to bring a chapter back to an earlier revision`
+// @Description `The restore is saved as a new revision so it can be undone, and comments stay on paragraphs whose text is still there`
+// @Tags Books
+// @Param slug path string true "Chapter slug"
+// @Param number path int true "Revision number"
+// @Success 200 {object} schemas.ChapterResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/chapter/{slug}/revisions/{number}/restore [post]
+// @Security BearerAuth
+func (ep Endpoint) RestoreChapterRevision(c *fiber.Ctx) error {
+	db := ep.DB
+	chapter, err := GetWritableChapter(c, db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	number, _ := c.ParamsInt("number", 0)
+	if number < 1 {
+		return c.Status(400).JSON(utils.InvalidParamErr("Invalid revision number"))
+	}
+	revision, err := chapterRevisionManager.GetByNumber(db, *chapter, uint(number))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+
+	data := schemas.ChapterCreateSchema{Title: revision.Title, Paragraphs: revision.Paragraphs, IsLast: revision.IsLast}
+	restoredChapter, errU := chapterManager.Update(db, *chapter, data, RequestUser(c))
+	if errU != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong"))
+	}
+	response := schemas.ChapterResponseSchema{
+		ResponseSchema: ResponseMessage("Chapter restored successfully"),
+		Data:           schemas.ChapterDetailSchema{}.Init(restoredChapter),
+	}
+	return c.Status(200).JSON(response)
+}
+
 // @Summary Review A Book
 // @Description `This endpoint allows a user to review a book.`
 // @Description `The author cannot review his own book.`
@@ -535,6 +896,10 @@ func (ep Endpoint) ReviewBook(c *fiber.Ctx) error {
 		return c.Status(404).JSON(err)
 	}
 
+	if errCode, errData := CheckBlocked(db, user, book.AuthorID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
 	// Check if current user has bought at least a chapter of the book
 	if user.SubscriptionExpired() {
 		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "User doesn't have active subscription"))
@@ -634,6 +999,7 @@ func (ep Endpoint) DeleteBookReview(c *fiber.Ctx) error {
 // @Router /books/book/review/{id}/replies [get]
 func (ep Endpoint) GetReviewReplies(c *fiber.Ctx) error {
 	db := ep.DB
+	user := RequestUser(c)
 	commentID := c.Params("id")
 	parsedID := ParseUUID(commentID)
 	if parsedID == nil {
@@ -646,11 +1012,12 @@ func (ep Endpoint) GetReviewReplies(c *fiber.Ctx) error {
 	}
 
 	// Paginate and return replies
-	paginatedData, paginatedReplies, err := PaginateQueryset(review.Replies, c, 100)
+	replies := ExcludeHiddenComments(db, user, review.Replies)
+	paginatedData, paginatedReplies, err := PaginateQueryset(replies, c, 100)
 	if err != nil {
 		return c.Status(400).JSON(err)
 	}
-	replies := paginatedReplies.([]models.Comment)
+	replies = paginatedReplies.([]models.Comment)
 	response := schemas.RepliesResponseSchema{
 		ResponseSchema: ResponseMessage("Replies fetched successfully"),
 		Data: schemas.RepliesResponseDataSchema{
@@ -689,6 +1056,13 @@ func (ep Endpoint) ReplyReviewOrParagraphComment(c *fiber.Ctx) error {
 		if review == nil {
 			return c.Status(404).JSON(utils.NotFoundErr("No review with that ID"))
 		}
+		ownerIDs := []uuid.UUID{review.UserID}
+		if review.Book != nil {
+			ownerIDs = append(ownerIDs, review.Book.AuthorID)
+		}
+		if errCode, errData := CheckBlocked(db, user, ownerIDs...); errData != nil {
+			return c.Status(*errCode).JSON(errData)
+		}
 		reply = commentManager.CreateReply(db, user, review, data)
 		// Create and Send Notification in socket
 		if user.ID != review.User.ID {
@@ -701,6 +1075,17 @@ func (ep Endpoint) ReplyReviewOrParagraphComment(c *fiber.Ctx) error {
 		if paragraphComment == nil {
 			return c.Status(404).JSON(utils.NotFoundErr("No paragraph comment with that ID"))
 		}
+		ownerIDs := []uuid.UUID{paragraphComment.UserID}
+		if paragraphComment.ParagraphID != nil {
+			paragraph := models.Paragraph{}
+			db.Preload("Chapter.Book").Take(&paragraph, "id = ?", *paragraphComment.ParagraphID)
+			if paragraph.ID != uuid.Nil {
+				ownerIDs = append(ownerIDs, paragraph.Chapter.Book.AuthorID)
+			}
+		}
+		if errCode, errData := CheckBlocked(db, user, ownerIDs...); errData != nil {
+			return c.Status(*errCode).JSON(errData)
+		}
 		reply = commentManager.CreateReply(db, user, paragraphComment, data)
 	}
 
@@ -773,6 +1158,76 @@ func (ep Endpoint) DeleteReply(c *fiber.Ctx) error {
 	return c.Status(200).JSON(ResponseMessage("Reply deleted successfully"))
 }
 
+// @Summary View Reading Progress Of A Book
+// @Description This endpoint views where a user stopped in a book, so they can carry on from there on any device
+// @Tags Books
+// @Param slug path string true "Book slug"
+// @Success 200 {object} schemas.ReadingProgressResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /books/book/{slug}/progress [get]
+// @Security BearerAuth
+func (ep Endpoint) GetReadingProgress(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	book, err := bookManager.GetBySlug(db, c.Params("slug"), false)
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	progress := readingProgressManager.GetByUserAndBook(db, *user, *book)
+	if progress == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("You haven't started reading this book"))
+	}
+	response := schemas.ReadingProgressResponseSchema{
+		ResponseSchema: ResponseMessage("Reading progress fetched successfully"),
+		Data:           schemas.ReadingProgressSchema{}.Init(*progress),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Save Reading Progress In A Book Chapter
+// @Description `This endpoint saves where a user is in a chapter, and is called as they scroll through it.`
+// @Description `A place the device was at before the saved one is ignored and the saved one is returned, so devices syncing after being offline can't move the reader back.`
+// @Tags Books
+// @Param slug path string true "Chapter slug"
+// @Param progress body schemas.ReadingProgressUpdateSchema true "Reading progress object"
+// @Success 200 {object} schemas.ReadingProgressResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /books/book/chapters/chapter/{slug}/progress [put]
+// @Security BearerAuth
+func (ep Endpoint) UpdateReadingProgress(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	chapter, err := GetVisibleChapter(c, db, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(err)
+	}
+	if !CanReadChapter(db, user, *chapter) {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Renew your subscription or buy this chapter to view it"))
+	}
+
+	data := schemas.ReadingProgressUpdateSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	readAt := time.Now()
+	if data.ReadAt != nil && data.ReadAt.Before(readAt) {
+		readAt = *data.ReadAt
+	}
+	progress, saved := readingProgressManager.Save(db, *user, *chapter, data.ParagraphIndex, readAt)
+	message := "Reading progress saved successfully"
+	if !saved {
+		message = "A later reading progress is already saved"
+	} else if progress.Percent >= 100 {
+		ReadBook(db, chapter.BookID, user, true)
+	}
+	response := schemas.ReadingProgressResponseSchema{
+		ResponseSchema: ResponseMessage(message),
+		Data:           schemas.ReadingProgressSchema{}.Init(progress),
+	}
+	return c.Status(200).JSON(response)
+}
+
 // @Summary Add A Comment To A Paragraph In A Book Chapter
 // @Description `This endpoint allows a user to add a comment in a paragraph to a book chapter.`
 // @Tags Books
@@ -793,7 +1248,7 @@ func (ep Endpoint) AddParagraphComment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(utils.InvalidParamErr("Enter a valid index"))
 	}
 
-	chapter, err := chapterManager.GetBySlug(db, slug)
+	chapter, err := GetVisibleChapter(c, db, slug)
 	if err != nil {
 		return c.Status(404).JSON(err)
 	}
@@ -801,6 +1256,9 @@ func (ep Endpoint) AddParagraphComment(c *fiber.Ctx) error {
 	if paragraph == nil {
 		return c.Status(404).JSON(utils.NotFoundErr("Paragraph does not exist"))
 	}
+	if errCode, errData := CheckBlocked(db, user, chapter.Book.AuthorID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
 
 	data := schemas.ParagraphCommentAddSchema{}
 	if errCode, errData := ValidateRequest(c, &data); errData != nil {
@@ -897,15 +1355,16 @@ func (ep Endpoint) VoteBook(c *fiber.Ctx) error {
 	if user.Lanterns < 1 {
 		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INSUFFICIENT_LANTERNS, "You have insufficient lanterns to vote"))
 	}
-	createdVote := voteManager.Create(db, user, book)
+	createdVote, errD := voteManager.Create(db, user, book)
+	if errD != nil {
+		return c.Status(400).JSON(errD)
+	}
 	// Create and Send Notification in socket
 	if user.ID != createdVote.UserID {
 		text := fmt.Sprintf("%s voted your book", user.Username)
 		notification := notificationManager.Create(db, user, book.Author, choices.NT_VOTE, text, book, nil, nil)
 		SendNotificationInSocket(c, notification)
 	}
-	user.Lanterns -= 1
-	db.Save(&user)
 	return c.Status(200).JSON(ResponseMessage("Book voted successfully"))
 }
 
@@ -932,9 +1391,13 @@ func (ep Endpoint) ConvertCoinsToLanterns(c *fiber.Ctx) error {
 		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INSUFFICIENT_COINS, "You have insufficient coins for that conversion"))
 	}
 
-	user.Lanterns += amount
-	user.Coins -= amount
-	db.Save(&user)
+	errD := walletManager.Transfer(db, choices.WR_LANTERN_CONVERSION, managers.WalletReference{},
+		managers.WalletMovement{From: user, Currency: choices.CUR_COIN, Amount: amount},
+		managers.WalletMovement{To: user, Currency: choices.CUR_LANTERN, Amount: amount},
+	)
+	if errD != nil {
+		return c.Status(400).JSON(errD)
+	}
 	return c.Status(200).JSON(ResponseMessage("Lanterns added successfully"))
 }
 
@@ -1093,6 +1556,9 @@ func (ep Endpoint) LikeAComment(c *fiber.Ctx) error {
 	if commentOrReply == nil {
 		return c.Status(404).JSON(err)
 	}
+	if errCode, errData := CheckBlocked(db, user, commentOrReply.UserID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
 	status := likeManager.AddOrDelete(db, *user, *commentOrReply)
 	return c.Status(200).JSON(ResponseMessage(status + " successfully"))
 }
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary View Author Earnings
+// @Description `This endpoint allows an author to view his/her earnings ledger and current balance`
+// @Description `Earnings come from gifts, chapter/book purchases and subscription revenue shares. Payouts are deducted from it.`
+// @Tags Wallet
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.AuthorEarningsResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /wallet/earnings [get]
+// @Security BearerAuth
+func (ep Endpoint) GetAuthorEarnings(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	if user.AccountType != choices.ACCTYPE_AUTHOR {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_AUTHORS_ONLY, "For Authors only!"))
+	}
+	earnings := earningManager.GetAll(db, &user.ID, nil)
+
+	// Paginate and return earnings
+	paginatedData, paginatedEarnings, err := PaginateQueryset(earnings, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	earnings = paginatedEarnings.([]models.AuthorEarning)
+	balance := earningManager.GetBalance(db, user.ID)
+	response := schemas.AuthorEarningsResponseSchema{
+		ResponseSchema: ResponseMessage("Earnings fetched successfully"),
+		Data: schemas.AuthorEarningsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+			Balance:                     &balance,
+		}.Init(earnings),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary View Author Payouts
+// @Description This endpoint allows an author to view his/her payout requests
+// @Tags Wallet
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.PayoutsResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /wallet/payouts [get]
+// @Security BearerAuth
+func (ep Endpoint) GetAuthorPayouts(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	if user.AccountType != choices.ACCTYPE_AUTHOR {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_AUTHORS_ONLY, "For Authors only!"))
+	}
+	payouts := payoutManager.GetAll(db, &user.ID, nil)
+
+	// Paginate and return payouts
+	paginatedData, paginatedPayouts, err := PaginateQueryset(payouts, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	payouts = paginatedPayouts.([]models.Payout)
+	response := schemas.PayoutsResponseSchema{
+		ResponseSchema: ResponseMessage("Payouts fetched successfully"),
+		Data: schemas.PayoutsResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(payouts),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Request A Payout
+// @Description `This endpoint allows an author to request a payout from his/her earnings balance`
+// @Description `The amount is reserved immediately and returned to the balance if the request is declined`
+// @Tags Wallet
+// @Param payout body schemas.PayoutRequestSchema true "Payout data"
+// @Success 201 {object} schemas.PayoutResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /wallet/payouts [post]
+// @Security BearerAuth
+func (ep Endpoint) RequestPayout(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	if user.AccountType != choices.ACCTYPE_AUTHOR {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_AUTHORS_ONLY, "For Authors only!"))
+	}
+	data := schemas.PayoutRequestSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if !data.Amount.IsPositive() {
+		return c.Status(422).JSON(utils.ValidationErr("amount", "Must be greater than 0"))
+	}
+
+	payout, err := payoutManager.Request(db, *user, data.Amount.Round(2))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	response := schemas.PayoutResponseSchema{
+		ResponseSchema: ResponseMessage("Payout requested successfully"),
+		Data:           schemas.PayoutSchema{}.Init(*payout),
+	}
+	return c.Status(201).JSON(response)
+}
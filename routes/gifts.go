@@ -9,6 +9,8 @@ import (
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 var (
@@ -55,6 +57,9 @@ func (ep Endpoint) SendGift(c *fiber.Ctx) error {
 	if user.ID == writer.ID {
 		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't send gifts to yourself"))
 	}
+	if errCode, errData := CheckBlocked(db, user, writer.ID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
 
 	gift := giftManager.GetBySlug(db, giftSlug)
 	if gift == nil {
@@ -67,7 +72,10 @@ func (ep Endpoint) SendGift(c *fiber.Ctx) error {
 	}
 
 	// Send gift
-	sentGift := sendGiftManager.Create(db, *gift, *user, *writer)
+	sentGift, errD := sendGiftManager.Create(db, *gift, user, *writer)
+	if errD != nil {
+		return c.Status(400).JSON(errD)
+	}
 
 	// Create and send notification in socket
 	notification := notificationManager.Create(
@@ -79,7 +87,7 @@ func (ep Endpoint) SendGift(c *fiber.Ctx) error {
 
 	response := schemas.SentGiftResponseSchema{
 		ResponseSchema: ResponseMessage("Gift sent successfully"),
-		Data:           schemas.SentGiftSchema{}.Init(sentGift),
+		Data:           schemas.SentGiftSchema{}.Init(*sentGift),
 	}
 	return c.Status(201).JSON(response)
 }
@@ -148,11 +156,24 @@ func (ep Endpoint) ClaimGift(c *fiber.Ctx) error {
 	}
 
 	if !sentGift.Claimed {
-		// Claim gift
-		user.Coins += sentGift.Gift.Price
-		sentGift.Claimed = true
-		db.Save(&user)
-		db.Save(&sentGift)
+		// Claim gift and credit its lantern value to the writer's earnings
+		lanternValue := decimal.NewFromFloat(ep.Config.LanternUSDValue)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			sentGift.Claimed = true
+			if err := tx.Save(sentGift).Error; err != nil {
+				return err
+			}
+			movement := managers.WalletMovement{To: user, Currency: choices.CUR_COIN, Amount: sentGift.Gift.Price}
+			if errD := walletManager.Transfer(tx, choices.WR_GIFT_CLAIM, managers.WalletReference{SentGiftID: &sentGift.ID}, movement); errD != nil {
+				return errD
+			}
+			earningManager.CreditGift(tx, *sentGift, lanternValue)
+			return nil
+		})
+		if err != nil {
+			sentGift.Claimed = false
+			return c.Status(500).JSON(utils.ServerErr("Something went wrong while claiming the gift"))
+		}
 	}
 
 	response := schemas.SentGiftResponseSchema{
@@ -1,211 +1,180 @@
 package routes
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"time"
-
-	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/icp"
+	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/schemas"
-	"github.com/LitPad/backend/senders"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/valyala/fasthttp"
+	"github.com/shopspring/decimal"
 )
 
-const ICP_TO_USD = 9.2
-const TEMP_FEE = 0.023
-
-func generateTokenForWalletReq(conf config.Config) (string, error) {
-	claims := jwt.MapClaims{
-		"iat": time.Now().Unix(),
-		"exp": 80 * time.Second,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString([]byte(conf.WalletSecret))
-}
-
 // @Summary Create a new ICP wallet
-// @Description `This endpoint creates a new ICP wallet`
+// @Description `This endpoint creates an ICP wallet for the current user`
 // @Tags Wallet
-// @Param user body schemas.CreateICPWallet true "User data"
+// @Success 201 {object} schemas.ICPWalletResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 502 {object} utils.ErrorResponse
 // @Router /wallet/icp [post]
+// @Security BearerAuth
 func (ep Endpoint) CreateICPWallet(c *fiber.Ctx) error {
-	wallet_server_ip := ep.Config.ICPWalletIp
-	endpoint := wallet_server_ip + "/wallet"
-
-	conf := config.GetConfig()
-
-	token, err := generateTokenForWalletReq(conf)
+	db := ep.DB
+	user := RequestUser(c)
+	client := icp.Get()
 
+	wallet, err := icpWalletManager.GetOrSync(db, client, *user)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"err": err})
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "The wallet server can't be reached"))
 	}
-
-	headers := map[string]string{
-		"Accept": "application/json",
-		"Access": "Litpad " + token,
+	if wallet != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You have an ICP wallet already"))
 	}
-
-	data := schemas.CreateICPWallet{}
-
-	if errCode, errData := ValidateRequest(c, &data); errData != nil {
-		return c.Status(*errCode).JSON(errData)
-	}
-
-	requestBody := []byte(fmt.Sprintf(`{"username": "%s"}`, data.Username))
-
-	response, err := senders.MakeRequest(fasthttp.MethodPost, endpoint, headers, requestBody)
-
+	serverWallet, err := client.CreateWallet(user.Username)
 	if err != nil {
-		return c.Status(response.StatusCode()).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create your ICP wallet"))
 	}
-
-	var decodedResponse map[string]interface{}
-	if err := json.Unmarshal(response.Body(), &decodedResponse); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+	createdWallet := icpWalletManager.Save(db, *user, *serverWallet)
+	response := schemas.ICPWalletResponseSchema{
+		ResponseSchema: ResponseMessage("Wallet created successfully"),
+		Data:           schemas.ICPWalletSchema{}.Init(createdWallet, nil),
 	}
-
-	return c.Status(201).JSON(decodedResponse)
+	return c.Status(201).JSON(response)
 }
 
 // @Summary Get user ICP wallet balance
 // @Description This endpoint returns user ICP wallet balance
 // @Tags Wallet
 // @Param username path string true "Username of user"
-// @Failure 400 {object} utils.ErrorResponse
+// @Success 200 {object} schemas.ICPWalletResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 502 {object} utils.ErrorResponse
 // @Router /wallet/icp/{username}/balance [get]
 func (ep Endpoint) GetICPWalletBalance(c *fiber.Ctx) error {
-	username := c.Params("username")
-
-	if username == "" {
-		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Invalid path params"))
+	db := ep.DB
+	user := userManager.GetByUsername(db, c.Params("username"))
+	if user == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No user with that username"))
 	}
 
-	wallet_server_ip := ep.Config.ICPWalletIp
-	endpoint := wallet_server_ip + "/wallet/" + username + "/balance"
-
-	token, err := generateTokenForWalletReq(ep.Config)
-
+	client := icp.Get()
+	wallet, err := icpWalletManager.GetOrSync(db, client, *user)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"err": err})
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "The wallet server can't be reached"))
 	}
-
-	headers := map[string]string{
-		"Accept": "application/json",
-		"Access": "Litpad " + token,
+	if wallet == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("This user doesn't have an ICP wallet"))
 	}
-
-	response, err := senders.MakeRequest(fasthttp.MethodGet, endpoint, headers, nil)
-
+	balance, err := client.GetBalance(user.Username)
 	if err != nil {
-		return c.Status(response.StatusCode()).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to fetch the wallet balance"))
 	}
-
-	var decodedResponse map[string]interface{}
-
-	if err := json.Unmarshal(response.Body(), &decodedResponse); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+	response := schemas.ICPWalletResponseSchema{
+		ResponseSchema: ResponseMessage("Wallet balance fetched successfully"),
+		Data:           schemas.ICPWalletSchema{}.Init(*wallet, &balance),
 	}
-
-	return c.Status(201).JSON(decodedResponse)
+	return c.Status(200).JSON(response)
 }
 
 // @Summary Send Gift Via ICP
-// @Description This endpoint allows a user to send a gift via ICP
+// @Description `This endpoint allows a user to pay for a gift with ICP from his/her wallet`
+// @Description `The gift is sent once the wallet server confirms the transfer. Transfers that can't be confirmed straight away return 202 and are confirmed later.`
 // @Tags Wallet
 // @Param username path string true "Username of the writer"
 // @Param gift_slug path string true "Slug of the gift being sent"
-// @Success 200 {object} schemas.SentGiftResponseSchema
+// @Success 200 {object} schemas.ICPTransferResponseSchema
+// @Success 202 {object} schemas.ICPTransferResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 502 {object} utils.ErrorResponse
 // @Router /wallet/icp/gifts/{username}/{gift_slug}/send/ [get]
 // @Security BearerAuth
 func (ep Endpoint) SendGiftViaICPWallet(c *fiber.Ctx) error {
 	db := ep.DB
 	user := RequestUser(c)
-	writeUsername := c.Params("username")
-	giftSlug := c.Params("gift_slug")
-
-	writer := userManager.GetWriterByUsername(db, writeUsername)
 
+	writer := userManager.GetWriterByUsername(db, c.Params("username"))
 	if writer == nil {
-		return c.Status(http.StatusNotFound).JSON(utils.NotFoundErr("No writer with tis username"))
+		return c.Status(404).JSON(utils.NotFoundErr("No writer with that username"))
 	}
-
-	wallet_server_ip := ep.Config.ICPWalletIp
-	endpoint := wallet_server_ip + "/wallet/" + writeUsername
-
-	conf := config.GetConfig()
-
-	token, err := generateTokenForWalletReq(conf)
-
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"err": err})
+	if user.ID == writer.ID {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't send gifts to yourself"))
 	}
-
-	headers := map[string]string{
-		"Accept": "application/json",
-		"Access": "Litpad " + token,
+	if errCode, errData := CheckBlocked(db, user, writer.ID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	gift := giftManager.GetBySlug(db, c.Params("gift_slug"))
+	if gift == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No available gift with that slug"))
 	}
 
-	response, err := senders.MakeRequest(fasthttp.MethodGet, endpoint, headers, nil)
-
+	client := icp.Get()
+	unreachableErr := utils.RequestErr(utils.ERR_SERVER_ERROR, "The wallet server can't be reached")
+	senderWallet, err := icpWalletManager.GetOrSync(db, client, *user)
 	if err != nil {
-		return c.Status(response.StatusCode()).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(502).JSON(unreachableErr)
 	}
-
-	var decodedResponse schemas.ICPWalletResponseSchema
-
-	if err := json.Unmarshal(response.Body(), &decodedResponse); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+	if senderWallet == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You don't have an ICP wallet"))
 	}
-
-	var account_id = decodedResponse.AccountID
-
-	gift := giftManager.GetBySlug(db, giftSlug)
-	if gift == nil {
-		return c.Status(http.StatusNotFound).JSON(utils.NotFoundErr("No available gift with that slug"))
+	writerWallet, err := icpWalletManager.GetOrSync(db, client, *writer)
+	if err != nil {
+		return c.Status(502).JSON(unreachableErr)
+	}
+	if writerWallet == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This writer doesn't have an ICP wallet"))
 	}
 
-	gift_price_in_icp := (float64(gift.Price) / ICP_TO_USD)
-	// gift_price_in_icp := (float64(gift.Price) / ICP_TO_USD) * TEMP_FEE
-
-	endpoint = wallet_server_ip + "/wallet/" + "transfer"
-
-	requestBody := []byte(fmt.Sprintf(`{"username": "%s", "address": "%s", "amount": "%s"}`, user.Username, account_id, fmt.Sprintf("%.6f", gift_price_in_icp)))
-
-	response, err = senders.MakeRequest(fasthttp.MethodPost, endpoint, headers, requestBody)
-
+	giftUsdValue := decimal.NewFromInt(int64(gift.Price)).Mul(decimal.NewFromFloat(ep.Config.CoinUSDValue))
+	amount, usdRate, err := client.ToICP(giftUsdValue)
 	if err != nil {
-		return c.Status(response.StatusCode()).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "The ICP exchange rate is unavailable"))
 	}
 
-	var decodedTransferResponse schemas.ICPTransferResponseSchema
+	// The transfer is recorded first so that it can be reconciled if its outcome isn't known
+	transfer := icpTransferManager.Create(db, *user, *writer, *writerWallet, *gift, amount, usdRate)
+	serverTransfer, err := client.Transfer(icp.TransferParams{
+		Reference: transfer.ID.String(), Username: user.Username, Address: writerWallet.AccountID, Amount: amount,
+	})
+	if err != nil {
+		if icp.IsRejected(err) {
+			icpTransferManager.Fail(db, &transfer, err.Error())
+			return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "The ICP transfer was rejected. Check your wallet balance"))
+		}
+		// The transfer may have been made. It is left to the reconciliation job.
+		return icpTransferResponse(c, transfer)
+	}
+
+	switch serverTransfer.State() {
+	case choices.ITS_CONFIRMED:
+		notification, err := icpTransferManager.Confirm(db, &transfer, &serverTransfer.ID)
+		if err != nil {
+			// Retried by the reconciliation job
+			return icpTransferResponse(c, transfer)
+		}
+		if notification != nil {
+			SendNotificationInSocket(c, *notification)
+		}
+	case choices.ITS_FAILED:
+		icpTransferManager.Fail(db, &transfer, "The transfer was rejected by the ledger")
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "The ICP transfer was rejected. Check your wallet balance"))
+	default:
+		icpTransferManager.SetReference(db, &transfer, serverTransfer.ID)
+	}
+	return icpTransferResponse(c, transfer)
+}
 
-	if err := json.Unmarshal(response.Body(), &decodedTransferResponse); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+func icpTransferResponse(c *fiber.Ctx, transfer models.ICPTransfer) error {
+	if transfer.Status == choices.ITS_CONFIRMED {
+		response := schemas.ICPTransferResponseSchema{
+			ResponseSchema: ResponseMessage("Gift sent successfully"),
+			Data:           schemas.ICPTransferSchema{}.Init(transfer),
+		}
+		return c.Status(200).JSON(response)
 	}
-
-	// Send Gift
-	sentGift := sendGiftManager.Process(db, *gift, *user, *writer)
-
-	notification := notificationManager.Create(
-		db, user, *writer, choices.NT_GIFT, fmt.Sprintf("%s sent you a gift.",
-			user.Username),
-		nil, nil, &sentGift.ID,
-	)
-
-	SendNotificationInSocket(c, notification)
-
-	gift_response := schemas.SentGiftResponseSchema{
-		ResponseSchema: ResponseMessage("Gift sent successfully"),
-		Data:           schemas.SentGiftSchema{}.Init(sentGift),
+	response := schemas.ICPTransferResponseSchema{
+		ResponseSchema: ResponseMessage("Your gift will be sent once the transfer is confirmed"),
+		Data:           schemas.ICPTransferSchema{}.Init(transfer),
 	}
-	return c.Status(http.StatusOK).JSON(gift_response)
+	return c.Status(202).JSON(response)
 }
@@ -6,18 +6,37 @@ import (
 )
 
 var (
-	truthy                 = true
-	userManager            = managers.UserManager{Model: models.User{}}
-	bookManager            = managers.BookManager{Model: models.Book{}}
-	chapterManager         = managers.ChapterManager{}
-	tagManager             = managers.TagManager{}
-	genreManager           = managers.GenreManager{}
-	reviewManager          = managers.ReviewManager{}
-	voteManager            = managers.VoteManager{}
-	commentManager         = managers.CommentManager{}
-	notificationManager    = managers.NotificationManager{}
-	bookmarkManager        = managers.BookmarkManager{}
-	bookReportManager      = managers.BookReportManager{}
-	likeManager            = managers.LikeManager{}
-	featuredContentManager = managers.FeaturedContentManager{}
+	truthy                   = true
+	userManager              = managers.UserManager{Model: models.User{}}
+	bookManager              = managers.BookManager{Model: models.Book{}}
+	chapterManager           = managers.ChapterManager{}
+	boughtChapterManager     = managers.BoughtChapterManager{}
+	tagManager               = managers.TagManager{}
+	genreManager             = managers.GenreManager{}
+	reviewManager            = managers.ReviewManager{}
+	voteManager              = managers.VoteManager{}
+	commentManager           = managers.CommentManager{}
+	notificationManager      = managers.NotificationManager{}
+	bookmarkManager          = managers.BookmarkManager{}
+	bookReportManager        = managers.BookReportManager{}
+	likeManager              = managers.LikeManager{}
+	featuredContentManager   = managers.FeaturedContentManager{}
+	earningManager           = managers.AuthorEarningManager{}
+	payoutManager            = managers.PayoutManager{}
+	walletManager            = managers.WalletManager{}
+	paymentEventManager      = managers.PaymentEventManager{}
+	subscriptionManager      = managers.SubscriptionManager{}
+	promoCodeManager         = managers.PromoCodeManager{}
+	icpWalletManager         = managers.ICPWalletManager{}
+	icpTransferManager       = managers.ICPTransferManager{}
+	roleManager              = managers.RoleManager{}
+	socialAccountManager     = managers.SocialAccountManager{}
+	dataExportManager        = managers.DataExportManager{}
+	authThrottleManager      = managers.AuthThrottleManager{}
+	authorApplicationManager = managers.AuthorApplicationManager{}
+	userBlockManager         = managers.UserBlockManager{}
+	emailChangeManager       = managers.EmailChangeManager{}
+	recommendationManager    = managers.BookRecommendationManager{}
+	readingProgressManager   = managers.ReadingProgressManager{}
+	chapterRevisionManager   = managers.ChapterRevisionManager{}
 )
@@ -2,9 +2,11 @@ package routes
 
 import (
 	"log"
+	"strings"
 	"time"
 
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
@@ -31,25 +33,49 @@ func (ep Endpoint) HandleLogsLogin(c *fiber.Ctx) error {
 	session := Session(c, ep.Store)
 	email := c.FormValue("email")
 	password := c.FormValue("password")
+	if _, errData := CheckAuthThrottle(c, db, choices.ATS_LOGIN, email); errData != nil {
+		session.Set("error", errData.Message)
+		session.Save()
+		return c.Redirect("/logs/login")
+	}
 	user := userManager.GetByEmail(db, email)
 	if user == nil {
+		RecordAuthFailure(c, db, choices.ATS_LOGIN, email)
 		session.Set("error", "Invalid email or password!")
 		session.Save()
 		return c.Redirect("/logs/login")
 	}	
 	if user.Password != password && !utils.CheckPasswordHash(password, user.Password) {
+		RecordAuthFailure(c, db, choices.ATS_LOGIN, email)
 		session.Set("error", "Invalid email or password!")
 		session.Save()
 		return c.Redirect("/logs/login")
 	}
+	authThrottleManager.Clear(db, choices.ATS_LOGIN, email)
 	if !user.IsStaff {
 		session.Set("error", "Unauthorized user!")
 		session.Save()
 		return c.Redirect("/logs/login")
 	}
-	
+	// Emailed codes aren't offered here, so staff need an authenticator app
+	if !user.TotpEnabled {
+		session.Set("error", "Set up two-factor authentication first!")
+		session.Save()
+		return c.Redirect("/logs/login")
+	}
+	code := c.FormValue("code")
+	method := choices.TFM_TOTP
+	if strings.Contains(code, "-") {
+		method = choices.TFM_RECOVERY
+	}
+	if _, errData := verifyTwoFactorCode(db, user, method, code); errData != nil {
+		session.Set("error", errData.Message)
+		session.Save()
+		return c.Redirect("/logs/login")
+	}
+
 	accessToken := GenerateAccessToken(*user)
-	userManager.GenerateAuthTokens(db, *user, GenerateAccessToken(*user), utils.GetRandomString(15))
+	userManager.GenerateAuthTokens(db, *user, accessToken, utils.GetRandomString(15))
 	// Hash password
 	if user.Password == password {
 		user.Password = utils.HashPassword(password)
@@ -4,13 +4,12 @@ import (
 	"encoding/json"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -68,7 +67,7 @@ func (ep Endpoint) AuthorMiddleware(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *err))
 	}
-	if !user.IsStaff {
+	if user.AccountType != choices.ACCTYPE_AUTHOR {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_AUTHORS_ONLY, "For Authors only!"))
 	}
 	c.Locals("user", user)
@@ -86,13 +85,45 @@ func (ep Endpoint) AdminMiddleware(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *err))
 	}
-	if !user.IsStaff {
+	// Only staff roles are given through RoleID
+	if user.RoleID == nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_ADMINS_ONLY, "For Admin only!"))
 	}
 	c.Locals("user", user)
 	return c.Next()
 }
 
+// Returns a middleware that lets through users with every one of the permissions.
+// It comes after AuthMiddleware or AdminMiddleware, which set the user.
+func (ep Endpoint) PermissionMiddleware(permissions ...choices.PermissionChoice) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := RequestUser(c)
+		if user == nil {
+			return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNAUTHORIZED_USER, "Unauthorized User!"))
+		}
+		for _, permission := range permissions {
+			if !HasPermission(c, ep.DB, permission) {
+				return c.Status(403).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You don't have permission to do this"))
+			}
+		}
+		return c.Next()
+	}
+}
+
+// Reports whether the user of a request has a permission. The permissions are loaded once per request.
+func HasPermission(c *fiber.Ctx, db *gorm.DB, permission choices.PermissionChoice) bool {
+	permissions, ok := c.Locals("permissions").(map[choices.PermissionChoice]bool)
+	if !ok {
+		user := RequestUser(c)
+		if user == nil {
+			return false
+		}
+		permissions = roleManager.GetUserPermissions(db, *user)
+		c.Locals("permissions", permissions)
+	}
+	return permissions[permission]
+}
+
 func (ep Endpoint) WalletAccessMiddleware(c *fiber.Ctx) error {
 
 	conf := config.GetConfig()
@@ -122,20 +153,6 @@ func (ep Endpoint) WalletAccessMiddleware(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-func (ep Endpoint) DynamicRateLimiter(expirationMinute int, maxRequest int ) fiber.Handler {
-	// Apply rate limiter middleware
-	return limiter.New(limiter.Config{
-		Max:        maxRequest,               // dynamic request limit
-		Expiration: time.Duration(expirationMinute) * time.Minute, // expiration time in minutes
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP() // Use IP as the key for rate limiting
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(429).JSON(utils.RateLimitError("Rate Limit Reached"))
-		},
-	})
-}
-
 func RequestLogger(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if !strings.HasPrefix(c.Path(), "/api/v1") {
@@ -0,0 +1,446 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/senders"
+	"github.com/LitPad/backend/utils"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Applies a stored provider event and records the outcome on the event.
+// Anything that has been settled already is left untouched, so an event can safely be applied more than once.
+func processPaymentEvent(db *gorm.DB, cfg config.Config, paymentEvent *models.PaymentEvent) error {
+	provider, err := payments.Get(paymentEvent.Provider)
+	if err != nil {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+	event, err := provider.ParseEvent(paymentEvent.Payload)
+	if err != nil {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+
+	switch event.Kind {
+	case payments.EK_PAYMENT:
+		return processPayment(db, paymentEvent, *event)
+	case payments.EK_PAYMENT_APPROVED:
+		return capturePayment(db, provider, paymentEvent, *event)
+	case payments.EK_INVOICE_PAID, payments.EK_INVOICE_FAILED:
+		return processInvoiceEvent(db, cfg, paymentEvent, *event)
+	case payments.EK_SUBSCRIPTION_CANCELED, payments.EK_SUBSCRIPTION_RESUMED, payments.EK_SUBSCRIPTION_PAST_DUE, payments.EK_SUBSCRIPTION_ENDED:
+		return processSubscriptionEvent(db, cfg, paymentEvent, *event)
+	case payments.EK_REFUND, payments.EK_DISPUTE_OPENED, payments.EK_DISPUTE_WON:
+		return processReversal(db, paymentEvent, *event)
+	}
+	log.Printf("Unhandled event type: %s\n", event.Type)
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_IGNORED, nil, nil)
+	return nil
+}
+
+// Settles a one-off payment (coins or a single subscription period) and emails the buyer about it.
+// A transaction that has succeeded already is left untouched.
+func settleTransaction(db *gorm.DB, reference string, paymentStatus choices.PaymentStatus, amountReceived *decimal.Decimal) (*models.Transaction, error) {
+	transaction := models.Transaction{}
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the transaction so that concurrent deliveries are applied one after the other
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&transaction, "reference = ?", reference)
+		if transaction.ID == uuid.Nil {
+			// The event can arrive before the transaction is committed. It will be retried.
+			return fmt.Errorf("no transaction with reference %s", reference)
+		}
+		tx.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, "transactions.id = ?", transaction.ID)
+		if transaction.PaymentStatus == choices.PSSUCCEEDED || transaction.PaymentStatus == paymentStatus {
+			return nil
+		}
+
+		if paymentStatus == choices.PSSUCCEEDED {
+			if err := checkPromoBonus(tx, &transaction); err != nil {
+				return err
+			}
+			user := transaction.User
+			// Checked against what was charged, which is lower than the listed price when a promo code was used
+			underpaid := amountReceived != nil && amountReceived.LessThan(transactionAmount(transaction))
+			if subPlan := transaction.SubscriptionPlan; subPlan != nil {
+				// For subscription
+				if underpaid {
+					paymentStatus = choices.PSFAILED
+				} else if _, err := subscriptionManager.Extend(tx, user, *subPlan, transaction.PaymentType); err != nil {
+					return err
+				} else if errD := creditPromoBonus(tx, choices.WR_PROMO_BONUS, transaction); errD != nil {
+					return errD
+				}
+			} else if transaction.Coin != nil {
+				if underpaid {
+					paymentStatus = choices.PSFAILED
+				} else {
+					movement := managers.WalletMovement{To: &user, Currency: choices.CUR_COIN, Amount: *transaction.CoinsTotal()}
+					if errD := walletManager.Transfer(tx, choices.WR_COIN_PURCHASE, managers.WalletReference{TransactionID: &transaction.ID}, movement); errD != nil {
+						return errD
+					}
+				}
+			}
+		}
+		transaction.PaymentStatus = paymentStatus
+		applied = true
+		return tx.Model(&transaction).Update("payment_status", paymentStatus).Error
+	})
+	if err != nil {
+		return &transaction, err
+	}
+
+	if applied {
+		amount := transactionAmount(transaction)
+		emailType := senders.ET_PAYMENT_SUCC
+		switch paymentStatus {
+		case choices.PSFAILED:
+			emailType = senders.ET_PAYMENT_FAIL
+		case choices.PSCANCELED:
+			emailType = senders.ET_PAYMENT_CANCEL
+		}
+		emailD := map[string]interface{}{"amount": amount}
+		go senders.SendEmail(&transaction.User, emailType, nil, nil, emailD)
+	}
+	return &transaction, nil
+}
+
+// Returns what was charged for a transaction
+func transactionAmount(transaction models.Transaction) decimal.Decimal {
+	if transaction.Amount.IsPositive() {
+		return transaction.Amount
+	}
+	if transaction.Coin != nil {
+		return transaction.Coin.Price.Mul(decimal.NewFromInt(int64(max(transaction.Quantity, 1))))
+	} else if transaction.SubscriptionPlan != nil {
+		return transaction.SubscriptionPlan.Amount
+	}
+	return decimal.Zero
+}
+
+// Drops the bonus coins of a promo code that ran out while its payment was being made (see PromoCodeManager.WithinLimits).
+// A discount was charged already so it is kept.
+func checkPromoBonus(tx *gorm.DB, transaction *models.Transaction) error {
+	if transaction.PromoCodeID == nil || transaction.BonusCoins <= 0 || promoCodeManager.WithinLimits(tx, *transaction) {
+		return nil
+	}
+	transaction.BonusCoins = 0
+	if transaction.ID == uuid.Nil {
+		return nil
+	}
+	return tx.Model(transaction).Update("bonus_coins", 0).Error
+}
+
+// Credits the bonus coins a promo code added to a subscription payment. Coin purchases include them in their total.
+func creditPromoBonus(tx *gorm.DB, reason choices.WalletReasonChoice, transaction models.Transaction) *utils.ErrorResponse {
+	if transaction.BonusCoins <= 0 {
+		return nil
+	}
+	user := transaction.User
+	movement := managers.WalletMovement{To: &user, Currency: choices.CUR_COIN, Amount: transaction.BonusCoins}
+	return walletManager.Transfer(tx, reason, managers.WalletReference{TransactionID: &transaction.ID}, movement)
+}
+
+// Takes back what a refunded or charged back payment was for: the coins it credited or the subscription time it added.
+// A subscription that renews with the provider the payment was made with is canceled too.
+// Coins that were spent already are left as debt. Only successful transactions are reversed, so it is safe to repeat.
+func reverseTransaction(db *gorm.DB, reference string, paymentStatus choices.PaymentStatus) (*models.Transaction, error) {
+	reason := choices.WR_REFUND
+	reversal := "refunded"
+	if paymentStatus == choices.PSDISPUTED {
+		reason = choices.WR_CHARGEBACK
+		reversal = "charged back"
+	}
+	transaction := models.Transaction{}
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the transaction so that a refund and its webhook event are applied one after the other
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&transaction, "reference = ?", reference)
+		if transaction.ID == uuid.Nil {
+			return fmt.Errorf("no transaction with reference %s", reference)
+		}
+		tx.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, "transactions.id = ?", transaction.ID)
+		if transaction.PaymentStatus != choices.PSSUCCEEDED {
+			return nil
+		}
+
+		if subPlan := transaction.SubscriptionPlan; subPlan != nil {
+			subscription := subscriptionManager.GetByUser(tx, transaction.User)
+			stopRenewal := subscription != nil && subscription.Renews() && subscription.Provider == transaction.PaymentType
+			if stopRenewal {
+				// The reader shouldn't be charged again for a subscription whose payment was taken back
+				if err := subscriptionManager.Cancel(tx, subscription); err != nil {
+					return err
+				}
+			}
+			if err := subscriptionManager.Revoke(tx, transaction.User, *subPlan); err != nil {
+				return err
+			}
+			if stopRenewal {
+				// Last, so that the reversal is applied again when the provider can't be reached
+				provider, errD := renewingProvider(*subscription)
+				if errD != nil {
+					return errD
+				}
+				if err := provider.CancelSubscription(*subscription.Reference); err != nil {
+					return err
+				}
+			}
+			if transaction.BonusCoins > 0 {
+				if errD := walletManager.ClawBack(tx, reason, managers.WalletReference{TransactionID: &transaction.ID}, &transaction.User, transaction.BonusCoins); errD != nil {
+					return errD
+				}
+			}
+		} else if transaction.Coin != nil {
+			if errD := walletManager.ClawBack(tx, reason, managers.WalletReference{TransactionID: &transaction.ID}, &transaction.User, *transaction.CoinsTotal()); errD != nil {
+				return errD
+			}
+		}
+		transaction.PaymentStatus = paymentStatus
+		applied = true
+		return tx.Model(&transaction).Update("payment_status", paymentStatus).Error
+	})
+	if err != nil {
+		return &transaction, err
+	}
+
+	if applied {
+		emailD := map[string]interface{}{"amount": transactionAmount(transaction), "reversal": reversal, "debt": -min(transaction.User.Coins, 0)}
+		go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_REVERSED, nil, nil, emailD)
+	}
+	return &transaction, nil
+}
+
+// Gives back what a charged back payment was for when the dispute is won
+func reinstateTransaction(db *gorm.DB, reference string) (*models.Transaction, error) {
+	transaction := models.Transaction{}
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&transaction, "reference = ?", reference)
+		if transaction.ID == uuid.Nil {
+			return fmt.Errorf("no transaction with reference %s", reference)
+		}
+		tx.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, "transactions.id = ?", transaction.ID)
+		if transaction.PaymentStatus != choices.PSDISPUTED {
+			return nil
+		}
+
+		user := transaction.User
+		if subPlan := transaction.SubscriptionPlan; subPlan != nil {
+			if _, err := subscriptionManager.Extend(tx, user, *subPlan, transaction.PaymentType); err != nil {
+				return err
+			}
+			if errD := creditPromoBonus(tx, choices.WR_CHARGEBACK_REVERSAL, transaction); errD != nil {
+				return errD
+			}
+		} else if transaction.Coin != nil {
+			// Any debt left by the chargeback is paid off first
+			movement := managers.WalletMovement{To: &user, Currency: choices.CUR_COIN, Amount: *transaction.CoinsTotal()}
+			if errD := walletManager.Transfer(tx, choices.WR_CHARGEBACK_REVERSAL, managers.WalletReference{TransactionID: &transaction.ID}, movement); errD != nil {
+				return errD
+			}
+		}
+		transaction.PaymentStatus = choices.PSSUCCEEDED
+		applied = true
+		return tx.Model(&transaction).Update("payment_status", choices.PSSUCCEEDED).Error
+	})
+	if err != nil {
+		return &transaction, err
+	}
+
+	if applied {
+		emailD := map[string]interface{}{"amount": transactionAmount(transaction)}
+		go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_SUCC, nil, nil, emailD)
+	}
+	return &transaction, nil
+}
+
+func processReversal(db *gorm.DB, paymentEvent *models.PaymentEvent, event payments.Event) error {
+	var transaction *models.Transaction
+	var err error
+	if event.Kind == payments.EK_DISPUTE_WON {
+		transaction, err = reinstateTransaction(db, event.Reference)
+	} else {
+		transaction, err = reverseTransaction(db, event.Reference, event.Status)
+	}
+	if err != nil {
+		var transactionID *uuid.UUID
+		if transaction.ID != uuid.Nil {
+			transactionID = &transaction.ID
+		}
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, transactionID, err)
+		return err
+	}
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, &transaction.ID, nil)
+	return nil
+}
+
+func processPayment(db *gorm.DB, paymentEvent *models.PaymentEvent, event payments.Event) error {
+	transaction, err := settleTransaction(db, event.Reference, event.Status, event.Amount)
+	if err != nil {
+		var transactionID *uuid.UUID
+		if transaction.ID != uuid.Nil {
+			transactionID = &transaction.ID
+		}
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, transactionID, err)
+		return err
+	}
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, &transaction.ID, nil)
+	return nil
+}
+
+// Captures a payment the buyer has approved. The capture is settled through its own event.
+func capturePayment(db *gorm.DB, provider payments.PaymentProvider, paymentEvent *models.PaymentEvent, event payments.Event) error {
+	capturer, ok := provider.(payments.Capturer)
+	if !ok {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_IGNORED, nil, nil)
+		return nil
+	}
+	transaction := models.Transaction{}
+	db.Take(&transaction, "reference = ?", event.Reference)
+	if transaction.ID == uuid.Nil {
+		err := fmt.Errorf("no transaction with reference %s", event.Reference)
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+	if transaction.PaymentStatus == choices.PSPENDING {
+		if err := capturer.Capture(event.Reference); err != nil {
+			paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, &transaction.ID, err)
+			return err
+		}
+	}
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, &transaction.ID, nil)
+	return nil
+}
+
+// Starts a new period when a subscription invoice (first payment, renewal or plan change) is paid
+// and marks the subscription as past due when a renewal payment fails.
+func processInvoiceEvent(db *gorm.DB, cfg config.Config, paymentEvent *models.PaymentEvent, event payments.Event) error {
+	subscription := subscriptionManager.GetByReference(db, event.SubscriptionReference)
+	if subscription == nil {
+		// The event can arrive before the subscription is stored. It will be retried.
+		err := fmt.Errorf("no subscription with reference %s", event.SubscriptionReference)
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+	user := subscription.User
+	amount := decimal.Zero
+	if event.Amount != nil {
+		amount = *event.Amount
+	}
+
+	if event.Kind == payments.EK_INVOICE_FAILED {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&models.Transaction{}).Where("reference = ? AND payment_status = ?", event.Reference, choices.PSPENDING).
+				Update("payment_status", choices.PSFAILED).Error
+			if err != nil {
+				return err
+			}
+			return subscriptionManager.MarkPastDue(tx, subscription)
+		})
+		if err != nil {
+			paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+			return err
+		}
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, nil, nil)
+		go senders.SendEmail(&user, senders.ET_PAYMENT_FAIL, nil, nil, map[string]interface{}{"amount": amount})
+		return nil
+	}
+
+	plan := subscription.Plan
+	if event.PriceReference != nil {
+		pricePlan := models.SubscriptionPlan{}
+		db.Take(&pricePlan, "price_reference = ?", *event.PriceReference)
+		if pricePlan.ID != uuid.Nil {
+			plan = pricePlan
+		}
+	}
+	start, end := subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd
+	if !event.PeriodEnd.IsZero() {
+		start, end = event.PeriodStart, event.PeriodEnd
+	}
+
+	transaction := models.Transaction{}
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the subscription so that concurrent deliveries are applied one after the other
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&models.Subscription{}, "id = ?", subscription.ID)
+		tx.Take(&transaction, "reference = ?", event.Reference)
+		if transaction.PaymentStatus == choices.PSSUCCEEDED {
+			return nil
+		}
+		if err := subscriptionManager.Activate(tx, subscription, plan, start, end, cfg.SubscriptionGraceDays); err != nil {
+			return err
+		}
+		transaction.Reference = event.Reference
+		transaction.UserID = user.ID
+		transaction.SubscriptionPlanID = &plan.ID
+		transaction.PaymentType = subscription.Provider
+		transaction.PaymentPurpose = choices.PP_SUB
+		transaction.PaymentStatus = choices.PSSUCCEEDED
+		transaction.Amount = amount
+		if err := checkPromoBonus(tx, &transaction); err != nil {
+			return err
+		}
+		applied = true
+		if err := tx.Save(&transaction).Error; err != nil {
+			return err
+		}
+		// The bonus of a promo code used for the first invoice
+		transaction.User = user
+		if errD := creditPromoBonus(tx, choices.WR_PROMO_BONUS, transaction); errD != nil {
+			return errD
+		}
+		return nil
+	})
+	if err != nil {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, &transaction.ID, nil)
+
+	if applied {
+		go senders.SendEmail(&user, senders.ET_PAYMENT_SUCC, nil, nil, map[string]interface{}{"amount": amount})
+	}
+	return nil
+}
+
+// Keeps subscriptions in line with changes made on the provider's side e.g cancellations from a customer portal
+// or subscriptions that ended after all renewal attempts failed.
+func processSubscriptionEvent(db *gorm.DB, cfg config.Config, paymentEvent *models.PaymentEvent, event payments.Event) error {
+	subscription := subscriptionManager.GetByReference(db, event.SubscriptionReference)
+	if subscription == nil {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_IGNORED, nil, nil)
+		return nil
+	}
+
+	var err error
+	switch event.Kind {
+	case payments.EK_SUBSCRIPTION_ENDED:
+		err = subscriptionManager.Expire(db, subscription)
+	case payments.EK_SUBSCRIPTION_PAST_DUE:
+		err = subscriptionManager.MarkPastDue(db, subscription)
+	case payments.EK_SUBSCRIPTION_CANCELED:
+		if subscription.CanceledAt == nil {
+			err = subscriptionManager.Cancel(db, subscription)
+		}
+	case payments.EK_SUBSCRIPTION_RESUMED:
+		err = subscriptionManager.Resume(db, subscription, cfg.SubscriptionGraceDays)
+	}
+	if err != nil {
+		paymentEventManager.Finish(db, paymentEvent, choices.PES_FAILED, nil, err)
+		return err
+	}
+	paymentEventManager.Finish(db, paymentEvent, choices.PES_PROCESSED, nil, nil)
+	return nil
+}
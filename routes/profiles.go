@@ -3,10 +3,13 @@ package routes
 import (
 	"fmt"
 
+	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/jobs"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/models/scopes"
 	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/senders"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -124,6 +127,93 @@ func (ep Endpoint) UpdatePassword(c *fiber.Ctx) error {
 	return c.Status(200).JSON(ResponseMessage("Password updated successfully"))
 }
 
+// @Summary Change Email
+// @Description `This endpoint starts a change of the user's email by sending an OTP to the new address.`
+// @Description `Users who signed up with a password have to enter it.`
+// @Tags Profiles
+// @Param data body schemas.ChangeEmailSchema true "Email change object"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /profiles/email [post]
+// @Security BearerAuth
+func (ep Endpoint) ChangeEmail(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.ChangeEmailSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if !user.SocialLogin && (data.Password == nil || !utils.CheckPasswordHash(*data.Password, user.Password)) {
+		return c.Status(422).JSON(utils.ValidationErr("password", "Password Mismatch"))
+	}
+	if data.Email == user.Email {
+		return c.Status(422).JSON(utils.ValidationErr("email", "This is your email already"))
+	}
+	if existingUser := userManager.GetByEmail(db, data.Email); existingUser != nil {
+		return c.Status(422).JSON(utils.ValidationErr("email", "Email already taken!"))
+	}
+
+	change := emailChangeManager.Create(db, *user, data.Email)
+	recipient := *user
+	recipient.Email = change.NewEmail
+	go senders.SendEmail(&recipient, senders.ET_CHANGE_EMAIL, &change.Otp, nil, nil)
+	return c.Status(200).JSON(ResponseMessage("An OTP has been sent to your new email"))
+}
+
+// @Summary Confirm Email Change
+// @Description `This endpoint changes the user's email with the OTP sent to the new address.`
+// @Description `The user is logged out of every device and the old address gets a link to revert the change.`
+// @Tags Profiles
+// @Param data body schemas.ConfirmEmailChangeSchema true "OTP"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /profiles/email/confirm [post]
+// @Security BearerAuth
+func (ep Endpoint) ConfirmEmailChange(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.ConfirmEmailChangeSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	// Slow down OTP guessing
+	if errCode, errData := CheckAuthThrottle(c, db, choices.ATS_VERIFY_EMAIL, user.Email); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	change := emailChangeManager.GetPending(db, *user)
+	if change == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("You haven't requested an email change"))
+	}
+	if change.Otp != data.Otp {
+		RecordAuthFailure(c, db, choices.ATS_VERIFY_EMAIL, user.Email)
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INCORRECT_OTP, "Incorrect OTP"))
+	}
+	authThrottleManager.Clear(db, choices.ATS_VERIFY_EMAIL, user.Email)
+	if change.IsOtpExpired() {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_EXPIRED_OTP, "Expired OTP"))
+	}
+
+	oldAddress := *user
+	revertToken, errData := emailChangeManager.Confirm(db, change, user)
+	if errData != nil {
+		errCode := 500
+		if errData.Code == utils.ERR_INVALID_ENTRY {
+			errCode = 422
+		}
+		return c.Status(errCode).JSON(errData)
+	}
+	go senders.SendEmail(&oldAddress, senders.ET_EMAIL_CHANGED, nil, revertToken, map[string]interface{}{"newEmail": change.NewEmail})
+	go senders.UpdateBrevoEmail(user.Username, oldAddress.Email, change.NewEmail)
+	return c.Status(200).JSON(ResponseMessage("Email changed successfully. Log in again with your new email"))
+}
+
 // @Summary Toggle Follow Status
 // @Description `This endpoint allows a user to follow or unfollow a writer`.
 // @Tags Profiles
@@ -156,6 +246,9 @@ func (ep Endpoint) FollowUser(c *fiber.Ctx) error {
 	if toFollowUser.AccountType == choices.ACCTYPE_READER {
 		return c.Status(403).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Readers cannot be followed"))
 	}
+	if errCode, errData := CheckBlocked(db, user, toFollowUser.ID); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
 	tx := db.Begin()
 
 	// Toggle follow
@@ -255,3 +348,254 @@ func (ep Endpoint) ReadNotification(c *fiber.Ctx) error {
 	}
 	return c.Status(200).JSON(ResponseMessage(respMessage))
 }
+
+// @Summary Apply To Become An Author
+// @Description `This endpoint submits a reader's application to become an author. Staff review it and the reader is notified of their decision.`
+// @Tags Profiles
+// @Param application body schemas.AuthorApplicationCreateSchema true "Application object"
+// @Success 201 {object} schemas.AuthorApplicationResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /profiles/author-application [post]
+// @Security BearerAuth
+func (ep Endpoint) ApplyForAuthorship(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.AuthorApplicationCreateSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if len(data.Links) > 5 {
+		return c.Status(422).JSON(utils.ValidationErr("links", "5 links max"))
+	}
+
+	application, errData := authorApplicationManager.Create(db, *user, data)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+	response := schemas.AuthorApplicationResponseSchema{
+		ResponseSchema: ResponseMessage("Application submitted successfully"),
+		Data:           schemas.AuthorApplicationSchema{}.Init(*application),
+	}
+	return c.Status(201).JSON(response)
+}
+
+// @Summary View Author Application
+// @Description `This endpoint returns the last application the user submitted to become an author, with staff's decision once it is reviewed`
+// @Tags Profiles
+// @Success 200 {object} schemas.AuthorApplicationResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /profiles/author-application [get]
+// @Security BearerAuth
+func (ep Endpoint) GetAuthorApplication(c *fiber.Ctx) error {
+	application := authorApplicationManager.GetLatestByUser(ep.DB, *RequestUser(c))
+	if application == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("You haven't applied to become an author"))
+	}
+	response := schemas.AuthorApplicationResponseSchema{
+		ResponseSchema: ResponseMessage("Application fetched successfully"),
+		Data:           schemas.AuthorApplicationSchema{}.Init(*application),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Export User Data
+// @Description `This endpoint starts putting together a copy of the user's data: profile, books, chapters, comments, votes, bookmarks, transactions, notifications and sent gifts`
+// @Description `A link for downloading it as a zip of json files is emailed to the user once it is ready. A copy can be asked for once a day.`
+// @Tags Profiles
+// @Success 202 {object} schemas.ResponseSchema
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
+// @Router /profiles/export [post]
+// @Security BearerAuth
+func (ep Endpoint) ExportUserData(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	if dataExportManager.GetRecent(db, *user) != nil {
+		return c.Status(429).JSON(utils.RateLimitError("You asked for a copy of your data recently. Try again tomorrow"))
+	}
+	export := dataExportManager.Create(db, *user)
+	jobs.QueueDataExport(db, export.ID)
+	return c.Status(202).JSON(ResponseMessage("We're putting your data together. You'll get an email with a download link"))
+}
+
+// @Summary Download User Data
+// @Description `This endpoint downloads a copy of a user's data with the link emailed to them`
+// @Tags Profiles
+// @Param token path string true "Download token"
+// @Produce application/zip
+// @Success 200 {file} binary
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /exports/{token} [get]
+func (ep Endpoint) DownloadUserData(c *fiber.Ctx) error {
+	export := dataExportManager.GetByToken(ep.DB, c.Params("token"))
+	if export == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("Download link is invalid or expired"))
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment(fmt.Sprintf("litpad-data-%s.zip", export.CreatedAt.Format("2006-01-02")))
+	return c.Status(200).Send(export.Archive)
+}
+
+// @Summary Delete Account
+// @Description `This endpoint schedules the deletion of the user's account. It is deleted after a cool-off period, which the user can cancel it in.`
+// @Description `Users who signed up with a password have to enter it. Users with a renewing subscription have to cancel it first.`
+// @Description `Deleting an account removes the user's personal data, books and chapters. Comments stay without the user's name and payment records are kept for accounting.`
+// @Tags Profiles
+// @Param data body schemas.DeleteAccountSchema true "Password"
+// @Success 200 {object} schemas.AccountDeletionResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /profiles/delete [post]
+// @Security BearerAuth
+func (ep Endpoint) DeleteAccount(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.DeleteAccountSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if !user.SocialLogin && (data.Password == nil || !utils.CheckPasswordHash(*data.Password, user.Password)) {
+		return c.Status(422).JSON(utils.ValidationErr("password", "Password Mismatch"))
+	}
+	if sub := subscriptionManager.GetByUser(db, *user); sub != nil && sub.IsActive() && sub.Renews() {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Cancel your subscription before deleting your account"))
+	}
+
+	alreadyScheduled := user.DeletionScheduledAt != nil
+	deletionAt := userManager.ScheduleDeletion(db, user, config.GetConfig().AccountDeletionDays)
+	if !alreadyScheduled {
+		go senders.SendEmail(user, senders.ET_DELETION_SCHEDULED, nil, nil, map[string]interface{}{"deletionAt": deletionAt})
+	}
+	response := schemas.AccountDeletionResponseSchema{
+		ResponseSchema: ResponseMessage("Your account will be deleted at the end of the cool-off period"),
+		Data:           schemas.AccountDeletionSchema{DeletionScheduledAt: deletionAt},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Cancel Account Deletion
+// @Description `This endpoint stops a scheduled deletion of the user's account`
+// @Tags Profiles
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /profiles/delete/cancel [post]
+// @Security BearerAuth
+func (ep Endpoint) CancelAccountDeletion(c *fiber.Ctx) error {
+	user := RequestUser(c)
+	if user.DeletionScheduledAt == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Your account isn't scheduled for deletion"))
+	}
+	userManager.CancelDeletion(ep.DB, user)
+	return c.Status(200).JSON(ResponseMessage("Account deletion canceled"))
+}
+
+// @Summary View Blocked And Muted Users
+// @Description `This endpoint returns the users that the user has blocked or muted, newest first`
+// @Tags Profiles
+// @Param kind query string false "Kind to filter by" Enums(BLOCK, MUTE)
+// @Param page query int false "Current Page" default(1)
+// @Success 200 {object} schemas.BlocksResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /profiles/blocks [get]
+// @Security BearerAuth
+func (ep Endpoint) GetBlocks(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	var kind *choices.BlockKindChoice
+	kindQuery := GetQueryValue(c, "kind")
+	if kindQuery != nil {
+		kindVal := choices.BlockKindChoice(*kindQuery)
+		if !kindVal.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid kind"))
+		}
+		kind = &kindVal
+	}
+	blocks := userBlockManager.GetAllByUser(db, *user, kind)
+
+	// Paginate and return blocks
+	paginatedData, paginatedBlocks, err := PaginateQueryset(blocks, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	blocks = paginatedBlocks.([]models.UserBlock)
+	response := schemas.BlocksResponseSchema{
+		ResponseSchema: ResponseMessage("Blocks fetched successfully"),
+		Data: schemas.BlocksResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+		}.Init(blocks),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Block Or Mute A User
+// @Description `This endpoint allows a user to block or mute another user.`
+// @Description `Blocked users can't comment on the user's books, reply to or like their comments, follow them or send them gifts, and they don't notify the user.`
+// @Description `Blocking also ends the following between both users. Muted users' comments are only hidden from the user.`
+// @Tags Profiles
+// @Param username path string true "Username of the user"
+// @Param data body schemas.BlockUserSchema true "Block object"
+// @Success 200 {object} schemas.BlockResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /profiles/blocks/{username} [post]
+// @Security BearerAuth
+func (ep Endpoint) BlockUser(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	blockedUser := userManager.GetByUsername(db, c.Params("username"))
+	if blockedUser == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("User does not exist!"))
+	}
+	if blockedUser.ID == user.ID {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't block or mute yourself"))
+	}
+
+	data := schemas.BlockUserSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	// Staff have to be able to reach everyone
+	if data.Kind == choices.BK_BLOCK && blockedUser.RoleID != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Staff can't be blocked"))
+	}
+	block, err := userBlockManager.Set(db, *user, *blockedUser, data.Kind)
+	if err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong while blocking the user"))
+	}
+
+	message := "User blocked successfully"
+	if data.Kind == choices.BK_MUTE {
+		message = "User muted successfully"
+	}
+	response := schemas.BlockResponseSchema{
+		ResponseSchema: ResponseMessage(message),
+		Data:           schemas.BlockSchema{}.Init(*block),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Unblock Or Unmute A User
+// @Description `This endpoint allows a user to unblock or unmute another user`
+// @Tags Profiles
+// @Param username path string true "Username of the user"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /profiles/blocks/{username} [delete]
+// @Security BearerAuth
+func (ep Endpoint) UnblockUser(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	blockedUser := userManager.GetByUsername(db, c.Params("username"))
+	if blockedUser == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("User does not exist!"))
+	}
+	if !userBlockManager.Remove(db, *user, *blockedUser) {
+		return c.Status(404).JSON(utils.NotFoundErr("You haven't blocked or muted this user"))
+	}
+	return c.Status(200).JSON(ResponseMessage("User unblocked successfully"))
+}
@@ -2,6 +2,8 @@ package routes
 
 import (
 	"github.com/LitPad/backend/config"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/ratelimit"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
@@ -17,6 +19,14 @@ type Endpoint struct {
 func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	store := session.New()
 	endpoint := Endpoint{DB: db, Config: config.GetConfig(), Store: store}
+	// Permission checks, e.g can(choices.PERM_VIEW_USERS)
+	can := endpoint.PermissionMiddleware
+	// Rate limits by policy, e.g limit(ratelimit.VOTES)
+	limitStore := ratelimit.NewStore(endpoint.Config)
+	limit := func(name ratelimit.PolicyName) fiber.Handler {
+		return ratelimit.New(limitStore, name)
+	}
+	app.Use(limit(ratelimit.GLOBAL))
 
 	// ROUTES (40)
 	api := app.Group("/api/v1")
@@ -36,8 +46,8 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	generalRouter.Get("/site-detail", endpoint.GetSiteDetails)
 	generalRouter.Post("/subscribe", endpoint.Subscribe)
 
-	// Auth Routes (11)
-	authRouter := api.Group("/auth")
+	// Auth Routes (26)
+	authRouter := api.Group("/auth", limit(ratelimit.AUTH))
 	authRouter.Post("/register", endpoint.Register)
 	authRouter.Post("/verify-email", endpoint.VerifyEmail)
 	authRouter.Post("/resend-verification-email", endpoint.ResendVerificationEmail)
@@ -45,52 +55,89 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	authRouter.Get("/verify-password-reset-token/:token_string", endpoint.VerifyPasswordResetToken)
 	authRouter.Post("/set-new-password", endpoint.SetNewPassword)
 	authRouter.Post("/login", endpoint.Login)
+	authRouter.Post("/unlock-account", endpoint.UnlockAccount)
+	authRouter.Post("/revert-email", endpoint.RevertEmailChange)
 	authRouter.Post("/google", endpoint.GoogleLogin)
-	// authRouter.Post("/facebook", endpoint.FacebookLogin)
+	authRouter.Post("/facebook", endpoint.FacebookLogin)
+	authRouter.Post("/apple", endpoint.AppleLogin)
+	authRouter.Get("/socials", endpoint.AuthMiddleware, endpoint.GetSocialAccounts)
+	authRouter.Post("/socials/:provider", endpoint.AuthMiddleware, endpoint.LinkSocialAccount)
+	authRouter.Delete("/socials/:provider", endpoint.AuthMiddleware, endpoint.UnlinkSocialAccount)
 	authRouter.Post("/refresh", endpoint.Refresh)
 	authRouter.Get("/logout", endpoint.AuthMiddleware, endpoint.Logout)
 	authRouter.Get("/logout/all", endpoint.AuthMiddleware, endpoint.LogoutAll)
+	authRouter.Get("/sessions", endpoint.AuthMiddleware, endpoint.GetSessions)
+	authRouter.Delete("/sessions/:id", endpoint.AuthMiddleware, endpoint.RevokeSession)
+	authRouter.Post("/2fa/verify", endpoint.VerifyTwoFactorLogin)
+	authRouter.Post("/2fa/email", endpoint.SendTwoFactorLoginEmail)
+	authRouter.Post("/2fa/totp/setup", endpoint.AuthMiddleware, endpoint.SetupTotp)
+	authRouter.Post("/2fa/totp/enable", endpoint.AuthMiddleware, endpoint.EnableTotp)
+	authRouter.Post("/2fa/totp/disable", endpoint.AuthMiddleware, endpoint.DisableTotp)
+	authRouter.Post("/2fa/recovery-codes", endpoint.AuthMiddleware, endpoint.RegenerateRecoveryCodes)
 
-	// Profile Routes (6)
+	// Profile Routes (16)
 	profilesRouter := api.Group("/profiles", endpoint.AuthMiddleware)
 	profilesRouter.Get("/profile/:username", endpoint.GetProfile)
 	profilesRouter.Patch("/update", endpoint.UpdateProfile)
 	profilesRouter.Put("/update-password", endpoint.UpdatePassword)
+	profilesRouter.Post("/email", endpoint.ChangeEmail)
+	profilesRouter.Post("/email/confirm", endpoint.ConfirmEmailChange)
 	profilesRouter.Get("/profile/:username/follow", endpoint.FollowUser)
 	profilesRouter.Get("/notifications", endpoint.GetNotifications)
 	profilesRouter.Post("/notifications/read", endpoint.ReadNotification)
+	profilesRouter.Get("/blocks", endpoint.GetBlocks)
+	profilesRouter.Post("/blocks/:username", endpoint.BlockUser)
+	profilesRouter.Delete("/blocks/:username", endpoint.UnblockUser)
+	profilesRouter.Get("/author-application", endpoint.GetAuthorApplication)
+	profilesRouter.Post("/author-application", endpoint.ApplyForAuthorship)
+	profilesRouter.Post("/export", endpoint.ExportUserData)
+	profilesRouter.Post("/delete", endpoint.DeleteAccount)
+	profilesRouter.Post("/delete/cancel", endpoint.CancelAccountDeletion)
 
-	// Book Routes (24)
+	// Data Export Routes (1)
+	api.Get("/exports/:token", endpoint.DownloadUserData)
+
+	// Book Routes (34)
 	bookRouter := api.Group("/books")
 	bookRouter.Get("", endpoint.GetLatestBooks)
-	bookRouter.Post("", endpoint.AdminMiddleware, endpoint.CreateBook)
+	bookRouter.Get("/search", endpoint.SearchBooks)
+	bookRouter.Get("/recommended", endpoint.AuthOrGuestMiddleware, endpoint.GetRecommendedBooks)
+	bookRouter.Get("/continue-reading", endpoint.AuthMiddleware, endpoint.GetContinueReading)
+	bookRouter.Post("", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.CreateBook)
 	bookRouter.Get("/bookmarked", endpoint.AuthMiddleware, endpoint.GetBookmarkedBooks)
 	bookRouter.Get("/book/:slug/bookmark", endpoint.AuthMiddleware, endpoint.BookmarkBook)
 	bookRouter.Post("/book/:slug/report", endpoint.AuthMiddleware, endpoint.ReportBook)
 	bookRouter.Get("/book/:slug", endpoint.GetSingleBook)
 	bookRouter.Get("/book/:slug/chapters", endpoint.AuthOrGuestMiddleware, endpoint.GetBookChapters)
-	bookRouter.Post("/book/:slug", endpoint.AuthMiddleware, endpoint.ReviewBook)
+	bookRouter.Get("/book/:slug/progress", endpoint.AuthMiddleware, endpoint.GetReadingProgress)
+	bookRouter.Post("/book/:slug", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.ReviewBook)
 
-	bookRouter.Put("/book/review/:id", endpoint.AuthMiddleware, endpoint.EditBookReview)
+	bookRouter.Put("/book/review/:id", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.EditBookReview)
 	bookRouter.Delete("/book/review/:id", endpoint.AuthMiddleware, endpoint.DeleteBookReview)
-	bookRouter.Get("/book/review/:id/replies", endpoint.GetReviewReplies)
-	bookRouter.Post("/book/review-or-paragraph-comment/:id/replies", endpoint.AuthMiddleware, endpoint.ReplyReviewOrParagraphComment)
-	bookRouter.Put("/book/review-or-paragraph-comment/replies/:id", endpoint.AuthMiddleware, endpoint.EditReply)
+	bookRouter.Get("/book/review/:id/replies", endpoint.AuthOrGuestMiddleware, endpoint.GetReviewReplies)
+	bookRouter.Post("/book/review-or-paragraph-comment/:id/replies", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.ReplyReviewOrParagraphComment)
+	bookRouter.Put("/book/review-or-paragraph-comment/replies/:id", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.EditReply)
 	bookRouter.Delete("/book/review-or-paragraph-comment/replies/:id", endpoint.AuthMiddleware, endpoint.DeleteReply)
-	bookRouter.Get("/book/:slug/vote", endpoint.AuthMiddleware, endpoint.VoteBook)
+	bookRouter.Get("/book/:slug/vote", endpoint.AuthMiddleware, limit(ratelimit.VOTES), endpoint.VoteBook)
+	bookRouter.Get("/book/:slug/buy", endpoint.AuthMiddleware, endpoint.BuyBook)
 	bookRouter.Get("/lanterns-generation/:amount", endpoint.AuthMiddleware, endpoint.ConvertCoinsToLanterns)
 
-	bookRouter.Put("/book/:slug", endpoint.AdminMiddleware, endpoint.UpdateBook)
-	bookRouter.Delete("/book/:slug", endpoint.AdminMiddleware, endpoint.DeleteBook)
-	bookRouter.Post("/book/:slug/set-contract", endpoint.AdminMiddleware, endpoint.SetContract)
-	bookRouter.Put("/book/chapter/:slug", endpoint.AdminMiddleware, endpoint.UpdateChapter)
-	bookRouter.Delete("/book/chapter/:slug", endpoint.AdminMiddleware, endpoint.DeleteChapter)
-	bookRouter.Post("/book/:slug/add-chapter", endpoint.AdminMiddleware, endpoint.AddChapter)
+	bookRouter.Put("/book/:slug", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.UpdateBook)
+	bookRouter.Delete("/book/:slug", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.DeleteBook)
+	bookRouter.Post("/book/:slug/set-contract", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.SetContract)
+	bookRouter.Put("/book/chapter/:slug", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.UpdateChapter)
+	bookRouter.Delete("/book/chapter/:slug", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.DeleteChapter)
+	bookRouter.Get("/book/chapter/:slug/revisions", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.GetChapterRevisions)
+	bookRouter.Get("/book/chapter/:slug/revisions/diff", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.GetChapterRevisionDiff)
+	bookRouter.Post("/book/chapter/:slug/revisions/:number/restore", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.RestoreChapterRevision)
+	bookRouter.Post("/book/:slug/add-chapter", endpoint.AuthMiddleware, can(choices.PERM_WRITE_BOOKS), endpoint.AddChapter)
 
 	bookRouter.Get("/book/chapters/chapter/:slug", endpoint.AuthMiddleware, endpoint.GetBookChapter)
+	bookRouter.Get("/book/chapters/chapter/:slug/buy", endpoint.AuthMiddleware, endpoint.BuyChapter)
+	bookRouter.Put("/book/chapters/chapter/:slug/progress", endpoint.AuthMiddleware, endpoint.UpdateReadingProgress)
 	bookRouter.Get("/book/chapters/chapter/:slug/paragraph/:index/comments", endpoint.AuthMiddleware, endpoint.GetParagraphComments)
-	bookRouter.Post("/book/chapters/chapter/:slug/paragraph/:index/comments", endpoint.AuthMiddleware, endpoint.AddParagraphComment)
-	bookRouter.Put("/book/chapters/chapter/paragraph-comment/:id", endpoint.AuthMiddleware, endpoint.EditParagraphComment)
+	bookRouter.Post("/book/chapters/chapter/:slug/paragraph/:index/comments", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.AddParagraphComment)
+	bookRouter.Put("/book/chapters/chapter/paragraph-comment/:id", endpoint.AuthMiddleware, limit(ratelimit.COMMENTS), endpoint.EditParagraphComment)
 	bookRouter.Delete("/book/chapters/chapter/paragraph-comment/:id", endpoint.AuthMiddleware, endpoint.DeleteParagraphComment)
 
 	bookRouter.Get("/book/chapters/chapter/comment/:id", endpoint.AuthMiddleware, endpoint.LikeAComment)
@@ -104,76 +151,105 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	// Gifts Routes (4)
 	giftsRouter := api.Group("/gifts")
 	giftsRouter.Get("", endpoint.GetAllGifts)
-	giftsRouter.Get("/:username/:gift_slug/send", endpoint.AuthMiddleware, endpoint.SendGift)
-	giftsRouter.Get("/sent", endpoint.GetAllSentGifts)
-	giftsRouter.Get("/sent/:id/claim", endpoint.ClaimGift)
+	giftsRouter.Get("/:username/:gift_slug/send", endpoint.AuthMiddleware, limit(ratelimit.GIFTS), endpoint.SendGift)
+	giftsRouter.Get("/sent", endpoint.AuthMiddleware, endpoint.GetAllSentGifts)
+	giftsRouter.Get("/sent/:id/claim", endpoint.AuthMiddleware, endpoint.ClaimGift)
 
-	// Wallet Routes (7)
+	// Wallet Routes (15)
 	walletRouter := api.Group("/wallet")
 	walletRouter.Get("/coins", endpoint.AvailableCoins)
 	walletRouter.Post("/coins", endpoint.AuthMiddleware, endpoint.BuyCoins)
 	walletRouter.Get("/transactions", endpoint.AuthMiddleware, endpoint.AllUserTransactions)
+	walletRouter.Get("/transactions/ledger", endpoint.AuthMiddleware, endpoint.GetWalletLedger)
 	walletRouter.Post("/verify-payment", endpoint.VerifyPayment)
+	walletRouter.Post("/verify-payment/:provider", endpoint.VerifyPayment)
 	walletRouter.Get("/plans", endpoint.GetSubscriptionPlans)
 	walletRouter.Post("/subscription", endpoint.AuthMiddleware, endpoint.BookSubscription)
+	walletRouter.Get("/subscription", endpoint.AuthMiddleware, endpoint.GetSubscription)
+	walletRouter.Put("/subscription", endpoint.AuthMiddleware, endpoint.ChangeSubscriptionPlan)
+	walletRouter.Post("/subscription/cancel", endpoint.AuthMiddleware, endpoint.CancelSubscription)
+	walletRouter.Get("/earnings", endpoint.AuthMiddleware, endpoint.GetAuthorEarnings)
+	walletRouter.Get("/payouts", endpoint.AuthMiddleware, endpoint.GetAuthorPayouts)
+	walletRouter.Post("/payouts", endpoint.AuthMiddleware, endpoint.RequestPayout)
 
 	// ICP Wallet Routes
 	icpWalletRouter := walletRouter.Group("/icp")
-	icpWalletRouter.Post("/", endpoint.CreateICPWallet)
+	icpWalletRouter.Post("/", endpoint.AuthMiddleware, endpoint.CreateICPWallet)
 	icpWalletRouter.Get("/:username/balance", endpoint.GetICPWalletBalance)
-	icpWalletRouter.Get("/gifts/:username/:gift_slug/send", endpoint.AuthMiddleware, endpoint.SendGiftViaICPWallet)
+	icpWalletRouter.Get("/gifts/:username/:gift_slug/send", endpoint.AuthMiddleware, limit(ratelimit.GIFTS), endpoint.SendGiftViaICPWallet)
 
 	// ADMIN ROUTES (7)
 	adminRouter := api.Group("/admin", endpoint.AdminMiddleware)
-	adminRouter.Get("/", endpoint.AdminDashboard)
+	adminRouter.Get("/", can(choices.PERM_VIEW_DASHBOARD), endpoint.AdminDashboard)
 	adminUsersRouter := adminRouter.Group("/users", endpoint.AdminMiddleware)
 	// Admin Users
 	adminRouter.Put("/", endpoint.UpdateProfile)
-	adminUsersRouter.Get("", endpoint.AdminGetUsers)
-	adminUsersRouter.Put("/:username", endpoint.AdminUpdateUser)
-	adminUsersRouter.Get("/:username/toggle-activation", endpoint.ToggleUserActivation)
-	adminUsersRouter.Post("/admins/invite", endpoint.InviteAdmin)
+	adminUsersRouter.Get("", can(choices.PERM_VIEW_USERS), endpoint.AdminGetUsers)
+	adminUsersRouter.Get("/roles", can(choices.PERM_MANAGE_ADMINS), endpoint.AdminGetRoles)
+	adminUsersRouter.Get("/lockouts", can(choices.PERM_VIEW_USERS), endpoint.AdminGetLockouts)
+	adminUsersRouter.Delete("/lockouts/:id", can(choices.PERM_MANAGE_USERS), endpoint.AdminClearLockout)
+	adminUsersRouter.Put("/:username", can(choices.PERM_MANAGE_USERS), endpoint.AdminUpdateUser)
+	adminUsersRouter.Get("/:username/toggle-activation", can(choices.PERM_MANAGE_USERS), endpoint.ToggleUserActivation)
+	adminUsersRouter.Post("/admins/invite", can(choices.PERM_MANAGE_ADMINS), endpoint.InviteAdmin)
+
+	adminRouter.Get("/subscribers", can(choices.PERM_VIEW_SUBSCRIBERS), endpoint.AdminGetSubscribers)
 
-	adminRouter.Get("/subscribers", endpoint.AdminGetSubscribers)
+	// Admin Authors (3)
+	adminAuthorsRouter := adminRouter.Group("/authors", endpoint.AdminMiddleware)
+	adminAuthorsRouter.Get("/applications", can(choices.PERM_VIEW_USERS), endpoint.AdminGetAuthorApplications)
+	adminAuthorsRouter.Get("/applications/:id", can(choices.PERM_VIEW_USERS), endpoint.AdminGetAuthorApplication)
+	adminAuthorsRouter.Put("/applications/:id", can(choices.PERM_MANAGE_USERS), endpoint.AdminReviewAuthorApplication)
 
 	// Admin Books (2)
 	adminBooksRouter := adminRouter.Group("/books", endpoint.AdminMiddleware)
-	adminBooksRouter.Get("", endpoint.AdminGetBooks)
-	adminBooksRouter.Get("/by-username/:username", endpoint.AdminGetAuthorBooks)
-	adminBooksRouter.Get("/book-detail/:slug", endpoint.AdminGetBookDetails)
-	adminBooksRouter.Get("/book-detail/:slug/reading-progress", endpoint.AdminGetBookReadingProgress)
-	adminBooksRouter.Get("/book-detail/:slug/retention-stats", endpoint.AdminGetBookRetentionStats)
-	adminBooksRouter.Get("/contracts", endpoint.AdminGetBookContracts)
-	adminBooksRouter.Post("/genres", endpoint.AdminAddBookGenre)
-	adminBooksRouter.Post("/tags/add/:genre_slug", endpoint.AdminAddBookTag)
-	adminBooksRouter.Get("/sections", endpoint.AdminGetSections)
-	adminBooksRouter.Post("/sections", endpoint.AdminAddBookSection)
-	adminBooksRouter.Post("/sections/:slug/subsections", endpoint.AdminAddBookSubSection)
-	adminBooksRouter.Put("/genres/:slug", endpoint.AdminUpdateBookGenre)
-	adminBooksRouter.Put("/sections/:slug", endpoint.AdminUpdateBookSection)
-	adminBooksRouter.Get("/subsections/:slug", endpoint.AdminGetSubSection)
-	adminBooksRouter.Put("/subsections/:slug", endpoint.AdminUpdateBookSubSection)
-	adminBooksRouter.Put("/tags/:slug", endpoint.AdminUpdateBookTag)
-	adminBooksRouter.Delete("/genres/:slug", endpoint.AdminDeleteBookGenre)
-	adminBooksRouter.Delete("/sections/:slug", endpoint.AdminDeleteBookSection)
-	adminBooksRouter.Delete("/subsections/:slug", endpoint.AdminDeleteBookSubSection)
-	adminBooksRouter.Get("/subsections/:slug/add-book/:book_slug", endpoint.AddBookToSubSection)
-	adminBooksRouter.Get("/subsections/:slug/remove-book/:book_slug", endpoint.RemoveBookFromSubSection)
-	adminBooksRouter.Get("/book/:slug/toggle-book-completion-status", endpoint.ToggleBookCompletionStatus)
-	adminBooksRouter.Delete("/tags/:slug", endpoint.AdminDeleteBookTag)
+	adminBooksRouter.Get("", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetBooks)
+	adminBooksRouter.Get("/by-username/:username", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetAuthorBooks)
+	adminBooksRouter.Get("/book-detail/:slug", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetBookDetails)
+	adminBooksRouter.Get("/book-detail/:slug/reading-progress", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetBookReadingProgress)
+	adminBooksRouter.Get("/book-detail/:slug/retention-stats", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetBookRetentionStats)
+	adminBooksRouter.Get("/contracts", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetBookContracts)
+	adminBooksRouter.Post("/genres", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminAddBookGenre)
+	adminBooksRouter.Post("/tags/add/:genre_slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminAddBookTag)
+	adminBooksRouter.Get("/sections", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetSections)
+	adminBooksRouter.Post("/sections", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminAddBookSection)
+	adminBooksRouter.Post("/sections/:slug/subsections", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminAddBookSubSection)
+	adminBooksRouter.Put("/genres/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminUpdateBookGenre)
+	adminBooksRouter.Put("/sections/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminUpdateBookSection)
+	adminBooksRouter.Get("/subsections/:slug", can(choices.PERM_VIEW_BOOKS), endpoint.AdminGetSubSection)
+	adminBooksRouter.Put("/subsections/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminUpdateBookSubSection)
+	adminBooksRouter.Put("/tags/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminUpdateBookTag)
+	adminBooksRouter.Delete("/genres/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminDeleteBookGenre)
+	adminBooksRouter.Delete("/sections/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminDeleteBookSection)
+	adminBooksRouter.Delete("/subsections/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminDeleteBookSubSection)
+	adminBooksRouter.Get("/subsections/:slug/add-book/:book_slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AddBookToSubSection)
+	adminBooksRouter.Get("/subsections/:slug/remove-book/:book_slug", can(choices.PERM_MANAGE_CATALOG), endpoint.RemoveBookFromSubSection)
+	adminBooksRouter.Get("/book/:slug/toggle-book-completion-status", can(choices.PERM_MANAGE_BOOKS), endpoint.ToggleBookCompletionStatus)
+	adminBooksRouter.Delete("/tags/:slug", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminDeleteBookTag)
 
 	// Admin Contents
-	adminRouter.Get("/featured-contents", endpoint.AdminGetFeaturedContents)
-	adminRouter.Post("/featured-contents", endpoint.AdminAddAFeaturedContent)
-	adminRouter.Put("/featured-contents/:id", endpoint.AdminUpdateAFeaturedContent)
-	adminRouter.Delete("/featured-contents/:id", endpoint.AdminDeleteAFeaturedContent)
+	adminRouter.Get("/featured-contents", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminGetFeaturedContents)
+	adminRouter.Post("/featured-contents", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminAddAFeaturedContent)
+	adminRouter.Put("/featured-contents/:id", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminUpdateAFeaturedContent)
+	adminRouter.Delete("/featured-contents/:id", can(choices.PERM_MANAGE_CATALOG), endpoint.AdminDeleteAFeaturedContent)
 
 	// Admin Waitlist (1)
-	adminRouter.Get("/waitlist", endpoint.AdminGetWaitlist)
+	adminRouter.Get("/waitlist", can(choices.PERM_VIEW_WAITLIST), endpoint.AdminGetWaitlist)
 
-	// Admin Payments (1)
-	walletRouter.Put("/payments/plans", endpoint.UpdateSubscriptionPlan)
-	adminRouter.Get("/payments/transactions", endpoint.AdminGetTransactions)
+	// Admin Payments (14)
+	adminRouter.Put("/payments/plans", can(choices.PERM_MANAGE_PAYMENTS), endpoint.UpdateSubscriptionPlan)
+	adminRouter.Get("/payments/transactions", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetTransactions)
+	adminRouter.Post("/payments/transactions/:id/refund", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminRefundTransaction)
+	adminRouter.Get("/payments/earnings", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetEarnings)
+	adminRouter.Get("/payments/payouts", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetPayouts)
+	adminRouter.Put("/payments/payouts/:id", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminUpdatePayoutStatus)
+	adminRouter.Get("/payments/events", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetPaymentEvents)
+	adminRouter.Post("/payments/events/:id/replay", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminReplayPaymentEvent)
+	adminRouter.Get("/payments/reports/revenue", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetRevenueReport)
+	adminRouter.Get("/payments/reports/summary", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetRevenueSummary)
+	adminRouter.Get("/payments/promo-codes", can(choices.PERM_VIEW_PAYMENTS), endpoint.AdminGetPromoCodes)
+	adminRouter.Post("/payments/promo-codes", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminCreatePromoCode)
+	adminRouter.Put("/payments/promo-codes/:id", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminUpdatePromoCode)
+	adminRouter.Delete("/payments/promo-codes/:id", can(choices.PERM_MANAGE_PAYMENTS), endpoint.AdminDeletePromoCode)
 	// --------------------------------------------------------------------------------
 
 	// Waitlist Routes (1)
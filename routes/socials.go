@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List linked accounts
+// @Description `This endpoint returns the identity provider accounts the user can sign in with`
+// @Tags Auth
+// @Success 200 {object} schemas.SocialAccountsResponseSchema
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /auth/socials [get]
+// @Security BearerAuth
+func (ep Endpoint) GetSocialAccounts(c *fiber.Ctx) error {
+	user := RequestUser(c)
+	accounts := socialAccountManager.GetByUser(ep.DB, *user)
+	data := []schemas.SocialAccountSchema{}
+	for _, account := range accounts {
+		data = append(data, schemas.SocialAccountSchema{}.Init(account))
+	}
+	response := schemas.SocialAccountsResponseSchema{
+		ResponseSchema: ResponseMessage("Linked accounts fetched successfully"),
+		Data:           data,
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Link an account
+// @Description `This endpoint links an identity provider account to the user so they can sign in with it`
+// @Description `provider is one of google, facebook or apple. The token is the one used to sign in with the provider.`
+// @Tags Auth
+// @Param provider path string true "Provider" Enums(google, facebook, apple)
+// @Param data body schemas.SocialLoginSchema true "Provider token"
+// @Success 201 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
+// @Router /auth/socials/{provider} [post]
+// @Security BearerAuth
+func (ep Endpoint) LinkSocialAccount(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	provider := choices.SocialProviderChoice(c.Params("provider"))
+	if !provider.IsValid() {
+		return c.Status(400).JSON(utils.InvalidParamErr("Invalid provider"))
+	}
+
+	data := schemas.SocialLoginSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	userIdentity, errCode, errData := VerifySocialToken(provider, data.Token, data.DeviceType)
+	if errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if _, errData := socialAccountManager.Link(db, *user, *userIdentity); errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+	return c.Status(201).JSON(ResponseMessage("Account linked successfully"))
+}
+
+// @Summary Unlink an account
+// @Description `This endpoint stops an identity provider account from signing in as the user`
+// @Tags Auth
+// @Param provider path string true "Provider" Enums(google, facebook, apple)
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /auth/socials/{provider} [delete]
+// @Security BearerAuth
+func (ep Endpoint) UnlinkSocialAccount(c *fiber.Ctx) error {
+	user := RequestUser(c)
+
+	provider := choices.SocialProviderChoice(c.Params("provider"))
+	if !provider.IsValid() {
+		return c.Status(400).JSON(utils.InvalidParamErr("Invalid provider"))
+	}
+	if errData := socialAccountManager.Unlink(ep.DB, *user, provider); errData != nil {
+		errCode := 400
+		if errData.Code == utils.ERR_NON_EXISTENT {
+			errCode = 404
+		}
+		return c.Status(errCode).JSON(errData)
+	}
+	return c.Status(200).JSON(ResponseMessage("Account unlinked successfully"))
+}
@@ -14,6 +14,7 @@ import (
 	webs "github.com/fasthttp/websocket"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -80,7 +81,8 @@ func ValidateAuth(db *gorm.DB, token string) (*models.User, *string, *string) {
 func SendNotificationInSocket(fiberCtx *fiber.Ctx, notification models.Notification, statusOpts ...choices.NotificationStatus) error {
 	cfg := config.GetConfig()
 
-	if os.Getenv("ENVIRONMENT") == "test" {
+	// Also when the notification wasn't created e.g because the receiver blocked the sender
+	if os.Getenv("ENVIRONMENT") == "test" || notification.ID == uuid.Nil {
 		return nil
 	}
 
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Returns the provider's recurring price for the plan's current amount, creating it if the plan has none
+func PlanPriceReference(db *gorm.DB, provider payments.SubscriptionProvider, plan *models.SubscriptionPlan) (string, error) {
+	if plan.PriceReference != nil {
+		return *plan.PriceReference, nil
+	}
+	interval := "month"
+	if plan.SubType == choices.ST_ANNUAL {
+		interval = "year"
+	}
+	priceReference, err := provider.CreatePrice(fmt.Sprintf("LitPad %s Subscription", plan.SubType), plan.Amount, interval)
+	if err != nil {
+		return "", err
+	}
+	plan.PriceReference = &priceReference
+	db.Model(plan).Update("price_reference", priceReference)
+	return priceReference, nil
+}
+
+// Creates a subscription that renews automatically and the transaction for its first invoice.
+// The first invoice is settled through the invoice webhook events. A promo code's discount only applies to that invoice.
+func CreateProviderSubscription(db *gorm.DB, user models.User, plan *models.SubscriptionPlan, paymentType choices.PaymentType, provider payments.SubscriptionProvider, paymentToken string, promoCode *models.PromoCode) (*models.Transaction, *utils.ErrorResponse) {
+	amount := plan.Amount
+	if promoCode != nil {
+		if errD := applyPromoCode(&amount, paymentType, *promoCode); errD != nil {
+			return nil, errD
+		}
+	}
+	errD := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create subscription")
+	priceReference, err := PlanPriceReference(db, provider, plan)
+	if err != nil {
+		return nil, &errD
+	}
+	params := payments.SubscriptionParams{
+		Email: user.Email, Name: user.Username, PriceReference: priceReference, Token: paymentToken,
+		AmountOff: plan.Amount.Sub(amount),
+	}
+	if existingSub := subscriptionManager.GetByUser(db, user); existingSub != nil && existingSub.Provider == paymentType {
+		params.CustomerReference = existingSub.CustomerReference
+	}
+
+	transaction := models.Transaction{
+		UserID: user.ID, SubscriptionPlanID: &plan.ID,
+		PaymentType: paymentType, PaymentPurpose: choices.PP_SUB, Amount: amount,
+	}
+	if promoCode != nil {
+		transaction.PromoCodeID = &promoCode.ID
+		transaction.BonusCoins = promoCode.BonusCoins
+	}
+	var providerSubscription *payments.Subscription
+	errC := recordPayment(db, user, promoCode, &transaction, func() *utils.ErrorResponse {
+		var err error
+		providerSubscription, err = provider.CreateSubscription(params)
+		if err != nil {
+			return &errD
+		}
+		return nil
+	}, func(tx *gorm.DB) *utils.ErrorResponse {
+		if _, err := subscriptionManager.Start(tx, user, *plan, paymentType, providerSubscription.Reference, providerSubscription.CustomerReference); err != nil {
+			errD = utils.ServerErr("Something went wrong while saving your subscription")
+			return &errD
+		}
+		transaction.Reference = providerSubscription.InvoiceReference
+		transaction.ClientSecret = providerSubscription.ClientSecret
+		return nil
+	})
+	if errC != nil {
+		return nil, errC
+	}
+	transaction.SubscriptionPlan = plan
+	return &transaction, nil
+}
+
+// Returns the provider that renews a subscription
+func renewingProvider(subscription models.Subscription) (payments.SubscriptionProvider, *utils.ErrorResponse) {
+	provider, err := payments.Get(subscription.Provider)
+	if err == nil {
+		if subProvider, ok := provider.(payments.SubscriptionProvider); ok {
+			return subProvider, nil
+		}
+	}
+	errD := utils.ServerErr("Your subscription's payment provider can't be reached")
+	return nil, &errD
+}
+
+// @Summary View Subscription
+// @Description This endpoint returns the current user's subscription
+// @Tags Wallet
+// @Success 200 {object} schemas.SubscriptionResponseSchema
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /wallet/subscription [get]
+// @Security BearerAuth
+func (ep Endpoint) GetSubscription(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	sub := subscriptionManager.GetByUser(db, *user)
+	if sub == nil {
+		return c.Status(404).JSON(utils.NotFoundErr("You don't have a subscription"))
+	}
+	response := schemas.SubscriptionResponseSchema{
+		ResponseSchema: ResponseMessage("Subscription fetched successfully"),
+		Data:           schemas.SubscriptionSchema{}.Init(*sub),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Change Subscription Plan
+// @Description `This endpoint allows a user to upgrade his/her subscription from the monthly plan to the annual plan`
+// @Description `The unused part of the current period is deducted and the rest is charged immediately`
+// @Tags Wallet
+// @Param subscription body schemas.ChangeSubscriptionSchema true "Subscription data"
+// @Success 200 {object} schemas.SubscriptionChangeResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /wallet/subscription [put]
+// @Security BearerAuth
+func (ep Endpoint) ChangeSubscriptionPlan(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.ChangeSubscriptionSchema{}
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	sub := subscriptionManager.GetByUser(db, *user)
+	if sub == nil || !sub.IsActive() {
+		return c.Status(404).JSON(utils.NotFoundErr("You don't have an active subscription"))
+	}
+	if !sub.Renews() {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Your subscription doesn't renew. Subscribe to the plan when it ends"))
+	}
+	if sub.Plan.SubType == data.SubType {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You are on that plan already"))
+	}
+	if data.SubType != choices.ST_ANNUAL {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Cancel your annual plan and subscribe to the monthly plan when it ends"))
+	}
+
+	plan := models.SubscriptionPlan{}
+	db.Take(&plan, "sub_type = ?", data.SubType)
+	if plan.ID == uuid.Nil {
+		return c.Status(404).JSON(utils.NotFoundErr("No subscription plan with that type"))
+	}
+	provider, errD := renewingProvider(*sub)
+	if errD != nil {
+		return c.Status(500).JSON(errD)
+	}
+	priceReference, err := PlanPriceReference(db, provider, &plan)
+	if err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Failed to change your plan"))
+	}
+	prorationAmount := subscriptionManager.ProrationAmount(*sub, plan, time.Now())
+	if err := provider.ChangeSubscriptionPrice(*sub.Reference, priceReference); err != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Your card was declined for the plan change"))
+	}
+	// The new period is applied when the proration invoice is paid
+	response := schemas.SubscriptionChangeResponseSchema{
+		ResponseSchema: ResponseMessage("Subscription plan changed successfully"),
+		Data: schemas.SubscriptionChangeSchema{
+			SubscriptionSchema: schemas.SubscriptionSchema{}.Init(*sub),
+			ProrationAmount:    prorationAmount,
+		},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Cancel Subscription
+// @Description `This endpoint allows a user to stop his/her subscription from renewing`
+// @Description `Access is kept till the end of the current period`
+// @Tags Wallet
+// @Success 200 {object} schemas.SubscriptionResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /wallet/subscription/cancel [post]
+// @Security BearerAuth
+func (ep Endpoint) CancelSubscription(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+	sub := subscriptionManager.GetByUser(db, *user)
+	if sub == nil || !sub.IsActive() {
+		return c.Status(404).JSON(utils.NotFoundErr("You don't have an active subscription"))
+	}
+	if !sub.Renews() {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Your subscription won't renew already"))
+	}
+	provider, errD := renewingProvider(*sub)
+	if errD != nil {
+		return c.Status(500).JSON(errD)
+	}
+	if err := provider.CancelSubscription(*sub.Reference); err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Failed to cancel your subscription"))
+	}
+	if err := subscriptionManager.Cancel(db, sub); err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong while canceling your subscription"))
+	}
+	response := schemas.SubscriptionResponseSchema{
+		ResponseSchema: ResponseMessage("Subscription canceled successfully"),
+		Data:           schemas.SubscriptionSchema{}.Init(*sub),
+	}
+	return c.Status(200).JSON(response)
+}
@@ -0,0 +1,227 @@
+package routes
+
+import (
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const totpIssuer = "LitPad"
+
+// Checks a second factor code and returns the error response for codes that can't be accepted
+func verifyTwoFactorCode(db *gorm.DB, user *models.User, method choices.TwoFactorMethodChoice, code string) (*int, *utils.ErrorResponse) {
+	errCode := 429
+	errData := utils.RateLimitError("Too many incorrect codes. Try again later")
+	if user.IsTwoFactorLocked() {
+		return &errCode, &errData
+	}
+	if !userManager.VerifyTwoFactorCode(db, user, method, code) {
+		if !user.IsTwoFactorLocked() {
+			errCode = 401
+			errData = utils.RequestErr(utils.ERR_INCORRECT_OTP, "Incorrect or expired code")
+		}
+		return &errCode, &errData
+	}
+	return nil, nil
+}
+
+// @Summary Complete a login with a second factor
+// @Description `This endpoint exchanges the challenge token given at login and a second factor code for access and refresh tokens`
+// @Description `method is one of the methods the challenge listed: totp (authenticator app), email or recovery`
+// @Tags Auth
+// @Param data body schemas.TwoFactorLoginSchema true "Challenge and code"
+// @Success 201 {object} schemas.LoginResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
+// @Router /auth/2fa/verify [post]
+func (ep Endpoint) VerifyTwoFactorLogin(c *fiber.Ctx) error {
+	db := ep.DB
+
+	data := schemas.TwoFactorLoginSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	user, deviceType, errMsg := DecodeTwoFactorChallenge(data.ChallengeToken, db)
+	if errMsg != nil {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *errMsg))
+	}
+	if errCode, errData := verifyTwoFactorCode(db, user, data.Method, data.Code); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return issueLoginTokens(c, db, *user, deviceType, "Login successful")
+}
+
+// @Summary Email a login code
+// @Description `This endpoint emails a code for completing a login, for users who can't use their authenticator app`
+// @Tags Auth
+// @Param data body schemas.TwoFactorChallengeTokenSchema true "Challenge"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /auth/2fa/email [post]
+func (ep Endpoint) SendTwoFactorLoginEmail(c *fiber.Ctx) error {
+	db := ep.DB
+
+	data := schemas.TwoFactorChallengeTokenSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	user, _, errMsg := DecodeTwoFactorChallenge(data.ChallengeToken, db)
+	if errMsg != nil {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *errMsg))
+	}
+	SendTwoFactorEmail(db, user)
+	return c.Status(200).JSON(ResponseMessage("Login code sent to your email"))
+}
+
+// @Summary Start authenticator app setup
+// @Description `This endpoint creates a secret for the user to add to an authenticator app, either as it is or from a QR code of the uri`
+// @Description `Two-factor authentication is turned on once a code from the app is sent to /auth/2fa/totp/enable`
+// @Tags Auth
+// @Success 200 {object} schemas.TotpSetupResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /auth/2fa/totp/setup [post]
+// @Security BearerAuth
+func (ep Endpoint) SetupTotp(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	if user.TotpEnabled {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is enabled already"))
+	}
+	secret := userManager.SetTotpSecret(db, user)
+	response := schemas.TotpSetupResponseSchema{
+		ResponseSchema: ResponseMessage("Add the secret to your authenticator app"),
+		Data:           schemas.TotpSetupSchema{Secret: secret, Uri: utils.TotpUri(secret, user.Email, totpIssuer)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// @Summary Enable two-factor authentication
+// @Description `This endpoint turns on two-factor authentication with a code from the authenticator app the secret was added to`
+// @Description `The recovery codes returned can each be used once to log in without the app. They aren't shown again.`
+// @Tags Auth
+// @Param data body schemas.TotpCodeSchema true "Code from the authenticator app"
+// @Success 200 {object} schemas.RecoveryCodesResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /auth/2fa/totp/enable [post]
+// @Security BearerAuth
+func (ep Endpoint) EnableTotp(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.TotpCodeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if user.TotpEnabled {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is enabled already"))
+	}
+	if user.TotpSecret == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Set up your authenticator app first"))
+	}
+	// The code is checked as it would be after enabling
+	user.TotpEnabled = true
+	if errCode, errData := verifyTwoFactorCode(db, user, choices.TFM_TOTP, data.Code); errData != nil {
+		user.TotpEnabled = false
+		return c.Status(*errCode).JSON(errData)
+	}
+	codes := userManager.EnableTotp(db, user)
+	response := schemas.RecoveryCodesResponseSchema{
+		ResponseSchema: ResponseMessage("Two-factor authentication enabled"),
+		Data:           schemas.RecoveryCodesSchema{Codes: codes},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// Checks the code given to change two-factor settings. Only a code from the app or a recovery code will do.
+func verifyTwoFactorSettingsCode(db *gorm.DB, user *models.User, data schemas.TwoFactorCodeSchema) (*int, *utils.ErrorResponse) {
+	errCode := 400
+	if !user.TotpEnabled {
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication isn't enabled")
+		return &errCode, &errData
+	}
+	if data.Method == choices.TFM_EMAIL {
+		errCode = 422
+		errData := utils.ValidationErr("method", "Use a code from your authenticator app or a recovery code")
+		return &errCode, &errData
+	}
+	return verifyTwoFactorCode(db, user, data.Method, data.Code)
+}
+
+// @Summary Disable two-factor authentication
+// @Description `This endpoint turns off two-factor authentication and removes the recovery codes`
+// @Description `Staff still have to enter a code sent to their email when they log in`
+// @Tags Auth
+// @Param data body schemas.TwoFactorCodeSchema true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} schemas.ResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
+// @Router /auth/2fa/totp/disable [post]
+// @Security BearerAuth
+func (ep Endpoint) DisableTotp(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.TwoFactorCodeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if errCode, errData := verifyTwoFactorSettingsCode(db, user, data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	userManager.DisableTotp(db, user)
+	return c.Status(200).JSON(ResponseMessage("Two-factor authentication disabled"))
+}
+
+// @Summary Regenerate recovery codes
+// @Description `This endpoint replaces the user's recovery codes. The old ones can't be used after this.`
+// @Tags Auth
+// @Param data body schemas.TwoFactorCodeSchema true "Code from the authenticator app or a recovery code"
+// @Success 200 {object} schemas.RecoveryCodesResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 429 {object} utils.ErrorResponse
+// @Router /auth/2fa/recovery-codes [post]
+// @Security BearerAuth
+func (ep Endpoint) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	data := schemas.TwoFactorCodeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if errCode, errData := verifyTwoFactorSettingsCode(db, user, data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	codes := userManager.GenerateRecoveryCodes(db, *user)
+	response := schemas.RecoveryCodesResponseSchema{
+		ResponseSchema: ResponseMessage("Recovery codes regenerated"),
+		Data:           schemas.RecoveryCodesSchema{Codes: codes},
+	}
+	return c.Status(200).JSON(response)
+}
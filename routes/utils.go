@@ -1,21 +1,22 @@
 package routes
 
 import (
+	"bytes"
+	"encoding/csv"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
-	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
-	"github.com/stripe/stripe-go/v72"
-	"github.com/stripe/stripe-go/v72/paymentintent"
 	"gorm.io/gorm"
 )
 
@@ -31,6 +32,66 @@ func RequestUser(c *fiber.Ctx) *models.User {
 	return user
 }
 
+// Returns a book the user of a request can edit. Those who manage books can edit any book and authors their own.
+func GetWritableBook(c *fiber.Ctx, db *gorm.DB, slug string, preload bool) (*models.Book, *utils.ErrorResponse) {
+	if HasPermission(c, db, choices.PERM_MANAGE_BOOKS) {
+		return bookManager.GetBySlug(db, slug, preload)
+	}
+	return bookManager.GetByAuthorAndSlug(db, RequestUser(c), slug)
+}
+
+// Returns a chapter the user of a request can edit (see GetWritableBook)
+func GetWritableChapter(c *fiber.Ctx, db *gorm.DB, slug string) (*models.Chapter, *utils.ErrorResponse) {
+	chapter, err := chapterManager.GetBySlug(db, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !CanEditBook(c, db, chapter.Book) {
+		errD := utils.NotFoundErr("No chapter with that slug")
+		return nil, &errD
+	}
+	return chapter, nil
+}
+
+func CanEditBook(c *fiber.Ctx, db *gorm.DB, book models.Book) bool {
+	return book.AuthorID == RequestUser(c).ID || HasPermission(c, db, choices.PERM_MANAGE_BOOKS)
+}
+
+// Checks that a chapter being scheduled is scheduled for later, and when an existing chapter is given that it hasn't come out yet.
+// Readers were told about a chapter when it first came out, so it can't be scheduled to come out again.
+func ValidateChapterSchedule(data schemas.ChapterCreateSchema, chapter ...models.Chapter) *utils.ErrorResponse {
+	if data.Status != choices.CS_SCHEDULED {
+		return nil
+	}
+	if len(chapter) > 0 && chapter[0].Status != choices.CS_DRAFT && chapter[0].Status != choices.CS_SCHEDULED {
+		errD := utils.ValidationErr("status", "Only a draft can be scheduled")
+		return &errD
+	}
+	if data.PublishAt == nil || !data.PublishAt.After(time.Now()) {
+		errD := utils.ValidationErr("publish_at", "Set a time in the future to schedule the chapter")
+		return &errD
+	}
+	return nil
+}
+
+// Whether the user of a request can see a chapter at all. Chapters that aren't published are only seen by those who can edit them.
+func CanSeeChapter(c *fiber.Ctx, db *gorm.DB, chapter models.Chapter) bool {
+	return chapter.IsPublished() || CanEditBook(c, db, chapter.Book)
+}
+
+// Returns a chapter the user of a request can see (see CanSeeChapter)
+func GetVisibleChapter(c *fiber.Ctx, db *gorm.DB, slug string) (*models.Chapter, *utils.ErrorResponse) {
+	chapter, err := chapterManager.GetBySlug(db, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !CanSeeChapter(c, db, *chapter) {
+		errD := utils.NotFoundErr("No chapter with that slug")
+		return nil, &errD
+	}
+	return chapter, nil
+}
+
 func Session(c *fiber.Ctx, store *session.Store) *session.Session {
 	// Get session from storage
 	sess, err := store.Get(c)
@@ -45,70 +106,168 @@ func GetBaseReferer(c *fiber.Ctx) string {
 	return string(referer[:])
 }
 
-func CreatePaymentIntent(db *gorm.DB, user models.User, plan *models.SubscriptionPlan, paymentToken *string, coin *models.Coin, quantity int) (*models.Transaction, *utils.ErrorResponse) {
-	cfg := config.GetConfig()
-	stripe.Key = cfg.StripeSecretKey
-	var price int64
-	if coin != nil {
-		price = coin.Price.Mul(decimal.NewFromFloat(100)).IntPart()
-	} else {
-		price = plan.Amount.Mul(decimal.NewFromFloat(100)).IntPart()
-	}
-
-	// Base PaymentIntent parameters
-	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(price),
-		Currency: stripe.String(string(stripe.CurrencyUSD)),
+// Starts a one-off payment with the chosen provider and records its transaction.
+// Payments the provider settles straight away (e.g Google Play purchases) are applied immediately.
+// A promo code lowers the amount charged and adds its bonus coins.
+func CreatePaymentIntent(db *gorm.DB, user models.User, paymentType choices.PaymentType, plan *models.SubscriptionPlan, paymentToken *string, coin *models.Coin, quantity int, promoCode *models.PromoCode) (*models.Transaction, *utils.ErrorResponse) {
+	provider, err := payments.Get(paymentType)
+	if err != nil {
+		errD := utils.RequestErr(utils.ERR_INVALID_REQUEST, "Invalid payment type")
+		return nil, &errD
 	}
-
-	// Determine payment method type based on token presence
-	if paymentToken != nil {
-		// Google Pay (Token provided)
-		params.PaymentMethodData = &stripe.PaymentIntentPaymentMethodDataParams{
-			Type: stripe.String("card"),
-			Card: &stripe.PaymentMethodCardParams{
-				Token: stripe.String(*paymentToken), // Tokenized card
-			},
+	params := payments.IntentParams{Token: paymentToken}
+	if coin != nil {
+		if paymentType == choices.PTYPE_GPAY && quantity != 1 {
+			// A google play purchase is for a single pack
+			errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Only one set of coins can be bought at a time with google play")
+			return nil, &errD
 		}
-		params.ConfirmationMethod = stripe.String(string(stripe.PaymentIntentConfirmationMethodManual))
-		params.Confirm = stripe.Bool(true)
+		params.Amount = coin.Price.Mul(decimal.NewFromInt(int64(quantity)))
+		params.Description = fmt.Sprintf("%d LitPad coins", coin.Amount)
+		params.ProductReference = coin.ProductReference
 	} else {
-		// Card, Cashapp, etc (No token provided)
-		params.PaymentMethodTypes = stripe.StringSlice([]string{"card", "cashapp"})
+		params.Amount = plan.Amount
+		params.Description = fmt.Sprintf("LitPad %s Subscription", plan.SubType)
+		params.ProductReference = plan.ProductReference
 	}
-
-	// Create the Payment Intent
-	intent, err := paymentintent.New(params)
-	if err != nil {
-		errD := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create Payment Intent")
-		return nil, &errD
+	if promoCode != nil {
+		errD := applyPromoCode(&params.Amount, paymentType, *promoCode)
+		if errD != nil {
+			return nil, errD
+		}
 	}
 
 	// Create Transaction Object
 	transaction := models.Transaction{
-		Reference:    intent.ID,
-		UserID:       user.ID,
-		Quantity:     quantity,
-		ClientSecret: intent.ClientSecret,
+		UserID:      user.ID,
+		Quantity:    quantity,
+		PaymentType: paymentType,
+		Amount:      params.Amount,
+	}
+	if promoCode != nil {
+		transaction.PromoCodeID = &promoCode.ID
+		transaction.BonusCoins = promoCode.BonusCoins
 	}
 	if coin != nil {
 		transaction.CoinID = &coin.ID
-		transaction.PaymentType = choices.PTYPE_STRIPE
 		transaction.PaymentPurpose = choices.PP_COINS
 	} else {
 		transaction.SubscriptionPlanID = &plan.ID
-		transaction.PaymentType = choices.PTYPE_GPAY
 		transaction.PaymentPurpose = choices.PP_SUB
 	}
-	db.Create(&transaction)
+	var intent *payments.Intent
+	errD := recordPayment(db, user, promoCode, &transaction, func() *utils.ErrorResponse {
+		// Create the Payment Intent
+		var err error
+		intent, err = provider.CreateIntent(params)
+		if err != nil {
+			errD := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create Payment Intent")
+			return &errD
+		}
+		return nil
+	}, func(tx *gorm.DB) *utils.ErrorResponse {
+		existingTransaction := models.Transaction{}
+		tx.Take(&existingTransaction, "reference = ?", intent.Reference)
+		if existingTransaction.ID != uuid.Nil {
+			// e.g a google play purchase that was submitted before
+			errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This payment has been used already")
+			return &errD
+		}
+		transaction.Reference = intent.Reference
+		transaction.ClientSecret = intent.ClientSecret
+		return nil
+	})
+	if errD != nil {
+		return nil, errD
+	}
 	transaction.SubscriptionPlan = plan
 	transaction.Coin = coin
+
+	if intent.Status != choices.PSPENDING {
+		settledTransaction, err := settleTransaction(db, intent.Reference, intent.Status, nil)
+		if err != nil {
+			errD := utils.ServerErr("Something went wrong while applying your payment")
+			return nil, &errD
+		}
+		transaction = *settledTransaction
+	}
 	return &transaction, nil
 }
 
+// Records a payment that is made with a provider by calling pay, then saving the transaction along with what record saves.
+// A payment with a promo code first holds a redemption of the code with its pending transaction, which is saved in a short
+// transaction that locks the code (see PromoCodeManager.Claim). The provider isn't called while the code is locked,
+// and the hold is released when the payment can't be made.
+func recordPayment(db *gorm.DB, user models.User, promoCode *models.PromoCode, transaction *models.Transaction, pay func() *utils.ErrorResponse, record func(tx *gorm.DB) *utils.ErrorResponse) *utils.ErrorResponse {
+	serverErr := utils.ServerErr("Something went wrong while saving your payment")
+	if promoCode != nil {
+		var errD *utils.ErrorResponse
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if errD = promoCodeManager.Claim(tx, *promoCode, user); errD != nil {
+				return errD
+			}
+			// Replaced with the provider's reference once the payment is made
+			transaction.Reference = fmt.Sprintf("hold-%s", uuid.NewString())
+			return tx.Create(transaction).Error
+		})
+		if errD != nil {
+			return errD
+		}
+		if err != nil {
+			return &serverErr
+		}
+	}
+
+	errD := pay()
+	if errD == nil {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if errD = record(tx); errD != nil {
+				return errD
+			}
+			return tx.Save(transaction).Error
+		})
+		if err != nil && errD == nil {
+			errD = &serverErr
+		}
+	}
+	if errD != nil && transaction.ID != uuid.Nil {
+		// Releases the redemption. One that is left behind runs out with the hold (see GetClaimedCount).
+		db.Delete(transaction)
+		transaction.ID = uuid.Nil
+	}
+	return errD
+}
+
+// Lowers an amount to be charged by a promo code's discount
+func applyPromoCode(amount *decimal.Decimal, paymentType choices.PaymentType, promoCode models.PromoCode) *utils.ErrorResponse {
+	discounted := promoCode.Discounted(*amount)
+	if discounted.Equal(*amount) {
+		return nil
+	}
+	if paymentType == choices.PTYPE_GPAY {
+		// Google Play charges its own listed prices
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Discount codes can't be used with google play")
+		return &errD
+	}
+	if !discounted.IsPositive() {
+		errD := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This promo code can't be used for this purchase")
+		return &errD
+	}
+	*amount = discounted
+	return nil
+}
+
+// Returns the status code for an error from CreatePaymentIntent
+func PaymentErrStatus(errD *utils.ErrorResponse) int {
+	if errD.Code == utils.ERR_SERVER_ERROR {
+		return 500
+	}
+	return 400
+}
+
 func IsValidPaymentStatus(s string) bool {
 	switch choices.PaymentStatus(s) {
-	case choices.PSPENDING, choices.PSSUCCEEDED, choices.PSFAILED, choices.PSCANCELED:
+	case choices.PSPENDING, choices.PSSUCCEEDED, choices.PSFAILED, choices.PSCANCELED, choices.PSREFUNDED, choices.PSDISPUTED:
 		return true
 	}
 	return false
@@ -159,6 +318,18 @@ func CheckTagStrings(db *gorm.DB, submittedList []string, genre models.Genre) ([
 	return tagsToReturn, nil
 }
 
+// Checks if a user can read a chapter.
+// Authors, staff and active subscribers can read every chapter, others only the first chapter and the ones they bought.
+func CanReadChapter(db *gorm.DB, user *models.User, chapter models.Chapter) bool {
+	if chapter.Book.AuthorID == user.ID || user.IsStaff || !user.SubscriptionExpired() {
+		return true
+	}
+	if chapterManager.IsFirstChapter(db, chapter) {
+		return true
+	}
+	return boughtChapterManager.GetByBuyerAndChapter(db, user, chapter) != nil || boughtChapterManager.HasBoughtBook(db, user, chapter.BookID)
+}
+
 func ReadBook(db *gorm.DB, bookID uuid.UUID, user *models.User, completed bool) models.BookRead {
 	previouslyRead := models.BookRead{}
 	db.First(&previouslyRead, "user_id = ?", user.ID)
@@ -173,6 +344,40 @@ func ReadBook(db *gorm.DB, bookID uuid.UUID, user *models.User, completed bool)
 	return bookRead
 }
 
+// Returns an error when any of the users that own what a user is interacting with has blocked them
+func CheckBlocked(db *gorm.DB, user *models.User, ownerIDs ...uuid.UUID) (*int, *utils.ErrorResponse) {
+	for _, ownerID := range ownerIDs {
+		if ownerID != user.ID && userBlockManager.IsBlocked(db, ownerID, user.ID) {
+			errCode := 403
+			errData := utils.RequestErr(utils.ERR_BLOCKED, "You have been blocked by this user")
+			return &errCode, &errData
+		}
+	}
+	return nil, nil
+}
+
+// Leaves out the comments of users that a user has blocked or muted
+func ExcludeHiddenComments(db *gorm.DB, user *models.User, comments []models.Comment) []models.Comment {
+	if user == nil {
+		return comments
+	}
+	hiddenUserIDs := userBlockManager.GetHiddenUserIDs(db, *user)
+	if len(hiddenUserIDs) == 0 {
+		return comments
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range hiddenUserIDs {
+		hidden[id] = true
+	}
+	visibleComments := []models.Comment{}
+	for _, comment := range comments {
+		if !hidden[comment.UserID] {
+			visibleComments = append(visibleComments, comment)
+		}
+	}
+	return visibleComments
+}
+
 func IsAmongUserType(target string) bool {
 	switch target {
 	case "ADMIN", string(choices.ACCTYPE_READER), string(choices.ACCTYPE_AUTHOR):
@@ -181,6 +386,53 @@ func IsAmongUserType(target string) bool {
 	return false
 }
 
+// Returns the date range of a report from the start and end (inclusive) query values, e.g 2024-06-30.
+// The range is the last 30 days by default. The end returned is exclusive.
+func ReportRange(c *fiber.Ctx) (*time.Time, *time.Time, *utils.ErrorResponse) {
+	end := time.Now().UTC().Truncate(24 * time.Hour).AddDate(0, 0, 1)
+	if endQuery := GetQueryValue(c, "end"); endQuery != nil {
+		parsedEnd, err := time.Parse(time.DateOnly, *endQuery)
+		if err != nil {
+			errD := utils.InvalidParamErr("Invalid end date. Use the format YYYY-MM-DD")
+			return nil, nil, &errD
+		}
+		end = parsedEnd.AddDate(0, 0, 1)
+	}
+	start := end.AddDate(0, 0, -30)
+	if startQuery := GetQueryValue(c, "start"); startQuery != nil {
+		parsedStart, err := time.Parse(time.DateOnly, *startQuery)
+		if err != nil {
+			errD := utils.InvalidParamErr("Invalid start date. Use the format YYYY-MM-DD")
+			return nil, nil, &errD
+		}
+		start = parsedStart
+	}
+	if !start.Before(end) {
+		errD := utils.InvalidParamErr("The start date can't be after the end date")
+		return nil, nil, &errD
+	}
+	return &start, &end, nil
+}
+
+// Checks if a report should be exported as csv instead of json
+func WantsCSV(c *fiber.Ctx) bool {
+	return c.Query("format") == "csv" || c.Get(fiber.HeaderAccept) == "text/csv"
+}
+
+// Sends rows as a csv file download
+func SendCSV(c *fiber.Ctx, filename string, header []string, rows [][]string) error {
+	buffer := bytes.Buffer{}
+	writer := csv.NewWriter(&buffer)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Failed to export the report"))
+	}
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.Status(200).Send(buffer.Bytes())
+}
+
 func GetQueryValue(c *fiber.Ctx, key string) *string {
 	value := c.Query(key, "")
 	if value == "" {
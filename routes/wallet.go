@@ -1,20 +1,15 @@
 package routes
 
 import (
-	"encoding/json"
-	"log"
-	"time"
+	"net/http"
 
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
 	"github.com/LitPad/backend/schemas"
-	"github.com/LitPad/backend/senders"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
-	"github.com/stripe/stripe-go/v78"
-	"github.com/stripe/stripe-go/v78/webhook"
 )
 
 // @Summary View Available Coins
@@ -34,15 +29,18 @@ func (ep Endpoint) AvailableCoins(c *fiber.Ctx) error {
 }
 
 // @Summary Buy Coins
-// @Description This endpoint allows a user to buy coins
+// @Description `This endpoint allows a user to buy coins with stripe (default), paypal or google play`
+// @Description `Stripe returns a client secret and paypal an approval link for completing the payment`
+// @Description `Google play purchases are verified with the purchase token and credited immediately`
 // @Tags Wallet
 // @Param coin body schemas.BuyCoinSchema true "Payment object"
-// @Success 200 {object} schemas.PaymentResponseSchema
+// @Success 201 {object} schemas.PaymentResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Router /wallet/coins [post]
 // @Security BearerAuth
 func (ep Endpoint) BuyCoins(c *fiber.Ctx) error {
-	stripe.Key = ep.Config.StripeSecretKey
 	db := ep.DB
 	user := RequestUser(c)
 
@@ -52,17 +50,33 @@ func (ep Endpoint) BuyCoins(c *fiber.Ctx) error {
 	if errCode, errData := ValidateRequest(c, &data); errData != nil {
 		return c.Status(*errCode).JSON(errData)
 	}
+	paymentType := data.GetPaymentType()
+	if paymentType == choices.PTYPE_GPAY && data.PaymentToken == nil {
+		return c.Status(422).JSON(utils.ValidationErr("payment_token", "Required for google play purchases"))
+	}
 
 	coin := models.Coin{}
 	db.Where("id = ?", data.CoinID).Take(&coin)
 	if coin.ID == uuid.Nil {
 		return c.Status(404).JSON(utils.NotFoundErr("No set of coins with that ID"))
 	}
+	if paymentType == choices.PTYPE_GPAY && coin.ProductReference == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "These coins can't be bought with google play"))
+	}
+
+	var promoCode *models.PromoCode
+	if data.PromoCode != nil {
+		var errD *utils.ErrorResponse
+		promoCode, errD = promoCodeManager.GetRedeemable(db, *data.PromoCode, *user, nil, &coin)
+		if errD != nil {
+			return c.Status(400).JSON(errD)
+		}
+	}
 
 	// Create payment intent
-	transaction, errD := CreatePaymentIntent(db, *user, nil, nil, &coin, data.Quantity)
+	transaction, errD := CreatePaymentIntent(db, *user, paymentType, nil, data.PaymentToken, &coin, data.Quantity, promoCode)
 	if errD != nil {
-		return c.Status(500).JSON(errD)
+		return c.Status(PaymentErrStatus(errD)).JSON(errD)
 	}
 
 	response := schemas.PaymentResponseSchema{
@@ -111,112 +125,84 @@ func (ep Endpoint) AllUserTransactions(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+// @Summary View Wallet Ledger
+// @Description `This endpoint returns every coin and lantern movement on a user's wallet with the balance after each one`
+// @Description `Each movement is a debit/credit pair sharing a group_id. Only the user's side is returned.`
+// @Tags Wallet
+// @Param page query int false "Current Page" default(1)
+// @Param currency query string false "Filter by currency: COIN or LANTERN"
+// @Param reason query string false "Filter by reason e.g VOTE, GIFT, CHAPTER_PURCHASE"
+// @Success 200 {object} schemas.WalletLedgerResponseSchema
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /wallet/transactions/ledger [get]
+// @Security BearerAuth
+func (ep Endpoint) GetWalletLedger(c *fiber.Ctx) error {
+	db := ep.DB
+	user := RequestUser(c)
+
+	var currency *choices.CurrencyChoice
+	currencyQuery := choices.CurrencyChoice(c.Query("currency"))
+	if currencyQuery != "" {
+		if !currencyQuery.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid currency"))
+		}
+		currency = &currencyQuery
+	}
+	var reason *choices.WalletReasonChoice
+	reasonQuery := choices.WalletReasonChoice(c.Query("reason"))
+	if reasonQuery != "" {
+		if !reasonQuery.IsValid() {
+			return c.Status(400).JSON(utils.InvalidParamErr("Invalid reason"))
+		}
+		reason = &reasonQuery
+	}
+	entries := walletManager.GetByUser(db, *user, currency, reason)
+
+	// Paginate and return entries
+	paginatedData, paginatedEntries, err := PaginateQueryset(entries, c, 100)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+	entries = paginatedEntries.([]models.WalletEntry)
+	response := schemas.WalletLedgerResponseSchema{
+		ResponseSchema: ResponseMessage("Ledger fetched successfully"),
+		Data: schemas.WalletLedgerResponseDataSchema{
+			PaginatedResponseDataSchema: *paginatedData,
+			Coins:                       user.Coins,
+			Lanterns:                    user.Lanterns,
+		}.Init(entries),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// Receives the payment providers' webhook events. Stripe posts to /wallet/verify-payment
+// and the others to /wallet/verify-payment/<provider> e.g /wallet/verify-payment/paypal
 func (ep Endpoint) VerifyPayment(c *fiber.Ctx) error {
-	stripe.Key = ep.Config.StripeSecretKey
 	db := ep.DB
-	transaction := models.Transaction{}
-	sig := c.Get("Stripe-Signature")
-	event, err := webhook.ConstructEvent(c.BodyRaw(), sig, ep.Config.StripeWebhookSecret)
+	paymentType, ok := payments.TypeFromSlug(c.Params("provider", "stripe"))
+	if !ok {
+		return c.Status(404).JSON(utils.NotFoundErr("Unknown payment provider"))
+	}
+	provider, err := payments.Get(paymentType)
+	if err != nil {
+		return c.Status(404).JSON(utils.NotFoundErr("Unknown payment provider"))
+	}
+	event, err := provider.VerifyWebhook(c.BodyRaw(), http.Header(c.GetReqHeaders()))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"err": err})
 	}
 
-	// Handle different event types
-	switch event.Type {
-	case "payment_intent.succeeded":
-		var intent stripe.PaymentIntent
-		err := json.Unmarshal(event.Data.Raw, &intent)
-		if err != nil {
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
-		// Payment was successful
-		transaction.Reference = intent.ID
-		db.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, transaction)
-		if transaction.ID != uuid.Nil {
-			user := transaction.User
-			subPlan := transaction.SubscriptionPlan
-			if subPlan != nil {
-				// For subscription
-				emailD := map[string]interface{}{"amount": subPlan.Amount}
-				expectedAmount := subPlan.Amount.Mul(decimal.NewFromFloat(100)).IntPart() // Convert to cents
-				if intent.AmountReceived < expectedAmount {
-					transaction.PaymentStatus = choices.PSFAILED
-					go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_FAIL, nil, nil, emailD)
-				} else {
-					subExpiry := time.Now().AddDate(0, 1, 0)
-					if transaction.SubscriptionPlan.SubType == choices.ST_ANNUAL {
-						subExpiry = time.Now().AddDate(0, 12, 0)
-					}
-					user.SubscriptionExpiry = &subExpiry
-					user.CurrentPlan = &subPlan.SubType
-					transaction.PaymentStatus = choices.PSSUCCEEDED
-					go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_SUCC, nil, nil, emailD)
-				}
-			} else {
-				coin := transaction.Coin
-				emailD := map[string]interface{}{"amount": coin.Price}
-				expectedAmount := coin.Price.Mul(decimal.NewFromFloat(100)).IntPart() // Convert to cents
-				if intent.AmountReceived < expectedAmount {
-					transaction.PaymentStatus = choices.PSFAILED
-					go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_FAIL, nil, nil, emailD)
-				} else {
-					coinsTotal := transaction.CoinsTotal()
-					user.Coins = user.Coins + *coinsTotal
-					transaction.PaymentStatus = choices.PSSUCCEEDED
-					go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_SUCC, nil, nil, emailD)
-				}
-			}
-			db.Save(&user)
-			db.Save(&transaction)
-		}
-	case "payment_intent.payment_failed":
-		var intent stripe.PaymentIntent
-		err := json.Unmarshal(event.Data.Raw, &intent)
-		if err != nil {
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
-		// Payment failed
-		transaction.Reference = intent.ID
-		db.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, transaction)
-		if transaction.ID != uuid.Nil {
-			transaction.PaymentStatus = choices.PSFAILED
-			db.Save(&transaction)
-			coin := transaction.Coin
-			plan := transaction.SubscriptionPlan
-			var amount decimal.Decimal
-			if coin != nil {
-				amount = coin.Price
-			} else {
-				amount = plan.Amount
-			}
-			emailD := map[string]interface{}{"amount": amount}
-			go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_FAIL, nil, nil, emailD)
-		}
-	case "payment_intent.canceled":
-		var intent stripe.PaymentIntent
-		err := json.Unmarshal(event.Data.Raw, &intent)
-		if err != nil {
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
-		// Payment canceled
-		transaction.Reference = intent.ID
-		db.Joins("User").Joins("Coin").Joins("SubscriptionPlan").Take(&transaction, transaction)
-		if transaction.ID != uuid.Nil {
-			transaction.PaymentStatus = choices.PSCANCELED
-			db.Save(&transaction)
-			coin := transaction.Coin
-			plan := transaction.SubscriptionPlan
-			var amount decimal.Decimal
-			if coin != nil {
-				amount = coin.Price
-			} else {
-				amount = plan.Amount
-			}
-			emailD := map[string]interface{}{"amount": amount}
-			go senders.SendEmail(&transaction.User, senders.ET_PAYMENT_CANCEL, nil, nil, emailD)
-		}
-	default:
-		log.Printf("Unhandled event type: %s\n", event.Type)
+	// Store the event before applying it so that redeliveries are detected
+	paymentEvent, err := paymentEventManager.Record(db, paymentType, event.ID, event.Type, c.BodyRaw())
+	if err != nil {
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong while storing the event"))
+	}
+	if paymentEvent.Status == choices.PES_PROCESSED || paymentEvent.Status == choices.PES_IGNORED {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	if err := processPaymentEvent(db, ep.Config, paymentEvent); err != nil {
+		// Providers redeliver events that didn't get a 2xx response. The error is kept on the event.
+		return c.Status(500).JSON(utils.ServerErr("Something went wrong while applying the event"))
 	}
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -240,15 +226,18 @@ func (ep Endpoint) GetSubscriptionPlans(c *fiber.Ctx) error {
 }
 
 // @Summary Subscribe
-// @Description This endpoint allows a user to create a subscription for books
+// @Description `This endpoint allows a user to create a subscription for books`
+// @Description `Stripe subscriptions renew automatically at the end of every period till they are canceled`
+// @Description `Paypal and google play pay for a single period`
+// @Description `The client secret returned is for the first payment, which may need confirmation on the client`
 // @Tags Wallet
 // @Param subscription body schemas.CreateSubscriptionSchema true "Payment object"
 // @Success 200 {object} schemas.PaymentResponseSchema
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
 // @Router /wallet/subscription [post]
 // @Security BearerAuth
 func (ep Endpoint) BookSubscription(c *fiber.Ctx) error {
-	stripe.Key = ep.Config.StripeSecretKey
 	db := ep.DB
 	user := RequestUser(c)
 
@@ -260,15 +249,46 @@ func (ep Endpoint) BookSubscription(c *fiber.Ctx) error {
 	}
 
 	plan := models.SubscriptionPlan{}
-	db.Where("type = ?", data.SubType).Take(&plan)
+	db.Where("sub_type = ?", data.SubType).Take(&plan)
 	if plan.ID == uuid.Nil {
 		return c.Status(404).JSON(utils.NotFoundErr("No subscription plan with that type"))
 	}
 
-	// Create payment intent
-	transaction, errD := CreatePaymentIntent(db, *user, &plan, &data.PaymentMethodToken, nil, 1)
+	if existingSub := subscriptionManager.GetByUser(db, *user); existingSub != nil && existingSub.IsActive() {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "You have an active subscription already"))
+	}
+	paymentType := data.GetPaymentType()
+	provider, err := payments.Get(paymentType)
+	if err != nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Invalid payment type"))
+	}
+
+	var promoCode *models.PromoCode
+	var errD *utils.ErrorResponse
+	if data.PromoCode != nil {
+		promoCode, errD = promoCodeManager.GetRedeemable(db, *data.PromoCode, *user, &plan, nil)
+		if errD != nil {
+			return c.Status(400).JSON(errD)
+		}
+	}
+
+	var transaction *models.Transaction
+	if subProvider, ok := provider.(payments.SubscriptionProvider); ok {
+		// Renews automatically
+		transaction, errD = CreateProviderSubscription(db, *user, &plan, paymentType, subProvider, data.PaymentMethodToken, promoCode)
+	} else {
+		// Pays for a single period
+		if paymentType == choices.PTYPE_GPAY && plan.ProductReference == nil {
+			return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "This plan can't be bought with google play"))
+		}
+		var paymentToken *string
+		if data.PaymentMethodToken != "" {
+			paymentToken = &data.PaymentMethodToken
+		}
+		transaction, errD = CreatePaymentIntent(db, *user, paymentType, &plan, paymentToken, nil, 1, promoCode)
+	}
 	if errD != nil {
-		return c.Status(500).JSON(errD)
+		return c.Status(PaymentErrStatus(errD)).JSON(errD)
 	}
 
 	response := schemas.PaymentResponseSchema{
@@ -1,6 +1,12 @@
 package schemas
 
-import "github.com/LitPad/backend/models"
+import (
+	"time"
+
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/google/uuid"
+)
 
 type UserProfilesResponseDataSchema struct {
 	PaginatedResponseDataSchema
@@ -51,7 +57,98 @@ type DashboardResponseSchema struct {
 }
 
 type InviteAdminSchema struct {
-	Email  string `json:"email" validate:"email,required"`
-	Admin  bool   `json:"admin" validate:"required"`
-	Author bool   `json:"author" validate:"required"`
+	Email  string             `json:"email" validate:"email,required"`
+	Role   choices.RoleChoice `json:"role" validate:"required,staff_role_validator" example:"content-moderator"`
+	Author bool               `json:"author"`
+}
+
+type RoleSchema struct {
+	Codename    choices.RoleChoice         `json:"codename" example:"content-moderator"`
+	Name        string                     `json:"name" example:"Content Moderator"`
+	IsStaff     bool                       `json:"is_staff"`
+	Permissions []choices.PermissionChoice `json:"permissions" example:"books.view,books.manage"`
+}
+
+func (r RoleSchema) Init(role models.Role) RoleSchema {
+	r.Codename = role.Codename
+	r.Name = role.Name
+	r.IsStaff = role.IsStaff
+	r.Permissions = []choices.PermissionChoice{}
+	for _, permission := range role.Permissions {
+		r.Permissions = append(r.Permissions, permission.Codename)
+	}
+	return r
+}
+
+type RolesResponseSchema struct {
+	ResponseSchema
+	Data []RoleSchema `json:"data"`
+}
+
+type LockoutSchema struct {
+	ID          uuid.UUID                       `json:"id"`
+	Scope       choices.AuthThrottleScopeChoice `json:"scope" example:"LOGIN"`
+	Key         string                          `json:"key" example:"johndoe@email.com"` // an email address, or an IP address
+	IsIP        bool                            `json:"is_ip"`
+	Failures    int                             `json:"failures"`
+	RetryAt     *time.Time                      `json:"retry_at"`
+	LockedUntil *time.Time                      `json:"locked_until"`
+}
+
+func (l LockoutSchema) Init(throttle models.AuthThrottle) LockoutSchema {
+	l.ID = throttle.ID
+	l.Scope = throttle.Scope
+	l.Key = throttle.Key
+	l.IsIP = throttle.IsIP
+	l.Failures = throttle.Failures
+	l.RetryAt = throttle.RetryAt
+	if throttle.IsLocked() {
+		l.LockedUntil = throttle.LockedUntil
+	}
+	return l
+}
+
+type LockoutsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []LockoutSchema `json:"lockouts"`
+}
+
+func (l LockoutsResponseDataSchema) Init(throttles []models.AuthThrottle) LockoutsResponseDataSchema {
+	// Set Initial Data
+	items := make([]LockoutSchema, 0)
+	for _, throttle := range throttles {
+		items = append(items, LockoutSchema{}.Init(throttle))
+	}
+	l.Items = items
+	return l
+}
+
+type LockoutsResponseSchema struct {
+	ResponseSchema
+	Data LockoutsResponseDataSchema `json:"data"`
+}
+
+type AuthorApplicationReviewSchema struct {
+	Status choices.AuthorApplicationStatusChoice `json:"status" validate:"required,author_application_decision_validator" example:"APPROVED"`
+	Reason *string                               `json:"reason" validate:"omitempty,max=1000" example:"Your sample shows a clear voice"`
+}
+
+type AuthorApplicationsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []AuthorApplicationSchema `json:"applications"`
+}
+
+func (a AuthorApplicationsResponseDataSchema) Init(applications []models.AuthorApplication) AuthorApplicationsResponseDataSchema {
+	// Set Initial Data
+	items := make([]AuthorApplicationSchema, 0)
+	for _, application := range applications {
+		items = append(items, AuthorApplicationSchema{}.Init(application))
+	}
+	a.Items = items
+	return a
+}
+
+type AuthorApplicationsResponseSchema struct {
+	ResponseSchema
+	Data AuthorApplicationsResponseDataSchema `json:"data"`
 }
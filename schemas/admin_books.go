@@ -239,6 +239,18 @@ type RetentionStatsSchema struct {
 	InProgress float64 `json:"in_progress" example:"45"`
 	Dropped    float64 `json:"dropped" example:"20"`
 	Total float64 `json:"-"`
+
+	Chapters []ChapterDropOffSchema `json:"chapters"`
+}
+
+// How many readers made it to a chapter, from the furthest chapter each reader's progress reached
+type ChapterDropOffSchema struct {
+	Title     string  `json:"title" example:"Chapter 3"`
+	Slug      string  `json:"slug" example:"chapter-3"`
+	Position  int     `json:"position" example:"3"`
+	Readers   int     `json:"readers" example:"640"`   // readers who reached it
+	Retention float64 `json:"retention" example:"64"`  // of those who started the first chapter, as a percentage
+	DropOff   float64 `json:"drop_off" example:"12.5"` // of those who reached it, the percentage who went no further
 }
 
 type BookRetentionStatsResponseSchema struct {
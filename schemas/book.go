@@ -89,12 +89,17 @@ type ChapterListSchema struct {
 	Title  string `json:"title"`
 	Slug   string `json:"slug"`
 	IsLast bool   `json:"is_last"`
+
+	Status    choices.ChapterStatusChoice `json:"status" example:"PUBLISHED"`
+	PublishAt *time.Time                  `json:"publish_at" example:"2024-06-05T02:32:34.462196+01:00"`
 }
 
 func (c ChapterListSchema) Init(chapter models.Chapter) ChapterListSchema {
 	c.Title = chapter.Title
 	c.Slug = chapter.Slug
 	c.IsLast = chapter.IsLast
+	c.Status = chapter.Status
+	c.PublishAt = chapter.PublishAt
 	return c
 }
 
@@ -135,6 +140,7 @@ type BookSchema struct {
 	CoverImage         string                `json:"cover_image"`
 	FullPrice          *int                  `json:"full_price"`
 	ChapterPrice       int                   `json:"chapter_price"`
+	FullPurchaseMode   bool                  `json:"full_purchase_mode"`
 	Completed          bool                  `json:"completed"`
 	Votes              int                   `json:"votes"`
 	Reads              int                   `json:"reads"`
@@ -148,6 +154,7 @@ func (b BookSchema) Init(book models.Book) BookSchema {
 	b.Blurb = book.Blurb
 	b.FullPrice = book.FullPrice
 	b.ChapterPrice = book.ChapterPrice
+	b.FullPurchaseMode = book.FullPurchaseMode
 	b.AgeDiscretion = book.AgeDiscretion
 
 	tags := book.Tags
@@ -346,6 +353,10 @@ type ChapterCreateSchema struct {
 	Title      string   `json:"title" validate:"required,max=100"`
 	Paragraphs []string `json:"paragraphs" validate:"required"`
 	IsLast     bool     `json:"is_last"`
+
+	// Defaults to PUBLISHED for new chapters and to the current status for existing ones
+	Status    choices.ChapterStatusChoice `json:"status" validate:"omitempty,chapter_status_validator" example:"SCHEDULED"`
+	PublishAt *time.Time                  `json:"publish_at" example:"2024-06-05T02:32:34.462196+01:00"` // required to schedule a chapter
 }
 
 type TagsResponseSchema struct {
@@ -567,3 +578,222 @@ type BookReportSchema struct {
 	Reason                string  `json:"reason" validate:"required,max=1000"`
 	AdditionalExplanation *string `json:"additional_explanation" validate:"omitempty,max=1000"`
 }
+
+// A book matching a search and how well it matched
+type BookSearchHit struct {
+	BookID      uuid.UUID
+	Rank        float64
+	ParagraphID *uuid.UUID // the chapter paragraph that matched best when chapters are searched
+}
+
+type BookSearchHighlightSchema struct {
+	Title       string  `json:"title" example:"The <mark>Dragon</mark> Prince"`
+	Blurb       string  `json:"blurb" example:"A <mark>dragon</mark> wakes beneath the city"`
+	ChapterSlug *string `json:"chapter_slug"`
+	Content     *string `json:"content" example:"the <mark>dragon</mark> opened one eye"`
+}
+
+type BookSearchResultSchema struct {
+	BookSchema
+	Rank       float64                   `json:"rank"`
+	Highlights BookSearchHighlightSchema `json:"highlights"`
+}
+
+type SearchFacetSchema struct {
+	Value string `json:"value" example:"fantasy"`
+	Name  string `json:"name" example:"Fantasy"`
+	Count int    `json:"count" example:"12"`
+}
+
+type BookSearchFacetsSchema struct {
+	Genres         []SearchFacetSchema `json:"genres"`
+	Tags           []SearchFacetSchema `json:"tags"`
+	AgeDiscretions []SearchFacetSchema `json:"age_discretions"`
+	Completed      []SearchFacetSchema `json:"completed"`
+}
+
+type BookSearchResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Facets BookSearchFacetsSchema   `json:"facets"`
+	Items  []BookSearchResultSchema `json:"books"`
+}
+
+func (b BookSearchResponseDataSchema) Init(books []models.Book, hits []BookSearchHit, highlights map[uuid.UUID]BookSearchHighlightSchema) BookSearchResponseDataSchema {
+	booksByID := make(map[uuid.UUID]models.Book)
+	for _, book := range books {
+		booksByID[book.ID] = book
+	}
+	// Keep the order of the hits
+	items := make([]BookSearchResultSchema, 0)
+	for _, hit := range hits {
+		book, ok := booksByID[hit.BookID]
+		if !ok {
+			continue
+		}
+		items = append(items, BookSearchResultSchema{
+			BookSchema: BookSchema{}.Init(book), Rank: hit.Rank, Highlights: highlights[hit.BookID],
+		})
+	}
+	b.Items = items
+	return b
+}
+
+type BookSearchResponseSchema struct {
+	ResponseSchema
+	Data BookSearchResponseDataSchema `json:"data"`
+}
+
+type BecauseOfBookSchema struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+type BookRecommendationSchema struct {
+	BookSchema
+	Reason        choices.RecommendationReasonChoice `json:"reason" example:"BECAUSE_YOU_READ"`
+	BecauseOfBook *BecauseOfBookSchema               `json:"because_of_book"`
+}
+
+func (b BookRecommendationSchema) Init(recommendation models.BookRecommendation) BookRecommendationSchema {
+	b.BookSchema = b.BookSchema.Init(recommendation.Book)
+	b.Reason = recommendation.Reason
+	if recommendation.BecauseOfBook != nil {
+		b.BecauseOfBook = &BecauseOfBookSchema{Title: recommendation.BecauseOfBook.Title, Slug: recommendation.BecauseOfBook.Slug}
+	}
+	return b
+}
+
+type BookRecommendationsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []BookRecommendationSchema `json:"books"`
+}
+
+func (b BookRecommendationsResponseDataSchema) Init(recommendations []models.BookRecommendation) BookRecommendationsResponseDataSchema {
+	// Set Initial Data
+	items := make([]BookRecommendationSchema, 0)
+	for _, recommendation := range recommendations {
+		items = append(items, BookRecommendationSchema{}.Init(recommendation))
+	}
+	b.Items = items
+	return b
+}
+
+type BookRecommendationsResponseSchema struct {
+	ResponseSchema
+	Data BookRecommendationsResponseDataSchema `json:"data"`
+}
+
+type ReadingProgressUpdateSchema struct {
+	ParagraphIndex uint `json:"paragraph_index" example:"12"`
+	// When the device was at this place. Defaults to now, but devices syncing after being offline should send it.
+	ReadAt *time.Time `json:"read_at" example:"2024-06-05T02:32:34.462196+01:00"`
+}
+
+type ReadingProgressSchema struct {
+	Chapter        ChapterListSchema `json:"chapter"`
+	ParagraphIndex uint              `json:"paragraph_index" example:"12"`
+	Percent        float64           `json:"percent" example:"42.5"`
+	ReadAt         time.Time         `json:"read_at" example:"2024-06-05T02:32:34.462196+01:00"`
+}
+
+func (r ReadingProgressSchema) Init(progress models.ReadingProgress) ReadingProgressSchema {
+	r.Chapter = r.Chapter.Init(progress.Chapter)
+	r.ParagraphIndex = progress.ParagraphIndex
+	r.Percent = progress.Percent
+	r.ReadAt = progress.ReadAt
+	return r
+}
+
+type ReadingProgressResponseSchema struct {
+	ResponseSchema
+	Data ReadingProgressSchema `json:"data"`
+}
+
+type ContinueReadingSchema struct {
+	Book     BookSchema            `json:"book"`
+	Progress ReadingProgressSchema `json:"progress"`
+}
+
+type ContinueReadingResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []ContinueReadingSchema `json:"books"`
+}
+
+func (c ContinueReadingResponseDataSchema) Init(progresses []models.ReadingProgress) ContinueReadingResponseDataSchema {
+	// Set Initial Data
+	items := make([]ContinueReadingSchema, 0)
+	for _, progress := range progresses {
+		items = append(items, ContinueReadingSchema{
+			Book: BookSchema{}.Init(progress.Book), Progress: ReadingProgressSchema{}.Init(progress),
+		})
+	}
+	c.Items = items
+	return c
+}
+
+type ContinueReadingResponseSchema struct {
+	ResponseSchema
+	Data ContinueReadingResponseDataSchema `json:"data"`
+}
+
+type ChapterRevisionSchema struct {
+	Number          uint            `json:"number" example:"3"`
+	Title           string          `json:"title"`
+	ParagraphsCount int             `json:"paragraphs_count" example:"40"`
+	IsLast          bool            `json:"is_last"`
+	Editor          *UserDataSchema `json:"editor"`
+	CreatedAt       time.Time       `json:"created_at" example:"2024-06-05T02:32:34.462196+01:00"`
+}
+
+func (c ChapterRevisionSchema) Init(revision models.ChapterRevision) ChapterRevisionSchema {
+	c.Number = revision.Number
+	c.Title = revision.Title
+	c.ParagraphsCount = len(revision.Paragraphs)
+	c.IsLast = revision.IsLast
+	if revision.Editor != nil {
+		editor := UserDataSchema{}.Init(*revision.Editor)
+		c.Editor = &editor
+	}
+	c.CreatedAt = revision.CreatedAt
+	return c
+}
+
+type ChapterRevisionsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []ChapterRevisionSchema `json:"revisions"`
+}
+
+func (c ChapterRevisionsResponseDataSchema) Init(revisions []models.ChapterRevision) ChapterRevisionsResponseDataSchema {
+	// Set Initial Data
+	items := make([]ChapterRevisionSchema, 0)
+	for _, revision := range revisions {
+		items = append(items, ChapterRevisionSchema{}.Init(revision))
+	}
+	c.Items = items
+	return c
+}
+
+type ChapterRevisionsResponseSchema struct {
+	ResponseSchema
+	Data ChapterRevisionsResponseDataSchema `json:"data"`
+}
+
+// A paragraph of one revision and what became of it in another. Indexes and texts are null on the side it isn't in.
+type ParagraphDiffSchema struct {
+	Change   choices.ParagraphChangeChoice `json:"change" example:"EDITED"`
+	OldIndex *uint                         `json:"old_index" example:"4"`
+	NewIndex *uint                         `json:"new_index" example:"5"`
+	OldText  *string                       `json:"old_text"`
+	NewText  *string                       `json:"new_text"`
+}
+
+type ChapterRevisionDiffSchema struct {
+	From       ChapterRevisionSchema `json:"from"`
+	To         ChapterRevisionSchema `json:"to"`
+	Paragraphs []ParagraphDiffSchema `json:"paragraphs"`
+}
+
+type ChapterRevisionDiffResponseSchema struct {
+	ResponseSchema
+	Data ChapterRevisionDiffSchema `json:"data"`
+}
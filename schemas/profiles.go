@@ -12,6 +12,7 @@ type FollowerData struct {
 	Name           *string         `json:"name"`
 	Username       string          `json:"username"`
 	AccountType    choices.AccType `json:"account_type"`
+	IsVerified     bool            `json:"is_verified"`
 	Avatar         *string         `json:"avatar"`
 	FollowersCount int             `json:"followers_count"`
 	StoriesCount   int             `json:"stories_count"`
@@ -22,6 +23,7 @@ func (dto FollowerData) FromModel(user models.User) FollowerData {
 	dto.Username = user.Username
 	dto.Avatar = &user.Avatar
 	dto.AccountType = user.AccountType
+	dto.IsVerified = user.IsVerifiedAuthor()
 	dto.FollowersCount = user.FollowersCount()
 	dto.StoriesCount = user.BooksCount()
 	return dto
@@ -34,6 +36,7 @@ type UserProfile struct {
 	Avatar       *string                         `json:"avatar"`
 	Bio          *string                         `json:"bio"`
 	AccountType  choices.AccType                 `json:"account_type"`
+	IsVerified   bool                            `json:"is_verified"` // an author whose application was approved
 	StoriesCount int                             `json:"stories_count"`
 	Followers    []FollowerData                  `json:"followers"`
 	Followings   []FollowerData                  `json:"followings"`
@@ -65,6 +68,7 @@ func (u UserProfile) Init(user models.User, currentUser *models.User) UserProfil
 		Avatar:       &user.Avatar,
 		Bio:          user.Bio,
 		AccountType:  user.AccountType,
+		IsVerified:   user.IsVerifiedAuthor(),
 		Followers:    followers,
 		Followings:   followings,
 		StoriesCount: user.BooksCount(),
@@ -96,6 +100,28 @@ type UpdatePasswordSchema struct {
 	OldPassword string `json:"old_password" validate:"required,min=8,max=50" example:"newstrongpassword"`
 }
 
+type DeleteAccountSchema struct {
+	Password *string `json:"password" validate:"omitempty,max=50" example:"password"` // not needed by users who signed up with google, facebook or apple
+}
+
+type ChangeEmailSchema struct {
+	Email    string  `json:"email" validate:"required,email,max=255" example:"johndoe@email.com"`
+	Password *string `json:"password" validate:"omitempty,max=50" example:"password"` // not needed by users who signed up with google, facebook or apple
+}
+
+type ConfirmEmailChangeSchema struct {
+	Otp uint `json:"otp" validate:"required" example:"123456"`
+}
+
+type AccountDeletionSchema struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at" example:"2024-06-19T02:32:34.462196+01:00"`
+}
+
+type AccountDeletionResponseSchema struct {
+	ResponseSchema
+	Data AccountDeletionSchema `json:"data"`
+}
+
 // NOTIFICATIONS
 type NotificationBookSchema struct {
 	Title      string `json:"title"`
@@ -162,3 +188,87 @@ type ReadNotificationSchema struct {
 	MarkAllAsRead bool       `json:"mark_all_as_read" example:"false"`
 	ID            *uuid.UUID `json:"id" validate:"required_if=MarkAllAsRead false,omitempty" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
 }
+
+type AuthorApplicationCreateSchema struct {
+	PenName    string   `json:"pen_name" validate:"required,max=100" example:"J. Doe"`
+	Bio        string   `json:"bio" validate:"required,max=1000" example:"I write fantasy set in West Africa"`
+	SampleWork string   `json:"sample_work" validate:"required,wordcount_min=100,wordcount_max=10000" example:"The first chapter of my story..."`
+	Links      []string `json:"links" validate:"omitempty,dive,url,max=500" example:"https://example.com/my-blog"`
+}
+
+type AuthorApplicationSchema struct {
+	ID         uuid.UUID                             `json:"id"`
+	User       UserDataSchema                        `json:"user"`
+	PenName    string                                `json:"pen_name"`
+	Bio        string                                `json:"bio"`
+	SampleWork string                                `json:"sample_work"`
+	Links      []string                              `json:"links"`
+	Status     choices.AuthorApplicationStatusChoice `json:"status" example:"PENDING"`
+	Reason     *string                               `json:"reason"`
+	ReviewedAt *time.Time                            `json:"reviewed_at"`
+	CreatedAt  time.Time                             `json:"created_at"`
+}
+
+func (a AuthorApplicationSchema) Init(application models.AuthorApplication) AuthorApplicationSchema {
+	a.ID = application.ID
+	a.User = a.User.Init(application.User)
+	a.PenName = application.PenName
+	a.Bio = application.Bio
+	a.SampleWork = application.SampleWork
+	a.Links = application.Links
+	if a.Links == nil {
+		a.Links = []string{}
+	}
+	a.Status = application.Status
+	a.Reason = application.Reason
+	a.ReviewedAt = application.ReviewedAt
+	a.CreatedAt = application.CreatedAt
+	return a
+}
+
+type AuthorApplicationResponseSchema struct {
+	ResponseSchema
+	Data AuthorApplicationSchema `json:"data"`
+}
+
+type BlockUserSchema struct {
+	Kind choices.BlockKindChoice `json:"kind" validate:"required,block_kind_validator" example:"BLOCK"`
+}
+
+type BlockSchema struct {
+	User      UserDataSchema          `json:"user"`
+	Kind      choices.BlockKindChoice `json:"kind" example:"BLOCK"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+func (b BlockSchema) Init(block models.UserBlock) BlockSchema {
+	b.User = b.User.Init(block.BlockedUser)
+	b.Kind = block.Kind
+	b.CreatedAt = block.CreatedAt
+	return b
+}
+
+type BlockResponseSchema struct {
+	ResponseSchema
+	Data BlockSchema `json:"data"`
+}
+
+type BlocksResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []BlockSchema `json:"blocks"`
+}
+
+func (b BlocksResponseDataSchema) Init(blocks []models.UserBlock) BlocksResponseDataSchema {
+	// Set Initial Data
+	blockItems := make([]BlockSchema, 0)
+	for _, block := range blocks {
+		blockItems = append(blockItems, BlockSchema{}.Init(block))
+	}
+	b.Items = blockItems
+	return b
+}
+
+type BlocksResponseSchema struct {
+	ResponseSchema
+	Data BlocksResponseDataSchema `json:"data"`
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/utils"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -23,9 +24,18 @@ func (c CoinSchema) Init(coin models.Coin) CoinSchema {
 }
 
 type BuyCoinSchema struct {
-	// PaymentType choices.PaymentType `json:"payment_type" validate:"required,payment_type_validator" example:"STRIPE"` // This should be stripe by default
-	Quantity int       `json:"quantity" validate:"required" example:"2"`
-	CoinID   uuid.UUID `json:"coin_id" validate:"required" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	PaymentType  choices.PaymentType `json:"payment_type" validate:"omitempty,payment_type_validator" example:"STRIPE"` // STRIPE by default
+	PaymentToken *string             `json:"payment_token" example:"tok_visa"`                                          // a tokenized card for stripe or a purchase token for google play
+	Quantity     int                 `json:"quantity" validate:"required" example:"2"`
+	CoinID       uuid.UUID           `json:"coin_id" validate:"required" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	PromoCode    *string             `json:"promo_code" validate:"omitempty,max=50" example:"LAUNCH20"`
+}
+
+func (b BuyCoinSchema) GetPaymentType() choices.PaymentType {
+	if b.PaymentType == "" {
+		return choices.PTYPE_STRIPE
+	}
+	return b.PaymentType
 }
 
 type TransactionSchema struct {
@@ -38,6 +48,7 @@ type TransactionSchema struct {
 	Amount         decimal.Decimal        `json:"amount" example:"10.35"`
 	AmountTotal    decimal.Decimal        `json:"amount_total" example:"30.35"`
 	PaymentStatus  choices.PaymentStatus  `json:"payment_status"`
+	BonusCoins     int                    `json:"bonus_coins" example:"20"` // added by a promo code
 	ClientSecret   string                 `json:"client_secret"`
 	CreatedAt      time.Time              `json:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at"`
@@ -48,19 +59,25 @@ func (t TransactionSchema) Init(transaction models.Transaction) TransactionSchem
 	if transaction.Coin != nil {
 		amount := transaction.Coin.Amount
 		t.Coins = &amount
-		coinsTotal := transaction.Quantity * amount
-		t.CoinsTotal = &coinsTotal
+		t.CoinsTotal = transaction.CoinsTotal()
 	}
 	t.PaymentType = transaction.PaymentType
 	t.PaymentStatus = transaction.PaymentStatus
 	t.PaymentPurpose = transaction.PaymentPurpose
-	if t.PaymentPurpose == choices.PP_COINS {
-		t.Amount = transaction.Coin.Price
+	quantity := decimal.NewFromInt(int64(max(transaction.Quantity, 1)))
+	if transaction.Amount.IsPositive() {
+		t.AmountTotal = transaction.Amount
+		t.Amount = transaction.Amount.Div(quantity)
 	} else {
-		t.Amount = transaction.SubscriptionPlan.Amount
+		if t.PaymentPurpose == choices.PP_COINS {
+			t.Amount = transaction.Coin.Price
+		} else {
+			t.Amount = transaction.SubscriptionPlan.Amount
+		}
+		t.AmountTotal = t.Amount.Mul(quantity)
 	}
-	t.AmountTotal = t.Amount.Mul(decimal.NewFromInt(int64(transaction.Quantity)))
 	t.Quantity = transaction.Quantity
+	t.BonusCoins = transaction.BonusCoins
 	t.ClientSecret = transaction.ClientSecret
 	t.CreatedAt = transaction.CreatedAt
 	t.UpdatedAt = transaction.UpdatedAt
@@ -107,10 +124,6 @@ type TransactionsResponseSchema struct {
 	Data TransactionsResponseDataSchema `json:"data"`
 }
 
-type CreateICPWallet struct{
-	Username       string `json:"username" validate:"required,max=1000" example:"john-doe"`
-}
-
 type SubscriptionPlanSchema struct {
 	Amount  decimal.Decimal                `json:"amount" validate:"required"`
 	SubType choices.SubscriptionTypeChoice `json:"subtype" validate:"required,subscription_type_validator"`
@@ -144,6 +157,447 @@ type SubscriptionPlanResponseSchema struct {
 
 type CreateSubscriptionSchema struct {
 	SubType            choices.SubscriptionTypeChoice `json:"subtype" validate:"required,subscription_type_validator"`
-	PaymentMethodToken string                         `json:"payment_method_token" validate:"required"`
+	PaymentType        choices.PaymentType            `json:"payment_type" validate:"omitempty,payment_type_validator" example:"STRIPE"` // STRIPE by default
+	PaymentMethodToken string                         `json:"payment_method_token" validate:"required_unless=PaymentType PAYPAL"`        // a tokenized card for stripe or a purchase token for google play
+	PromoCode          *string                        `json:"promo_code" validate:"omitempty,max=50" example:"LAUNCH20"`
+}
+
+func (c CreateSubscriptionSchema) GetPaymentType() choices.PaymentType {
+	if c.PaymentType == "" {
+		return choices.PTYPE_STRIPE
+	}
+	return c.PaymentType
+}
+
+type ChangeSubscriptionSchema struct {
+	SubType choices.SubscriptionTypeChoice `json:"subtype" validate:"required,subscription_type_validator" example:"ANNUAL"`
+}
+
+type SubscriptionSchema struct {
+	Plan               SubscriptionPlanSchema           `json:"plan"`
+	Status             choices.SubscriptionStatusChoice `json:"status" example:"ACTIVE"`
+	CurrentPeriodStart time.Time                        `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time                        `json:"current_period_end"`
+	ExpiresAt          time.Time                        `json:"expires_at"`
+	Renews             bool                             `json:"renews" example:"true"`
+	CanceledAt         *time.Time                       `json:"canceled_at"`
+}
+
+func (s SubscriptionSchema) Init(subscription models.Subscription) SubscriptionSchema {
+	s.Plan = s.Plan.Init(subscription.Plan)
+	s.Status = subscription.Status
+	s.CurrentPeriodStart = subscription.CurrentPeriodStart
+	s.CurrentPeriodEnd = subscription.CurrentPeriodEnd
+	s.ExpiresAt = subscription.ExpiresAt
+	s.Renews = subscription.Renews()
+	s.CanceledAt = subscription.CanceledAt
+	return s
+}
+
+type SubscriptionResponseSchema struct {
+	ResponseSchema
+	Data SubscriptionSchema `json:"data"`
+}
+
+type SubscriptionChangeSchema struct {
+	SubscriptionSchema
+	ProrationAmount decimal.Decimal `json:"proration_amount" example:"85.5"`
+}
+
+type SubscriptionChangeResponseSchema struct {
+	ResponseSchema
+	Data SubscriptionChangeSchema `json:"data"`
+}
+
+
+type AuthorEarningSchema struct {
+	ID          uuid.UUID                   `json:"id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	Author      UserDataSchema              `json:"author"`
+	Source      choices.EarningSourceChoice `json:"source" example:"CHAPTER_PURCHASE"`
+	Amount      decimal.Decimal             `json:"amount" example:"1.25"`
+	BookSlug    *string                     `json:"book_slug" example:"the-mysterious-island"`
+	Period      *string                     `json:"period" example:"2024-06"`
+	Description string                      `json:"description" example:"john spent 10 coins on The Mysterious Island"`
+	CreatedAt   time.Time                   `json:"created_at"`
+}
+
+func (a AuthorEarningSchema) Init(earning models.AuthorEarning) AuthorEarningSchema {
+	a.ID = earning.ID
+	a.Author = a.Author.Init(earning.Author)
+	a.Source = earning.Source
+	a.Amount = earning.Amount
+	if earning.Book != nil {
+		a.BookSlug = &earning.Book.Slug
+	}
+	a.Period = earning.Period
+	a.Description = earning.Description
+	a.CreatedAt = earning.CreatedAt
+	return a
+}
+
+type AuthorEarningsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Balance *decimal.Decimal      `json:"balance,omitempty" example:"20.5"`
+	Items   []AuthorEarningSchema `json:"earnings"`
+}
+
+func (a AuthorEarningsResponseDataSchema) Init(earnings []models.AuthorEarning) AuthorEarningsResponseDataSchema {
+	// Set Initial Data
+	earningItems := []AuthorEarningSchema{}
+	for _, earning := range earnings {
+		earningItems = append(earningItems, AuthorEarningSchema{}.Init(earning))
+	}
+	a.Items = earningItems
+	return a
 }
 
+type AuthorEarningsResponseSchema struct {
+	ResponseSchema
+	Data AuthorEarningsResponseDataSchema `json:"data"`
+}
+
+type PayoutRequestSchema struct {
+	Amount decimal.Decimal `json:"amount" validate:"required" example:"20.5"`
+}
+
+type PayoutStatusUpdateSchema struct {
+	Status    choices.PayoutStatusChoice `json:"status" validate:"required,payout_status_validator" example:"APPROVED"`
+	Note      *string                    `json:"note" validate:"omitempty,max=1000" example:"Invalid bank details"`
+	Reference *string                    `json:"reference" validate:"required_if=Status PAID,omitempty,max=1000" example:"TRF-2024-0001"`
+}
+
+type PayoutSchema struct {
+	ID         uuid.UUID                  `json:"id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	Author     UserDataSchema             `json:"author"`
+	Amount     decimal.Decimal            `json:"amount" example:"20.5"`
+	Status     choices.PayoutStatusChoice `json:"status" example:"REQUESTED"`
+	Reference  *string                    `json:"reference" example:"TRF-2024-0001"`
+	Note       *string                    `json:"note"`
+	ApprovedAt *time.Time                 `json:"approved_at"`
+	PaidAt     *time.Time                 `json:"paid_at"`
+	CreatedAt  time.Time                  `json:"created_at"`
+}
+
+func (p PayoutSchema) Init(payout models.Payout) PayoutSchema {
+	p.ID = payout.ID
+	p.Author = p.Author.Init(payout.Author)
+	p.Amount = payout.Amount
+	p.Status = payout.Status
+	p.Reference = payout.Reference
+	p.Note = payout.Note
+	p.ApprovedAt = payout.ApprovedAt
+	p.PaidAt = payout.PaidAt
+	p.CreatedAt = payout.CreatedAt
+	return p
+}
+
+type PayoutResponseSchema struct {
+	ResponseSchema
+	Data PayoutSchema `json:"data"`
+}
+
+type PayoutsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []PayoutSchema `json:"payouts"`
+}
+
+func (p PayoutsResponseDataSchema) Init(payouts []models.Payout) PayoutsResponseDataSchema {
+	// Set Initial Data
+	payoutItems := []PayoutSchema{}
+	for _, payout := range payouts {
+		payoutItems = append(payoutItems, PayoutSchema{}.Init(payout))
+	}
+	p.Items = payoutItems
+	return p
+}
+
+type PayoutsResponseSchema struct {
+	ResponseSchema
+	Data PayoutsResponseDataSchema `json:"data"`
+}
+
+type WalletEntrySchema struct {
+	ID            uuid.UUID                  `json:"id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	GroupID       uuid.UUID                  `json:"group_id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	Currency      choices.CurrencyChoice     `json:"currency" example:"COIN"`
+	Amount        int                        `json:"amount" example:"-10"`
+	BalanceAfter  int                        `json:"balance_after" example:"25"`
+	Reason        choices.WalletReasonChoice `json:"reason" example:"CHAPTER_PURCHASE"`
+	TransactionID *uuid.UUID                 `json:"transaction_id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	SentGiftID    *uuid.UUID                 `json:"sent_gift_id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	VoteID        *uuid.UUID                 `json:"vote_id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	BookSlug      *string                    `json:"book_slug" example:"the-mysterious-island"`
+	CreatedAt     time.Time                  `json:"created_at"`
+}
+
+func (w WalletEntrySchema) Init(entry models.WalletEntry) WalletEntrySchema {
+	w.ID = entry.ID
+	w.GroupID = entry.GroupID
+	w.Currency = entry.Currency
+	w.Amount = entry.Amount
+	if entry.BalanceAfter != nil {
+		w.BalanceAfter = *entry.BalanceAfter
+	}
+	w.Reason = entry.Reason
+	w.TransactionID = entry.TransactionID
+	w.SentGiftID = entry.SentGiftID
+	w.VoteID = entry.VoteID
+	if entry.Book != nil {
+		w.BookSlug = &entry.Book.Slug
+	}
+	w.CreatedAt = entry.CreatedAt
+	return w
+}
+
+type WalletLedgerResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Coins    int                 `json:"coins" example:"25"`
+	Lanterns int                 `json:"lanterns" example:"4"`
+	Items    []WalletEntrySchema `json:"entries"`
+}
+
+func (w WalletLedgerResponseDataSchema) Init(entries []models.WalletEntry) WalletLedgerResponseDataSchema {
+	// Set Initial Data
+	entryItems := []WalletEntrySchema{}
+	for _, entry := range entries {
+		entryItems = append(entryItems, WalletEntrySchema{}.Init(entry))
+	}
+	w.Items = entryItems
+	return w
+}
+
+type WalletLedgerResponseSchema struct {
+	ResponseSchema
+	Data WalletLedgerResponseDataSchema `json:"data"`
+}
+
+type PaymentEventSchema struct {
+	ID                   uuid.UUID                        `json:"id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	EventID              string                           `json:"event_id" example:"evt_1PJ6X2Kj3oFz0mQh"`
+	Type                 string                           `json:"type" example:"payment_intent.succeeded"`
+	Status               choices.PaymentEventStatusChoice `json:"status" example:"FAILED"`
+	Error                *string                          `json:"error" example:"no transaction with reference pi_3PJ6X2Kj3oFz0mQh"`
+	Attempts             int                              `json:"attempts" example:"2"`
+	TransactionReference *string                          `json:"transaction_reference" example:"pi_3PJ6X2Kj3oFz0mQh"`
+	ProcessedAt          *time.Time                       `json:"processed_at"`
+	CreatedAt            time.Time                        `json:"created_at"`
+}
+
+func (p PaymentEventSchema) Init(event models.PaymentEvent) PaymentEventSchema {
+	p.ID = event.ID
+	p.EventID = event.EventID
+	p.Type = event.Type
+	p.Status = event.Status
+	p.Error = event.Error
+	p.Attempts = event.Attempts
+	if event.Transaction != nil {
+		p.TransactionReference = &event.Transaction.Reference
+	}
+	p.ProcessedAt = event.ProcessedAt
+	p.CreatedAt = event.CreatedAt
+	return p
+}
+
+type PaymentEventResponseSchema struct {
+	ResponseSchema
+	Data PaymentEventSchema `json:"data"`
+}
+
+type PaymentEventsResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []PaymentEventSchema `json:"events"`
+}
+
+func (p PaymentEventsResponseDataSchema) Init(events []models.PaymentEvent) PaymentEventsResponseDataSchema {
+	// Set Initial Data
+	eventItems := []PaymentEventSchema{}
+	for _, event := range events {
+		eventItems = append(eventItems, PaymentEventSchema{}.Init(event))
+	}
+	p.Items = eventItems
+	return p
+}
+
+type PaymentEventsResponseSchema struct {
+	ResponseSchema
+	Data PaymentEventsResponseDataSchema `json:"data"`
+}
+
+// Revenue of a period for one kind of payment: a subscription plan or a coin pack
+type RevenueReportRow struct {
+	Period         time.Time                       `json:"period" example:"2024-06-01T00:00:00Z"`
+	PaymentPurpose choices.PaymentPurpose          `json:"payment_purpose" example:"COINS"`
+	SubType        *choices.SubscriptionTypeChoice `json:"sub_type" example:"MONTHLY"`
+	CoinPack       *int                            `json:"coin_pack" example:"100"` // the coins in the pack
+	Payments       int64                           `json:"payments" example:"12"`
+	GrossRevenue   decimal.Decimal                 `json:"gross_revenue" example:"120.50"`
+	RefundedAmount decimal.Decimal                 `json:"refunded_amount" example:"10.25"` // refunded or charged back
+	NetRevenue     decimal.Decimal                 `json:"net_revenue" example:"110.25"`
+}
+
+type RevenueReportSchema struct {
+	Start    time.Time                    `json:"start"`
+	End      time.Time                    `json:"end"`
+	Interval choices.ReportIntervalChoice `json:"interval" example:"day"`
+	Items    []RevenueReportRow           `json:"rows"`
+}
+
+type RevenueReportResponseSchema struct {
+	ResponseSchema
+	Data RevenueReportSchema `json:"data"`
+}
+
+type RevenueSummarySchema struct {
+	Start          time.Time       `json:"start"`
+	End            time.Time       `json:"end"`
+	Payments       int64           `json:"payments" example:"120"` // successful, including the ones reversed later
+	FailedPayments int64           `json:"failed_payments" example:"8"`
+	Refunds        int64           `json:"refunds" example:"3"`
+	Chargebacks    int64           `json:"chargebacks" example:"1"`
+	GrossRevenue   decimal.Decimal `json:"gross_revenue" example:"1200.50"`
+	RefundedAmount decimal.Decimal `json:"refunded_amount" example:"40.25"`
+	NetRevenue     decimal.Decimal `json:"net_revenue" example:"1160.25"`
+	RefundRate     float64         `json:"refund_rate" example:"0.033"`  // refunds and chargebacks per successful payment
+	FailureRate    float64         `json:"failure_rate" example:"0.062"` // failed payments per attempted payment
+	Users          int64           `json:"users" example:"2000"`         // users registered by the end of the range
+	PayingUsers    int64           `json:"paying_users" example:"90"`
+	Arpu           decimal.Decimal `json:"arpu" example:"0.58"`   // net revenue per user
+	Arppu          decimal.Decimal `json:"arppu" example:"12.89"` // net revenue per paying user
+}
+
+type RevenueSummaryResponseSchema struct {
+	ResponseSchema
+	Data RevenueSummarySchema `json:"data"`
+}
+
+type PromoCodeCreateSchema struct {
+	Code                  string                          `json:"code" validate:"required,min=3,max=50,alphanum" example:"LAUNCH20"`
+	DiscountType          choices.PromoDiscountTypeChoice `json:"discount_type" validate:"required,promo_discount_type_validator" example:"PERCENTAGE"`
+	DiscountValue         decimal.Decimal                 `json:"discount_value" example:"20"` // a percentage or an amount in USD
+	BonusCoins            int                             `json:"bonus_coins" validate:"min=0" example:"10"`
+	StartsAt              *time.Time                      `json:"starts_at" example:"2024-06-01T00:00:00Z"`
+	EndsAt                *time.Time                      `json:"ends_at" example:"2024-07-01T00:00:00Z"`
+	MaxRedemptions        *int                            `json:"max_redemptions" validate:"omitempty,min=1" example:"500"` // by all users, unlimited when not set
+	MaxRedemptionsPerUser int                             `json:"max_redemptions_per_user" validate:"required,min=1" example:"1"`
+	SubType               *choices.SubscriptionTypeChoice `json:"subtype" validate:"omitempty,subscription_type_validator" example:"MONTHLY"` // only for this plan
+	CoinID                *uuid.UUID                      `json:"coin_id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`                     // only for this coin pack
+	IsActive              bool                            `json:"is_active" example:"true"`
+}
+
+type PromoCodeSchema struct {
+	ID                    uuid.UUID                       `json:"id" example:"19e8bd22-fab1-4bb4-ba82-77c41bea6b99"`
+	Code                  string                          `json:"code" example:"LAUNCH20"`
+	DiscountType          choices.PromoDiscountTypeChoice `json:"discount_type" example:"PERCENTAGE"`
+	DiscountValue         decimal.Decimal                 `json:"discount_value" example:"20"`
+	BonusCoins            int                             `json:"bonus_coins" example:"10"`
+	StartsAt              *time.Time                      `json:"starts_at"`
+	EndsAt                *time.Time                      `json:"ends_at"`
+	MaxRedemptions        *int                            `json:"max_redemptions" example:"500"`
+	MaxRedemptionsPerUser int                             `json:"max_redemptions_per_user" example:"1"`
+	SubType               *choices.SubscriptionTypeChoice `json:"subtype" example:"MONTHLY"`
+	Coin                  *CoinSchema                     `json:"coin"`
+	IsActive              bool                            `json:"is_active" example:"true"`
+	Redemptions           int64                           `json:"redemptions" example:"42"`
+	CreatedAt             time.Time                       `json:"created_at"`
+}
+
+func (p PromoCodeSchema) Init(promoCode models.PromoCode, redemptions int64) PromoCodeSchema {
+	p.ID = promoCode.ID
+	p.Code = promoCode.Code
+	p.DiscountType = promoCode.DiscountType
+	p.DiscountValue = promoCode.DiscountValue
+	p.BonusCoins = promoCode.BonusCoins
+	p.StartsAt = promoCode.StartsAt
+	p.EndsAt = promoCode.EndsAt
+	p.MaxRedemptions = promoCode.MaxRedemptions
+	p.MaxRedemptionsPerUser = promoCode.MaxRedemptionsPerUser
+	if promoCode.SubscriptionPlan != nil {
+		p.SubType = &promoCode.SubscriptionPlan.SubType
+	}
+	if promoCode.Coin != nil {
+		coin := CoinSchema{}.Init(*promoCode.Coin)
+		p.Coin = &coin
+	}
+	p.IsActive = promoCode.IsActive
+	p.Redemptions = redemptions
+	p.CreatedAt = promoCode.CreatedAt
+	return p
+}
+
+type PromoCodeResponseSchema struct {
+	ResponseSchema
+	Data PromoCodeSchema `json:"data"`
+}
+
+type PromoCodesResponseDataSchema struct {
+	PaginatedResponseDataSchema
+	Items []PromoCodeSchema `json:"promo_codes"`
+}
+
+func (p PromoCodesResponseDataSchema) Init(promoCodes []models.PromoCode, redemptions map[uuid.UUID]int64) PromoCodesResponseDataSchema {
+	// Set Initial Data
+	promoCodeItems := []PromoCodeSchema{}
+	for _, promoCode := range promoCodes {
+		promoCodeItems = append(promoCodeItems, PromoCodeSchema{}.Init(promoCode, redemptions[promoCode.ID]))
+	}
+	p.Items = promoCodeItems
+	return p
+}
+
+type PromoCodesResponseSchema struct {
+	ResponseSchema
+	Data PromoCodesResponseDataSchema `json:"data"`
+}
+
+type ICPWalletSchema struct {
+	AccountID string           `json:"account_id" example:"d4685b31b51450508aff0331584df7692a84467b680326f5c5f7d30ae711682f"`
+	PublicKey string           `json:"public_key"`
+	Balance   *decimal.Decimal `json:"balance,omitempty" example:"1.25"` // in ICP
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func (i ICPWalletSchema) Init(wallet models.ICPWallet, balance *decimal.Decimal) ICPWalletSchema {
+	i.AccountID = wallet.AccountID
+	i.PublicKey = wallet.PublicKey
+	i.Balance = balance
+	i.CreatedAt = wallet.CreatedAt
+	return i
+}
+
+type ICPWalletResponseSchema struct {
+	ResponseSchema
+	Data ICPWalletSchema `json:"data"`
+}
+
+type ICPTransferSchema struct {
+	ID        uuid.UUID                       `json:"id" example:"2b3bd817-135e-41bd-9781-33807c92ff40"`
+	Receiver  UserDataSchema                  `json:"receiver"`
+	Gift      GiftSchema                      `json:"gift"`
+	Amount    decimal.Decimal                 `json:"amount" example:"0.01086957"` // in ICP
+	UsdRate   decimal.Decimal                 `json:"usd_rate" example:"9.2"`
+	Status    choices.ICPTransferStatusChoice `json:"status" example:"CONFIRMED"`
+	Error     *string                         `json:"error"`
+	SentGift  *SentGiftSchema                 `json:"sent_gift"` // set once the transfer is confirmed
+	CreatedAt time.Time                       `json:"created_at"`
+}
+
+func (i ICPTransferSchema) Init(transfer models.ICPTransfer) ICPTransferSchema {
+	i.ID = transfer.ID
+	i.Receiver = i.Receiver.Init(transfer.Receiver)
+	i.Gift = *utils.ConvertStructData(transfer.Gift, GiftSchema{}).(*GiftSchema)
+	i.Amount = transfer.Amount
+	i.UsdRate = transfer.UsdRate
+	i.Status = transfer.Status
+	i.Error = transfer.Error
+	if transfer.SentGift != nil {
+		sentGift := SentGiftSchema{}.Init(*transfer.SentGift)
+		i.SentGift = &sentGift
+	}
+	i.CreatedAt = transfer.CreatedAt
+	return i
+}
+
+type ICPTransferResponseSchema struct {
+	ResponseSchema
+	Data ICPTransferSchema `json:"data"`
+}
@@ -7,11 +7,13 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/models"
@@ -23,14 +25,24 @@ type EmailTypeChoice string
 
 const (
 	ET_ACTIVATE              EmailTypeChoice = "activate"
+	ET_LOGIN_OTP             EmailTypeChoice = "login-otp"
 	ET_WELCOME               EmailTypeChoice = "welcome"
 	ET_RESET                 EmailTypeChoice = "reset"
 	ET_RESET_SUCC            EmailTypeChoice = "reset-success"
 	ET_PAYMENT_SUCC          EmailTypeChoice = "payment-succeeded"
 	ET_PAYMENT_FAIL          EmailTypeChoice = "payment-failed"
 	ET_PAYMENT_CANCEL        EmailTypeChoice = "payment-canceled"
+	ET_PAYMENT_REVERSED      EmailTypeChoice = "payment-reversed"
 	ET_SUBSCRIPTION_EXPIRING EmailTypeChoice = "subscription-expiring"
 	ET_SUBSCRIPTION_EXPIRED  EmailTypeChoice = "subscription-expired"
+	ET_DATA_EXPORT_READY     EmailTypeChoice = "data-export-ready"
+	ET_DELETION_SCHEDULED    EmailTypeChoice = "deletion-scheduled"
+	ET_ACCOUNT_DELETED       EmailTypeChoice = "account-deleted"
+	ET_ACCOUNT_LOCKED        EmailTypeChoice = "account-locked"
+	ET_AUTHOR_APPROVED       EmailTypeChoice = "author-approved"
+	ET_AUTHOR_DECLINED       EmailTypeChoice = "author-declined"
+	ET_CHANGE_EMAIL          EmailTypeChoice = "change-email"
+	ET_EMAIL_CHANGED         EmailTypeChoice = "email-changed"
 )
 
 func sortEmail(cfg config.Config, emailType EmailTypeChoice, otp *uint, tokenString *string, extraData map[string]interface{}) map[string]interface{} {
@@ -50,6 +62,13 @@ func sortEmail(cfg config.Config, emailType EmailTypeChoice, otp *uint, tokenStr
 		data["subject"] = subject
 		data["code"] = strings.Split(strconv.FormatUint(uint64(*otp), 10), "")
 
+	case ET_LOGIN_OTP:
+		templateFile = "templates/email-verification.html"
+		subject = "Your login code"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["code"] = strings.Split(strconv.FormatUint(uint64(*otp), 10), "")
+
 	case ET_RESET:
 		templateFile = "templates/password-reset.html"
 		subject = "Reset your password"
@@ -84,6 +103,17 @@ func sortEmail(cfg config.Config, emailType EmailTypeChoice, otp *uint, tokenStr
 		data["template_file"] = templateFile
 		data["subject"] = subject
 		data["text"] = fmt.Sprintf("Your payment of %s was canceled.", amount)
+	case ET_PAYMENT_REVERSED:
+		templateFile = "templates/payment-reversed.html"
+		subject = "Payment reversed"
+		amount := extraData["amount"].(decimal.Decimal)
+		reversal := extraData["reversal"].(string)
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = fmt.Sprintf("Your payment of %s was %s, so what it paid for has been taken back", amount, reversal)
+		if debt, ok := extraData["debt"].(int); ok && debt > 0 {
+			data["text"] = fmt.Sprintf("%s. %d coins had been spent already and will be deducted from your next purchase", data["text"], debt)
+		}
 	case ET_SUBSCRIPTION_EXPIRING:
 		templateFile = "templates/subscription-expiring.html"
 		subject = "Subscription close to expiry"
@@ -98,6 +128,61 @@ func sortEmail(cfg config.Config, emailType EmailTypeChoice, otp *uint, tokenStr
 		data["template_file"] = templateFile
 		data["subject"] = subject
 		data["text"] = fmt.Sprintf("Your %s book subscription has expired. Please renew your subscription", subscriptionType)
+	case ET_DATA_EXPORT_READY:
+		templateFile = "templates/data-export.html"
+		subject = "Your data is ready to download"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = "the copy of your data you asked for is ready. Use the link below to download it"
+		data["url"] = extraData["url"].(string)
+	case ET_DELETION_SCHEDULED:
+		templateFile = "templates/subscription-expired.html"
+		subject = "Your account will be deleted"
+		deletionAt := extraData["deletionAt"].(time.Time)
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = fmt.Sprintf("Your account will be deleted on %s. If you change your mind, log in and cancel the deletion before then", deletionAt.Format("2 January 2006"))
+	case ET_ACCOUNT_DELETED:
+		templateFile = "templates/subscription-expired.html"
+		subject = "Your account has been deleted"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = "Your account and your personal data have been deleted. Records of your payments are kept as the law requires"
+	case ET_ACCOUNT_LOCKED:
+		templateFile = "templates/account-locked.html"
+		subject = "Your account has been locked"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = "there were too many failed attempts to sign in to your account, so it has been locked for a while. Use the link below to unlock it now"
+		data["url"] = fmt.Sprintf("%s://unlock-account?token=%s", cfg.AppScheme, *tokenString)
+	case ET_AUTHOR_APPROVED:
+		templateFile = "templates/subscription-expired.html"
+		subject = "You're now a LitPad author"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = "Your author application has been approved. You can start publishing your books now"
+		if reason, ok := extraData["reason"].(string); ok {
+			data["text"] = fmt.Sprintf("%s. %s", data["text"], reason)
+		}
+	case ET_AUTHOR_DECLINED:
+		templateFile = "templates/subscription-expired.html"
+		subject = "Your author application"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = fmt.Sprintf("Your author application wasn't approved this time. %s. You can apply again whenever you're ready", extraData["reason"].(string))
+	case ET_CHANGE_EMAIL:
+		templateFile = "templates/email-verification.html"
+		subject = "Confirm your new email"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["code"] = strings.Split(strconv.FormatUint(uint64(*otp), 10), "")
+	case ET_EMAIL_CHANGED:
+		templateFile = "templates/email-changed.html"
+		subject = "Your email was changed"
+		data["template_file"] = templateFile
+		data["subject"] = subject
+		data["text"] = fmt.Sprintf("the email of your account was changed to %s. If you didn't make this change, use the link below to get your account back", extraData["newEmail"].(string))
+		data["url"] = fmt.Sprintf("%s://revert-email?token=%s", cfg.AppScheme, *tokenString)
 	}
 	return data
 }
@@ -181,6 +266,9 @@ type ContactPayload struct {
 }
 
 func AddEmailToBrevo(name string, email string) {
+	if os.Getenv("ENVIRONMENT") == "test" {
+		return
+	}
 	cfg := config.GetConfig()
 
 	// Prepare the payload for Brevo API
@@ -213,6 +301,45 @@ func AddEmailToBrevo(name string, email string) {
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println(err)
+		return
 	}
 	defer resp.Body.Close()
 }
+
+// Moves a Brevo contact to a user's new email so the replaced one stops getting mail.
+// The new email is added as a contact when the old one was never added.
+func UpdateBrevoEmail(name string, oldEmail string, newEmail string) {
+	if os.Getenv("ENVIRONMENT") == "test" {
+		return
+	}
+	cfg := config.GetConfig()
+
+	payload := map[string]interface{}{
+		"listIds":    []int{cfg.BrevoListID},
+		"attributes": map[string]string{"EMAIL": newEmail, "FIRSTNAME": name},
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/%s", strings.TrimSuffix(cfg.BrevoContactsUrl, "/"), url.PathEscape(oldEmail)), bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api-key", cfg.MailApiKey)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		AddEmailToBrevo(name, newEmail)
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func getAuthorApplications(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	t.Run("Reject Applications Fetch Due To Invalid Status", func(t *testing.T) {
+		url := fmt.Sprintf("%s?status=invalid", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid application status", body["message"])
+	})
+
+	t.Run("Accept Applications Fetch", func(t *testing.T) {
+		AuthorApplicationData(db, TestVerifiedUser(db))
+		url := fmt.Sprintf("%s?status=%s", baseUrl, choices.AAS_PENDING)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Applications fetched successfully", body["message"])
+		assert.Len(t, body["data"].(map[string]interface{})["applications"], 1)
+	})
+}
+
+func getAuthorApplication(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	t.Run("Reject Application Fetch Due To Unknown Application", func(t *testing.T) {
+		url := fmt.Sprintf("%s/%s", baseUrl, uuid.New())
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "No application with that ID", body["message"])
+	})
+
+	t.Run("Accept Application Fetch", func(t *testing.T) {
+		application := AuthorApplicationData(db, TestVerifiedUser(db))
+		url := fmt.Sprintf("%s/%s", baseUrl, application.ID)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Application fetched successfully", body["message"])
+	})
+}
+
+func reviewAuthorApplication(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	user := TestVerifiedUser(db)
+	application := AuthorApplicationData(db, user)
+	url := fmt.Sprintf("%s/%s", baseUrl, application.ID)
+
+	t.Run("Reject Application Review Due To Missing Permission", func(t *testing.T) {
+		finance := TestStaff(db, choices.ROLE_FINANCE)
+		data := schemas.AuthorApplicationReviewSchema{Status: choices.AAS_APPROVED}
+		res := ProcessJsonTestBody(t, app, url, "PUT", data, AccessToken(db, finance))
+		// Assert Status code
+		assert.Equal(t, 403, res.StatusCode)
+	})
+
+	t.Run("Reject Application Decline Due To Missing Reason", func(t *testing.T) {
+		data := schemas.AuthorApplicationReviewSchema{Status: choices.AAS_DECLINED}
+		res := ProcessJsonTestBody(t, app, url, "PUT", data, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Give a reason for declining the application", body["data"].(map[string]interface{})["reason"])
+	})
+
+	t.Run("Accept Application Decline", func(t *testing.T) {
+		reason := "Your sample work is too short to judge"
+		data := schemas.AuthorApplicationReviewSchema{Status: choices.AAS_DECLINED, Reason: &reason}
+		res := ProcessJsonTestBody(t, app, url, "PUT", data, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Application declined successfully", body["message"])
+		assert.Equal(t, reason, body["data"].(map[string]interface{})["reason"])
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, choices.ACCTYPE_READER, user.AccountType)
+	})
+
+	t.Run("Reject Application Review Due To Reviewed Application", func(t *testing.T) {
+		data := schemas.AuthorApplicationReviewSchema{Status: choices.AAS_APPROVED}
+		res := ProcessJsonTestBody(t, app, url, "PUT", data, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "This application has been reviewed already", body["message"])
+	})
+
+	t.Run("Reject Application Review Due To Concurrent Review", func(t *testing.T) {
+		// A reviewer who loaded the application before it was declined still sees it as pending
+		staleApplication := application
+		errData := managers.AuthorApplicationManager{}.Review(db, &staleApplication, TestStaff(db, choices.ROLE_FINANCE), choices.AAS_APPROVED, nil)
+		assert.NotNil(t, errData)
+		assert.Equal(t, "This application has been reviewed already", errData.Message)
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, choices.ACCTYPE_READER, user.AccountType)
+	})
+
+	t.Run("Accept Application Approval", func(t *testing.T) {
+		// The reader applies again after being declined
+		application := AuthorApplicationData(db, user)
+		url := fmt.Sprintf("%s/%s", baseUrl, application.ID)
+		data := schemas.AuthorApplicationReviewSchema{Status: choices.AAS_APPROVED}
+		res := ProcessJsonTestBody(t, app, url, "PUT", data, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Application approved successfully", body["message"])
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, choices.ACCTYPE_AUTHOR, user.AccountType)
+		assert.True(t, user.IsVerifiedAuthor())
+
+		// The applicant is notified
+		notification := models.Notification{ReceiverID: user.ID, Ntype: choices.NT_AUTHOR_APPLICATION}
+		db.Take(&notification, notification)
+		assert.NotEqual(t, uuid.Nil, notification.ID)
+	})
+}
+
+func TestAdminAuthors(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	admin := TestAdmin(db)
+	token := AccessToken(db, admin)
+	baseUrl := "/api/v1/admin/authors/applications"
+
+	// Run Admin Authors Endpoint Tests
+	getAuthorApplications(t, app, db, baseUrl, token)
+	getAuthorApplication(t, app, db, baseUrl, token)
+	reviewAuthorApplication(t, app, db, baseUrl, token)
+}
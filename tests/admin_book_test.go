@@ -3,7 +3,9 @@ package tests
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/schemas"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -215,6 +217,41 @@ func adminGetBookDetails(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl stri
 	})
 }
 
+func adminGetBookRetentionStats(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	author := TestAuthor(db)
+	book := BookData(db, author)
+	first := models.Chapter{BookID: book.ID, Title: "Retention First Chapter"}
+	db.Create(&first)
+	last := models.Chapter{BookID: book.ID, Title: "Retention Last Chapter", IsLast: true}
+	db.Create(&last)
+	// One reader stopped in the first chapter and the other read on
+	db.Create(&[]models.ReadingProgress{
+		{UserID: TestVerifiedUser(db).ID, BookID: book.ID, ChapterID: first.ID, FurthestChapterID: first.ID, Percent: 25, ReadAt: time.Now()},
+		{UserID: TestAuthor(db, true).ID, BookID: book.ID, ChapterID: last.ID, FurthestChapterID: last.ID, Percent: 75, ReadAt: time.Now()},
+	})
+
+	t.Run("Accept Book Retention Stats Fetch With Chapter Drop Off", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book-detail/%s/retention-stats", baseUrl, book.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Book Retention data fetched successfully", body["message"])
+		chapters := body["data"].(map[string]interface{})["chapters"].([]interface{})
+		assert.Len(t, chapters, 2)
+		firstStats := chapters[0].(map[string]interface{})
+		assert.Equal(t, first.Slug, firstStats["slug"])
+		assert.Equal(t, float64(2), firstStats["readers"])
+		assert.Equal(t, float64(50), firstStats["drop_off"])
+		lastStats := chapters[1].(map[string]interface{})
+		assert.Equal(t, float64(1), lastStats["readers"])
+		assert.Equal(t, float64(50), lastStats["retention"])
+	})
+}
+
 func adminGetBookContracts(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
 	author := TestAuthor(db)
 	BookData(db, author)
@@ -261,5 +298,6 @@ func TestAdminBooks(t *testing.T) {
 	adminGetBooks(t, app, db, baseUrl, token)
 	adminGetBooksByAuthor(t, app, db, baseUrl, token)
 	adminGetBookDetails(t, app, db, baseUrl, token)
+	adminGetBookRetentionStats(t, app, db, baseUrl, token)
 	adminGetBookContracts(t, app, db, baseUrl, token)
 }
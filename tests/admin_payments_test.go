@@ -1,10 +1,18 @@
 package tests
 
 import (
+	"encoding/csv"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/schemas"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -24,6 +32,353 @@ func getTransactions(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string,
 	})
 }
 
+func getPayouts(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	t.Run("Reject Payouts Fetch Due To Invalid Status", func(t *testing.T) {
+		url := fmt.Sprintf("%s/payouts?status=invalid", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid payout status", body["message"])
+	})
+
+	t.Run("Accept Payouts Fetch", func(t *testing.T) {
+		TestPayout(db, TestAuthor(db))
+		url := fmt.Sprintf("%s/payouts?status=REQUESTED", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Payouts fetched successfully", body["message"])
+	})
+}
+
+func updatePayoutStatus(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	payout := TestPayout(db, TestAuthor(db))
+	statusData := schemas.PayoutStatusUpdateSchema{Status: choices.POS_APPROVED}
+
+	t.Run("Reject Payout Update Due To Non-existent Payout", func(t *testing.T) {
+		url := fmt.Sprintf("%s/payouts/%s", baseUrl, uuid.New())
+		res := ProcessJsonTestBody(t, app, url, "PUT", statusData, token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "No payout with that ID", body["message"])
+	})
+
+	t.Run("Accept Payout Update Due To Valid Transition", func(t *testing.T) {
+		url := fmt.Sprintf("%s/payouts/%s", baseUrl, payout.ID)
+		res := ProcessJsonTestBody(t, app, url, "PUT", statusData, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Payout updated successfully", body["message"])
+	})
+
+	t.Run("Reject Payout Update Due To Invalid Transition", func(t *testing.T) {
+		url := fmt.Sprintf("%s/payouts/%s", baseUrl, payout.ID)
+		res := ProcessJsonTestBody(t, app, url, "PUT", statusData, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Payout can't move from APPROVED to APPROVED", body["message"])
+	})
+}
+
+func replayPaymentEvent(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	reference := "pi_test_replay"
+	event := TestPaymentEvent(db, reference)
+
+	t.Run("Accept Failed Events Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/events?status=FAILED", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Events fetched successfully", body["message"])
+		events := body["data"].(map[string]interface{})["events"].([]interface{})
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("Reject Event Replay Due To Unknown Transaction", func(t *testing.T) {
+		url := fmt.Sprintf("%s/events/%s/replay", baseUrl, event.ID)
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "no transaction with reference pi_test_replay", body["message"])
+	})
+
+	TestCoinTransaction(db, TestVerifiedUser(db), reference)
+	t.Run("Accept Event Replay Due To Known Transaction", func(t *testing.T) {
+		url := fmt.Sprintf("%s/events/%s/replay", baseUrl, event.ID)
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Event replayed successfully", body["message"])
+		assert.Equal(t, "PROCESSED", body["data"].(map[string]interface{})["status"])
+	})
+
+	t.Run("Reject Event Replay Due To Already Processed", func(t *testing.T) {
+		url := fmt.Sprintf("%s/events/%s/replay", baseUrl, event.ID)
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "This event has been processed already", body["message"])
+	})
+}
+
+func refundTransaction(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	provider, restore := payments.UseFake(choices.PTYPE_STRIPE)
+	defer restore()
+
+	user := TestVerifiedUser(db)
+	transaction := TestCoinTransaction(db, user, "pi_test_refund")
+	url := fmt.Sprintf("%s/transactions/%s/refund", baseUrl, transaction.ID)
+
+	t.Run("Reject Transaction Refund Due To Unsuccessful Payment", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Only successful payments can be refunded", body["message"])
+	})
+
+	db.Model(&transaction).Update("payment_status", choices.PSSUCCEEDED)
+	TestWalletBalance(db, &user, 100, 0)
+	t.Run("Accept Transaction Refund And Take Back Coins", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Transaction refunded successfully", body["message"])
+		assert.Equal(t, string(choices.PSREFUNDED), body["data"].(map[string]interface{})["payment_status"])
+		assert.Equal(t, []string{"pi_test_refund"}, *provider.Refunds)
+		db.Take(&user, user.ID)
+		assert.Equal(t, 0, user.Coins)
+	})
+
+	subscriber := TestSubscriber(db)
+	TestSubscription(db, subscriber, nil)
+	subTransaction := TestSubscriptionTransaction(db, subscriber, "pi_test_sub_refund")
+	t.Run("Accept Transaction Refund And End Subscription", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/transactions/%s/refund", baseUrl, subTransaction.ID), "POST", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		subscription := models.Subscription{}
+		db.Take(&subscription, "user_id = ?", subscriber.ID)
+		assert.Equal(t, choices.SS_EXPIRED, subscription.Status)
+	})
+
+	// The user whose coins were refunded above
+	renewingSubscriber := user
+	subReference := "sub_test_refund"
+	TestSubscription(db, renewingSubscriber, &subReference)
+	renewingTransaction := TestSubscriptionTransaction(db, renewingSubscriber, "in_test_sub_refund")
+	t.Run("Accept Transaction Refund And Cancel Renewing Subscription", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/transactions/%s/refund", baseUrl, renewingTransaction.ID), "POST", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		assert.Equal(t, []string{subReference}, *provider.Canceled)
+		subscription := models.Subscription{}
+		db.Take(&subscription, "user_id = ?", renewingSubscriber.ID)
+		assert.NotNil(t, subscription.CanceledAt)
+		assert.False(t, subscription.Renews())
+	})
+}
+
+func getRevenueReports(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	TestReportTransactions(db, TestVerifiedUser(db), day)
+	dayRange := "start=2024-01-15&end=2024-01-15"
+
+	t.Run("Reject Revenue Report Due To Invalid Interval", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/reports/revenue?interval=year", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid report interval", body["message"])
+	})
+
+	t.Run("Reject Revenue Report Due To Invalid Range", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/reports/revenue?start=2024-01-16&end=2024-01-15", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "The start date can't be after the end date", body["message"])
+	})
+
+	t.Run("Accept Revenue Report Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/reports/revenue?interval=month&"+dayRange, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Revenue report fetched successfully", body["message"])
+		rows := body["data"].(map[string]interface{})["rows"].([]interface{})
+		assert.Len(t, rows, 2)
+		// Coins first, with the refund taken off the net revenue
+		coinRow := rows[0].(map[string]interface{})
+		assert.Equal(t, "COINS", coinRow["payment_purpose"])
+		assert.Equal(t, float64(2), coinRow["payments"])
+		assert.Equal(t, "200", coinRow["gross_revenue"])
+		assert.Equal(t, "100", coinRow["net_revenue"])
+		assert.Equal(t, "MONTHLY", rows[1].(map[string]interface{})["sub_type"])
+	})
+
+	t.Run("Accept Revenue Summary Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/reports/summary?"+dayRange, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Revenue summary fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(3), data["payments"])
+		assert.Equal(t, float64(1), data["refunds"])
+		assert.Equal(t, "110", data["net_revenue"])
+		assert.InDelta(t, 1.0/3, data["refund_rate"], 0.0001)
+		assert.InDelta(t, 0.25, data["failure_rate"], 0.0001)
+		assert.Equal(t, "110", data["arppu"])
+	})
+
+	t.Run("Accept Revenue Summary Export As CSV", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/reports/summary?format=csv&"+dayRange, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+
+		records, err := csv.NewReader(res.Body).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, "gross_revenue", records[0][6])
+		assert.Equal(t, "210.00", records[1][6])
+	})
+}
+
+func managePromoCodes(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	url := baseUrl + "/promo-codes"
+	promoCodeData := schemas.PromoCodeCreateSchema{
+		Code: "summer10", DiscountType: choices.PDT_FIXED, DiscountValue: decimal.NewFromInt(10),
+		MaxRedemptionsPerUser: 1, IsActive: true,
+	}
+
+	t.Run("Reject Promo Code Create Due To Invalid Percentage", func(t *testing.T) {
+		invalidData := promoCodeData
+		invalidData.DiscountType = choices.PDT_PERCENTAGE
+		invalidData.DiscountValue = decimal.NewFromInt(100)
+		res := ProcessJsonTestBody(t, app, url, "POST", invalidData, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Must be less than 100 percent", body["data"].(map[string]interface{})["discount_value"])
+	})
+
+	var promoCodeID string
+	t.Run("Accept Promo Code Create Due To Valid Data", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", promoCodeData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Promo code created successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "SUMMER10", data["code"])
+		promoCodeID = data["id"].(string)
+	})
+
+	t.Run("Reject Promo Code Create Due To Existing Code", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", promoCodeData, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Promo code already exists", body["data"].(map[string]interface{})["code"])
+	})
+
+	t.Run("Accept Promo Code Update Due To Valid Data", func(t *testing.T) {
+		promoCodeData.IsActive = false
+		res := ProcessJsonTestBody(t, app, url+"/"+promoCodeID, "PUT", promoCodeData, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Promo code updated successfully", body["message"])
+		assert.Equal(t, false, body["data"].(map[string]interface{})["is_active"])
+	})
+
+	t.Run("Accept Promo Codes Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Promo codes fetched successfully", body["message"])
+	})
+
+	t.Run("Accept Promo Code Delete", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url+"/"+promoCodeID, "DELETE", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Promo code deleted successfully", body["message"])
+	})
+}
+
 func TestAdminPayments(t *testing.T) {
 	app := fiber.New()
 	db := Setup(t, app)
@@ -33,4 +388,10 @@ func TestAdminPayments(t *testing.T) {
 
 	// Run Admin Payments Endpoint Tests
 	getTransactions(t, app, db, baseUrl, token)
+	getPayouts(t, app, db, baseUrl, token)
+	updatePayoutStatus(t, app, db, baseUrl, token)
+	replayPaymentEvent(t, app, db, baseUrl, token)
+	refundTransaction(t, app, db, baseUrl, token)
+	getRevenueReports(t, app, db, baseUrl, token)
+	managePromoCodes(t, app, db, baseUrl, token)
 }
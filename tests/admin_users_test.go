@@ -82,6 +82,142 @@ func toggleUserActivation(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl str
 	})
 }
 
+func getRoles(t *testing.T, app *fiber.App, baseUrl string, token string) {
+	t.Run("Accept Roles Fetch", func(t *testing.T) {
+		url := fmt.Sprintf("%s/roles", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Roles fetched successfully", body["message"])
+		assert.NotEmpty(t, body["data"])
+	})
+}
+
+func fillStaffRoles(t *testing.T, db *gorm.DB) {
+	t.Run("Accept Staff Role Fill Without Making Staff Superusers", func(t *testing.T) {
+		// Staff made before roles existed
+		user := models.User{Email: "testlegacystaff@example.com", Password: MASTER_PASSWORD, IsStaff: true, IsEmailVerified: true}
+		db.FirstOrCreate(&user, models.User{Email: user.Email})
+		roleManager.FillStaffRoles(db)
+
+		db.Take(&user, "id = ?", user.ID)
+		assert.False(t, user.IsSuperuser)
+		assert.Equal(t, &roleManager.GetByCodename(db, choices.ROLE_STAFF).ID, user.RoleID)
+		permissions := roleManager.GetUserPermissions(db, user)
+		assert.True(t, permissions[choices.PERM_MANAGE_BOOKS])
+		assert.False(t, permissions[choices.PERM_MANAGE_ADMINS])
+	})
+}
+
+func inviteAdmin(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	user := TestVerifiedUser(db)
+	url := fmt.Sprintf("%s/admins/invite", baseUrl)
+
+	t.Run("Reject Admin Invite Due To Missing Permission", func(t *testing.T) {
+		finance := TestStaff(db, choices.ROLE_FINANCE)
+		data := schemas.InviteAdminSchema{Email: user.Email, Role: choices.ROLE_SUPPORT}
+		res := ProcessJsonTestBody(t, app, url, "POST", data, AccessToken(db, finance))
+		// Assert Status code
+		assert.Equal(t, 403, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You don't have permission to do this", body["message"])
+	})
+
+	t.Run("Reject Admin Invite Due To Invalid Role", func(t *testing.T) {
+		data := schemas.InviteAdminSchema{Email: user.Email, Role: choices.ROLE_READER}
+		res := ProcessJsonTestBody(t, app, url, "POST", data, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid Entry", body["message"])
+	})
+
+	t.Run("Accept Admin Invite Due To Valid Role", func(t *testing.T) {
+		data := schemas.InviteAdminSchema{Email: user.Email, Role: choices.ROLE_SUPPORT}
+		res := ProcessJsonTestBody(t, app, url, "POST", data, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "User upgraded successfully!", body["message"])
+
+		db.Take(&user, user.ID)
+		assert.True(t, user.IsStaff)
+		assert.NotNil(t, user.RoleID)
+	})
+}
+
+func lockouts(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	user := TestVerifiedUser(db)
+	for i := 0; i < 10; i++ {
+		authThrottleManager.RecordFailure(db, choices.ATS_LOGIN, user.Email, "10.0.0.1")
+	}
+	lockout := authThrottleManager.GetActive(db, nil)[0]
+
+	t.Run("Reject Lockouts Fetch Due To Invalid Scope", func(t *testing.T) {
+		url := fmt.Sprintf("%s/lockouts?scope=invalid", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid scope", body["message"])
+	})
+
+	t.Run("Accept Lockouts Fetch", func(t *testing.T) {
+		url := fmt.Sprintf("%s/lockouts?scope=%s", baseUrl, choices.ATS_LOGIN)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Lockouts fetched successfully", body["message"])
+		items := body["data"].(map[string]interface{})["lockouts"].([]interface{})
+		assert.Len(t, items, 1)
+		assert.Equal(t, user.Email, items[0].(map[string]interface{})["key"])
+	})
+
+	t.Run("Accept Lockout Clear", func(t *testing.T) {
+		url := fmt.Sprintf("%s/lockouts/%s", baseUrl, lockout.ID)
+		res := ProcessTestGetOrDelete(app, url, "DELETE", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Lockout cleared successfully", body["message"])
+	})
+
+	t.Run("Reject Lockout Clear Due To Unknown Lockout", func(t *testing.T) {
+		url := fmt.Sprintf("%s/lockouts/%s", baseUrl, lockout.ID)
+		res := ProcessTestGetOrDelete(app, url, "DELETE", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "No lockout with that ID", body["message"])
+	})
+}
+
 func TestAdminUsers(t *testing.T) {
 	app := fiber.New()
 	db := Setup(t, app)
@@ -93,4 +229,8 @@ func TestAdminUsers(t *testing.T) {
 	getUsers(t, app, db, baseUrl, token)
 	updateUserRole(t, app, baseUrl, admin, token)
 	toggleUserActivation(t, app, db, baseUrl, token)
+	getRoles(t, app, baseUrl, token)
+	fillStaffRoles(t, db)
+	inviteAdmin(t, app, db, baseUrl, token)
+	lockouts(t, app, db, baseUrl, token)
 }
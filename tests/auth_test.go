@@ -1,14 +1,19 @@
 package tests
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/LitPad/backend/database"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/schemas"
 	"github.com/LitPad/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -405,6 +410,153 @@ func facebookLogin(t *testing.T, app *fiber.App, baseUrl string) {
 	})
 }
 
+func appleLogin(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	appleServer, restore := UseTestAppleServer()
+	defer restore()
+	url := fmt.Sprintf("%s/apple", baseUrl)
+	email := "testappleuser@privaterelay.appleid.com"
+	name := "Apple User"
+
+	t.Run("Reject apple login due to invalid audience", func(t *testing.T) {
+		loginData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_user", email, "com.another.app"), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid Audience", body["message"])
+	})
+
+	t.Run("Reject apple login due to token signed with another key", func(t *testing.T) {
+		anotherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		loginData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_user", email, TEST_APPLE_CLIENT_ID, anotherKey), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid Token", body["message"])
+	})
+
+	t.Run("Accept apple login and create user", func(t *testing.T) {
+		loginData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_user", email, TEST_APPLE_CLIENT_ID), DeviceType: choices.DT_IOS, Name: &name}
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Social Login successful", body["message"])
+
+		user := userManager.GetByEmail(db, email)
+		assert.NotNil(t, user)
+		assert.Equal(t, name, *user.Name)
+		assert.Len(t, socialAccountManager.GetByUser(db, *user), 1)
+	})
+
+	t.Run("Accept apple login by linked account after email change", func(t *testing.T) {
+		loginData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_user", "changed@privaterelay.appleid.com", TEST_APPLE_CLIENT_ID), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Nil(t, userManager.GetByEmail(db, "changed@privaterelay.appleid.com"))
+	})
+
+	t.Run("Reject apple login due to existing password account", func(t *testing.T) {
+		user := TestVerifiedUser(db)
+		loginData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_password_user", user.Email, TEST_APPLE_CLIENT_ID), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "This account wasn't created via apple. Please sign in using your email and password.", body["message"])
+	})
+}
+
+func socialAccounts(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	appleServer, restore := UseTestAppleServer()
+	defer restore()
+	user := TestVerifiedUser(db)
+	token := AccessToken(db, user)
+	url := fmt.Sprintf("%s/socials/apple", baseUrl)
+
+	t.Run("Reject account link due to account linked to another user", func(t *testing.T) {
+		linkData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_user", "testappleuser@privaterelay.appleid.com", TEST_APPLE_CLIENT_ID), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", linkData, token)
+
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "That apple account is linked to another user", body["message"])
+	})
+
+	t.Run("Accept account link and login with it", func(t *testing.T) {
+		linkData := schemas.SocialLoginSchema{Token: appleServer.Token("apple_password_user", user.Email, TEST_APPLE_CLIENT_ID), DeviceType: choices.DT_IOS}
+		res := ProcessJsonTestBody(t, app, url, "POST", linkData, token)
+
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Account linked successfully", body["message"])
+
+		res = ProcessJsonTestBody(t, app, fmt.Sprintf("%s/apple", baseUrl), "POST", linkData)
+		assert.Equal(t, 201, res.StatusCode)
+
+		res = ProcessTestGetOrDelete(app, fmt.Sprintf("%s/socials", baseUrl), "GET", token)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Len(t, body["data"], 1)
+	})
+
+	t.Run("Reject account unlink due to only account of social user", func(t *testing.T) {
+		socialUser := userManager.GetByEmail(db, "testappleuser@privaterelay.appleid.com")
+		res := ProcessTestGetOrDelete(app, url, "DELETE", AccessToken(db, *socialUser))
+
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Link another account before unlinking this one, you won't be able to sign in otherwise", body["message"])
+	})
+
+	t.Run("Accept account unlink", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "DELETE", token)
+
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Account unlinked successfully", body["message"])
+	})
+}
+
 func refresh(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	t.Run("Reject Token Refresh Due To Invalid/Expired Token", func(t *testing.T) {
 		url := fmt.Sprintf("%s/refresh", baseUrl)
@@ -420,11 +572,11 @@ func refresh(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 		assert.Equal(t, "Refresh token is invalid or expired", body["message"])
 	})
 
+	user := TestVerifiedUser(db)
+	token := JwtData(db, user)
+	url := fmt.Sprintf("%s/refresh", baseUrl)
+	var newRefresh string
 	t.Run("Accept Token Refresh Due To Valid Refresh Token", func(t *testing.T) {
-		user := TestVerifiedUser(db)
-		token := JwtData(db, user)
-
-		url := fmt.Sprintf("%s/refresh", baseUrl)
 		refreshData := schemas.RefreshTokenSchema{Refresh: token.Refresh}
 		res := ProcessJsonTestBody(t, app, url, "POST", refreshData)
 
@@ -435,6 +587,168 @@ func refresh(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 		body := ParseResponseBody(t, res.Body).(map[string]interface{})
 		assert.Equal(t, "success", body["status"])
 		assert.Equal(t, "Tokens refresh successful", body["message"])
+		newRefresh = body["data"].(map[string]interface{})["refresh"].(string)
+	})
+
+	t.Run("Reject Token Refresh Due To Reused Refresh Token", func(t *testing.T) {
+		refreshData := schemas.RefreshTokenSchema{Refresh: token.Refresh}
+		res := ProcessJsonTestBody(t, app, url, "POST", refreshData)
+
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Refresh token has been used already. Login again", body["message"])
+
+		// The whole session is revoked, including the tokens that replaced the reused ones
+		res = ProcessJsonTestBody(t, app, url, "POST", schemas.RefreshTokenSchema{Refresh: newRefresh})
+		assert.Equal(t, 401, res.StatusCode)
+	})
+}
+
+func sessions(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestVerifiedUser(db)
+	token := AccessToken(db, user)
+	otherToken := AccessToken(db, user)
+	url := fmt.Sprintf("%s/sessions", baseUrl)
+
+	t.Run("Accept Sessions Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Sessions fetched successfully", body["message"])
+		currentSessions := 0
+		for _, session := range body["data"].([]interface{}) {
+			if session.(map[string]interface{})["is_current"].(bool) {
+				currentSessions++
+			}
+		}
+		assert.Equal(t, 1, currentSessions)
+	})
+
+	t.Run("Reject Session Revoke Due To Unknown Session", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/%s", url, uuid.New()), "DELETE", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "You don't have a session with that ID", body["message"])
+	})
+
+	t.Run("Accept Session Revoke", func(t *testing.T) {
+		otherSession := models.AuthToken{}
+		db.Take(&otherSession, "access = ?", otherToken)
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/%s", url, otherSession.FamilyID), "DELETE", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Session revoked successfully", body["message"])
+
+		// The revoked session's token can't be used anymore
+		res = ProcessTestGetOrDelete(app, url, "GET", otherToken)
+		assert.Equal(t, 401, res.StatusCode)
+	})
+}
+
+func twoFactor(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestTwoFactorUser(db)
+	token := AccessToken(db, user)
+	loginData := schemas.LoginSchema{Email: user.Email, Password: MASTER_PASSWORD}
+	var secret string
+	var recoveryCodes []interface{}
+
+	t.Run("Accept Authenticator App Setup And Enable", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/2fa/totp/setup", baseUrl), "POST", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		secret = body["data"].(map[string]interface{})["secret"].(string)
+
+		url := fmt.Sprintf("%s/2fa/totp/enable", baseUrl)
+		res = ProcessJsonTestBody(t, app, url, "POST", schemas.TotpCodeSchema{Code: "000000"}, token)
+		assert.Equal(t, 401, res.StatusCode)
+
+		code := utils.TotpCode(secret, utils.TotpStep(time.Now()))
+		res = ProcessJsonTestBody(t, app, url, "POST", schemas.TotpCodeSchema{Code: code}, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Two-factor authentication enabled", body["message"])
+		recoveryCodes = body["data"].(map[string]interface{})["codes"].([]interface{})
+		assert.Equal(t, 10, len(recoveryCodes))
+	})
+
+	t.Run("Accept Login With A Recovery Code", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/login", baseUrl), "POST", loginData)
+		// Password logins get a challenge instead of tokens
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		challenge := body["data"].(map[string]interface{})
+		assert.Nil(t, challenge["access"])
+		assert.Equal(t, []interface{}{"totp", "email", "recovery"}, challenge["methods"])
+
+		url := fmt.Sprintf("%s/2fa/verify", baseUrl)
+		verifyData := schemas.TwoFactorLoginSchema{
+			ChallengeToken: challenge["challenge_token"].(string), Method: choices.TFM_TOTP, Code: "000000",
+		}
+		res = ProcessJsonTestBody(t, app, url, "POST", verifyData)
+		assert.Equal(t, 401, res.StatusCode)
+
+		verifyData.Method = choices.TFM_RECOVERY
+		verifyData.Code = recoveryCodes[0].(string)
+		res = ProcessJsonTestBody(t, app, url, "POST", verifyData)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Login successful", body["message"])
+		assert.NotNil(t, body["data"].(map[string]interface{})["access"])
+
+		// Recovery codes only work once
+		res = ProcessJsonTestBody(t, app, url, "POST", verifyData)
+		assert.Equal(t, 401, res.StatusCode)
+	})
+
+	t.Run("Accept Staff Login With An Emailed Code", func(t *testing.T) {
+		admin := TestAdmin(db)
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/login", baseUrl), "POST", schemas.LoginSchema{Email: admin.Email, Password: MASTER_PASSWORD})
+		// Staff without an authenticator app are sent a code
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		challenge := body["data"].(map[string]interface{})
+		assert.Equal(t, []interface{}{"email"}, challenge["methods"])
+
+		db.Take(&admin, "id = ?", admin.ID)
+		verifyData := schemas.TwoFactorLoginSchema{
+			ChallengeToken: challenge["challenge_token"].(string), Method: choices.TFM_EMAIL, Code: fmt.Sprint(*admin.Otp),
+		}
+		res = ProcessJsonTestBody(t, app, fmt.Sprintf("%s/2fa/verify", baseUrl), "POST", verifyData)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+	})
+
+	t.Run("Accept Two-Factor Disable", func(t *testing.T) {
+		url := fmt.Sprintf("%s/2fa/totp/disable", baseUrl)
+		disableData := schemas.TwoFactorCodeSchema{Method: choices.TFM_RECOVERY, Code: recoveryCodes[1].(string)}
+		res := ProcessJsonTestBody(t, app, url, "POST", disableData, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Logins give tokens straight away again
+		res = ProcessJsonTestBody(t, app, fmt.Sprintf("%s/login", baseUrl), "POST", loginData)
+		assert.Equal(t, 201, res.StatusCode)
 	})
 }
 
@@ -465,6 +779,92 @@ func logout(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	})
 }
 
+func bruteForceProtection(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestAuthor(db)
+	loginUrl := fmt.Sprintf("%s/login", baseUrl)
+	wrongLogin := schemas.LoginSchema{Email: user.Email, Password: "invalidpassword"}
+	// Requests from tests come from this address
+	ip := "0.0.0.0"
+
+	t.Run("Reject login due to too many failed attempts", func(t *testing.T) {
+		// The free failures, then one that has to be waited out
+		for i := 0; i < 6; i++ {
+			res := ProcessJsonTestBody(t, app, loginUrl, "POST", wrongLogin)
+			assert.Equal(t, 401, res.StatusCode)
+		}
+		res := ProcessJsonTestBody(t, app, loginUrl, "POST", wrongLogin)
+		// Assert Status code
+		assert.Equal(t, 429, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get("Retry-After"))
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, utils.ERR_LIMITS_REACHED, body["code"])
+	})
+
+	var unlockToken *string
+	t.Run("Reject login due to locked account", func(t *testing.T) {
+		for i := 0; i < 10 && unlockToken == nil; i++ {
+			unlockToken = authThrottleManager.RecordFailure(db, choices.ATS_LOGIN, user.Email, ip)
+		}
+		assert.NotNil(t, unlockToken)
+
+		res := ProcessJsonTestBody(t, app, loginUrl, "POST", schemas.LoginSchema{Email: user.Email, Password: MASTER_PASSWORD})
+		// Assert Status code
+		assert.Equal(t, 429, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, utils.ERR_ACCOUNT_LOCKED, body["code"])
+	})
+
+	t.Run("Reject account unlock due to invalid token", func(t *testing.T) {
+		url := fmt.Sprintf("%s/unlock-account", baseUrl)
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.UnlockAccountSchema{Token: "invalidtoken"})
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid or expired unlock token", body["message"])
+	})
+
+	t.Run("Accept account unlock and login", func(t *testing.T) {
+		url := fmt.Sprintf("%s/unlock-account", baseUrl)
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.UnlockAccountSchema{Token: *unlockToken})
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Account unlocked successfully", body["message"])
+
+		res = ProcessJsonTestBody(t, app, loginUrl, "POST", schemas.LoginSchema{Email: user.Email, Password: MASTER_PASSWORD})
+		assert.Equal(t, 201, res.StatusCode)
+	})
+
+	t.Run("Reject password reset link due to too many requests", func(t *testing.T) {
+		url := fmt.Sprintf("%s/send-password-reset-link", baseUrl)
+		data := schemas.EmailRequestSchema{Email: user.Email}
+		for i := 0; i < 4; i++ {
+			res := ProcessJsonTestBody(t, app, url, "POST", data)
+			assert.Equal(t, 200, res.StatusCode)
+		}
+		res := ProcessJsonTestBody(t, app, url, "POST", data)
+		// Assert Status code
+		assert.Equal(t, 429, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, utils.ERR_LIMITS_REACHED, body["code"])
+	})
+}
+
 func TestAuth(t *testing.T) {
 	app := fiber.New()
 	db := Setup(t, app)
@@ -480,7 +880,12 @@ func TestAuth(t *testing.T) {
 	login(t, app, db, baseUrl)
 	googleLogin(t, app, baseUrl)
 	facebookLogin(t, app, baseUrl)
+	appleLogin(t, app, db, baseUrl)
+	socialAccounts(t, app, db, baseUrl)
 	refresh(t, app, db, baseUrl)
+	sessions(t, app, db, baseUrl)
+	twoFactor(t, app, db, baseUrl)
+	bruteForceProtection(t, app, db, baseUrl)
 	logout(t, app, db, baseUrl)
 
 	// Drop Tables and Close Connectiom
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/LitPad/backend/database"
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/schemas"
@@ -101,6 +102,163 @@ func getBooksByAuthor(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string)
 	})
 }
 
+func searchBooks(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	book := BookData(db, TestAuthor(db))
+	chapter := ChapterData(db, book)
+	db.Create(&models.Paragraph{ChapterID: chapter.ID, Index: 1, Text: "The lighthouse keeper counted the waves"})
+
+	t.Run("Reject Books Search Due To No Search Words", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=%%20-", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Enter a word to search for", body["message"])
+	})
+
+	t.Run("Reject Books Search Due To Invalid Age Discretion", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=test&age_discretion=10", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid age discretion", body["message"])
+	})
+
+	t.Run("Reject Books Search Due To Invalid Genre Slug", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=test&genre_slug=invalid-genre", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid book genre", body["message"])
+	})
+
+	t.Run("Accept Books Search By Title Prefix With Highlights And Facets", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=tes&genre_slug=%s", baseUrl, GenreData(db).Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Books fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		books := data["books"].([]interface{})
+		assert.Len(t, books, 1)
+		result := books[0].(map[string]interface{})
+		assert.Equal(t, book.Slug, result["slug"])
+		assert.Equal(t, "<mark>Test</mark> Book", result["highlights"].(map[string]interface{})["title"])
+		genres := data["facets"].(map[string]interface{})["genres"].([]interface{})
+		assert.Equal(t, float64(1), genres[0].(map[string]interface{})["count"])
+	})
+
+	t.Run("Accept Books Search Despite Typo", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=Tesst%%20Book", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 1)
+	})
+
+	t.Run("Accept Books Search In Chapters Only When Asked", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=lighthouse", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 0)
+
+		res = ProcessTestGetOrDelete(app, url+"&in_chapters=true", "GET")
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		books = body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 1)
+		highlights := books[0].(map[string]interface{})["highlights"].(map[string]interface{})
+		assert.Equal(t, chapter.Slug, highlights["chapter_slug"])
+		assert.Contains(t, highlights["content"], "<mark>lighthouse</mark>")
+	})
+
+	t.Run("Accept Books Search Narrowed By Facet", func(t *testing.T) {
+		url := fmt.Sprintf("%s/search?q=test&completed=true", baseUrl)
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 0)
+	})
+}
+
+func getRecommendedBooks(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	readBook := BookData(db, author)
+	likedBook := models.Book{AuthorID: author.ID, Title: "Liked Book", GenreID: readBook.GenreID, AgeDiscretion: choices.ATYPE_EIGHTEEN}
+	reportedBook := models.Book{AuthorID: author.ID, Title: "Reported Book", GenreID: readBook.GenreID, AgeDiscretion: choices.ATYPE_EIGHTEEN}
+	db.Create(&likedBook)
+	db.Create(&reportedBook)
+
+	reader := TestVerifiedUser(db)
+	coReader := TestAuthor(db, true)
+	db.Create(&[]models.BookRead{
+		{UserID: coReader.ID, BookID: readBook.ID}, {UserID: coReader.ID, BookID: likedBook.ID},
+		{UserID: coReader.ID, BookID: reportedBook.ID}, {UserID: reader.ID, BookID: readBook.ID},
+	})
+	db.Create(&models.BookReport{UserID: reader.ID, BookID: reportedBook.ID, Reason: "Spam"})
+	db.Create(&models.Waitlist{Name: "Test Reader", Email: reader.Email, GenreID: readBook.GenreID})
+	url := fmt.Sprintf("%s/recommended", baseUrl)
+
+	t.Run("Accept Trending Books For Guests", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Recommended books fetched successfully", body["message"])
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 3)
+		assert.Equal(t, "TRENDING", books[0].(map[string]interface{})["reason"])
+	})
+
+	t.Run("Accept Books Like Those Read Then Waitlist Genre Books", func(t *testing.T) {
+		recommendationManager.ComputeSimilarities(db)
+		recommendationManager.ComputeForUser(db, reader.ID)
+		res := ProcessTestGetOrDelete(app, url, "GET", AccessToken(db, reader))
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 2) // The reported book is left out
+		first := books[0].(map[string]interface{})
+		assert.Equal(t, likedBook.Slug, first["slug"])
+		assert.Equal(t, "BECAUSE_YOU_READ", first["reason"])
+		assert.Equal(t, readBook.Slug, first["because_of_book"].(map[string]interface{})["slug"])
+		second := books[1].(map[string]interface{})
+		assert.Equal(t, readBook.Slug, second["slug"])
+		assert.Equal(t, "WAITLIST_GENRE", second["reason"])
+	})
+}
+
 func getBookChapters(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	t.Run("Reject Book Chapters Fetch Due To Invalid Slug", func(t *testing.T) {
 		url := fmt.Sprintf("%s/book/invalid-slug/chapters", baseUrl)
@@ -130,6 +288,119 @@ func getBookChapters(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string)
 	})
 }
 
+func readingProgress(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	book := models.Book{AuthorID: author.ID, Title: "Progress Book", GenreID: GenreData(db).ID, AgeDiscretion: choices.ATYPE_EIGHTEEN}
+	db.Create(&book)
+	chapters := []models.Chapter{
+		{BookID: book.ID, Title: "First Chapter"},
+		{BookID: book.ID, Title: "Last Chapter", IsLast: true},
+	}
+	for _, chapter := range chapters {
+		db.Create(&chapter)
+		for index := uint(1); index <= 4; index++ {
+			db.Create(&models.Paragraph{ChapterID: chapter.ID, Index: index, Text: "A paragraph"})
+		}
+	}
+	db.Where("book_id = ?", book.ID).Order("created_at ASC").Find(&chapters)
+	reader := TestVerifiedUser(db, true)
+	token := AccessToken(db, reader)
+	progressUrl := fmt.Sprintf("%s/book/%s/progress", baseUrl, book.Slug)
+	continueReadingUrl := fmt.Sprintf("%s/continue-reading", baseUrl)
+
+	t.Run("Reject Reading Progress Fetch Due To Not Started", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, progressUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You haven't started reading this book", body["message"])
+	})
+
+	t.Run("Reject Reading Progress Save Due To Invalid Chapter Slug", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/invalid-slug/progress", baseUrl)
+		res := ProcessJsonTestBody(t, app, url, "PUT", schemas.ReadingProgressUpdateSchema{ParagraphIndex: 1}, token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "No chapter with that slug", body["message"])
+	})
+
+	t.Run("Accept Reading Progress Save", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/progress", baseUrl, chapters[0].Slug)
+		res := ProcessJsonTestBody(t, app, url, "PUT", schemas.ReadingProgressUpdateSchema{ParagraphIndex: 2}, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Reading progress saved successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(25), data["percent"]) // Half of the first of two chapters
+		assert.Equal(t, chapters[0].Slug, data["chapter"].(map[string]interface{})["slug"])
+	})
+
+	t.Run("Accept Reading Progress Save Ignored For An Earlier Place", func(t *testing.T) {
+		readAt := time.Now().Add(-time.Hour)
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/progress", baseUrl, chapters[1].Slug)
+		res := ProcessJsonTestBody(t, app, url, "PUT", schemas.ReadingProgressUpdateSchema{ParagraphIndex: 1, ReadAt: &readAt}, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "A later reading progress is already saved", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(25), data["percent"])
+		assert.Equal(t, chapters[0].Slug, data["chapter"].(map[string]interface{})["slug"])
+	})
+
+	t.Run("Accept Reading Progress Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, progressUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Reading progress fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["paragraph_index"])
+	})
+
+	t.Run("Accept Continue Reading Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, continueReadingUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Books fetched successfully", body["message"])
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 1)
+		assert.Equal(t, book.Slug, books[0].(map[string]interface{})["book"].(map[string]interface{})["slug"])
+	})
+
+	t.Run("Accept Continue Reading Fetch Without Finished Books", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/progress", baseUrl, chapters[1].Slug)
+		res := ProcessJsonTestBody(t, app, url, "PUT", schemas.ReadingProgressUpdateSchema{ParagraphIndex: 4}, token)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, float64(100), body["data"].(map[string]interface{})["percent"])
+
+		res = ProcessTestGetOrDelete(app, continueReadingUrl, "GET", token)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		books := body["data"].(map[string]interface{})["books"].([]interface{})
+		assert.Len(t, books, 0)
+	})
+}
+
 func getBook(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	t.Run("Reject Book Details Fetch Due To Invalid Slug", func(t *testing.T) {
 		url := fmt.Sprintf("%s/book/invalid-slug", baseUrl)
@@ -243,6 +514,18 @@ func updateBook(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 		assert.Equal(t, "Author has no book with that slug", body["message"])
 	})
 
+	t.Run("Reject Book Update Due To Invalid Owner", func(t *testing.T) {
+		anotherAuthor := TestAuthor(db, true)
+		url := fmt.Sprintf("%s/book/%s", baseUrl, book.Slug)
+		res := ProcessMultipartTestBody(t, app, url, "PUT", bookData, []string{}, []string{}, AccessToken(db, anotherAuthor))
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Author has no book with that slug", body["message"])
+	})
+
 	t.Run("Accept Book Update Due To Valid Data", func(t *testing.T) {
 		url := fmt.Sprintf("%s/book/%s", baseUrl, book.Slug)
 		res := ProcessMultipartTestBody(t, app, url, "PUT", bookData, []string{}, []string{}, token)
@@ -341,6 +624,209 @@ func deleteBook(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 // 	})
 // }
 
+func scheduleChapters(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	token := AccessToken(db, author)
+	book := models.Book{AuthorID: author.ID, Title: "Scheduled Book", GenreID: GenreData(db).ID, AgeDiscretion: choices.ATYPE_EIGHTEEN}
+	db.Create(&book)
+	follower := TestVerifiedUser(db, true)
+	db.Model(&author).Association("Followers").Append(&follower)
+	bookmarker := TestAuthor(db, true)
+	db.Create(&models.Bookmark{UserID: bookmarker.ID, BookID: book.ID})
+	addChapterUrl := fmt.Sprintf("%s/book/%s/add-chapter", baseUrl, book.Slug)
+	chaptersUrl := fmt.Sprintf("%s/book/%s/chapters", baseUrl, book.Slug)
+	chapterData := schemas.ChapterCreateSchema{Title: "Written Ahead", Paragraphs: []string{"A paragraph"}, Status: choices.CS_SCHEDULED}
+
+	t.Run("Reject Chapter Scheduling Due To Past Publish Time", func(t *testing.T) {
+		publishAt := time.Now().Add(-time.Hour)
+		chapterData.PublishAt = &publishAt
+		res := ProcessJsonTestBody(t, app, addChapterUrl, "POST", chapterData, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "Set a time in the future to schedule the chapter", data["publish_at"])
+	})
+
+	var chapterSlug string
+	t.Run("Accept Chapter Scheduling Hidden From Readers", func(t *testing.T) {
+		publishAt := time.Now().Add(time.Hour)
+		chapterData.PublishAt = &publishAt
+		res := ProcessJsonTestBody(t, app, addChapterUrl, "POST", chapterData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter added successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "SCHEDULED", data["status"])
+		chapterSlug = data["slug"].(string)
+
+		// Readers neither see it listed nor can open it, but the author can
+		res = ProcessTestGetOrDelete(app, chaptersUrl, "GET", AccessToken(db, follower))
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Len(t, body["data"].(map[string]interface{})["chapters"], 0)
+		res = ProcessTestGetOrDelete(app, chaptersUrl, "GET", token)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Len(t, body["data"].(map[string]interface{})["chapters"], 1)
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s", baseUrl, chapterSlug)
+		res = ProcessTestGetOrDelete(app, url, "GET", AccessToken(db, follower))
+		assert.Equal(t, 404, res.StatusCode)
+		res = ProcessTestGetOrDelete(app, url, "GET", token)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+
+	t.Run("Accept Scheduled Chapter Publishing With Notifications", func(t *testing.T) {
+		db.Model(&models.Chapter{}).Where("slug = ?", chapterSlug).UpdateColumn("publish_at", time.Now().Add(-time.Minute))
+		published := managers.ChapterManager{}.PublishDue(db)
+		assert.Len(t, published, 1)
+		assert.Empty(t, managers.ChapterManager{}.PublishDue(db))
+
+		res := ProcessTestGetOrDelete(app, chaptersUrl, "GET", AccessToken(db, follower))
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		chapters := body["data"].(map[string]interface{})["chapters"].([]interface{})
+		assert.Len(t, chapters, 1)
+		assert.Equal(t, "PUBLISHED", chapters[0].(map[string]interface{})["status"])
+		for _, receiver := range []models.User{follower, bookmarker} {
+			var count int64
+			db.Model(&models.Notification{}).Where("receiver_id = ? AND ntype = ? AND book_id = ?", receiver.ID, choices.NT_NEW_CHAPTER, book.ID).Count(&count)
+			assert.Equal(t, int64(1), count)
+		}
+	})
+
+	t.Run("Reject Chapter Scheduling Due To Being Published", func(t *testing.T) {
+		publishAt := time.Now().Add(time.Hour)
+		chapterData.PublishAt = &publishAt
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/book/chapter/%s", baseUrl, chapterSlug), "PUT", chapterData, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Only a draft can be scheduled", body["data"].(map[string]interface{})["status"])
+	})
+}
+
+func chapterRevisions(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	token := AccessToken(db, author)
+	reader := TestVerifiedUser(db, true)
+	book := models.Book{AuthorID: author.ID, Title: "Revised Book", GenreID: GenreData(db).ID, AgeDiscretion: choices.ATYPE_EIGHTEEN}
+	db.Create(&book)
+	chapter := models.Chapter{BookID: book.ID, Title: "First Draft"}
+	db.Create(&chapter)
+	paragraphs := []models.Paragraph{
+		{ChapterID: chapter.ID, Index: 1, Text: "The house stood at the end of the lane"},
+		{ChapterID: chapter.ID, Index: 2, Text: "Nobody had lived there for years"},
+		{ChapterID: chapter.ID, Index: 3, Text: "This line goes away"},
+	}
+	db.Create(&paragraphs)
+	comment := models.Comment{UserID: reader.ID, ParagraphID: &paragraphs[1].ID, Text: "Spooky"}
+	db.Create(&comment)
+	chapterUrl := fmt.Sprintf("%s/book/chapter/%s", baseUrl, chapter.Slug)
+	revisionsUrl := fmt.Sprintf("%s/revisions", chapterUrl)
+
+	t.Run("Accept Chapter Update Keeping Comments On Moved Paragraphs", func(t *testing.T) {
+		chapterData := schemas.ChapterCreateSchema{Title: "Second Draft", Paragraphs: []string{
+			"A new opening line",
+			"Nobody had lived there for many years",
+			"The house stood at the end of the lane",
+		}}
+		res := ProcessJsonTestBody(t, app, chapterUrl, "PUT", chapterData, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter updated successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Len(t, data["paragraphs"], 3)
+		edited := data["paragraphs"].([]interface{})[1].(map[string]interface{})
+		assert.Equal(t, "Nobody had lived there for many years", edited["text"])
+		assert.Equal(t, float64(1), edited["comments_count"])
+
+		// The comment is still on the paragraph it was made on
+		db.Take(&comment, comment.ID)
+		assert.Equal(t, paragraphs[1].ID, *comment.ParagraphID)
+	})
+
+	t.Run("Accept Chapter Revisions Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, revisionsUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter revisions fetched successfully", body["message"])
+		revisions := body["data"].(map[string]interface{})["revisions"].([]interface{})
+		assert.Len(t, revisions, 2)
+		latest := revisions[0].(map[string]interface{})
+		assert.Equal(t, float64(2), latest["number"])
+		assert.Equal(t, "Second Draft", latest["title"])
+		assert.NotNil(t, latest["editor"])
+		assert.Nil(t, revisions[1].(map[string]interface{})["editor"])
+	})
+
+	t.Run("Reject Chapter Revisions Fetch Due To Invalid Owner", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, revisionsUrl, "GET", AccessToken(db, TestAuthor(db, true)))
+		assert.Equal(t, 404, res.StatusCode)
+	})
+
+	t.Run("Accept Chapter Revisions Diff", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, revisionsUrl+"/diff", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter revisions compared successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), data["from"].(map[string]interface{})["number"])
+		assert.Equal(t, float64(2), data["to"].(map[string]interface{})["number"])
+		changes := []string{}
+		for _, paragraph := range data["paragraphs"].([]interface{}) {
+			changes = append(changes, paragraph.(map[string]interface{})["change"].(string))
+		}
+		assert.Equal(t, []string{"ADDED", "EDITED", "UNCHANGED", "REMOVED"}, changes)
+	})
+
+	t.Run("Reject Chapter Revisions Diff Due To Invalid Revision", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, revisionsUrl+"/diff?from=9", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter has no revision with that number", body["message"])
+	})
+
+	t.Run("Accept Chapter Revision Restore", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, revisionsUrl+"/1/restore", "POST", nil, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Chapter restored successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "First Draft", data["title"])
+		restored := data["paragraphs"].([]interface{})
+		assert.Len(t, restored, 3)
+		assert.Equal(t, "Nobody had lived there for years", restored[1].(map[string]interface{})["text"])
+		assert.Equal(t, float64(1), restored[1].(map[string]interface{})["comments_count"])
+
+		// Restoring is itself a revision
+		var count int64
+		db.Model(&models.ChapterRevision{}).Where("chapter_id = ?", chapter.ID).Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+}
+
 func deleteChapter(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	author := TestAuthor(db)
 	token := AccessToken(db, author)
@@ -707,9 +1193,8 @@ func voteBook(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	})
 
 	t.Run("Accept Book Vote Due To Sufficient Lanterns", func(t *testing.T) {
-		voter.Lanterns = 10
+		TestWalletBalance(db, &voter, 0, 10)
 		userManager.GenerateAuthTokens(db, voter, token, "test")
-		db.Save(&voter)
 
 		url := fmt.Sprintf("%s/book/%s/vote", baseUrl, book.Slug)
 		res := ProcessTestGetOrDelete(app, url, "GET", token)
@@ -764,9 +1249,8 @@ func convertCoinsToLanterns(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl s
 	})
 
 	t.Run("Accept Coins Conversion Due To Sufficient Coins", func(t *testing.T) {
-		user.Coins = 10
+		TestWalletBalance(db, &user, 10, 0)
 		userManager.GenerateAuthTokens(db, user, token, "test")
-		db.Save(&user)
 
 		url := fmt.Sprintf("%s/lanterns-generation/%s", baseUrl, "2")
 		res := ProcessTestGetOrDelete(app, url, "GET", token)
@@ -780,6 +1264,151 @@ func convertCoinsToLanterns(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl s
 	})
 }
 
+func buyChapter(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	book := BookData(db, author)
+	book.ContractStatus = choices.CTS_APPROVED
+	book.FullPurchaseMode = false
+	book.ChapterPrice = 5
+	db.Save(&book)
+	ChapterData(db, book)
+	chapter := models.Chapter{BookID: book.ID, Title: "Test Paid Chapter"}
+	db.FirstOrCreate(&chapter, chapter)
+	buyer := TestVerifiedUser(db)
+	token := AccessToken(db, buyer)
+
+	t.Run("Reject Chapter View Due To Not Bought", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Renew your subscription or buy this chapter to view it", body["message"])
+	})
+
+	t.Run("Reject Chapter Purchase Due To Insufficient Coins", func(t *testing.T) {
+		TestWalletBalance(db, &buyer, 0, 0)
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/buy", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You have insufficient coins", body["message"])
+	})
+
+	t.Run("Accept Chapter Purchase Due To Sufficient Coins", func(t *testing.T) {
+		TestWalletBalance(db, &buyer, 10, 0)
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/buy", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Chapter bought successfully", body["message"])
+
+		db.Take(&buyer, buyer.ID)
+		assert.Equal(t, 5, buyer.Coins)
+	})
+
+	t.Run("Reject Chapter Purchase Due To Already Bought", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s/buy", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You have bought this chapter already", body["message"])
+	})
+
+	t.Run("Accept Chapter View Due To Bought", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Chapter fetched successfully", body["message"])
+	})
+}
+
+func buyBook(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	book := BookData(db, author)
+	book.ContractStatus = choices.CTS_APPROVED
+	book.FullPurchaseMode = false
+	db.Save(&book)
+	ChapterData(db, book)
+	buyer := TestVerifiedUser(db)
+	token := AccessToken(db, buyer)
+
+	t.Run("Reject Book Purchase Due To Not In Full Purchase Mode", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/%s/buy", baseUrl, book.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "This book can't be bought in full", body["message"])
+	})
+
+	fullPrice := 20
+	book.FullPurchaseMode = true
+	book.FullPrice = &fullPrice
+	db.Save(&book)
+
+	t.Run("Accept Book Purchase Due To Sufficient Coins", func(t *testing.T) {
+		TestWalletBalance(db, &buyer, 25, 0)
+		url := fmt.Sprintf("%s/book/%s/buy", baseUrl, book.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Book bought successfully", body["message"])
+
+		db.Take(&buyer, buyer.ID)
+		assert.Equal(t, 5, buyer.Coins)
+	})
+
+	t.Run("Reject Book Purchase Due To Already Bought", func(t *testing.T) {
+		url := fmt.Sprintf("%s/book/%s/buy", baseUrl, book.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You have bought this book already", body["message"])
+	})
+
+	t.Run("Accept Chapter View Due To Bought Book", func(t *testing.T) {
+		// A chapter that came out after the book was bought
+		chapter := models.Chapter{BookID: book.ID, Title: "Test Chapter After Purchase"}
+		db.Create(&chapter)
+		url := fmt.Sprintf("%s/book/chapters/chapter/%s", baseUrl, chapter.Slug)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
 func setContract(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	author := TestAuthor(db)
 	book := BookData(db, author)
@@ -835,13 +1464,18 @@ func TestBooks(t *testing.T) {
 	getBookGenres(t, app, db, baseUrl)
 	getBooks(t, app, db, baseUrl)
 	getBooksByAuthor(t, app, db, baseUrl)
+	searchBooks(t, app, db, baseUrl)
+	getRecommendedBooks(t, app, db, baseUrl)
 	getBookChapters(t, app, db, baseUrl)
+	readingProgress(t, app, db, baseUrl)
 	getBook(t, app, db, baseUrl)
 	createBook(t, app, db, baseUrl)
 	updateBook(t, app, db, baseUrl)
 	deleteBook(t, app, db, baseUrl)
 	// addChapter(t, app, db, baseUrl)
 	// updateChapter(t, app, db, baseUrl)
+	scheduleChapters(t, app, db, baseUrl)
+	chapterRevisions(t, app, db, baseUrl)
 	deleteChapter(t, app, db, baseUrl)
 	reviewBook(t, app, db, baseUrl)
 	editBookReview(t, app, db, baseUrl)
@@ -856,6 +1490,8 @@ func TestBooks(t *testing.T) {
 	voteBook(t, app, db, baseUrl)
 	convertCoinsToLanterns(t, app, db, baseUrl)
 	setContract(t, app, db, baseUrl)
+	buyChapter(t, app, db, baseUrl)
+	buyBook(t, app, db, baseUrl)
 
 	// Drop Tables and Close Connectiom
 	database.DropTables(db)
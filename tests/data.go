@@ -1,12 +1,17 @@
 package tests
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/LitPad/backend/managers"
 	"github.com/LitPad/backend/models"
 	"github.com/LitPad/backend/models/choices"
 	"github.com/LitPad/backend/routes"
 	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v78"
 	"gorm.io/gorm"
 )
 
@@ -58,6 +63,17 @@ func TestAuthor(db *gorm.DB, another ...bool) models.User {
 	return user
 }
 
+// A user of its own, so that turning on two-factor authentication doesn't change the logins of other tests
+func TestTwoFactorUser(db *gorm.DB) models.User {
+	user := models.User{
+		Email:           "testtwofactoruser@example.com",
+		Password:        MASTER_PASSWORD,
+		IsEmailVerified: true,
+	}
+	db.FirstOrCreate(&user, models.User{Email: user.Email})
+	return user
+}
+
 func JwtData(db *gorm.DB, user models.User) models.AuthToken {
 	token := userManager.GenerateAuthTokens(db, user, routes.GenerateAccessToken(user), routes.GenerateRefreshToken())
 	return token
@@ -128,6 +144,16 @@ func NotificationData(db *gorm.DB, sender models.User, receiver models.User) mod
 	return notification
 }
 
+// A pending application of a reader to become an author
+func AuthorApplicationData(db *gorm.DB, user models.User) models.AuthorApplication {
+	application := models.AuthorApplication{
+		UserID: user.ID, PenName: "Test Pen Name", Bio: "I write test stories",
+		SampleWork: strings.Repeat("word ", 100), Status: choices.AAS_PENDING,
+	}
+	db.FirstOrCreate(&application, models.AuthorApplication{UserID: user.ID, Status: choices.AAS_PENDING})
+	return application
+}
+
 // ADMIN TEST DATA
 func TestAdmin(db *gorm.DB) models.User {
 	email := "testadmin@example.com"
@@ -140,9 +166,23 @@ func TestAdmin(db *gorm.DB) models.User {
 		IsEmailVerified: true,
 	}
 	db.FirstOrCreate(&user, models.User{Email: user.Email})
+	if user.RoleID == nil {
+		roleManager.Assign(db, &user, roleManager.GetByCodename(db, choices.ROLE_SUPERADMIN))
+	}
 	return user
 }
 
+// Returns a staff member with a role other than superadmin
+func TestStaff(db *gorm.DB, role choices.RoleChoice) models.User {
+	user := models.User{
+		Email:           fmt.Sprintf("test%s@example.com", role),
+		Password:        MASTER_PASSWORD,
+		IsEmailVerified: true,
+	}
+	db.FirstOrCreate(&user, models.User{Email: user.Email})
+	roleManager.Assign(db, &user, roleManager.GetByCodename(db, role))
+	return user
+}
 
 func TestSubscriber(db *gorm.DB) models.User {
 	email := "testsubscriber@example.com"
@@ -182,4 +222,174 @@ func TestCoin(db *gorm.DB) models.Coin {
 	coin := models.Coin{Amount: 100, Price: decimal.NewFromInt(100)}
 	db.FirstOrCreate(&coin, coin)
 	return coin
-}
\ No newline at end of file
+}
+
+// Coins that are also sold as a google play product
+func TestStoreCoin(db *gorm.DB) models.Coin {
+	productReference := "coins_50"
+	coin := models.Coin{Amount: 50, Price: decimal.NewFromInt(50), ProductReference: &productReference}
+	db.FirstOrCreate(&coin, models.Coin{ProductReference: &productReference})
+	return coin
+}
+
+func TestGift(db *gorm.DB) models.Gift {
+	gift := models.Gift{Name: "Test Gift", Price: 100, Lanterns: 10}
+	db.FirstOrCreate(&gift, models.Gift{Name: gift.Name})
+	return gift
+}
+
+func TestPromoCode(db *gorm.DB, code string) models.PromoCode {
+	promoCode := models.PromoCode{
+		Code: code, DiscountType: choices.PDT_PERCENTAGE, DiscountValue: decimal.NewFromInt(20),
+		BonusCoins: 10, MaxRedemptionsPerUser: 1, IsActive: true,
+	}
+	db.FirstOrCreate(&promoCode, models.PromoCode{Code: code})
+	return promoCode
+}
+
+func TestCoinTransaction(db *gorm.DB, user models.User, reference string) models.Transaction {
+	coin := TestCoin(db)
+	transaction := models.Transaction{
+		Reference: reference, UserID: user.ID, CoinID: &coin.ID, Quantity: 1,
+		PaymentType: choices.PTYPE_STRIPE, PaymentPurpose: choices.PP_COINS,
+	}
+	db.FirstOrCreate(&transaction, transaction)
+	return transaction
+}
+
+// A subscription period paid for once
+func TestSubscriptionTransaction(db *gorm.DB, user models.User, reference string) models.Transaction {
+	plan := TestSubscriptionPlan(db)
+	transaction := models.Transaction{
+		Reference: reference, UserID: user.ID, SubscriptionPlanID: &plan.ID,
+		PaymentType: choices.PTYPE_STRIPE, PaymentPurpose: choices.PP_SUB, PaymentStatus: choices.PSSUCCEEDED,
+	}
+	db.FirstOrCreate(&transaction, models.Transaction{Reference: reference})
+	return transaction
+}
+
+// Payments of all outcomes made on a given day: two successful ones (a coin pack and a subscription), a refunded one and a failed one
+func TestReportTransactions(db *gorm.DB, user models.User, day time.Time) {
+	coin := TestCoin(db)
+	plan := TestSubscriptionPlan(db)
+	transactions := []models.Transaction{
+		{Reference: "pi_test_report_paid", CoinID: &coin.ID, PaymentPurpose: choices.PP_COINS, PaymentStatus: choices.PSSUCCEEDED, Amount: decimal.NewFromInt(100)},
+		{Reference: "pi_test_report_refunded", CoinID: &coin.ID, PaymentPurpose: choices.PP_COINS, PaymentStatus: choices.PSREFUNDED, Amount: decimal.NewFromInt(100)},
+		{Reference: "pi_test_report_failed", CoinID: &coin.ID, PaymentPurpose: choices.PP_COINS, PaymentStatus: choices.PSFAILED, Amount: decimal.NewFromInt(100)},
+		{Reference: "pi_test_report_sub", SubscriptionPlanID: &plan.ID, PaymentPurpose: choices.PP_SUB, PaymentStatus: choices.PSSUCCEEDED, Amount: decimal.NewFromInt(10)},
+	}
+	for _, transaction := range transactions {
+		transaction.UserID = user.ID
+		transaction.Quantity = 1
+		transaction.PaymentType = choices.PTYPE_STRIPE
+		transaction.CreatedAt = day
+		db.FirstOrCreate(&transaction, models.Transaction{Reference: transaction.Reference})
+	}
+}
+
+func StripeEventData(eventID string, eventType string, reference string, amountReceived int64) []byte {
+	event := map[string]interface{}{
+		"id": eventID, "object": "event", "type": eventType, "api_version": stripe.APIVersion,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{"id": reference, "object": "payment_intent", "amount_received": amountReceived},
+		},
+	}
+	payload, _ := json.Marshal(event)
+	return payload
+}
+
+// A renewing monthly subscription. A nil reference stands for one paid for once.
+func TestSubscription(db *gorm.DB, user models.User, reference *string) models.Subscription {
+	plan := TestSubscriptionPlan(db)
+	start := time.Now()
+	subscription := models.Subscription{
+		UserID: user.ID, PlanID: plan.ID, Status: choices.SS_ACTIVE, Reference: reference,
+		CurrentPeriodStart: start, CurrentPeriodEnd: plan.PeriodEnd(start), ExpiresAt: plan.PeriodEnd(start),
+		CancelAtPeriodEnd: reference == nil,
+	}
+	db.FirstOrCreate(&subscription, models.Subscription{UserID: user.ID})
+	subscription.Plan = plan
+	return subscription
+}
+
+func StripeInvoiceEventData(eventID string, eventType string, invoiceID string, subscriptionReference string, periodStart time.Time, periodEnd time.Time) []byte {
+	event := map[string]interface{}{
+		"id": eventID, "object": "event", "type": eventType, "api_version": stripe.APIVersion,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{
+				"id": invoiceID, "object": "invoice", "subscription": subscriptionReference, "amount_paid": 100000, "amount_due": 100000,
+				"lines": map[string]interface{}{
+					"object": "list",
+					"data": []map[string]interface{}{
+						{"id": "il_test", "object": "line_item", "amount": 100000, "period": map[string]interface{}{"start": periodStart.Unix(), "end": periodEnd.Unix()}},
+					},
+				},
+			},
+		},
+	}
+	payload, _ := json.Marshal(event)
+	return payload
+}
+
+func StripeSubscriptionEventData(eventID string, eventType string, reference string, status string, cancelAtPeriodEnd bool) []byte {
+	event := map[string]interface{}{
+		"id": eventID, "object": "event", "type": eventType, "api_version": stripe.APIVersion,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{"id": reference, "object": "subscription", "status": status, "cancel_at_period_end": cancelAtPeriodEnd},
+		},
+	}
+	payload, _ := json.Marshal(event)
+	return payload
+}
+// A dispute on the charge of a payment intent, with the charge expanded
+func StripeDisputeEventData(eventID string, eventType string, reference string, status string) []byte {
+	event := map[string]interface{}{
+		"id": eventID, "object": "event", "type": eventType, "api_version": stripe.APIVersion,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{
+				"id": "dp_test", "object": "dispute", "amount": 10000, "status": status, "payment_intent": reference,
+				"charge": map[string]interface{}{"id": "ch_test", "object": "charge", "created": time.Now().Unix(), "payment_intent": reference},
+			},
+		},
+	}
+	payload, _ := json.Marshal(event)
+	return payload
+}
+
+func TestAuthorEarning(db *gorm.DB, author models.User, amount decimal.Decimal) models.AuthorEarning {
+	earning := models.AuthorEarning{
+		AuthorID: author.ID, Source: choices.ES_GIFT,
+		Amount: amount, Description: "Test earning",
+	}
+	db.Create(&earning)
+	return earning
+}
+
+func TestPayout(db *gorm.DB, author models.User) models.Payout {
+	payout := models.Payout{AuthorID: author.ID, Amount: decimal.NewFromInt(10), Status: choices.POS_REQUESTED}
+	db.FirstOrCreate(&payout, payout)
+	return payout
+}
+
+// Sets a user's coins and lanterns by moving the difference through the wallet ledger
+func TestWalletBalance(db *gorm.DB, user *models.User, coins int, lanterns int) {
+	db.Take(user, "id = ?", user.ID)
+	movements := []managers.WalletMovement{}
+	for currency, difference := range map[choices.CurrencyChoice]int{choices.CUR_COIN: coins - user.Coins, choices.CUR_LANTERN: lanterns - user.Lanterns} {
+		if difference > 0 {
+			movements = append(movements, managers.WalletMovement{To: user, Currency: currency, Amount: difference})
+		} else if difference < 0 {
+			movements = append(movements, managers.WalletMovement{From: user, Currency: currency, Amount: -difference})
+		}
+	}
+	managers.WalletManager{}.Transfer(db, choices.WR_OPENING_BALANCE, managers.WalletReference{}, movements...)
+}
+
+func TestPaymentEvent(db *gorm.DB, reference string) models.PaymentEvent {
+	event := models.PaymentEvent{
+		EventID: "evt_test_failed", Type: "payment_intent.succeeded", Status: choices.PES_FAILED,
+		Payload: StripeEventData("evt_test_failed", "payment_intent.succeeded", reference, 10000),
+	}
+	db.FirstOrCreate(&event, models.PaymentEvent{EventID: event.EventID})
+	return event
+}
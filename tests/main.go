@@ -2,6 +2,8 @@ package tests
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,15 +20,33 @@ import (
 
 	"github.com/LitPad/backend/config"
 	"github.com/LitPad/backend/database"
+	"github.com/LitPad/backend/icp"
+	"github.com/LitPad/backend/identity"
+	"github.com/LitPad/backend/initials"
 	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/ratelimit"
 	"github.com/LitPad/backend/routes"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v78/webhook"
 	"gorm.io/gorm"
 )
 
 var (
 	userManager = managers.UserManager{}
+	roleManager = managers.RoleManager{}
+	socialAccountManager = managers.SocialAccountManager{}
+	dataExportManager = managers.DataExportManager{}
+	authThrottleManager = managers.AuthThrottleManager{}
+	emailChangeManager = managers.EmailChangeManager{}
+	recommendationManager = managers.BookRecommendationManager{}
+	// Stands in for redis in each test (see Setup)
+	redisServer *miniredis.Miniredis
 )
 
 func CreateSingleTable(db *gorm.DB, model interface{}) {
@@ -58,6 +78,14 @@ func Setup(t *testing.T, app *fiber.App) *gorm.DB {
 	t.Setenv("ENVIRONMENT", "test")
 	t.Setenv("CONFIG_PATH", "../")
 
+	// Rate limits are counted in a fresh redis stand-in and relaxed so tests
+	// making lots of requests aren't cut off (see TestRateLimits)
+	redisServer = miniredis.RunT(t)
+	t.Setenv("REDIS_URL", redisServer.Addr())
+	for _, name := range ratelimit.Names() {
+		t.Cleanup(ratelimit.Use(name, ratelimit.Policy{Max: 10000, Window: time.Minute}))
+	}
+
 	// Set up the test database
 	db := SetupTestDatabase(t)
 
@@ -65,6 +93,7 @@ func Setup(t *testing.T, app *fiber.App) *gorm.DB {
 	t.Log("Dropping & Creating Tables...")
 	database.DropTables(db)
 	database.CreateTables(db)
+	initials.CreateRoles(db)
 	t.Log("Tables Created Successfully")
 	return db
 }
@@ -106,6 +135,34 @@ func ProcessJsonTestBody(t *testing.T, app *fiber.App, url string, method string
 	return res
 }
 
+// Posts a webhook event signed the way stripe signs them
+func ProcessStripeWebhookTestBody(app *fiber.App, url string, payload []byte) *http.Response {
+	signedPayload := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload: payload, Secret: config.GetConfig().StripeWebhookSecret,
+	})
+	req := httptest.NewRequest("POST", url, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signedPayload.Header)
+	res, err := app.Test(req)
+	if err != nil {
+		log.Println(err)
+	}
+	return res
+}
+
+// Posts a webhook event the way the fake payment providers expect them
+func ProcessFakeWebhookTestBody(app *fiber.App, url string, event payments.Event) *http.Response {
+	payload, _ := json.Marshal(event)
+	req := httptest.NewRequest("POST", url, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Fake-Signature", "valid")
+	res, err := app.Test(req)
+	if err != nil {
+		log.Println(err)
+	}
+	return res
+}
+
 func ProcessMultipartTestBody(t *testing.T, app *fiber.App, url string, method string, body interface{}, fileFieldNames []string, filePaths []string, access ...string) *http.Response {
 	// Multipart handling
 	requestBody := &bytes.Buffer{}
@@ -269,4 +326,110 @@ func ConvertDateTime(timeObj time.Time) string {
 	formatted = fmt.Sprintf("%s%s", formatted, roundedTime.Format("-07:00"))
 
 	return formatted
-}
\ No newline at end of file
+}
+// A stand-in for the ICP wallet server that keeps wallets and transfers in memory.
+// Transfers take TransferStatus, which confirms them straight away unless it is changed.
+type TestICPWalletServer struct {
+	*httptest.Server
+	Wallets        map[string]icp.Wallet   // by username
+	Transfers      map[string]icp.Transfer // by reference
+	TransferStatus string
+}
+
+func NewTestICPWalletServer() *TestICPWalletServer {
+	walletServer := &TestICPWalletServer{Wallets: map[string]icp.Wallet{}, Transfers: map[string]icp.Transfer{}, TransferStatus: "confirmed"}
+	walletServer.Server = httptest.NewServer(http.HandlerFunc(walletServer.handle))
+	return walletServer
+}
+
+func (s *TestICPWalletServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	body := map[string]string{}
+	if r.Method == http.MethodPost {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch {
+	case r.Method == http.MethodPost && len(path) == 1:
+		wallet := icp.Wallet{AccountID: "account_" + body["username"], PublicKey: "key_" + body["username"], Balance: decimal.NewFromInt(5)}
+		s.Wallets[body["username"]] = wallet
+		json.NewEncoder(w).Encode(wallet)
+	case r.Method == http.MethodPost && len(path) == 2 && path[1] == "transfer":
+		amount, _ := decimal.NewFromString(body["amount"])
+		transfer := icp.Transfer{
+			ID: "transfer_" + body["reference"], Reference: body["reference"], Amount: amount,
+			From: s.Wallets[body["username"]].AccountID, To: body["address"], Status: s.TransferStatus,
+		}
+		s.Transfers[transfer.Reference] = transfer
+		json.NewEncoder(w).Encode(transfer)
+	case r.Method == http.MethodGet && len(path) == 3 && path[1] == "transfer":
+		transfer, ok := s.Transfers[path[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(transfer)
+	case r.Method == http.MethodGet && len(path) >= 2:
+		wallet, ok := s.Wallets[path[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(wallet)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Makes the app use a wallet server stand-in with a fixed exchange rate of 10 USD per ICP
+func UseTestICPWalletServer() (*TestICPWalletServer, func()) {
+	walletServer := NewTestICPWalletServer()
+	restore := icp.Use(icp.Client{ApiUrl: walletServer.URL, Secret: "test_secret", UsdRate: decimal.NewFromInt(10)})
+	return walletServer, func() {
+		restore()
+		walletServer.Close()
+	}
+}
+
+const TEST_APPLE_CLIENT_ID = "com.litpad.test"
+
+// A stand-in for Apple's key endpoint serving a locally generated key, which signs test identity tokens
+type TestAppleServer struct {
+	*httptest.Server
+	Key *rsa.PrivateKey
+	Kid string
+}
+
+func (s *TestAppleServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{identity.RSAJwk(s.Kid, &s.Key.PublicKey)}})
+}
+
+// Returns an identity token like the ones Apple gives the ios app, signed with key
+func (s *TestAppleServer) Token(subject string, email string, audience string, key ...*rsa.PrivateKey) string {
+	signingKey := s.Key
+	if len(key) > 0 {
+		signingKey = key[0]
+	}
+	claims := jwt.MapClaims{
+		"iss": "https://appleid.apple.com", "aud": audience, "sub": subject,
+		"email": email, "email_verified": "true",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(10 * time.Minute).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.Kid
+	signed, _ := token.SignedString(signingKey)
+	return signed
+}
+
+// Makes the app verify apple tokens with the stand-in's key
+func UseTestAppleServer() (*TestAppleServer, func()) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	appleServer := &TestAppleServer{Key: key, Kid: "test_key"}
+	appleServer.Server = httptest.NewServer(http.HandlerFunc(appleServer.handle))
+	restore := identity.Use(choices.SP_APPLE, identity.AppleProvider{ClientIDs: []string{TEST_APPLE_CLIENT_ID}, JwksUrl: appleServer.URL})
+	return appleServer, func() {
+		restore()
+		appleServer.Close()
+	}
+}
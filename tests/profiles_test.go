@@ -1,9 +1,20 @@
 package tests
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/LitPad/backend/jobs"
+	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
 	"github.com/LitPad/backend/schemas"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -118,6 +129,101 @@ func updatePassword(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	})
 }
 
+func changeEmail(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := models.User{Email: "testemailchange@example.com", Password: MASTER_PASSWORD, IsEmailVerified: true}
+	db.Create(&user)
+	token := AccessToken(db, user)
+	password := MASTER_PASSWORD
+	newEmail := "testnewemail@example.com"
+
+	t.Run("Reject Email Change Due To Password Mismatch", func(t *testing.T) {
+		wrongPassword := "wrongpassword"
+		res := ProcessJsonTestBody(t, app, baseUrl+"/email", "POST", schemas.ChangeEmailSchema{Email: newEmail, Password: &wrongPassword}, token)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Password Mismatch", body["data"].(map[string]interface{})["password"])
+	})
+
+	t.Run("Reject Email Change Due To Taken Email", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, baseUrl+"/email", "POST", schemas.ChangeEmailSchema{Email: TestAuthor(db).Email, Password: &password}, token)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Email already taken!", body["data"].(map[string]interface{})["email"])
+	})
+
+	t.Run("Accept Email Change Request", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, baseUrl+"/email", "POST", schemas.ChangeEmailSchema{Email: newEmail, Password: &password}, token)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "An OTP has been sent to your new email", body["message"])
+	})
+
+	change := emailChangeManager.GetPending(db, user)
+	t.Run("Reject Email Change Confirmation Due To Incorrect OTP", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, baseUrl+"/email/confirm", "POST", schemas.ConfirmEmailChangeSchema{Otp: change.Otp + 1}, token)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Incorrect OTP", body["message"])
+	})
+
+	t.Run("Accept Email Change Confirmation", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, baseUrl+"/email/confirm", "POST", schemas.ConfirmEmailChangeSchema{Otp: change.Otp}, token)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Email changed successfully. Log in again with your new email", body["message"])
+		db.Take(&user, user.ID)
+		assert.Equal(t, newEmail, user.Email)
+
+		// The user is logged out everywhere
+		res = ProcessTestGetOrDelete(app, baseUrl+"/notifications", "GET", token)
+		assert.Equal(t, 401, res.StatusCode)
+	})
+
+	t.Run("Reject Email Change Revert Due To Invalid Token", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, "/api/v1/auth/revert-email", "POST", schemas.RevertEmailChangeSchema{Token: "invalid-token"})
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid or expired revert token", body["message"])
+	})
+
+	t.Run("Accept Email Change Revert", func(t *testing.T) {
+		// Someone else changes the email again
+		change := emailChangeManager.Create(db, user, "testhijacker@example.com")
+		revertToken, _ := emailChangeManager.Confirm(db, &change, &user)
+
+		res := ProcessJsonTestBody(t, app, "/api/v1/auth/revert-email", "POST", schemas.RevertEmailChangeSchema{Token: *revertToken})
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Email reverted successfully. Set a new password if someone else changed it", body["message"])
+		db.Take(&user, user.ID)
+		assert.Equal(t, newEmail, user.Email)
+
+		// The link can't be used again
+		res = ProcessJsonTestBody(t, app, "/api/v1/auth/revert-email", "POST", schemas.RevertEmailChangeSchema{Token: *revertToken})
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
 func followUser(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	user := TestVerifiedUser(db)
 	user2 := TestVerifiedUser(db, true)
@@ -220,6 +326,370 @@ func readNotification(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string)
 	})
 }
 
+func authorApplication(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestVerifiedUser(db)
+	token := AccessToken(db, user)
+	url := fmt.Sprintf("%s/author-application", baseUrl)
+	data := schemas.AuthorApplicationCreateSchema{
+		PenName: "J. Doe", Bio: "I write fantasy", SampleWork: strings.Repeat("word ", 100),
+		Links: []string{"https://example.com/my-blog"},
+	}
+
+	t.Run("Reject Author Application Fetch Due To No Application", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You haven't applied to become an author", body["message"])
+	})
+
+	t.Run("Reject Author Application Due To Short Sample Work", func(t *testing.T) {
+		invalidData := data
+		invalidData.SampleWork = "Too short"
+		res := ProcessJsonTestBody(t, app, url, "POST", invalidData, token)
+		// Assert Status code
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "100 words min", body["data"].(map[string]interface{})["sample_work"])
+	})
+
+	t.Run("Reject Author Application Due To Author Account", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", data, AccessToken(db, TestAuthor(db)))
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You're an author already", body["message"])
+	})
+
+	t.Run("Accept Author Application", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", data, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Application submitted successfully", body["message"])
+		assert.Equal(t, string(choices.AAS_PENDING), body["data"].(map[string]interface{})["status"])
+	})
+
+	t.Run("Reject Author Application Due To Pending Application", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", data, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Your last application is still being reviewed", body["message"])
+	})
+
+	t.Run("Accept Author Application Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Application fetched successfully", body["message"])
+		assert.Equal(t, data.PenName, body["data"].(map[string]interface{})["pen_name"])
+	})
+}
+
+func blockUser(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db)
+	book := BookData(db, author)
+	user := TestVerifiedUser(db)
+	user2 := TestVerifiedUser(db, true)
+	authorToken := AccessToken(db, author)
+	token := AccessToken(db, user)
+	url := fmt.Sprintf("%s/blocks/%s", baseUrl, user.Username)
+
+	t.Run("Reject User Block Due To Blocking Yourself", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/blocks/%s", baseUrl, author.Username), "POST", schemas.BlockUserSchema{Kind: choices.BK_BLOCK}, authorToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You can't block or mute yourself", body["message"])
+	})
+
+	t.Run("Reject User Block Due To Invalid Kind", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.BlockUserSchema{Kind: "IGNORE"}, authorToken)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "Invalid kind. Choices are BLOCK, MUTE", data["kind"])
+	})
+
+	t.Run("Accept User Block", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.BlockUserSchema{Kind: choices.BK_BLOCK}, authorToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "User blocked successfully", body["message"])
+
+		// The user no longer follows the author
+		followings := db.Model(&user).Where("id = ?", author.ID).Association("Followings").Count()
+		assert.Equal(t, int64(0), followings)
+	})
+
+	t.Run("Reject Interactions From Blocked User", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/profile/%s/follow", baseUrl, author.Username), "GET", token)
+		assert.Equal(t, 403, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "blocked", body["code"])
+		assert.Equal(t, "You have been blocked by this user", body["message"])
+
+		reviewData := schemas.ReviewBookSchema{Rating: choices.RC_5, Text: "A review from a blocked user"}
+		res = ProcessJsonTestBody(t, app, fmt.Sprintf("/api/v1/books/book/%s", book.Slug), "POST", reviewData, token)
+		assert.Equal(t, 403, res.StatusCode)
+
+		res = ProcessTestGetOrDelete(app, fmt.Sprintf("/api/v1/gifts/%s/%s/send", author.Username, TestGift(db).Slug), "GET", token)
+		assert.Equal(t, 403, res.StatusCode)
+
+		// Replying to someone else's comment in the author's chapter
+		chapter := ChapterData(db, book)
+		paragraph := models.Paragraph{ChapterID: chapter.ID, Index: 1, Text: "A paragraph of the author's"}
+		db.Create(&paragraph)
+		comment := models.Comment{UserID: user2.ID, ParagraphID: &paragraph.ID, Text: "A comment from another reader"}
+		db.Create(&comment)
+		replyData := schemas.ReplyReviewOrCommentSchema{ReplyEditSchema: schemas.ReplyEditSchema{Text: "A reply from a blocked user"}, Type: choices.RT_PARAGRAPH_COMMENT}
+		res = ProcessJsonTestBody(t, app, fmt.Sprintf("/api/v1/books/book/review-or-paragraph-comment/%s/replies", comment.ID), "POST", replyData, token)
+		assert.Equal(t, 403, res.StatusCode)
+
+		// Nothing the blocked user does notifies the author
+		notification := managers.NotificationManager{}.Create(db, &user, author, choices.NT_FOLLOWING, "Test", nil, nil, nil)
+		assert.Equal(t, uuid.Nil, notification.ID)
+	})
+
+	t.Run("Accept User Mute And Hide Their Replies", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/blocks/%s", baseUrl, user2.Username), "POST", schemas.BlockUserSchema{Kind: choices.BK_MUTE}, authorToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "User muted successfully", body["message"])
+
+		review := ReviewData(db, book, TestAuthor(db, true))
+		ReplyData(db, review, user2)
+		repliesUrl := fmt.Sprintf("/api/v1/books/book/review/%s/replies", review.ID)
+
+		// Hidden from the author only
+		res = ProcessTestGetOrDelete(app, repliesUrl, "GET", authorToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Empty(t, body["data"].(map[string]interface{})["replies"])
+
+		res = ProcessTestGetOrDelete(app, repliesUrl, "GET")
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Len(t, body["data"].(map[string]interface{})["replies"], 1)
+	})
+
+	t.Run("Accept Blocks Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/blocks?kind=BLOCK", baseUrl), "GET", authorToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Blocks fetched successfully", body["message"])
+		blocks := body["data"].(map[string]interface{})["blocks"].([]interface{})
+		assert.Len(t, blocks, 1)
+		assert.Equal(t, user.Username, blocks[0].(map[string]interface{})["user"].(map[string]interface{})["username"])
+	})
+
+	t.Run("Accept User Unblock", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "DELETE", authorToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "User unblocked successfully", body["message"])
+	})
+
+	t.Run("Reject User Unblock Due To No Block", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "DELETE", authorToken)
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You haven't blocked or muted this user", body["message"])
+	})
+}
+
+func exportUserData(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestVerifiedUser(db)
+	token := AccessToken(db, user)
+	TestTransaction(db, user)
+
+	t.Run("Accept Data Export Request", func(t *testing.T) {
+		url := fmt.Sprintf("%s/export", baseUrl)
+		res := ProcessJsonTestBody(t, app, url, "POST", nil, token)
+		// Assert Status code
+		assert.Equal(t, 202, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "We're putting your data together. You'll get an email with a download link", body["message"])
+	})
+
+	t.Run("Reject Data Export Request Due To Recent Export", func(t *testing.T) {
+		url := fmt.Sprintf("%s/export", baseUrl)
+		res := ProcessJsonTestBody(t, app, url, "POST", nil, token)
+		// Assert Status code
+		assert.Equal(t, 429, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You asked for a copy of your data recently. Try again tomorrow", body["message"])
+	})
+
+	t.Run("Reject Data Download Due To Invalid Token", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, "/api/v1/exports/invalid-token", "GET")
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Download link is invalid or expired", body["message"])
+	})
+
+	t.Run("Accept Data Download", func(t *testing.T) {
+		export := dataExportManager.GetRecent(db, user)
+		assert.Equal(t, choices.DES_READY, export.Status)
+		archive, err := dataExportManager.BuildArchive(db, user)
+		assert.Nil(t, err)
+		downloadToken := dataExportManager.MarkReady(db, export, archive)
+
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("/api/v1/exports/%s", downloadToken), "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "application/zip", res.Header.Get("Content-Type"))
+
+		// Check the archive's files
+		content, _ := io.ReadAll(res.Body)
+		reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		assert.Nil(t, err)
+		files := map[string]*zip.File{}
+		for _, file := range reader.File {
+			files[file.Name] = file
+		}
+		for _, name := range []string{"profile.json", "books.json", "comments.json", "votes.json", "bookmarks.json", "transactions.json", "notifications.json", "sent-gifts.json"} {
+			assert.Contains(t, files, name)
+		}
+		file, _ := files["transactions.json"].Open()
+		transactions := []map[string]interface{}{}
+		json.NewDecoder(file).Decode(&transactions)
+		assert.Len(t, transactions, 1)
+	})
+}
+
+func deleteAccount(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	author := TestAuthor(db, true)
+	token := AccessToken(db, author)
+	book := BookData(db, author)
+	// A comment of the user on someone else's book
+	review := ReviewData(db, BookData(db, TestAuthor(db)), TestVerifiedUser(db))
+	reply := ReplyData(db, review, author)
+	transaction := TestTransaction(db, author)
+	url := fmt.Sprintf("%s/delete", baseUrl)
+
+	t.Run("Reject Account Deletion Due To Password Mismatch", func(t *testing.T) {
+		password := "invalidpassword"
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.DeleteAccountSchema{Password: &password}, token)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Password Mismatch", body["data"].(map[string]interface{})["password"])
+	})
+
+	t.Run("Accept Account Deletion", func(t *testing.T) {
+		password := MASTER_PASSWORD
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.DeleteAccountSchema{Password: &password}, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Your account will be deleted at the end of the cool-off period", body["message"])
+		assert.NotNil(t, body["data"].(map[string]interface{})["deletion_scheduled_at"])
+	})
+
+	t.Run("Accept Account Deletion Cancel", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/cancel", url), "POST", nil, token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Account deletion canceled", body["message"])
+	})
+
+	t.Run("Reject Account Deletion Cancel Due To No Scheduled Deletion", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, fmt.Sprintf("%s/cancel", url), "POST", nil, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Your account isn't scheduled for deletion", body["message"])
+	})
+
+	t.Run("Delete Account After Cool-off Period", func(t *testing.T) {
+		provider, restore := payments.UseFake(choices.PTYPE_STRIPE)
+		defer restore()
+		// Started during the cool-off period
+		subReference := "sub_test_deleted_account"
+		TestSubscription(db, author, &subReference)
+
+		past := time.Now().Add(-time.Minute)
+		db.Model(&author).Update("deletion_scheduled_at", past)
+		jobs.AccountDeletionJob(db)
+		assert.Equal(t, []string{subReference}, *provider.Canceled)
+
+		db.Take(&author, author.ID)
+		assert.NotNil(t, author.AccountDeletedAt)
+		assert.False(t, author.IsActive)
+		assert.Nil(t, author.Name)
+		assert.NotEqual(t, "testanotherauthormail@example.com", author.Email)
+
+		// Books go, comments stay under the anonymised user and transactions are kept
+		var count int64
+		db.Model(&models.Book{}).Where("id = ?", book.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.Model(&models.Comment{}).Where("id = ?", reply.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+		db.Model(&models.Transaction{}).Where("id = ?", transaction.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
 func TestProfiles(t *testing.T) {
 	app := fiber.New()
 	db := Setup(t, app)
@@ -229,7 +699,12 @@ func TestProfiles(t *testing.T) {
 	getProfile(t, app, db, baseUrl)
 	updateProfile(t, app, db, baseUrl)
 	updatePassword(t, app, db, baseUrl)
+	changeEmail(t, app, db, baseUrl)
 	followUser(t, app, db, baseUrl)
 	getNotifications(t, app, db, baseUrl)
 	readNotification(t, app, db, baseUrl)
+	authorApplication(t, app, db, baseUrl)
+	blockUser(t, app, db, baseUrl)
+	exportUserData(t, app, db, baseUrl)
+	deleteAccount(t, app, db, baseUrl)
 }
\ No newline at end of file
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/LitPad/backend/database"
+	"github.com/LitPad/backend/ratelimit"
+	"github.com/LitPad/backend/schemas"
+	"github.com/LitPad/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func perUserRateLimits(t *testing.T, app *fiber.App, db *gorm.DB) {
+	defer ratelimit.Use(ratelimit.VOTES, ratelimit.Policy{Max: 2, Window: time.Minute})()
+	book := BookData(db, TestAuthor(db))
+	voter := TestVerifiedUser(db)
+	token := AccessToken(db, voter)
+	url := fmt.Sprintf("/api/v1/books/book/%s/vote", book.Slug)
+
+	t.Run("Accept Requests Within Limit With RateLimit Headers", func(t *testing.T) {
+		for remaining := 1; remaining >= 0; remaining-- {
+			res := ProcessTestGetOrDelete(app, url, "GET", token)
+			// Votes fail without lanterns but still count towards the limit
+			assert.Equal(t, 400, res.StatusCode)
+			assert.Equal(t, "2", res.Header.Get("RateLimit-Limit"))
+			assert.Equal(t, fmt.Sprint(remaining), res.Header.Get("RateLimit-Remaining"))
+			assert.Equal(t, "60", res.Header.Get("RateLimit-Reset"))
+			assert.Equal(t, "2;w=60", res.Header.Get("RateLimit-Policy"))
+		}
+	})
+
+	t.Run("Reject Request Over Limit", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 429, res.StatusCode)
+		assert.Equal(t, "0", res.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "60", res.Header.Get("Retry-After"))
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, utils.ERR_LIMITS_REACHED, body["code"])
+		assert.Equal(t, "Rate Limit Reached", body["message"])
+	})
+
+	t.Run("Accept Request From Another User On The Same Address", func(t *testing.T) {
+		otherToken := AccessToken(db, TestUser(db))
+		res := ProcessTestGetOrDelete(app, url, "GET", otherToken)
+		assert.Equal(t, 400, res.StatusCode)
+		assert.Equal(t, "1", res.Header.Get("RateLimit-Remaining"))
+	})
+
+	t.Run("Accept Request After Window Ends", func(t *testing.T) {
+		redisServer.FastForward(time.Minute)
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		assert.Equal(t, 400, res.StatusCode)
+		assert.Equal(t, "1", res.Header.Get("RateLimit-Remaining"))
+	})
+
+	t.Run("Accept Request When Store Is Down", func(t *testing.T) {
+		redisServer.Close()
+		res := ProcessTestGetOrDelete(app, url, "GET", token)
+		assert.Equal(t, 400, res.StatusCode)
+		assert.Empty(t, res.Header.Get("RateLimit-Limit"))
+	})
+}
+
+func perAddressRateLimits(t *testing.T, app *fiber.App) {
+	defer ratelimit.Use(ratelimit.AUTH, ratelimit.Policy{Max: 1, Window: time.Minute})()
+	url := "/api/v1/auth/login"
+	loginData := schemas.LoginSchema{Email: "ratelimited@example.com", Password: "password"}
+
+	t.Run("Reject Unauthenticated Requests Over Limit By Address", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", loginData)
+		assert.Equal(t, 401, res.StatusCode)
+
+		res = ProcessJsonTestBody(t, app, url, "POST", loginData)
+		assert.Equal(t, 429, res.StatusCode)
+		assert.Equal(t, "1;w=60", res.Header.Get("RateLimit-Policy"))
+		assert.NotEmpty(t, res.Header.Get("Retry-After"))
+	})
+}
+
+func TestRateLimits(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+
+	// Run Rate Limit Tests
+	perAddressRateLimits(t, app)
+	perUserRateLimits(t, app, db)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
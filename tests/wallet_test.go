@@ -1,9 +1,19 @@
 package tests
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/LitPad/backend/icp"
+	"github.com/LitPad/backend/managers"
+	"github.com/LitPad/backend/models"
+	"github.com/LitPad/backend/models/choices"
+	"github.com/LitPad/backend/payments"
+	"github.com/LitPad/backend/schemas"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -24,35 +34,573 @@ func getAvailbleCoins(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string,
 	})
 }
 
-// func buyCoins(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
-// 	coin := TestCoin(db)
-// 	coinData := schemas.BuyCoinSchema{CoinID: uuid.New(), Quantity: 1}
-
-// 	t.Run("Reject Coins Buy Due To Invalid Coin ID", func(t *testing.T) {
-// 		url := baseUrl + "/coins"
-// 		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
-// 		// Assert Status code
-// 		assert.Equal(t, 404, res.StatusCode)
-
-// 		// Parse and assert body
-// 		body := ParseResponseBody(t, res.Body).(map[string]interface{})
-// 		assert.Equal(t, "failure", body["status"])
-// 		assert.Equal(t, "No set of coins with that ID", body["message"])
-// 	})
-
-// 	t.Run("Accept Coins Buying Due To Valid Data", func(t *testing.T) {
-// 		coinData.CoinID = coin.ID
-// 		url := baseUrl + "/coins"
-// 		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
-// 		// Assert Status code
-// 		assert.Equal(t, 201, res.StatusCode)
-
-// 		// Parse and assert body
-// 		body := ParseResponseBody(t, res.Body).(map[string]interface{})
-// 		assert.Equal(t, "success", body["status"])
-// 		assert.Equal(t, "Payment Data Generated", body["message"])
-// 	})
-// }
+func buyCoins(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	_, restoreStripe := payments.UseFake(choices.PTYPE_STRIPE)
+	defer restoreStripe()
+	_, restorePaypal := payments.UseFake(choices.PTYPE_PAYPAL)
+	defer restorePaypal()
+	_, restoreGooglePlay := payments.UseFake(choices.PTYPE_GPAY)
+	defer restoreGooglePlay()
+
+	user := TestVerifiedUser(db)
+	TestWalletBalance(db, &user, 0, 0)
+	coin := TestCoin(db)
+	storeCoin := TestStoreCoin(db)
+	coinData := schemas.BuyCoinSchema{CoinID: uuid.New(), Quantity: 1}
+	url := baseUrl + "/coins"
+
+	t.Run("Reject Coins Buy Due To Invalid Coin ID", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "No set of coins with that ID", body["message"])
+	})
+
+	t.Run("Accept Coins Buying Due To Valid Data", func(t *testing.T) {
+		coinData.CoinID = coin.ID
+		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Payment Data Generated", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, string(choices.PSPENDING), data["payment_status"])
+	})
+
+	t.Run("Accept Coins Buying With Paypal After Capture Event", func(t *testing.T) {
+		coinData.PaymentType = choices.PTYPE_PAYPAL
+		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+		data := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})
+
+		// The order is settled when its capture completes
+		amount := coin.Price
+		event := payments.Event{
+			ID: "evt_test_paypal_capture", Type: "PAYMENT.CAPTURE.COMPLETED", Kind: payments.EK_PAYMENT,
+			Reference: data["reference"].(string), Status: choices.PSSUCCEEDED, Amount: &amount,
+		}
+		res = ProcessFakeWebhookTestBody(app, baseUrl+"/verify-payment/paypal", event)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+		db.Take(&user, user.ID)
+		assert.Equal(t, coin.Amount, user.Coins)
+	})
+
+	promoCode := TestPromoCode(db, "LAUNCH20")
+	t.Run("Reject Coins Buying Due To Invalid Promo Code", func(t *testing.T) {
+		invalidCode := "UNKNOWN"
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.BuyCoinSchema{CoinID: coin.ID, Quantity: 1, PromoCode: &invalidCode}, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid promo code", body["message"])
+	})
+
+	t.Run("Accept Coins Buying With Promo Code At A Discount", func(t *testing.T) {
+		code := "launch20"
+		promoData := schemas.BuyCoinSchema{CoinID: coin.ID, Quantity: 1, PaymentType: choices.PTYPE_PAYPAL, PromoCode: &code}
+		res := ProcessJsonTestBody(t, app, url, "POST", promoData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+		data := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})
+		assert.Equal(t, "80", data["amount"])
+		assert.Equal(t, float64(promoCode.BonusCoins), data["bonus_coins"])
+
+		// The discounted amount is enough to settle the payment
+		amount := promoCode.Discounted(coin.Price)
+		event := payments.Event{
+			ID: "evt_test_paypal_promo_capture", Type: "PAYMENT.CAPTURE.COMPLETED", Kind: payments.EK_PAYMENT,
+			Reference: data["reference"].(string), Status: choices.PSSUCCEEDED, Amount: &amount,
+		}
+		res = ProcessFakeWebhookTestBody(app, baseUrl+"/verify-payment/paypal", event)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+		db.Take(&user, user.ID)
+		assert.Equal(t, 2*coin.Amount+promoCode.BonusCoins, user.Coins)
+	})
+
+	t.Run("Reject Coins Buying Due To Used Promo Code", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", schemas.BuyCoinSchema{CoinID: coin.ID, Quantity: 1, PromoCode: &promoCode.Code}, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You have used this promo code already", body["message"])
+	})
+
+	t.Run("Reject Coins Buying Due To Promo Code Held By An Unpaid Payment", func(t *testing.T) {
+		heldCode := TestPromoCode(db, "SPRING10")
+		promoData := schemas.BuyCoinSchema{CoinID: coin.ID, Quantity: 1, PaymentType: choices.PTYPE_PAYPAL, PromoCode: &heldCode.Code}
+		res := ProcessJsonTestBody(t, app, url, "POST", promoData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+		firstReference := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})["reference"].(string)
+
+		// The unpaid payment holds the only redemption
+		res = ProcessJsonTestBody(t, app, url, "POST", promoData, token)
+		assert.Equal(t, 400, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "You have used this promo code already", body["message"])
+
+		// Once the hold is over the code can be used again, but only one of the payments gets the bonus
+		db.Model(&models.Transaction{}).Where("reference = ?", firstReference).UpdateColumn("created_at", time.Now().Add(-2*time.Hour))
+		res = ProcessJsonTestBody(t, app, url, "POST", promoData, token)
+		assert.Equal(t, 201, res.StatusCode)
+		secondReference := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})["reference"].(string)
+		db.Take(&user, user.ID)
+		coinsBefore := user.Coins
+		amount := heldCode.Discounted(coin.Price)
+		for i, reference := range []string{secondReference, firstReference} {
+			event := payments.Event{
+				ID: fmt.Sprintf("evt_test_paypal_held_capture_%d", i), Type: "PAYMENT.CAPTURE.COMPLETED", Kind: payments.EK_PAYMENT,
+				Reference: reference, Status: choices.PSSUCCEEDED, Amount: &amount,
+			}
+			res = ProcessFakeWebhookTestBody(app, baseUrl+"/verify-payment/paypal", event)
+			assert.Equal(t, 200, res.StatusCode)
+		}
+		db.Take(&user, user.ID)
+		assert.Equal(t, coinsBefore+2*coin.Amount+heldCode.BonusCoins, user.Coins)
+	})
+
+	purchaseToken := "test_purchase_token"
+	coinData = schemas.BuyCoinSchema{CoinID: storeCoin.ID, Quantity: 1, PaymentType: choices.PTYPE_GPAY, PaymentToken: &purchaseToken}
+	t.Run("Reject Coins Buying With Google Play Due To Several Sets", func(t *testing.T) {
+		severalSets := coinData
+		severalSets.Quantity = 50
+		res := ProcessJsonTestBody(t, app, url, "POST", severalSets, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Only one set of coins can be bought at a time with google play", body["message"])
+	})
+
+	t.Run("Accept Coins Buying With Google Play Immediately", func(t *testing.T) {
+		db.Take(&user, user.ID)
+		coinsBefore := user.Coins
+		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		data := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})
+		assert.Equal(t, string(choices.PSSUCCEEDED), data["payment_status"])
+		db.Take(&user, user.ID)
+		assert.Equal(t, coinsBefore+storeCoin.Amount, user.Coins)
+	})
+
+	t.Run("Reject Coins Buying Due To Used Google Play Purchase", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", coinData, token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "This payment has been used already", body["message"])
+	})
+}
+
+func requestPayout(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	author := TestAuthor(db)
+	authorToken := AccessToken(db, author)
+	payoutData := schemas.PayoutRequestSchema{Amount: decimal.NewFromInt(10)}
+	url := baseUrl + "/payouts"
+
+	t.Run("Reject Payout Request Due To Not Being An Author", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", payoutData, token)
+		// Assert Status code
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "For Authors only!", body["message"])
+	})
+
+	t.Run("Reject Payout Request Due To Insufficient Earnings", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", payoutData, authorToken)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Your earnings balance is insufficient for that payout", body["message"])
+	})
+
+	t.Run("Accept Payout Request Due To Sufficient Earnings", func(t *testing.T) {
+		TestAuthorEarning(db, author, decimal.NewFromInt(15))
+		res := ProcessJsonTestBody(t, app, url, "POST", payoutData, authorToken)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Payout requested successfully", body["message"])
+	})
+
+	t.Run("Accept Earnings Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/earnings", "GET", authorToken)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Earnings fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		balance, _ := decimal.NewFromString(data["balance"].(string))
+		assert.True(t, balance.Equal(decimal.NewFromInt(5)))
+	})
+}
+
+func getWalletLedger(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	user := TestVerifiedUser(db)
+	TestWalletBalance(db, &user, 10, 0)
+
+	t.Run("Reject Ledger Fetch Due To Invalid Currency", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/transactions/ledger?currency=invalid", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Invalid currency", body["message"])
+	})
+
+	t.Run("Accept Ledger Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/transactions/ledger?currency=COIN", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Ledger fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(10), data["coins"])
+		entries := data["entries"].([]interface{})
+		assert.NotEmpty(t, entries)
+		assert.Equal(t, float64(10), entries[0].(map[string]interface{})["balance_after"])
+	})
+}
+
+func verifyPayment(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestVerifiedUser(db)
+	TestWalletBalance(db, &user, 0, 0)
+	url := baseUrl + "/verify-payment"
+	reference := "pi_test_coins"
+	payload := StripeEventData("evt_test_coins", "payment_intent.succeeded", reference, 10000)
+
+	t.Run("Reject Payment Event Due To Invalid Signature", func(t *testing.T) {
+		res := ProcessJsonTestBody(t, app, url, "POST", map[string]string{"id": "evt_test_unsigned"})
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+	})
+
+	t.Run("Reject Payment Event Due To Unknown Transaction", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 500, res.StatusCode)
+
+		// The event is kept so it can be retried
+		event := models.PaymentEvent{}
+		db.Take(&event, "event_id = ?", "evt_test_coins")
+		assert.Equal(t, choices.PES_FAILED, event.Status)
+	})
+
+	TestCoinTransaction(db, user, reference)
+	t.Run("Accept Payment Event Due To Known Transaction", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, 100, user.Coins)
+	})
+
+	t.Run("Accept Payment Event Redelivery Without Crediting Twice", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, 100, user.Coins)
+		event := models.PaymentEvent{}
+		db.Take(&event, "event_id = ?", "evt_test_coins")
+		assert.Equal(t, choices.PES_PROCESSED, event.Status)
+		assert.Equal(t, 2, event.Attempts)
+	})
+}
+
+func chargebackWebhooks(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestVerifiedUser(db)
+	url := baseUrl + "/verify-payment"
+	reference := "pi_test_dispute"
+	transaction := TestCoinTransaction(db, user, reference)
+	db.Model(&transaction).Update("payment_status", choices.PSSUCCEEDED)
+	// 50 of the 100 coins bought have been spent and 30 converted to lanterns
+	TestWalletBalance(db, &user, 20, 30)
+	openedPayload := StripeDisputeEventData("evt_test_dispute_opened", "charge.dispute.created", reference, "needs_response")
+
+	t.Run("Accept Dispute Event And Take Back Coins", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, openedPayload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The spent coins are left as debt
+		db.Take(&user, user.ID)
+		assert.Equal(t, -50, user.Coins)
+		assert.Equal(t, 0, user.Lanterns)
+		db.Take(&transaction, transaction.ID)
+		assert.Equal(t, choices.PSDISPUTED, transaction.PaymentStatus)
+	})
+
+	t.Run("Accept Dispute Event Redelivery Without Taking Back Twice", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, openedPayload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, -50, user.Coins)
+	})
+
+	t.Run("Accept Won Dispute Event And Give Back Coins", func(t *testing.T) {
+		payload := StripeDisputeEventData("evt_test_dispute_won", "charge.dispute.closed", reference, "won")
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		db.Take(&user, user.ID)
+		assert.Equal(t, 50, user.Coins)
+		db.Take(&transaction, transaction.ID)
+		assert.Equal(t, choices.PSSUCCEEDED, transaction.PaymentStatus)
+	})
+}
+
+func subscriptionWebhooks(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	user := TestSubscriber(db)
+	url := baseUrl + "/verify-payment"
+	reference := "sub_test_renewing"
+	periodStart := time.Now().AddDate(0, 1, 0)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	paidPayload := StripeInvoiceEventData("evt_test_invoice_paid", "invoice.paid", "in_test_renewal", reference, periodStart, periodEnd)
+
+	t.Run("Reject Invoice Event Due To Unknown Subscription", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, paidPayload)
+		// Assert Status code
+		assert.Equal(t, 500, res.StatusCode)
+	})
+
+	TestSubscription(db, user, &reference)
+	t.Run("Accept Invoice Paid Event Due To Known Subscription", func(t *testing.T) {
+		res := ProcessStripeWebhookTestBody(app, url, paidPayload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The renewal starts a new period with the grace period after it
+		subscription := models.Subscription{}
+		db.Take(&subscription, "reference = ?", reference)
+		assert.Equal(t, choices.SS_ACTIVE, subscription.Status)
+		assert.Equal(t, periodEnd.Unix(), subscription.CurrentPeriodEnd.Unix())
+		assert.True(t, subscription.ExpiresAt.After(periodEnd))
+		transaction := models.Transaction{}
+		db.Take(&transaction, "reference = ?", "in_test_renewal")
+		assert.Equal(t, choices.PSSUCCEEDED, transaction.PaymentStatus)
+		db.Take(&user, user.ID)
+		assert.Equal(t, subscription.ExpiresAt.Unix(), user.SubscriptionExpiry.Unix())
+	})
+
+	t.Run("Accept Invoice Payment Failed Event", func(t *testing.T) {
+		payload := StripeInvoiceEventData("evt_test_invoice_failed", "invoice.payment_failed", "in_test_failed", reference, periodStart, periodEnd)
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Access is kept during the grace period
+		subscription := models.Subscription{}
+		db.Take(&subscription, "reference = ?", reference)
+		assert.Equal(t, choices.SS_PAST_DUE, subscription.Status)
+		assert.True(t, subscription.IsActive())
+	})
+
+	t.Run("Accept Subscription Deleted Event", func(t *testing.T) {
+		payload := StripeSubscriptionEventData("evt_test_subscription_deleted", "customer.subscription.deleted", reference, "canceled", false)
+		res := ProcessStripeWebhookTestBody(app, url, payload)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		subscription := models.Subscription{}
+		db.Take(&subscription, "reference = ?", reference)
+		assert.Equal(t, choices.SS_EXPIRED, subscription.Status)
+		assert.False(t, subscription.IsActive())
+		db.Take(&user, user.ID)
+		assert.True(t, user.SubscriptionExpired())
+	})
+}
+
+func cancelSubscription(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	url := baseUrl + "/subscription/cancel"
+
+	t.Run("Reject Subscription Cancel Due To No Subscription", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You don't have an active subscription", body["message"])
+	})
+
+	// Subscriptions paid for once don't renew
+	TestSubscription(db, TestVerifiedUser(db), nil)
+	t.Run("Reject Subscription Cancel Due To Non Renewing Subscription", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "Your subscription won't renew already", body["message"])
+	})
+
+	t.Run("Accept Subscription Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, baseUrl+"/subscription", "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Subscription fetched successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, false, data["renews"])
+	})
+}
+
+func icpWallets(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string, token string) {
+	walletServer, restore := UseTestICPWalletServer()
+	defer restore()
+
+	user := TestVerifiedUser(db)
+	writer := TestAuthor(db)
+	walletServer.Wallets[writer.Username] = icp.Wallet{AccountID: "account_writer", PublicKey: "key_writer"}
+	gift := TestGift(db)
+	url := baseUrl + "/icp"
+	sendUrl := fmt.Sprintf("%s/gifts/%s/%s/send", url, writer.Username, gift.Slug)
+
+	t.Run("Reject ICP Gift Due To No Wallet", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, sendUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "failure", body["status"])
+		assert.Equal(t, "You don't have an ICP wallet", body["message"])
+	})
+
+	t.Run("Accept ICP Wallet Create", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "success", body["status"])
+		assert.Equal(t, "Wallet created successfully", body["message"])
+		assert.Equal(t, "account_"+user.Username, body["data"].(map[string]interface{})["account_id"])
+	})
+
+	t.Run("Reject ICP Wallet Create Due To Existing Wallet", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, url, "POST", token)
+		// Assert Status code
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "You have an ICP wallet already", body["message"])
+	})
+
+	t.Run("Accept ICP Wallet Balance Fetch", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, fmt.Sprintf("%s/%s/balance", url, user.Username), "GET")
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "5", body["data"].(map[string]interface{})["balance"])
+	})
+
+	t.Run("Accept ICP Gift Due To Confirmed Transfer", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, sendUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "Gift sent successfully", body["message"])
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, string(choices.ITS_CONFIRMED), data["status"])
+		// 100 coins are worth 1 USD, which is 0.1 ICP at 10 USD per ICP
+		assert.Equal(t, "0.1", data["amount"])
+		assert.NotNil(t, data["sent_gift"])
+	})
+
+	walletServer.TransferStatus = "pending"
+	t.Run("Accept ICP Gift After Reconciling Pending Transfer", func(t *testing.T) {
+		res := ProcessTestGetOrDelete(app, sendUrl, "GET", token)
+		// Assert Status code
+		assert.Equal(t, 202, res.StatusCode)
+
+		// Parse and assert body
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, string(choices.ITS_PENDING), data["status"])
+		assert.Nil(t, data["sent_gift"])
+
+		// A transfer without a status isn't taken as confirmed
+		reference := data["id"].(string)
+		transfer := walletServer.Transfers[reference]
+		transfer.Status = ""
+		walletServer.Transfers[reference] = transfer
+		db.Model(&models.ICPTransfer{}).Where("id = ?", reference).Update("created_at", time.Now().Add(-2*time.Minute))
+		confirmed, failed := managers.ICPTransferManager{}.Reconcile(db, icp.Get())
+		assert.Equal(t, 0, confirmed)
+		assert.Equal(t, 0, failed)
+
+		// The gift is sent once the wallet server confirms the transfer
+		transfer.Status = "confirmed"
+		walletServer.Transfers[reference] = transfer
+		confirmed, _ = managers.ICPTransferManager{}.Reconcile(db, icp.Get())
+		assert.Equal(t, 1, confirmed)
+
+		icpTransfer := models.ICPTransfer{}
+		db.Take(&icpTransfer, "id = ?", reference)
+		assert.Equal(t, choices.ITS_CONFIRMED, icpTransfer.Status)
+		assert.NotNil(t, icpTransfer.SentGiftID)
+	})
+}
 
 func TestWallet(t *testing.T) {
 	app := fiber.New()
@@ -63,5 +611,12 @@ func TestWallet(t *testing.T) {
 
 	// Run Wallet Endpoint Tests
 	getAvailbleCoins(t, app, db, baseUrl, token)
-	// buyCoins(t, app, db, baseUrl, token)
+	requestPayout(t, app, db, baseUrl, token)
+	getWalletLedger(t, app, db, baseUrl, token)
+	verifyPayment(t, app, db, baseUrl)
+	subscriptionWebhooks(t, app, db, baseUrl)
+	chargebackWebhooks(t, app, db, baseUrl)
+	cancelSubscription(t, app, db, baseUrl, token)
+	buyCoins(t, app, db, baseUrl, token)
+	icpWallets(t, app, db, baseUrl, token)
 }
\ No newline at end of file
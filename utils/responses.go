@@ -41,7 +41,10 @@ var ERR_ALREADY_REVIEWED = "already_reviewed"
 var ERR_ALREADY_VOTED = "already_voted"
 var ERR_CONTRACT_ALREADY_APPROVED = "contract_already_approved"
 var ERR_INSUFFICIENT_LANTERNS = "insufficient_lanterns"
+var ERR_INSUFFICIENT_EARNINGS = "insufficient_earnings"
 var ERR_LIMITS_REACHED = "limits_reached"
+var ERR_ACCOUNT_LOCKED = "account_locked"
+var ERR_BLOCKED = "blocked"
 
 func RequestErr(code string, message string, opts ...map[string]string) ErrorResponse {
 	var data *map[string]string
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP (RFC 6238) with the settings authenticator apps default to
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // steps before and after the current one that are accepted, to allow for clock drift
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generates a base32 secret to be added to an authenticator app
+func GenerateTotpSecret() string {
+	secret := make([]byte, 20)
+	rand.Read(secret)
+	return totpEncoding.EncodeToString(secret)
+}
+
+// Returns the link authenticator apps read from a QR code to add an account
+func TotpUri(secret string, account string, issuer string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, account))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+func TotpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// Returns the code of a secret for a time step
+func TotpCode(secret string, step int64) string {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return ""
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(intPow(10, totpDigits)))
+}
+
+// Checks a code against the steps around a time and returns the step it matched.
+// Steps up to lastStep are rejected so that a code can't be used twice.
+func ValidateTotp(secret string, code string, at time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := TotpStep(at)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(TotpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Generates a one-time recovery code, e.g 4k7qz-m2x9d
+func GenerateRecoveryCode() string {
+	const charset = "abcdefghijkmnpqrstuvwxyz23456789"
+	randomBytes := make([]byte, 10)
+	rand.Read(randomBytes)
+	code := make([]byte, len(randomBytes))
+	for i, b := range randomBytes {
+		code[i] = charset[int(b)%len(charset)]
+	}
+	return fmt.Sprintf("%s-%s", code[:5], code[5:])
+}
+
+// Recovery codes are random enough to be stored as plain hashes, which are quick to look up
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
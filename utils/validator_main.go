@@ -47,6 +47,13 @@ func init() {
 	customValidator.RegisterValidation("contract_status_validator", ContractStatusChoiceValidator)
 	customValidator.RegisterValidation("reply_type_validator", ReplyTypeValidator)
 	customValidator.RegisterValidation("featured_content_location_choice_validator", FeaturedContentLocationChoiceValidator)
+	customValidator.RegisterValidation("payout_status_validator", PayoutStatusValidator)
+	customValidator.RegisterValidation("promo_discount_type_validator", PromoDiscountTypeValidator)
+	customValidator.RegisterValidation("two_factor_method_validator", TwoFactorMethodValidator)
+	customValidator.RegisterValidation("staff_role_validator", StaffRoleValidator)
+	customValidator.RegisterValidation("author_application_decision_validator", AuthorApplicationDecisionValidator)
+	customValidator.RegisterValidation("block_kind_validator", BlockKindValidator)
+	customValidator.RegisterValidation("chapter_status_validator", ChapterStatusValidator)
     customValidator.RegisterValidation("wordcount_min", WordCountMinValidator)
     customValidator.RegisterValidation("wordcount_max", WordCountMaxValidator)
 
@@ -79,12 +86,20 @@ func registerTranslations(param string) {
 	registerTranslation("contract_status_validator", "Invalid status type. Choices are PENDING, APPROVED, DECLINED, UPDATED", translator)
 	registerTranslation("reply_type_validator", "Invalid reply type. Choices are REVIEW, PARAGRAPH_COMMENT", translator)
 	registerTranslation("featured_content_location_choice_validator", "Invalid location choice. Choices are home, library, inbox", translator)
+	registerTranslation("payout_status_validator", "Invalid payout status. Choices are APPROVED, PAID, DECLINED", translator)
+	registerTranslation("promo_discount_type_validator", "Invalid discount type. Choices are PERCENTAGE, FIXED", translator)
+	registerTranslation("two_factor_method_validator", "Invalid method. Choices are totp, email, recovery", translator)
+	registerTranslation("staff_role_validator", "Invalid role. Choices are superadmin, content-moderator, finance, support, staff", translator)
+	registerTranslation("author_application_decision_validator", "Invalid status. Choices are APPROVED, DECLINED", translator)
+	registerTranslation("block_kind_validator", "Invalid kind. Choices are BLOCK, MUTE", translator)
+	registerTranslation("chapter_status_validator", "Invalid status. Choices are DRAFT, SCHEDULED, PUBLISHED, UNPUBLISHED", translator)
 
 	minErrMsg := fmt.Sprintf("%s characters min", param)
 	registerTranslation("min", minErrMsg, translator)
 	maxErrMsg := fmt.Sprintf("%s characters max", param)
 	registerTranslation("max", maxErrMsg, translator)
 	registerTranslation("email", "Invalid Email", translator)
+	registerTranslation("url", "Invalid URL", translator)
 	eqErrMsg := fmt.Sprintf("Must be %s", param)
 	registerTranslation("eq", eqErrMsg, translator)
 
@@ -59,11 +59,41 @@ func ReplyTypeValidator(fl validator.FieldLevel) bool {
 	return fl.Field().Interface().(choices.ReplyType).IsValid()
 }
 
+// Validates if a payout status value is the correct one
+func PayoutStatusValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.PayoutStatusChoice).IsValid()
+}
+
+func PromoDiscountTypeValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.PromoDiscountTypeChoice).IsValid()
+}
+
 // Validates if a device type value is the correct one
 func DeviceTypeValidator(fl validator.FieldLevel) bool {
 	return fl.Field().Interface().(choices.DeviceType).IsValid()
 }
 
+func TwoFactorMethodValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.TwoFactorMethodChoice).IsValid()
+}
+
+// Validates if a role can be given to staff
+func StaffRoleValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.RoleChoice).IsStaff()
+}
+
+func AuthorApplicationDecisionValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.AuthorApplicationStatusChoice).IsDecision()
+}
+
+func BlockKindValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.BlockKindChoice).IsValid()
+}
+
+func ChapterStatusValidator(fl validator.FieldLevel) bool {
+	return fl.Field().Interface().(choices.ChapterStatusChoice).IsValid()
+}
+
 func CountWords(text string) int {
     if strings.TrimSpace(text) == "" {
         return 0